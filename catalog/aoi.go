@@ -0,0 +1,107 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/service/geometry"
+	"github.com/airbusgeo/geocube/interface/storage/uri"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+)
+
+// Sidecar files of a Shapefile (the first ones are mandatory)
+var shapefileSidecars = []string{".shx", ".dbf", ".prj", ".cpg"}
+
+const shapefileMandatorySidecars = 2
+
+// LoadAOI loads the AOI of the area from its vector file, if defined and not already loaded
+// The features are reprojected to EPSG:4326 and dissolved in one geometry.
+func (c *Catalog) LoadAOI(ctx context.Context, area *entities.AreaToIngest) error {
+	if area.AOI != nil || area.AOIFile == nil {
+		return nil
+	}
+	filename, cleanup, err := c.fetchVectorFile(ctx, area.AOIFile.URI)
+	if err != nil {
+		return fmt.Errorf("LoadAOI.%w", err)
+	}
+	defer cleanup()
+	if area.AOI, err = geometry.ReadVectorFile(ctx, filename, area.AOIFile.Layer, area.AOIFile.Where); err != nil {
+		return fmt.Errorf("LoadAOI.%w", err)
+	}
+	return nil
+}
+
+// embedAOI replaces the aoi_file of the json of the area by the GeoJSON geometry of the AOI (already loaded),
+// so that the vector file is not loaded again when the json is parsed.
+func embedAOI(areaJSON []byte, aoi geom.Geometry) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(areaJSON, &fields); err != nil {
+		return nil, fmt.Errorf("embedAOI: %w", err)
+	}
+	if _, ok := fields["aoi_file"]; !ok {
+		return areaJSON, nil
+	}
+	geometry, err := json.Marshal(geojson.Geometry{Geometry: aoi})
+	if err != nil {
+		return nil, fmt.Errorf("embedAOI.Marshal: %w", err)
+	}
+	delete(fields, "aoi_file")
+	fields["type"], fields["geometry"] = json.RawMessage(`"Feature"`), geometry
+	delete(fields, "features")
+	return json.Marshal(fields)
+}
+
+// fetchVectorFile returns the local path of the vector file, downloading it (and its sidecar files) if it is in a storage (gs:// or s3://)
+// A local file is only allowed if c.LocalAOIFiles.
+// cleanup must be called to remove the downloaded files
+func (c *Catalog) fetchVectorFile(ctx context.Context, fileURI string) (string, func(), error) {
+	if !strings.Contains(fileURI, "://") || strings.HasPrefix(fileURI, "file://") {
+		if !c.LocalAOIFiles {
+			return "", nil, fmt.Errorf("fetchVectorFile: local files are not allowed (found %s): only gs:// and s3:// are supported", fileURI)
+		}
+		return strings.TrimPrefix(fileURI, "file://"), func() {}, nil
+	}
+	if !strings.HasPrefix(fileURI, "gs://") && !strings.HasPrefix(fileURI, "s3://") {
+		return "", nil, fmt.Errorf("fetchVectorFile: storage not supported (found %s): only gs:// and s3:// are supported", fileURI)
+	}
+	u, err := uri.ParseUri(fileURI)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetchVectorFile.ParseUri: %w", err)
+	}
+	storage, err := u.NewStorageStrategy(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetchVectorFile.NewStorageStrategy: %w", err)
+	}
+	dir, err := os.MkdirTemp(c.WorkingDir, "aoi")
+	if err != nil {
+		return "", nil, fmt.Errorf("fetchVectorFile.MkdirTemp: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	filename := filepath.Join(dir, path.Base(fileURI))
+	if err := storage.DownloadToFile(ctx, fileURI, filename); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("fetchVectorFile.DownloadToFile[%s]: %w", fileURI, err)
+	}
+	if ext := path.Ext(fileURI); strings.EqualFold(ext, ".shp") {
+		for i, sidecar := range shapefileSidecars {
+			if strings.ToUpper(ext) == ext {
+				sidecar = strings.ToUpper(sidecar)
+			}
+			sidecarURI := strings.TrimSuffix(fileURI, ext) + sidecar
+			err := storage.DownloadToFile(ctx, sidecarURI, strings.TrimSuffix(filename, ext)+sidecar)
+			if err != nil && i < shapefileMandatorySidecars {
+				cleanup()
+				return "", nil, fmt.Errorf("fetchVectorFile.DownloadToFile[%s]: %w", sidecarURI, err)
+			}
+		}
+	}
+	return filename, cleanup, nil
+}
@@ -0,0 +1,60 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/go-spatial/geom"
+)
+
+func TestFetchVectorFileLocal(t *testing.T) {
+	ctx := context.Background()
+	for _, uri := range []string{"/etc/passwd", "file:///etc/passwd", "aoi.gpkg", "http://host/aoi.gpkg", "ftp://host/aoi.gpkg"} {
+		if _, _, err := (&Catalog{}).fetchVectorFile(ctx, uri); err == nil {
+			t.Errorf("%s: expecting an error", uri)
+		}
+	}
+
+	c := &Catalog{LocalAOIFiles: true}
+	for uri, expected := range map[string]string{"/data/aoi.gpkg": "/data/aoi.gpkg", "file:///data/aoi.gpkg": "/data/aoi.gpkg", "aoi.gpkg": "aoi.gpkg"} {
+		filename, cleanup, err := c.fetchVectorFile(ctx, uri)
+		if err != nil {
+			t.Errorf("%s: %v", uri, err)
+			continue
+		}
+		cleanup()
+		if filename != expected {
+			t.Errorf("%s: expecting %s, got %s", uri, expected, filename)
+		}
+	}
+	if _, _, err := c.fetchVectorFile(ctx, "http://host/aoi.gpkg"); err == nil {
+		t.Errorf("expecting an error for an unsupported storage")
+	}
+}
+
+func TestEmbedAOI(t *testing.T) {
+	aoi := geom.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	areaJSON, err := embedAOI([]byte(`{"name":"aoi","aoi_file":{"uri":"gs://bucket/aoi.gpkg"},"scene_type":{"constellation":"sentinel2"}}`), aoi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var area entities.AreaToIngest
+	if err := json.Unmarshal(areaJSON, &area); err != nil {
+		t.Fatalf("%v: %s", err, areaJSON)
+	}
+	if area.AOIFile != nil || area.AOIID != "aoi" || area.SceneType.Constellation != "sentinel2" {
+		t.Errorf("wrong area: %s", areaJSON)
+	}
+	extent, err := geom.NewExtentFromGeometry(area.AOI)
+	if err != nil || extent.MinX() != 0 || extent.MaxX() != 1 || extent.MinY() != 0 || extent.MaxY() != 1 {
+		t.Errorf("wrong aoi: %v (%v)", area.AOI, err)
+	}
+
+	// Without aoi_file, the json is not modified
+	areaJSON = []byte(`{"name":"aoi","type":"Feature","geometry":{"type":"Point","coordinates":[0,0]}}`)
+	if embedded, err := embedAOI(areaJSON, aoi); err != nil || string(embedded) != string(areaJSON) {
+		t.Errorf("expecting %s, got %s (%v)", areaJSON, embedded, err)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/airbusgeo/geocube-ingester/catalog/entities"
@@ -13,14 +15,15 @@ import (
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/annotations"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/url"
 	"github.com/airbusgeo/geocube-ingester/service"
+	"github.com/airbusgeo/geocube-ingester/service/geometry"
 	"github.com/airbusgeo/geocube-ingester/service/log"
 	"github.com/paulsmith/gogeos/geos"
 	"golang.org/x/sync/errgroup"
 )
 
-func sceneBurstsInventory(ctx context.Context, scene *entities.Scene, pareaAOI *geos.PGeometry, annotationsProviders []catalog.AnnotationsProvider) error {
+func sceneBurstsInventory(ctx context.Context, scene *entities.Scene, pareaAOI *geos.PGeometry, annotationsProviders []catalog.AnnotationsProvider, cache *annotations.BurstsCache) error {
 	log.Logger(ctx).Debug("Load annotations of " + scene.SourceID)
-	bursts, err := burstsFromAnnotations(ctx, scene, annotationsProviders)
+	bursts, err := burstsFromAnnotations(ctx, scene, annotationsProviders, cache)
 	if err != nil {
 		return err
 	}
@@ -29,6 +32,9 @@ func sceneBurstsInventory(ctx context.Context, scene *entities.Scene, pareaAOI *
 	}
 	// Check that burst AOI intersects area AOI
 	for _, burst := range bursts {
+		if burst.GeometryWKT, err = geometry.NormalizeWKT(burst.GeometryWKT); err != nil {
+			return fmt.Errorf("burstsInventory.%w", err)
+		}
 		burstAOI, err := geos.FromWKT(burst.GeometryWKT)
 		if err != nil {
 			return fmt.Errorf("burstsInventory.FromWKT: %w", err)
@@ -47,14 +53,14 @@ func sceneBurstsInventory(ctx context.Context, scene *entities.Scene, pareaAOI *
 	return nil
 }
 
-func sceneBurstsInventoryWorker(ctx context.Context, jobs <-chan *entities.Scene, pareaAOI *geos.PGeometry, annotationsProviders []catalog.AnnotationsProvider) error {
+func sceneBurstsInventoryWorker(ctx context.Context, jobs <-chan *entities.Scene, pareaAOI *geos.PGeometry, annotationsProviders []catalog.AnnotationsProvider, cache *annotations.BurstsCache) error {
 	for scene := range jobs {
 		select {
 		case <-ctx.Done():
 		default:
 			retryCount := 3
 			for {
-				err := sceneBurstsInventory(ctx, scene, pareaAOI, annotationsProviders)
+				err := sceneBurstsInventory(ctx, scene, pareaAOI, annotationsProviders, cache)
 				if err == nil {
 					break
 				}
@@ -86,6 +92,7 @@ func (c *Catalog) BurstsInventory(ctx context.Context, area entities.AreaToInges
 	for _, annotationsUrl := range area.AnnotationsURLs {
 		annotationsProviders = append(annotationsProviders, url.AnnotationsProvider{URLPattern: annotationsUrl})
 	}
+	annotationsProviders = append(annotationsProviders, c.AnnotationsProviders...)
 	if len(annotationsProviders) == 0 {
 		return nil, 0, fmt.Errorf("burstsInventory: no annotationProvider defined")
 	}
@@ -95,7 +102,9 @@ func (c *Catalog) BurstsInventory(ctx context.Context, area entities.AreaToInges
 
 	// Start 10 workers
 	for i := 0; i < 10 && i < len(scenes); i++ {
-		wg.Go(func() error { return sceneBurstsInventoryWorker(ctx, jobChan, pareaAOI, annotationsProviders) })
+		wg.Go(func() error {
+			return sceneBurstsInventoryWorker(ctx, jobChan, pareaAOI, annotationsProviders, c.BurstsCache)
+		})
 	}
 
 	// Push jobs
@@ -125,52 +134,255 @@ func (c *Catalog) BurstsInventory(ctx context.Context, area entities.AreaToInges
 	return scenes[0:j], n, nil
 }
 
-// BurstsSort defines for each burst the previous and reference bursts
-// Returns the number of tracks and swaths
-func (c *Catalog) BurstsSort(ctx context.Context, scenes []*entities.Scene) int {
-	// Sort bursts by track and swath
-	burstsPerTrackSwath := map[string][]*entities.Tile{}
+// PreviousRole returns the role of the k-th previous tile (k>=1)
+func PreviousRole(k int) string {
+	if k <= 1 {
+		return common.RolePrevious
+	}
+	return fmt.Sprintf("%s_%d", common.RolePrevious, k)
+}
+
+const (
+	// Tolerance on the temporal baseline between two bursts
+	baselineTolerance = 24 * time.Hour
+	// Maximum temporal baseline with the previous acquisition, if the strategy is to skip the missing bursts (revisit of one Sentinel-1 satellite)
+	maxBaselineDays = 12
+)
+
+// BurstsSort defines for each burst the previous and reference bursts according to the strategy
+// The bursts are stacked by burst ID. For backward compatibility, the bursts ingested with a legacy ID
+// are stacked with the bursts having the same track, swath and the closest AnxTime.
+// If previousTiles > 1, the k-th previous bursts (k in [2, previousTiles]) are added as inputs with the role PreviousRole(k).
+// As only the root and leaf bursts of the previous ingestions are known, the k-th previous burst is defined
+// only if there is no ingested burst between it and the current burst.
+// If a stack of bursts has already been ingested, its reference is kept.
+// Returns the number of tracks and swaths and the network of pairs of each stack
+func (c *Catalog) BurstsSort(ctx context.Context, scenes []*entities.Scene, strategy entities.BurstsStrategy, previousTiles int) (int, []entities.PairNetwork) {
+	// Sort bursts by burst ID
+	stacks := map[string][]*entities.Tile{}
+	var legacyBursts []*entities.Tile
 	for _, scene := range scenes {
 		for _, burst := range scene.Tiles {
-			track := burst.SourceID[0:4]
-			trackSwath := track + burst.Data.SwathID
-			burstsPerTrackSwath[trackSwath] = append(burstsPerTrackSwath[trackSwath], burst)
+			if annotations.IsBurstID(burst.SourceID) {
+				stacks[burst.SourceID] = append(stacks[burst.SourceID], burst)
+			} else {
+				legacyBursts = append(legacyBursts, burst)
+			}
 		}
 	}
+	// Bursts ingested with a legacy ID are added to the stack of the bursts with the same track, swath and the closest AnxTime (at most 5 apart)
+	for _, burst := range legacyBursts {
+		trackSwath, anxTime, err := parseLegacyBurstID(burst.SourceID)
+		if err != nil {
+			log.Logger(ctx).Sugar().Warnf("ignore burst %s: %v", burst.SourceID, err)
+			continue
+		}
+		stackID, bestDiff := burst.SourceID, 5
+		for id, stack := range stacks {
+			ts, a, err := parseLegacyBurstID(tileLegacyID(stack[0]))
+			if err != nil || ts != trackSwath {
+				continue
+			}
+			// The stack ID breaks the ties, as the map is iterated in random order
+			if diff := max(a-anxTime, anxTime-a); diff < bestDiff || (diff == bestDiff && diff < 5 && id < stackID) {
+				stackID, bestDiff = id, diff
+			}
+		}
+		stacks[stackID] = append(stacks[stackID], burst)
+	}
 
 	// Find previous and reference for each bursts
-	for track, bursts := range burstsPerTrackSwath {
-		// Sort by AnxTime
-		sort.Slice(bursts, func(i, j int) bool { return bursts[i].AnxTime < bursts[j].AnxTime })
-		// Create pools of burst with similar AnxTime, sort by date and find ref and prev burst
-		for i, il := 0, 0; i < len(bursts); il = i {
-			for ; i < len(bursts) && bursts[i].AnxTime < bursts[il].AnxTime+5; i++ {
+	var networks []entities.PairNetwork
+	trackSwaths := service.StringSet{}
+	for id, sbursts := range stacks {
+		// Sort by date and find ref and prev burst
+		sort.Slice(sbursts, func(j, k int) bool { return sbursts[j].Date.Before(sbursts[k].Date) })
+		network := burstsStackSort(ctx, sbursts, strategy, previousTiles)
+		network.StackID, network.TrackSwath = id, stackTrackSwath(id)
+		log.Logger(ctx).Sugar().Debugf("Burst %s => ref date: %s", id, network.Reference.Date)
+		networks = append(networks, network)
+		trackSwaths.Push(network.TrackSwath)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		if networks[i].TrackSwath != networks[j].TrackSwath {
+			return networks[i].TrackSwath < networks[j].TrackSwath
+		}
+		return networks[i].StackID < networks[j].StackID
+	})
+
+	return len(trackSwaths), networks
+}
+
+// stackTrackSwath returns the track and the swath of a stack of bursts, given its burst ID (or legacy ID)
+func stackTrackSwath(id string) string {
+	if annotations.IsBurstID(id) {
+		return id[:strings.Index(id, "_")] + id[strings.LastIndex(id, "_"):]
+	}
+	if trackSwath, _, err := parseLegacyBurstID(id); err == nil {
+		return trackSwath
+	}
+	return id
+}
+
+// burstsStackSort defines the reference and the previous bursts of a stack of bursts sorted by date
+func burstsStackSort(ctx context.Context, sbursts []*entities.Tile, strategy entities.BurstsStrategy, previousTiles int) entities.PairNetwork {
+	return stackSort(ctx, sbursts, strategy, previousTiles, 6*24*time.Hour)
+}
+
+// stackSort defines the reference and the previous tiles of a stack of tiles sorted by date
+// If the previous acquisition is expected at revisit (>0) and the previous tile is older, a warning is logged
+func stackSort(ctx context.Context, sbursts []*entities.Tile, strategy entities.BurstsStrategy, previousTiles int, revisit time.Duration) entities.PairNetwork {
+	r := referenceIndex(sbursts, strategy)
+	network := entities.PairNetwork{AnxTime: sbursts[r].AnxTime, Reference: sbursts[r].TileLite}
+
+	previous := make([]int, len(sbursts)) // Index of the previous burst (-1 if none or unknown)
+	used := make([]bool, len(sbursts))    // The burst is already the previous of another burst
+	for j, b := range sbursts {
+		previous[j] = -1
+		if b.Ingested {
+			used[j] = !b.Leaf
+			continue
+		}
+		if j == r {
+			continue
+		}
+		b.Reference = &sbursts[r].TileLite
+
+		if previous[j] = previousIndex(sbursts, j, used, strategy); previous[j] < 0 {
+			network.Unpaired = append(network.Unpaired, b.TileLite)
+			continue
+		}
+		used[previous[j]] = true
+		b.Previous = &sbursts[previous[j]].TileLite
+		baseline := b.Date.Sub(b.Previous.Date)
+		network.Pairs = append(network.Pairs, entities.Pair{Tile: b.TileLite, Previous: *b.Previous, BaselineDays: int((baseline + baselineTolerance/2) / (24 * time.Hour))})
+		// If the current date is more than the revisit after the previous date, log a warning
+		if revisit > 0 && strategy.PairingDays == 0 && baseline > revisit+baselineTolerance {
+			log.Logger(ctx).Sugar().Warnf("%s:%s No tile was found %v before. Found %s (%v before)",
+				b.SceneID, b.SourceID, revisit, b.Previous.SceneID, baseline)
+		}
+
+		// Other previous bursts
+		for k, p := 2, previous[j]; k <= previousTiles && previous[p] >= 0; k++ {
+			p = previous[p]
+			b.Inputs = append(b.Inputs, entities.TileInput{Role: PreviousRole(k), Tile: sbursts[p].TileLite})
+		}
+	}
+	return network
+}
+
+// referenceIndex returns the index of the reference burst of a stack of bursts sorted by date
+// The windows of the strategy are ignored: a stack has only one reference, whatever the window of its bursts
+func referenceIndex(sbursts []*entities.Tile, strategy entities.BurstsStrategy) int {
+	// Keep the reference of the bursts already ingested
+	for j, b := range sbursts {
+		if b.Ingested && b.Root {
+			return j
+		}
+	}
+	switch strategy.Reference {
+	case entities.ReferenceMiddle:
+		return len(sbursts) / 2
+	case entities.ReferenceDate:
+		r := 0
+		for j, b := range sbursts {
+			if b.Date.Sub(strategy.ReferenceDate).Abs() < sbursts[r].Date.Sub(strategy.ReferenceDate).Abs() {
+				r = j
 			}
+		}
+		return r
+	}
+	return 0
+}
 
-			sbursts := bursts[il:i]
-			sort.Slice(sbursts, func(j, k int) bool { return sbursts[j].Date.Before(sbursts[k].Date) })
-			log.Logger(ctx).Sugar().Debugf("Track %s AnxTime: %d => ref date: %s", track, sbursts[0].AnxTime, sbursts[0].Date)
-			for j := 1; j < len(sbursts); j++ {
-				b := sbursts[j]
-				if !b.Ingested {
-					b.Reference = &sbursts[0].TileLite
-					b.Previous = &sbursts[j-1].TileLite
-					// If the current date is more than 6 days after the previous date, log a warning
-					if b.Date.After(b.Previous.Date.Add(time.Hour * 24 * 7)) {
-						log.Logger(ctx).Sugar().Warnf("%s:%s No burst was found 6 days before. Found %s (%v before)",
-							b.SceneID, b.SourceID, b.Previous.SceneID, b.Date.Sub(b.Previous.Date))
-					}
+// previousIndex returns the index of the previous burst of the j-th burst of a stack of bursts sorted by date (-1 if none)
+// A burst cannot be the previous of several bursts.
+// If the strategy defines windows of time, the previous burst must be in the same window.
+func previousIndex(sbursts []*entities.Tile, j int, used []bool, strategy entities.BurstsStrategy) int {
+	date := sbursts[j].Date
+	first := 0 // Index of the first burst of the window
+	if len(strategy.Windows) > 0 {
+		w := strategy.Windows.Index(date)
+		if w < 0 {
+			return -1
+		}
+		first = sort.Search(j, func(k int) bool { return !sbursts[k].Date.Before(strategy.Windows[w].Start) })
+	}
+	if strategy.PairingDays == 0 {
+		// Previous acquisition
+		for k := j - 1; k >= first; k-- {
+			if !used[k] {
+				if strategy.SkipMissing && date.Sub(sbursts[k].Date) > maxBaselineDays*24*time.Hour+baselineTolerance {
+					return -1
 				}
+				return k
 			}
 		}
+		return -1
+	}
+
+	// Acquisition at the expected baseline
+	target := date.Add(-time.Duration(strategy.PairingDays) * 24 * time.Hour)
+	for k := j - 1; k >= first; k-- {
+		if !used[k] && sbursts[k].Date.Sub(target).Abs() <= baselineTolerance {
+			return k
+		}
+	}
+	if strategy.SkipMissing {
+		return -1
+	}
+	// Closest earlier acquisition
+	for k := j - 1; k >= first; k-- {
+		if !used[k] && sbursts[k].Date.Before(target) {
+			return k
+		}
+	}
+	return -1
+}
+
+// burstsFromAnnotations loads bursts features (anxtime, swath and geometry) from annotation files (or from the cache, if not nil)
+func burstsFromAnnotations(ctx context.Context, scene *entities.Scene, annotationsProviders []catalog.AnnotationsProvider, cache *annotations.BurstsCache) ([]*entities.Tile, error) {
+	bursts, err := sceneBursts(ctx, scene, annotationsProviders, cache)
+	if err != nil {
+		return nil, fmt.Errorf("burstsFromAnnotations.%w", err)
+	}
 
+	var burstsInventory []*entities.Tile
+	for _, burst := range bursts {
+		// Add info from scene
+		burstsInventory = append(burstsInventory, &entities.Tile{
+			TileLite: entities.TileLite{
+				Date:     scene.Data.Date,
+				SceneID:  scene.SourceID,
+				SourceID: annotations.FormatBurstID(burst.Track, burst.BurstID, burst.SwathID),
+			},
+			Data: common.TileAttrs{
+				SwathID:  burst.SwathID,
+				TileNr:   burst.TileNr,
+				LegacyID: legacyBurstID(scene.Tags[common.TagOrbitDirection], scene.Tags[common.TagRelativeOrbit], burst.SwathID, burst.AnxTime),
+			},
+			AnxTime:     burst.AnxTime,
+			GeometryWKT: burst.GeometryWKT,
+		})
 	}
 
-	return len(burstsPerTrackSwath)
+	return burstsInventory, nil
 }
 
-// burstsFromAnnotations loads bursts features (anxtime, swath and geometry) from annotation files
-func burstsFromAnnotations(ctx context.Context, scene *entities.Scene, annotationsProviders []catalog.AnnotationsProvider) ([]*entities.Tile, error) {
+// sceneBursts returns the bursts of the scene from the cache or parses them from the annotation files (and stores them in the cache)
+// The bursts are sorted by swath and AnxTime
+func sceneBursts(ctx context.Context, scene *entities.Scene, annotationsProviders []catalog.AnnotationsProvider, cache *annotations.BurstsCache) ([]*annotations.Burst, error) {
+	if cache != nil {
+		bursts, ok, err := cache.Get(ctx, scene.SourceID)
+		if err != nil {
+			log.Logger(ctx).Sugar().Warnf("sceneBursts.%v", err)
+		} else if ok {
+			log.Logger(ctx).Sugar().Debugf("Bursts of %s loaded from cache", scene.SourceID)
+			progress(ctx).addCachedScenes(1)
+			return bursts, nil
+		}
+	}
+
 	var err, e error
 	var annotationsFiles map[string][]byte
 	for _, annotationsProvider := range annotationsProviders {
@@ -180,37 +392,66 @@ func burstsFromAnnotations(ctx context.Context, scene *entities.Scene, annotatio
 		}
 	}
 	if err != nil {
-		return nil, fmt.Errorf("burstsFromAnnotations.%w", err)
+		return nil, fmt.Errorf("sceneBursts.%w", err)
 	}
+	progress(ctx).addAnnotations(len(annotationsFiles))
 
-	var burstsInventory []*entities.Tile
+	relativeOrbit, err := strconv.Atoi(scene.Tags[common.TagRelativeOrbit])
+	if err != nil {
+		return nil, fmt.Errorf("sceneBursts: relative orbit of %s: %w", scene.SourceID, err)
+	}
+
+	var allBursts []*annotations.Burst
 	anxTimes := map[int]struct{}{}
 	for url, file := range annotationsFiles {
-		bursts, err := annotations.BurstsFromAnnotation(file, url)
+		bursts, err := annotations.BurstsFromAnnotation(file, url, relativeOrbit)
 		if err != nil {
-			return nil, fmt.Errorf("burstsFromAnnotations.%w", err)
+			return nil, fmt.Errorf("sceneBursts.%w", err)
 		}
 		for anxTime, burst := range bursts {
 			if _, ok := anxTimes[anxTime]; !ok {
 				anxTimes[anxTime] = struct{}{}
-
-				// Add info from scene
-				burstsInventory = append(burstsInventory, &entities.Tile{
-					TileLite: entities.TileLite{
-						Date:     scene.Data.Date,
-						SceneID:  scene.SourceID,
-						SourceID: fmt.Sprintf("%s%s_%s_%d", scene.Tags[common.TagOrbitDirection][0:1], scene.Tags[common.TagRelativeOrbit], burst.SwathID, burst.AnxTime),
-					},
-					Data: common.TileAttrs{
-						SwathID: burst.SwathID,
-						TileNr:  burst.TileNr,
-					},
-					AnxTime:     burst.AnxTime,
-					GeometryWKT: burst.GeometryWKT,
-				})
+				allBursts = append(allBursts, burst)
 			}
 		}
 	}
+	sort.Slice(allBursts, func(i, j int) bool {
+		if allBursts[i].SwathID != allBursts[j].SwathID {
+			return allBursts[i].SwathID < allBursts[j].SwathID
+		}
+		return allBursts[i].AnxTime < allBursts[j].AnxTime
+	})
 
-	return burstsInventory, nil
+	if cache != nil && len(allBursts) > 0 {
+		if err := cache.Put(ctx, scene.SourceID, allBursts); err != nil {
+			log.Logger(ctx).Sugar().Warnf("sceneBursts.%v", err)
+		}
+	}
+	return allBursts, nil
+}
+
+// legacyBurstID returns the former identifier of a burst: <orbitdir><relorbit>_<swath>_<anxtime>
+func legacyBurstID(orbitDirection, relativeOrbit, swath string, anxTime int) string {
+	return fmt.Sprintf("%s%s_%s_%d", orbitDirection[0:1], relativeOrbit, swath, anxTime)
+}
+
+// tileLegacyID returns the former identifier of a burst
+func tileLegacyID(t *entities.Tile) string {
+	if t.Data.LegacyID != "" || annotations.IsBurstID(t.SourceID) {
+		return t.Data.LegacyID
+	}
+	return t.SourceID
+}
+
+// parseLegacyBurstID returns the track and swath (<orbitdir><relorbit>_<swath>) and the AnxTime of a former identifier of a burst
+func parseLegacyBurstID(id string) (string, int, error) {
+	i := strings.LastIndex(id, "_")
+	if i < 0 {
+		return "", 0, fmt.Errorf("parseLegacyBurstID: wrong format: %s", id)
+	}
+	anxTime, err := strconv.Atoi(id[i+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("parseLegacyBurstID[%s]: %w", id, err)
+	}
+	return id[:i], anxTime, nil
 }
@@ -0,0 +1,174 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/annotations"
+)
+
+// newStack creates a stack of bursts acquired at the given days after 2020-01-01
+func newStack(days ...int) []*entities.Tile {
+	var bursts []*entities.Tile
+	for _, day := range days {
+		date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day)
+		bursts = append(bursts, &entities.Tile{TileLite: entities.TileLite{SourceID: date.Format("20060102"), SceneID: date.Format("20060102"), Date: date}})
+	}
+	return bursts
+}
+
+// checkStack checks the reference and the previous bursts (nil: no previous) of the stack
+func checkStack(t *testing.T, bursts []*entities.Tile, reference string, previous []*string) {
+	for i, b := range bursts {
+		if b.SourceID == reference {
+			if b.Reference != nil || b.Previous != nil {
+				t.Errorf("%s: reference must not have reference nor previous", b.SourceID)
+			}
+			continue
+		}
+		if b.Reference == nil || b.Reference.SourceID != reference {
+			t.Errorf("%s: expecting reference %s, got %v", b.SourceID, reference, b.Reference)
+		}
+		if previous[i] == nil && b.Previous != nil {
+			t.Errorf("%s: expecting no previous, got %s", b.SourceID, b.Previous.SourceID)
+		} else if previous[i] != nil && (b.Previous == nil || b.Previous.SourceID != *previous[i]) {
+			t.Errorf("%s: expecting previous %s, got %v", b.SourceID, *previous[i], b.Previous)
+		}
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestBurstsStackSortDefault(t *testing.T) {
+	bursts := newStack(0, 6, 12, 30)
+	network := burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{}, 2)
+	checkStack(t, bursts, "20200101", []*string{nil, ptr("20200101"), ptr("20200107"), ptr("20200113")})
+	if len(network.Pairs) != 3 || network.Pairs[2].BaselineDays != 18 {
+		t.Errorf("expecting 3 pairs with a baseline of 18 days for the last one, got %v", network.Pairs)
+	}
+	if len(bursts[3].Inputs) != 1 || bursts[3].Inputs[0].Role != "previous_2" || bursts[3].Inputs[0].Tile.SourceID != "20200107" {
+		t.Errorf("expecting previous_2 input, got %v", bursts[3].Inputs)
+	}
+
+	// Skip missing
+	bursts = newStack(0, 6, 12, 30)
+	network = burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{SkipMissing: true}, 1)
+	checkStack(t, bursts, "20200101", []*string{nil, ptr("20200101"), ptr("20200107"), nil})
+	if len(network.Unpaired) != 1 || network.Unpaired[0].SourceID != "20200131" {
+		t.Errorf("expecting 1 unpaired burst, got %v", network.Unpaired)
+	}
+}
+
+func TestBurstsStackSortStrategies(t *testing.T) {
+	// Middle reference and pairs at 12 days
+	bursts := newStack(0, 6, 12, 18, 24, 36)
+	burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{Reference: entities.ReferenceMiddle, PairingDays: 12}, 1)
+	checkStack(t, bursts, "20200119", []*string{nil, nil, ptr("20200101"), ptr("20200107"), ptr("20200113"), ptr("20200125")})
+
+	// Pairs at 12 days, with a missing date
+	bursts = newStack(0, 6, 18, 24)
+	burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{PairingDays: 12}, 1)
+	checkStack(t, bursts, "20200101", []*string{nil, nil, ptr("20200107"), ptr("20200101")})
+	bursts = newStack(0, 6, 18, 24)
+	burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{PairingDays: 12, SkipMissing: true}, 1)
+	checkStack(t, bursts, "20200101", []*string{nil, nil, ptr("20200107"), nil})
+
+	// Reference date
+	bursts = newStack(0, 6, 12)
+	burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{Reference: entities.ReferenceDate, ReferenceDate: time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)}, 1)
+	checkStack(t, bursts, "20200107", []*string{nil, ptr("20200101"), ptr("20200107")})
+
+	// The reference of the ingested bursts is kept
+	bursts = newStack(0, 6, 12)
+	bursts[0].Ingested, bursts[0].Root, bursts[0].Leaf = true, true, true
+	burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{Reference: entities.ReferenceMiddle}, 1)
+	checkStack(t, bursts, "20200101", []*string{nil, ptr("20200101"), ptr("20200107")})
+}
+
+func TestBurstsStackSortWindows(t *testing.T) {
+	// Two windows: days [0, 12] and [30, 42]
+	day := func(d int) time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d) }
+	windows := entities.TimeWindows{{Start: day(0), End: day(12)}, {Start: day(30), End: day(42)}}
+	bursts := newStack(0, 6, 30, 36)
+	burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{Windows: windows}, 1)
+	checkStack(t, bursts, "20200101", []*string{nil, ptr("20200101"), nil, ptr("20200131")})
+
+	bursts = newStack(0, 6, 30, 36)
+	burstsStackSort(context.Background(), bursts, entities.BurstsStrategy{}, 1)
+	checkStack(t, bursts, "20200101", []*string{nil, ptr("20200101"), ptr("20200107"), ptr("20200131")})
+}
+
+func TestBurstsSortLegacyID(t *testing.T) {
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ingested := &entities.Tile{TileLite: entities.TileLite{SourceID: "A44_IW1_8951", Date: date}, Ingested: true, Root: true, Leaf: true}
+	burst := &entities.Tile{TileLite: entities.TileLite{SourceID: "t044_093118_iw1", Date: date.AddDate(0, 0, 6)}, Data: common.TileAttrs{LegacyID: "A44_IW1_8953"}}
+	other := &entities.Tile{TileLite: entities.TileLite{SourceID: "t044_093119_iw1", Date: date.AddDate(0, 0, 6)}, Data: common.TileAttrs{LegacyID: "A44_IW1_8981"}}
+	scenes := []*entities.Scene{{Tiles: []*entities.Tile{ingested}}, {Tiles: []*entities.Tile{burst, other}}}
+
+	n, networks := (&Catalog{}).BurstsSort(context.Background(), scenes, entities.BurstsStrategy{}, 1)
+	if n != 1 || len(networks) != 2 {
+		t.Fatalf("expecting 1 track and swath and 2 stacks, got %d and %d", n, len(networks))
+	}
+	if burst.Previous == nil || burst.Previous.SourceID != ingested.SourceID || burst.Reference == nil || burst.Reference.SourceID != ingested.SourceID {
+		t.Errorf("%s: expecting previous and reference %s, got %v and %v", burst.SourceID, ingested.SourceID, burst.Previous, burst.Reference)
+	}
+	if other.Previous != nil || other.Reference != nil {
+		t.Errorf("%s: expecting no previous nor reference", other.SourceID)
+	}
+}
+
+func TestBurstsSortLegacyIDClosest(t *testing.T) {
+	date := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		legacyIDs [2]string
+		expected  int // Index of the burst stacked with the ingested burst
+	}{
+		{[2]string{"A44_IW1_8953", "A44_IW1_8956"}, 1}, // Closest AnxTime
+		{[2]string{"A44_IW1_8957", "A44_IW1_8953"}, 0}, // Same difference: smallest burst ID
+	} {
+		for try := 0; try < 20; try++ {
+			ingested := &entities.Tile{TileLite: entities.TileLite{SourceID: "A44_IW1_8955", Date: date}, Ingested: true, Root: true, Leaf: true}
+			bursts := []*entities.Tile{
+				{TileLite: entities.TileLite{SourceID: "t044_093118_iw1", Date: date.AddDate(0, 0, 6)}, Data: common.TileAttrs{LegacyID: c.legacyIDs[0]}},
+				{TileLite: entities.TileLite{SourceID: "t044_093119_iw1", Date: date.AddDate(0, 0, 6)}, Data: common.TileAttrs{LegacyID: c.legacyIDs[1]}},
+			}
+			scenes := []*entities.Scene{{Tiles: []*entities.Tile{ingested}}, {Tiles: bursts}}
+			(&Catalog{}).BurstsSort(context.Background(), scenes, entities.BurstsStrategy{}, 1)
+			if b := bursts[c.expected]; b.Previous == nil || b.Previous.SourceID != ingested.SourceID {
+				t.Fatalf("%v: expecting %s to be stacked with %s", c.legacyIDs, b.SourceID, ingested.SourceID)
+			}
+			if b := bursts[1-c.expected]; b.Previous != nil {
+				t.Fatalf("%v: expecting %s to have no previous", c.legacyIDs, b.SourceID)
+			}
+		}
+	}
+}
+
+func TestSceneBurstsCached(t *testing.T) {
+	cache, err := annotations.NewBurstsCache(context.Background(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	scene := &entities.Scene{Scene: common.Scene{SourceID: "S1A_IW_SLC__1SDV_20230101T060000_20230101T060027_046589_059557_1A2B"}}
+	bursts := []*annotations.Burst{{SwathID: "IW1", TileNr: 1, AnxTime: 8951, BurstID: 93118, Track: 44, GeometryWKT: "POLYGON((0 0,1 0,1 1,0 1,0 0))"}}
+	if err := cache.Put(context.Background(), scene.SourceID, bursts); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &jobProgress{}
+	cached, err := sceneBursts(withJobProgress(context.Background(), p), scene, nil, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != len(bursts) {
+		t.Errorf("expecting %d bursts, got %d", len(bursts), len(cached))
+	}
+	if progress := p.get(); progress.CachedScenesNb != 1 || progress.AnnotationsNb != 0 {
+		t.Errorf("expecting 1 cached scene and no annotations, got %+v", progress)
+	}
+}
@@ -8,20 +8,25 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"time"
 
-	geocube "github.com/airbusgeo/geocube-client-go/client"
 	"github.com/airbusgeo/geocube-ingester/catalog/entities"
 	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/annotations"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+	"github.com/airbusgeo/geocube-ingester/interface/orbit"
 	"github.com/airbusgeo/geocube-ingester/service/log"
 )
 
 // Catalog is the main class of this package
 type Catalog struct {
-	GeocubeClient                  *geocube.Client
+	Indexer                        indexer.Indexer
 	Workflow                       WorkflowManager
 	CopernicusCatalog              bool
 	CreodiasCatalog                bool
+	ASFCatalog                     bool
 	LandsatAwsCatalog              bool
 	OneAtlasCatalogUser            string
 	OneAtlasApikey                 string
@@ -29,10 +34,23 @@ type Catalog struct {
 	OneAtlasOrderEndpoint          string
 	OneAtlasAuthenticationEndpoint string
 	AnnotationsURLs                []string
+	AnnotationsProviders           []catalog.AnnotationsProvider // Providers of annotations used after AnnotationsURLs (e.g. remote archives of Copernicus or ASF)
+	BurstsCache                    *annotations.BurstsCache      // To cache the bursts parsed from the annotations (optional)
 	WorkingDir                     string
+	LocalAOIFiles                  bool        // Allow the aoi_file of the areas to be a local file (otherwise, only gs:// and s3:// are allowed). Must not be set on a server.
+	Jobs                           JobsBackend // To save the catalog jobs (optional, if nil the jobs are only kept in memory)
+	jobs                           *jobs
 }
 
 func (c *Catalog) ValidateArea(ctx context.Context, area *entities.AreaToIngest) error {
+	// Load the AOI from its vector file (if defined)
+	if err := c.LoadAOI(ctx, area); err != nil {
+		return fmt.Errorf("validateArea.%w", err)
+	}
+	if area.AOI == nil {
+		return fmt.Errorf("validateArea: the AOI is missing")
+	}
+
 	// Check AOI ID
 	matched, err := regexp.MatchString("^[a-zA-Z0-9-:_]+([a-zA-Z0-9-:_]+)*$", area.AOIID)
 	if err != nil {
@@ -47,34 +65,92 @@ func (c *Catalog) ValidateArea(ctx context.Context, area *entities.AreaToIngest)
 		return fmt.Errorf("validateArea: unrecognized constellation: %s", area.SceneType.Constellation)
 	}
 
-	if c.GeocubeClient == nil {
-		return fmt.Errorf("validateArea: no connection to the geocube")
+	// Check orbit type
+	orbitType, err := orbit.ParseType(area.OrbitType)
+	if err != nil {
+		return fmt.Errorf("validateArea.%w", err)
 	}
+	area.OrbitType = string(orbitType)
 
-	// Check that instances exist
-	for k, layer := range area.Layers {
-		if layer.InstanceID != "" {
-			if _, err := c.GeocubeClient.GetVariableFromInstanceID(ctx, layer.InstanceID); err != nil {
-				return fmt.Errorf("validateArea: %w", err)
+	// Check bursts strategy
+	if err := area.BurstsStrategy.Validate(); err != nil {
+		return fmt.Errorf("validateArea.BurstsStrategy: %w", err)
+	}
+	if err := area.GRDTiling.Validate(); err != nil {
+		return fmt.Errorf("validateArea.GRDTiling: %w", err)
+	}
+	if err := area.OpticalLinkage.Validate(); err != nil {
+		return fmt.Errorf("validateArea.OpticalLinkage: %w", err)
+	}
+	if err := area.SceneSelection.Validate(); err != nil {
+		return fmt.Errorf("validateArea.SceneSelection: %w", err)
+	}
+	if err := area.Split.Validate(); err != nil {
+		return fmt.Errorf("validateArea.Split: %w", err)
+	}
+	if err := area.TimeFilter.Validate(area.StartTime, area.EndTime); err != nil {
+		return fmt.Errorf("validateArea.TimeFilter: %w", err)
+	}
+	if area.Split.Grid == entities.SplitGridBurst && (common.GetConstellationFromString(area.SceneType.Constellation) != common.Sentinel1 || area.SceneType.IsS1GRD()) {
+		return fmt.Errorf("validateArea.Split: grid '%s' is only available for Sentinel-1 SLC", entities.SplitGridBurst)
+	}
+	if area.SceneSelection.Enabled() {
+		switch common.GetConstellationFromString(area.SceneType.Constellation) {
+		case common.Sentinel1:
+			return fmt.Errorf("validateArea.SceneSelection: only available for optical constellations")
+		case common.Landsat89, common.Landsat457:
+		default:
+			// Only the Landsat catalog provides the sun elevation of the scenes
+			if area.SceneSelection.SunElevationWeight != 0 {
+				return fmt.Errorf("validateArea.SceneSelection: sun_elevation_weight is only available for Landsat")
 			}
-		} else {
-			v, err := c.GeocubeClient.GetVariableFromName(ctx, layer.Variable)
-			if err != nil {
-				return fmt.Errorf("validateArea: %w", err)
+		}
+	}
+	if area.PreviousTiles < 0 {
+		return fmt.Errorf("validateArea: previous_tiles must be positive (found %d)", area.PreviousTiles)
+	}
+
+	// Check that the instances exist or can be created (they are created by ScenesToIngest)
+	if _, err := c.ProvisionLayers(ctx, area, true); err != nil {
+		return fmt.Errorf("validateArea.%w", err)
+	}
+	return nil
+}
+
+// ProvisionLayers sets the instance ID of the layers of the area.
+// If the definition of the variable of a layer is provided and the indexer supports it, the variable and the instance are created if they do not exist.
+// Returns the list of the actions done (or that would have been done, if dryRun)
+func (c *Catalog) ProvisionLayers(ctx context.Context, area *entities.AreaToIngest, dryRun bool) ([]string, error) {
+	if c.Indexer == nil {
+		return nil, fmt.Errorf("provisionLayers: no indexer configured")
+	}
+
+	var actions []string
+	provisioner, canProvision := c.Indexer.(indexer.Provisioner)
+	for k, layer := range area.Layers {
+		var err error
+		if layer.Definition != nil && layer.InstanceID == "" && canProvision {
+			var acts []string
+			if layer.InstanceID, acts, err = provisioner.ProvisionInstance(ctx, layer.Variable, layer.Instance, *layer.Definition, dryRun); err != nil {
+				return nil, fmt.Errorf("provisionLayers[%s].%w", k, err)
 			}
-			vi := v.Instance(layer.Instance)
-			if vi == nil {
-				return fmt.Errorf("validateArea: unknown instance %s for variable %s", layer.Instance, layer.Variable)
+			for _, action := range acts {
+				log.Logger(ctx).Sugar().Infof("layer %s: %s", k, action)
+				actions = append(actions, fmt.Sprintf("layer %s: %s", k, action))
 			}
-			layer.InstanceID = vi.InstanceID
-			area.Layers[k] = layer
+		} else if layer.InstanceID, err = c.Indexer.InstanceID(ctx, layer.Variable, layer.Instance, layer.InstanceID); err != nil {
+			return nil, fmt.Errorf("provisionLayers[%s].%w", k, err)
 		}
+		area.Layers[k] = layer
 	}
-	return nil
+	return actions, nil
 }
 
 // DoScenesInventory lists scenes for a given AOI, satellites and interval of time
 func (c *Catalog) DoScenesInventory(ctx context.Context, area entities.AreaToIngest) (entities.Scenes, error) {
+	if err := c.LoadAOI(ctx, &area); err != nil {
+		return entities.Scenes{}, fmt.Errorf("DoScenesInventory.%w", err)
+	}
 	aoi, err := area.GeosAOI(false)
 	if err != nil {
 		return entities.Scenes{}, fmt.Errorf("DoScenesInventory.FromWKT: %w", err)
@@ -93,7 +169,9 @@ func (c *Catalog) DoScenesInventory(ctx context.Context, area entities.AreaToIng
 }
 
 // DoTilesInventory creates an inventory of all the tiles of the given scenes
-func (c *Catalog) DoTilesInventory(ctx context.Context, area entities.AreaToIngest, scenes entities.Scenes, rootLeaf []common.Tile) (int, error) {
+// rootTiles and leafTiles are the tiles already ingested in the area (Sentinel-1 or optical linkage only)
+// For Sentinel-1 and linked optical tiles, the network of pairs of tiles is added to the scenes
+func (c *Catalog) DoTilesInventory(ctx context.Context, area entities.AreaToIngest, scenes *entities.Scenes, rootTiles, leafTiles []common.Tile) (int, error) {
 	constellation := common.GetConstellationFromString(area.SceneType.Constellation)
 	switch constellation {
 	case common.Sentinel1:
@@ -113,6 +191,37 @@ func (c *Catalog) DoTilesInventory(ctx context.Context, area entities.AreaToInge
 				}
 				scene.Data.TileMappings[scene.SourceID] = common.TileMapping{}
 			}
+		} else if area.SceneType.IsS1GRD() {
+			// GRD tiles inventory intersecting AOI
+			aoi, err := area.GeosAOI(true)
+			if err != nil {
+				return 0, fmt.Errorf("DoTilesInventory.FromWKT: %w", err)
+			}
+
+			log.Logger(ctx).Debug("Create GRD tiles inventory")
+			if _, err := c.GRDInventory(ctx, area.GRDTiling, *aoi, scenes.Scenes); err != nil {
+				return 0, fmt.Errorf("DoTilesInventory.%w", err)
+			}
+
+			// Remove scenes without tiles
+			grdScenes := scenes.Scenes[:0]
+			for _, scene := range scenes.Scenes {
+				if len(scene.Tiles) > 0 {
+					grdScenes = append(grdScenes, scene)
+				}
+			}
+			scenes.Scenes = grdScenes
+
+			log.Logger(ctx).Debug("Append previous ingested scenes")
+			ingestedScenes, err := c.IngestedScenesInventoryFromTiles(ctx, rootTiles, leafTiles)
+			if err != nil {
+				return 0, fmt.Errorf("DoTilesInventory.%w", err)
+			}
+
+			log.Logger(ctx).Debug("Sort GRD tiles inventory")
+			scenes.PairNetworks = c.GRDSort(ctx, slices.Concat(scenes.Scenes, ingestedScenes), area.LinkageWindows())
+
+			runtime.KeepAlive(aoi)
 		} else {
 			// burst inventory intersecting AOI
 			aoi, err := area.GeosAOI(true)
@@ -121,26 +230,29 @@ func (c *Catalog) DoTilesInventory(ctx context.Context, area entities.AreaToInge
 			}
 
 			log.Logger(ctx).Debug("Create burst inventory")
-			scenes, burstsNb, err := c.BurstsInventory(ctx, area, *aoi, scenes.Scenes)
+			burstsScenes, burstsNb, err := c.BurstsInventory(ctx, area, *aoi, scenes.Scenes)
 			if err != nil {
 				return 0, fmt.Errorf("DoTilesInventory.%w", err)
 			}
 
 			log.Logger(ctx).Debug("Append previous ingested scenes")
-			ingestedScenes, err := c.IngestedScenesInventoryFromTiles(ctx, rootLeaf)
+			ingestedScenes, err := c.IngestedScenesInventoryFromTiles(ctx, rootTiles, leafTiles)
 			if err != nil {
 				return 0, fmt.Errorf("DoTilesInventory.%w", err)
 			}
-			scenes = append(scenes, ingestedScenes...)
+			burstsScenes = append(burstsScenes, ingestedScenes...)
 
 			log.Logger(ctx).Debug("Sort burst inventory")
-			nTrackSwaths := c.BurstsSort(ctx, scenes)
+			var nTrackSwaths int
+			strategy := area.BurstsStrategy
+			strategy.Windows = area.LinkageWindows()
+			nTrackSwaths, scenes.PairNetworks = c.BurstsSort(ctx, burstsScenes, strategy, area.PreviousTiles)
 			log.Logger(ctx).Sugar().Debugf("%d bursts found in %d tracks and swaths", burstsNb, nTrackSwaths)
 
 			runtime.KeepAlive(aoi)
 		}
 
-	case common.Sentinel2, common.SPOT, common.PHR, common.Landsat89:
+	case common.Sentinel2, common.Sentinel3, common.SPOT, common.PHR, common.Landsat89, common.Landsat457:
 		for _, scene := range scenes.Scenes {
 			scene.Tiles = append(scene.Tiles, &entities.Tile{
 				TileLite: entities.TileLite{
@@ -154,6 +266,25 @@ func (c *Catalog) DoTilesInventory(ctx context.Context, area entities.AreaToInge
 				scene.Data.TileMappings = map[string]common.TileMapping{}
 			}
 			scene.Data.TileMappings[scene.SourceID] = common.TileMapping{}
+			if area.OpticalLinkage.Enabled() {
+				// Keep the footprint, to stack the tiles of the next ingestions
+				scene.Data.TileMappings[scene.SourceID] = common.TileMapping{GeometryWKT: scene.GeometryWKT}
+			}
+		}
+
+		if area.OpticalLinkage.Enabled() {
+			log.Logger(ctx).Debug("Append previous ingested scenes")
+			ingestedScenes, err := c.IngestedScenesInventoryFromTiles(ctx, rootTiles, leafTiles)
+			if err != nil {
+				return 0, fmt.Errorf("DoTilesInventory.%w", err)
+			}
+
+			log.Logger(ctx).Debug("Link optical tiles")
+			linkage := area.OpticalLinkage
+			linkage.Windows = area.LinkageWindows()
+			if scenes.PairNetworks, err = c.OpticalSort(ctx, slices.Concat(scenes.Scenes, ingestedScenes), linkage, area.PreviousTiles); err != nil {
+				return 0, fmt.Errorf("DoTilesInventory.%w", err)
+			}
 		}
 	}
 
@@ -172,7 +303,7 @@ func (c *Catalog) DoTilesInventory(ctx context.Context, area entities.AreaToInge
 
 // DeletePendingRecords deletes records of scenes that have not been successfully posted to the workflow server
 func (c *Catalog) DeletePendingRecords(ctx context.Context, scenes entities.Scenes, scenesID map[string]int) {
-	if c.GeocubeClient == nil {
+	if c.Indexer == nil {
 		return
 	}
 
@@ -184,7 +315,7 @@ func (c *Catalog) DeletePendingRecords(ctx context.Context, scenes entities.Scen
 		}
 	}
 	if len(ids) > 0 {
-		if _, e := c.GeocubeClient.DeleteRecords(ctx, ids); e != nil {
+		if e := c.Indexer.DeleteRecords(ctx, ids); e != nil {
 			log.Logger(ctx).Sugar().Warnf("Catalog.IngestScenes : unable to delete unused records (%v): %v", ids, e)
 		}
 	}
@@ -194,6 +325,8 @@ func (c *Catalog) DeletePendingRecords(ctx context.Context, scenes entities.Scen
 type IngestAreaResult struct {
 	ScenesID map[string]int `json:"scenes_id"`
 	TilesNb  int            `json:"tiles_nb"`
+	// Results of the child areas, if the area is split (ScenesID and TilesNb are the aggregation of the results of the children)
+	Children map[string]IngestAreaResult `json:"children,omitempty"`
 }
 
 func (c *Catalog) IngestArea(ctx context.Context, area entities.AreaToIngest, scenes, scenesWithTiles entities.Scenes, outputDir string) (IngestAreaResult, error) {
@@ -206,26 +339,15 @@ func (c *Catalog) IngestArea(ctx context.Context, area entities.AreaToIngest, sc
 	if err := c.ValidateArea(ctx, &area); err != nil {
 		return result, fmt.Errorf("IngestArea.%w", err)
 	}
+	if area.Split.Enabled() {
+		return c.ingestSplitArea(ctx, area, scenes, scenesWithTiles, outputDir)
+	}
 
 	t := time.Now()
 
-	// Scene inventory
-	if scenes.Scenes == nil && scenesWithTiles.Scenes == nil {
-		if scenes, err = c.DoScenesInventory(ctx, area); err != nil {
-			return result, fmt.Errorf("ingestArea.%w", err)
-		}
-		ToJSON(struct{ Scenes entities.Scenes }{Scenes: scenes}, outputDir, "scenesInventory.json")
-	}
-
-	// Tile inventory
-	if scenesWithTiles.Scenes == nil {
-		result.TilesNb, err = c.FindTiles(ctx, area, scenes)
-		if err != nil {
-			return result, fmt.Errorf("ingestArea.%w", err)
-		}
-		ToJSON(struct{ Scenes entities.Scenes }{Scenes: scenes}, outputDir, "tilesInventory.json")
-	} else {
-		scenes = scenesWithTiles
+	// Scene and tile inventory
+	if scenes, result.TilesNb, err = c.inventory(ctx, area, scenes, scenesWithTiles, outputDir); err != nil {
+		return result, fmt.Errorf("ingestArea.%w", err)
 	}
 
 	defer func() {
@@ -233,6 +355,7 @@ func (c *Catalog) IngestArea(ctx context.Context, area entities.AreaToIngest, sc
 	}()
 
 	// Create scenes to ingest
+	progress(ctx).step(jobStepRecords)
 	log.Logger(ctx).Sugar().Debugf("Create %d scenes to ingest (inventory took: %v)", len(scenes.Scenes), time.Since(t))
 	t = time.Now()
 	scenesToIngest, err = c.ScenesToIngest(ctx, area, scenes)
@@ -242,6 +365,7 @@ func (c *Catalog) IngestArea(ctx context.Context, area entities.AreaToIngest, sc
 	ToJSON(struct{ Scenes []common.SceneToIngest }{Scenes: scenesToIngest}, outputDir, "scenesToIngest.json")
 
 	// Post scenes
+	progress(ctx).step(jobStepPost)
 	log.Logger(ctx).Sugar().Debugf("Post %d scenes to ingest (creation took %v)", len(scenesToIngest), time.Since(t))
 	t = time.Now()
 	if result.ScenesID, err = c.PostScenes(ctx, area, scenesToIngest); err != nil {
@@ -259,6 +383,40 @@ func (c *Catalog) IngestArea(ctx context.Context, area entities.AreaToIngest, sc
 	return result, err
 }
 
+// inventory does the inventory of the scenes (if scenes and scenesWithTiles are not defined) and of the tiles (if scenesWithTiles is not defined)
+// Returns the scenes with their tiles and the number of tiles
+func (c *Catalog) inventory(ctx context.Context, area entities.AreaToIngest, scenes, scenesWithTiles entities.Scenes, outputDir string) (entities.Scenes, int, error) {
+	var err error
+	var tilesNb int
+
+	// Scene inventory
+	if scenes.Scenes == nil && scenesWithTiles.Scenes == nil {
+		progress(ctx).step(jobStepScenes)
+		if scenes, err = c.DoScenesInventory(ctx, area); err != nil {
+			return scenes, 0, fmt.Errorf("inventory.%w", err)
+		}
+		ToJSON(struct{ Scenes entities.Scenes }{Scenes: scenes}, outputDir, "scenesInventory.json")
+	}
+
+	progress(ctx).setScenes(len(scenes.Scenes) + len(scenesWithTiles.Scenes))
+
+	// Tile inventory
+	if scenesWithTiles.Scenes == nil {
+		progress(ctx).step(jobStepTiles)
+		if tilesNb, err = c.FindTiles(ctx, area, &scenes); err != nil {
+			return scenes, 0, fmt.Errorf("inventory.%w", err)
+		}
+		ToJSON(struct{ Scenes entities.Scenes }{Scenes: scenes}, outputDir, "tilesInventory.json")
+	} else {
+		scenes = scenesWithTiles
+		for _, scene := range scenes.Scenes {
+			tilesNb += len(scene.Tiles)
+		}
+	}
+	progress(ctx).setTiles(tilesNb)
+	return scenes, tilesNb, nil
+}
+
 func ToJSON(v interface{}, workingdir, filename string) error {
 	if workingdir != "" {
 		vb, err := json.Marshal(v)
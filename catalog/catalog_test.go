@@ -0,0 +1,124 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+)
+
+// testProvisioner implements indexer.Indexer and indexer.Provisioner in memory (only InstanceID and ProvisionInstance)
+type testProvisioner struct {
+	indexer.Indexer
+	instances map[string]string // variable:instance => id
+	created   []string
+}
+
+func (p *testProvisioner) InstanceID(ctx context.Context, variable, instance, instanceID string) (string, error) {
+	if instanceID != "" {
+		return instanceID, nil
+	}
+	if id, ok := p.instances[variable+":"+instance]; ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("unknown instance %s:%s", variable, instance)
+}
+
+func (p *testProvisioner) ProvisionInstance(ctx context.Context, variable, instance string, definition indexer.Variable, dryRun bool) (string, []string, error) {
+	if id, ok := p.instances[variable+":"+instance]; ok {
+		return id, nil, nil
+	}
+	actions := []string{"create " + variable + ":" + instance}
+	if dryRun {
+		return "", actions, nil
+	}
+	id := fmt.Sprintf("id-%d", len(p.instances))
+	p.instances[variable+":"+instance] = id
+	p.created = append(p.created, variable+":"+instance)
+	return id, actions, nil
+}
+
+func TestProvisionLayers(t *testing.T) {
+	ctx := context.Background()
+	var area entities.AreaToIngest
+	if err := json.Unmarshal([]byte(`{
+		"name": "aoi",
+		"type": "Feature",
+		"geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,1],[0,0]]]},
+		"scene_type": {"constellation": "sentinel1"},
+		"layers": {
+			"sigma0_VV": {"variable": "sigma0", "instance": "VV", "definition": {"dformat": "float32,0,0,1"}},
+			"coh_VV": {"variable": "coherence", "instance": "VV"}
+		}
+	}`), &area); err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvisioner{instances: map[string]string{"coherence:VV": "coh-id"}}
+	c := &Catalog{Indexer: p}
+
+	// The validation does not create anything
+	if err := c.ValidateArea(ctx, &area); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.created) != 0 {
+		t.Errorf("ValidateArea must not create instances, got %v", p.created)
+	}
+	if area.Layers["coh_VV"].InstanceID != "coh-id" || area.Layers["sigma0_VV"].InstanceID != "" {
+		t.Errorf("wrong instances after validation: %v", area.InstancesID())
+	}
+
+	// Provisioning
+	actions, err := c.ProvisionLayers(ctx, &area, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || len(p.created) != 1 || p.created[0] != "sigma0:VV" {
+		t.Errorf("expecting the creation of sigma0:VV, got %v", p.created)
+	}
+	if id := area.Layers["sigma0_VV"].InstanceID; id == "" {
+		t.Errorf("the instance id of sigma0_VV is not set")
+	}
+
+	// Unknown instance without definition
+	area.Layers["other"] = area.Layers["coh_VV"]
+	layer := area.Layers["other"]
+	layer.Instance, layer.InstanceID = "VH", ""
+	area.Layers["other"] = layer
+	if err := c.ValidateArea(ctx, &area); err == nil {
+		t.Errorf("expecting an error for an unknown instance without definition")
+	}
+}
+
+func TestValidateAreaSceneSelection(t *testing.T) {
+	ctx := context.Background()
+	c := &Catalog{Indexer: &testProvisioner{instances: map[string]string{}}}
+	for _, test := range []struct {
+		constellation string
+		selection     string
+		valid         bool
+	}{
+		{"sentinel2", `{"period": "weekly"}`, true},
+		{"landsat89", `{"period": "weekly", "sun_elevation_weight": 0.5}`, true},
+		{"sentinel2", `{"period": "weekly", "sun_elevation_weight": 0.5}`, false},
+		{"sentinel3", `{"period": "weekly", "sun_elevation_weight": 0.5}`, false},
+		{"sentinel1", `{"period": "weekly"}`, false},
+		{"sentinel1", `{}`, true},
+	} {
+		var area entities.AreaToIngest
+		if err := json.Unmarshal([]byte(fmt.Sprintf(`{
+			"name": "aoi",
+			"type": "Feature",
+			"geometry": {"type": "Polygon", "coordinates": [[[0,0],[1,0],[1,1],[0,1],[0,0]]]},
+			"scene_type": {"constellation": "%s"},
+			"scene_selection": %s
+		}`, test.constellation, test.selection)), &area); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ValidateArea(ctx, &area); (err == nil) != test.valid {
+			t.Errorf("%s %s: expecting valid=%v, got %v", test.constellation, test.selection, test.valid, err)
+		}
+	}
+}
@@ -3,9 +3,13 @@ package entities
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
 	"github.com/airbusgeo/geocube-ingester/service/geometry"
 	"github.com/go-spatial/geom"
 	"github.com/go-spatial/geom/encoding/geojson"
@@ -29,7 +33,419 @@ type Tile struct {
 	GeometryWKT string           `json:"wkt"`
 	Previous    *TileLite        `json:"previous"`
 	Reference   *TileLite        `json:"reference"`
+	Inputs      []TileInput      `json:"inputs,omitempty"` // Additional inputs
 	Ingested    bool             `json:"-"`
+	Root        bool             `json:"-"` // Ingested tile with no previous and no reference
+	Leaf        bool             `json:"-"` // Ingested tile that is not the previous of any tile
+}
+
+// TileInput is an additional input of a tile, identified by its role
+type TileInput struct {
+	Role string   `json:"role"`
+	Tile TileLite `json:"tile"`
+}
+
+// Strategies to choose the reference burst of a stack of Sentinel-1 bursts
+const (
+	ReferenceFirst  = "first"  // First burst of the stack
+	ReferenceMiddle = "middle" // Burst in the middle of the stack
+	ReferenceDate   = "date"   // Burst acquired the closest to a given date
+)
+
+// BurstsStrategy defines how the reference and the previous burst of each Sentinel-1 burst are chosen
+type BurstsStrategy struct {
+	Reference     string    `json:"reference"`      // ReferenceFirst (default), ReferenceMiddle or ReferenceDate
+	ReferenceDate time.Time `json:"reference_date"` // If Reference=ReferenceDate
+	// Temporal baseline in days (multiple of 6) between a burst and its previous burst.
+	// Default: the previous acquisition
+	PairingDays int `json:"pairing_days,omitempty"`
+	// If the burst acquired at the expected baseline is missing, the burst is not paired
+	// Otherwise, it is paired with the closest earlier burst available
+	SkipMissing bool `json:"skip_missing,omitempty"`
+	// If defined, a burst is only paired with a burst of the same window (see AreaToIngest.LinkageWindows). The reference is shared by all the windows
+	Windows TimeWindows `json:"-"`
+}
+
+// Validate checks the strategy
+func (s BurstsStrategy) Validate() error {
+	switch s.Reference {
+	case "", ReferenceFirst, ReferenceMiddle:
+	case ReferenceDate:
+		if s.ReferenceDate.IsZero() {
+			return fmt.Errorf("reference_date is required with the reference strategy '%s'", ReferenceDate)
+		}
+	default:
+		return fmt.Errorf("unknown reference strategy: '%s'", s.Reference)
+	}
+	if s.PairingDays < 0 || s.PairingDays%6 != 0 {
+		return fmt.Errorf("pairing_days must be a positive multiple of 6 (found %d)", s.PairingDays)
+	}
+	return nil
+}
+
+// Tilings of the Sentinel-1 GRD products
+const (
+	GRDTilingSlice = "slice" // One tile per product (slice)
+	GRDTilingFrame = "frame" // One tile per frame (latitude band of a relative orbit)
+)
+
+// GRDTiling defines how the Sentinel-1 GRD products are divided into tiles
+type GRDTiling struct {
+	Mode        string  `json:"mode"`                   // GRDTilingSlice (default) or GRDTilingFrame
+	FrameHeight float64 `json:"frame_height,omitempty"` // Height of the frames in degrees of latitude (default: 1)
+}
+
+// Validate checks the tiling
+func (t GRDTiling) Validate() error {
+	switch t.Mode {
+	case "", GRDTilingSlice, GRDTilingFrame:
+	default:
+		return fmt.Errorf("unknown GRD tiling: '%s'", t.Mode)
+	}
+	if t.FrameHeight < 0 || t.FrameHeight > 90 {
+		return fmt.Errorf("frame_height must be between 0 and 90 degrees (found %f)", t.FrameHeight)
+	}
+	return nil
+}
+
+// Keys to stack the optical tiles
+const (
+	StackByTile      = "tile"      // MGRS tile (Sentinel-2), WRS path/row (Landsat) or footprint if not available (SPOT, PHR)
+	StackByFootprint = "footprint" // Overlap of the footprints
+)
+
+// OpticalLinkage defines how the previous and the reference tiles of each optical tile (Sentinel-2, Landsat, SPOT, PHR) are chosen
+type OpticalLinkage struct {
+	StackBy string `json:"stack_by"` // "" (no linkage, default), StackByTile or StackByFootprint
+	// Minimum overlap between the footprints of two tiles of the same stack, as a ratio of the smallest one (StackByFootprint only, default: 0.5)
+	MinOverlap    float64   `json:"min_overlap,omitempty"`
+	Reference     string    `json:"reference"`      // ReferenceFirst (default), ReferenceMiddle or ReferenceDate
+	ReferenceDate time.Time `json:"reference_date"` // If Reference=ReferenceDate
+	// If defined, a tile is only linked to a previous tile of the same window (see AreaToIngest.LinkageWindows)
+	Windows TimeWindows `json:"-"`
+}
+
+// Enabled returns true if the optical tiles have to be linked to their previous and reference tiles
+func (l OpticalLinkage) Enabled() bool {
+	return l.StackBy != ""
+}
+
+// Strategy returns the strategy to choose the reference and the previous tile of a stack
+func (l OpticalLinkage) Strategy() BurstsStrategy {
+	return BurstsStrategy{Reference: l.Reference, ReferenceDate: l.ReferenceDate, Windows: l.Windows}
+}
+
+// Validate checks the linkage
+func (l OpticalLinkage) Validate() error {
+	switch l.StackBy {
+	case "", StackByTile, StackByFootprint:
+	default:
+		return fmt.Errorf("unknown stack_by: '%s'", l.StackBy)
+	}
+	if l.MinOverlap < 0 || l.MinOverlap > 1 {
+		return fmt.Errorf("min_overlap must be between 0 and 1 (found %f)", l.MinOverlap)
+	}
+	return l.Strategy().Validate()
+}
+
+// Periods of the selection of the scenes
+const (
+	PeriodWeekly  = "weekly"  // ISO weeks (from monday)
+	PeriodMonthly = "monthly" // Calendar months
+	PeriodCustom  = "custom"  // Periods of SceneSelection.Days days from the start time of the area
+)
+
+// SceneSelection defines how the best scenes covering the AOI are selected for each period
+type SceneSelection struct {
+	Period string `json:"period"`         // "" (no selection, default), PeriodWeekly, PeriodMonthly or PeriodCustom
+	Days   int    `json:"days,omitempty"` // Length of the period in days (PeriodCustom only)
+	// Weight of the sun elevation (in degrees) in the cost of a scene: cost = cloud cover (%) - weight * sun elevation (default: 0)
+	SunElevationWeight float64 `json:"sun_elevation_weight,omitempty"`
+	// Minimum ratio of the AOI newly covered by a scene to be selected (default: 0.01)
+	MinCoverage float64 `json:"min_coverage,omitempty"`
+}
+
+// Enabled returns true if a selection of the scenes is required
+func (s SceneSelection) Enabled() bool {
+	return s.Period != ""
+}
+
+// Validate checks the selection
+func (s SceneSelection) Validate() error {
+	switch s.Period {
+	case "", PeriodWeekly, PeriodMonthly:
+	case PeriodCustom:
+		if s.Days <= 0 {
+			return fmt.Errorf("days must be positive with the period '%s' (found %d)", PeriodCustom, s.Days)
+		}
+	default:
+		return fmt.Errorf("unknown period: '%s'", s.Period)
+	}
+	if s.MinCoverage < 0 || s.MinCoverage > 1 {
+		return fmt.Errorf("min_coverage must be between 0 and 1 (found %f)", s.MinCoverage)
+	}
+	return nil
+}
+
+// Grids to split an AOI
+const (
+	SplitGridDegree = "degree" // Regular grid of AOISplit.Size degrees
+	SplitGridMGRS   = "mgrs"   // 100km squares of the MGRS grid (e.g. 31TCJ, as the Sentinel-2 tiles)
+	SplitGridBurst  = "burst"  // Footprints of the Sentinel-1 bursts, grouped by track and by AOISplit.Size consecutive burst IDs, so that a stack of bursts is ingested in one child AOI
+)
+
+const (
+	defaultSplitSize   = 1. // degrees
+	defaultSplitBursts = 10 // consecutive burst IDs (about 200km along the track)
+)
+
+// AOISplit defines how a large AOI is split into child AOIs, ingested and tracked independently in the workflow under the parent AOI
+type AOISplit struct {
+	Grid string  `json:"grid"`           // "" (no split, default), SplitGridDegree, SplitGridMGRS or SplitGridBurst
+	Size float64 `json:"size,omitempty"` // Size of the cells in degrees (SplitGridDegree, default: 1) or in number of burst IDs (SplitGridBurst, default: 10)
+}
+
+// Enabled returns true if the AOI has to be split
+func (s AOISplit) Enabled() bool {
+	return s.Grid != ""
+}
+
+// CellSize returns the size of the cells in degrees (SplitGridDegree only)
+func (s AOISplit) CellSize() float64 {
+	if s.Size == 0 {
+		return defaultSplitSize
+	}
+	return s.Size
+}
+
+// CellBursts returns the number of consecutive burst IDs of the cells (SplitGridBurst only)
+func (s AOISplit) CellBursts() int {
+	if s.Size == 0 {
+		return defaultSplitBursts
+	}
+	return int(s.Size)
+}
+
+// Validate checks the split
+func (s AOISplit) Validate() error {
+	switch s.Grid {
+	case "", SplitGridMGRS:
+	case SplitGridDegree:
+		if s.Size < 0 || s.Size > 90 {
+			return fmt.Errorf("size must be between 0 and 90 degrees (found %f)", s.Size)
+		}
+	case SplitGridBurst:
+		if s.Size < 0 || s.Size != math.Trunc(s.Size) {
+			return fmt.Errorf("size must be a positive number of bursts (found %f)", s.Size)
+		}
+	default:
+		return fmt.Errorf("unknown grid: '%s'", s.Grid)
+	}
+	return nil
+}
+
+// ChildAOIID returns the name of the child AOI of the cell
+func (a *AreaToIngest) ChildAOIID(cell string) string {
+	return a.AOIID + "_" + cell
+}
+
+// TimeWindow is an interval of time [Start, End]
+type TimeWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Season is a window of time recurring each year, from the day Start to the day End (included) with the format MM-DD (e.g. 04-01 to 09-30)
+// If End is before Start, the season spans two years (e.g. 11-01 to 02-28)
+type Season struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+const seasonDayFormat = "01-02"
+
+// TimeFilter restricts the acquisition dates of the scenes of an area to several windows of time, recurring seasons or dates (optional)
+// The windows are clipped to [StartTime, EndTime] of the area, if defined.
+type TimeFilter struct {
+	Windows []TimeWindow `json:"windows,omitempty"`
+	Seasons []Season     `json:"seasons,omitempty"` // Each year between StartTime and EndTime of the area (required)
+	Dates   []string     `json:"dates,omitempty"`   // Whole days (UTC) with the format YYYY-MM-DD
+	// By default, a tile is not linked to a previous tile of another window (e.g. the previous season)
+	PairAcrossWindows bool `json:"pair_across_windows,omitempty"`
+}
+
+// Enabled returns true if the time filter is defined
+func (f TimeFilter) Enabled() bool {
+	return len(f.Windows) > 0 || len(f.Seasons) > 0 || len(f.Dates) > 0
+}
+
+// Validate checks the time filter, given the interval of time of the area
+func (f TimeFilter) Validate(startTime, endTime time.Time) error {
+	for _, w := range f.Windows {
+		if w.End.Before(w.Start) {
+			return fmt.Errorf("window ends before it starts (%v < %v)", w.End, w.Start)
+		}
+	}
+	if len(f.Seasons) > 0 && (startTime.IsZero() || endTime.IsZero()) {
+		return fmt.Errorf("start_time and end_time are required with seasons")
+	}
+	for _, season := range f.Seasons {
+		if _, _, err := season.window(2000); err != nil {
+			return err
+		}
+	}
+	for _, date := range f.Dates {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("wrong date '%s' (expecting YYYY-MM-DD)", date)
+		}
+	}
+	return nil
+}
+
+// window returns the window of the season starting in the year
+func (s Season) window(year int) (time.Time, time.Time, error) {
+	start, err := time.Parse(seasonDayFormat, s.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("wrong start of season '%s' (expecting MM-DD)", s.Start)
+	}
+	end, err := time.Parse(seasonDayFormat, s.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("wrong end of season '%s' (expecting MM-DD)", s.End)
+	}
+	endYear := year
+	if end.Before(start) {
+		endYear++
+	}
+	return time.Date(year, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+		time.Date(endYear, end.Month(), end.Day()+1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond), nil
+}
+
+// TimeWindows is a sorted list of disjoint windows of time
+type TimeWindows []TimeWindow
+
+// Index returns the index of the window containing t (-1 if none)
+func (ws TimeWindows) Index(t time.Time) int {
+	i := sort.Search(len(ws), func(i int) bool { return !ws[i].End.Before(t) })
+	if i < len(ws) && !t.Before(ws[i].Start) {
+		return i
+	}
+	return -1
+}
+
+// Contains returns true if t is in one of the windows
+func (ws TimeWindows) Contains(t time.Time) bool {
+	return ws.Index(t) >= 0
+}
+
+// Extent returns the smallest window containing all the windows
+func (ws TimeWindows) Extent() TimeWindow {
+	if len(ws) == 0 {
+		return TimeWindow{}
+	}
+	return TimeWindow{Start: ws[0].Start, End: ws[len(ws)-1].End}
+}
+
+// TimeWindows returns the windows of time of the area: the windows of the time filter clipped to [StartTime, EndTime], or [StartTime, EndTime] if there is no time filter
+func (a *AreaToIngest) TimeWindows() (TimeWindows, error) {
+	if !a.TimeFilter.Enabled() {
+		return TimeWindows{{Start: a.StartTime, End: a.EndTime}}, nil
+	}
+	if err := a.TimeFilter.Validate(a.StartTime, a.EndTime); err != nil {
+		return nil, fmt.Errorf("TimeWindows: %w", err)
+	}
+	windows := append(TimeWindows{}, a.TimeFilter.Windows...)
+	for _, season := range a.TimeFilter.Seasons {
+		for year := a.StartTime.Year() - 1; year <= a.EndTime.Year(); year++ {
+			start, end, _ := season.window(year)
+			windows = append(windows, TimeWindow{Start: start, End: end})
+		}
+	}
+	for _, date := range a.TimeFilter.Dates {
+		day, _ := time.Parse("2006-01-02", date)
+		windows = append(windows, TimeWindow{Start: day, End: day.Add(24*time.Hour - time.Nanosecond)})
+	}
+
+	// Clip to [StartTime, EndTime]
+	clipped := windows[:0]
+	for _, w := range windows {
+		if !a.StartTime.IsZero() && w.Start.Before(a.StartTime) {
+			w.Start = a.StartTime
+		}
+		if !a.EndTime.IsZero() && w.End.After(a.EndTime) {
+			w.End = a.EndTime
+		}
+		if !w.End.Before(w.Start) {
+			clipped = append(clipped, w)
+		}
+	}
+
+	// Sort and merge the overlapping windows
+	sort.Slice(clipped, func(i, j int) bool { return clipped[i].Start.Before(clipped[j].Start) })
+	var merged TimeWindows
+	for _, w := range clipped {
+		if n := len(merged); n > 0 && !w.Start.After(merged[n-1].End) {
+			if w.End.After(merged[n-1].End) {
+				merged[n-1].End = w.End
+			}
+			continue
+		}
+		merged = append(merged, w)
+	}
+	return merged, nil
+}
+
+// LinkageWindows returns the windows of time that the previous tile of a tile must share with it (nil if the linkage is not restricted)
+func (a *AreaToIngest) LinkageWindows() TimeWindows {
+	if !a.TimeFilter.Enabled() || a.TimeFilter.PairAcrossWindows {
+		return nil
+	}
+	windows, err := a.TimeWindows()
+	if err != nil {
+		return nil
+	}
+	return windows
+}
+
+// PeriodSelection is the report of the selection of the scenes of a period
+type PeriodSelection struct {
+	Start    time.Time       `json:"start"`
+	End      time.Time       `json:"end"`
+	Coverage float64         `json:"coverage"` // Ratio of the AOI covered by the selected scenes
+	Selected []SelectedScene `json:"selected,omitempty"`
+	Rejected []RejectedScene `json:"rejected,omitempty"`
+}
+
+// SelectedScene is a scene selected to cover the AOI
+type SelectedScene struct {
+	SceneID      string  `json:"scene_id"`
+	CloudCover   float64 `json:"cloud_cover"`
+	SunElevation float64 `json:"sun_elevation,omitempty"`
+	Cost         float64 `json:"cost"`
+	Coverage     float64 `json:"coverage"` // Ratio of the AOI covered by this scene and not by the scenes with a lower cost
+}
+
+// RejectedScene is a scene that has not been selected
+type RejectedScene struct {
+	SceneID string  `json:"scene_id"`
+	Cost    float64 `json:"cost"`
+	Reason  string  `json:"reason"`
+}
+
+// PairNetwork is the network of pairs of a stack of Sentinel-1 bursts (bursts with the same burst ID), GRD tiles or optical tiles
+type PairNetwork struct {
+	StackID    string     `json:"stack_id"` // Burst ID (or GRD tile ID, or optical stack ID)
+	TrackSwath string     `json:"track_swath"`
+	AnxTime    int        `json:"anx_time"`
+	Reference  TileLite   `json:"reference"`
+	Pairs      []Pair     `json:"pairs,omitempty"`
+	Unpaired   []TileLite `json:"unpaired,omitempty"` // New bursts (except the reference) without previous burst
+}
+
+// Pair is a burst and its previous burst
+type Pair struct {
+	Tile         TileLite `json:"tile"`
+	Previous     TileLite `json:"previous"`
+	BaselineDays int      `json:"baseline_days"`
 }
 
 // Scene is a specialisation of common.Scene for the catalog
@@ -48,12 +464,27 @@ type SceneType struct {
 	Parameters    map[string]string
 }
 
+// IsS1GRD returns true if the scenes are Sentinel-1 GRD products (producttype=GRD)
+func (s SceneType) IsS1GRD() bool {
+	return common.GetConstellationFromString(s.Constellation) == common.Sentinel1 &&
+		strings.HasPrefix(strings.ToUpper(s.Parameters["producttype"]), "GRD")
+}
+
 const AOIBuffer = 0.05
 
+// AOIFile is a vector file (Shapefile, GeoPackage, KML, FlatGeobuf, GeoJSON...) defining the AOI
+type AOIFile struct {
+	URI   string `json:"uri"`             // Local path or storage URI (gs://, s3://). A Shapefile is read with its sidecar files (.shx, .dbf, .prj, .cpg)
+	Layer string `json:"layer,omitempty"` // Name of the layer (default: the first layer)
+	Where string `json:"where,omitempty"` // Attribute filter of the features (OGR SQL WHERE clause, e.g. "name='Toulouse'")
+}
+
 // AreaToIngest is the input of the catalog
 type AreaToIngest struct {
-	AOIID          string `json:"name"`
+	AOIID string `json:"name"`
+	// AOI is the geometry of the area, defined by the GeoJSON geometry embedded in the json or, if AOIFile is defined, loaded from the vector file (see catalog.LoadAOI)
 	AOI            geom.Geometry
+	AOIFile        *AOIFile          `json:"aoi_file,omitempty"`
 	StartTime      time.Time         `json:"start_time"`
 	EndTime        time.Time         `json:"end_time"`
 	SceneType      SceneType         `json:"scene_type"`
@@ -64,6 +495,8 @@ type AreaToIngest struct {
 		Variable   string `json:"variable"`
 		Instance   string `json:"instance"`
 		InstanceID string `json:"instance_id"`
+		// Definition of the variable (optional), to create the variable and the instance if they do not exist
+		Definition *indexer.Variable `json:"definition,omitempty"`
 	} `json:"layers"`
 	RecordTags      map[string]string `json:"record_tags"`
 	AnnotationsURLs []string          `json:"annotations_urls"`
@@ -72,6 +505,25 @@ type AreaToIngest struct {
 	Page            int               `json:"page"`
 	Limit           int               `json:"limit"`
 	StorageURI      string            `json:"storage_uri"` // If empty, use the default storage uri of the ingester
+	// Orbit file required to download a Sentinel-1 scene (POEORB or RESORB, optional): the scene is set to RETRY until it is available
+	OrbitType string `json:"orbit_type,omitempty"`
+	// Layouts to consolidate the instances of the layers in, when the ingestion of the AOI is done (optional)
+	ConsolidationLayouts []string `json:"consolidation_layouts,omitempty"`
+	// Number of previous tiles of each tile (Sentinel-1 or optical linkage only, default: 1)
+	// The previous tiles after the first one are additional inputs with the roles previous_2, previous_3...
+	PreviousTiles int `json:"previous_tiles,omitempty"`
+	// Strategy to choose the reference and the previous bursts (Sentinel-1 only)
+	BurstsStrategy BurstsStrategy `json:"bursts_strategy"`
+	// Tiling of the products (Sentinel-1 GRD only)
+	GRDTiling GRDTiling `json:"grd_tiling"`
+	// Strategy to link the tiles to their previous and reference tiles (Sentinel-2, Landsat, SPOT, PHR only)
+	OpticalLinkage OpticalLinkage `json:"optical_linkage"`
+	// Selection of the best scenes covering the AOI for each period (optical only)
+	SceneSelection SceneSelection `json:"scene_selection"`
+	// Split of the AOI into child AOIs (optional)
+	Split AOISplit `json:"split"`
+	// Restriction of the acquisition dates to several windows, seasons or dates (optional)
+	TimeFilter TimeFilter `json:"time_filter"`
 }
 
 // AutoFill fills ProductName, Satellite, Constellation
@@ -86,7 +538,11 @@ func (s *Scene) AutoFill() {
 		constellation = "SENTINEL2"
 		satellite = constellation + s.SourceID[2:3]
 		s.ProductName = s.SourceID[0:26] + "_NXXYY" + s.SourceID[32:]
-	case common.Landsat89:
+	case common.Sentinel3:
+		constellation = "SENTINEL3"
+		satellite = constellation + s.SourceID[2:3]
+		s.ProductName = s.SourceID
+	case common.Landsat89, common.Landsat457:
 		constellation = "LANDSAT"
 		satellite = constellation + s.SourceID[2:4]
 		s.ProductName = s.SourceID
@@ -109,25 +565,40 @@ func (a *AreaToIngest) InstancesID() map[string]string {
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface for AreaToIngest
+// If aoi_file is defined, the geometry is not read from the json: it is loaded later from the file.
 func (area *AreaToIngest) UnmarshalJSON(data []byte) error {
-	var err error
-	if area.AOI, err = geometry.UnmarshalGeometry(data); err != nil {
-		return err
-	}
-
 	type AreaToIngesterUnmarshaller AreaToIngest
 	if err := json.Unmarshal(data, (*AreaToIngesterUnmarshaller)(area)); err != nil {
 		return err
 	}
 
+	if area.AOIFile != nil {
+		if area.AOIFile.URI == "" {
+			return fmt.Errorf("UnmarshalJSON: aoi_file.uri is missing")
+		}
+		return nil
+	}
+
+	var err error
+	if area.AOI, err = geometry.UnmarshalGeometry(data); err != nil {
+		return err
+	}
 	return nil
 }
 
+// GeosAOI returns the AOI normalized (split along the antimeridian, closed through the pole, see geometry.NormalizeLonLat)
 func (area *AreaToIngest) GeosAOI(applyBuffer bool) (*geos.Geometry, error) {
-	aoi, err := geos.FromWKT(wkt.MustEncode(area.AOI))
+	g, normalized := geometry.NormalizeLonLat(area.AOI)
+	aoi, err := geos.FromWKT(wkt.MustEncode(g))
 	if err != nil {
 		return nil, fmt.Errorf("GeosAOI: %w", err)
 	}
+	if normalized {
+		// Fix the degenerated edges that the split may have created
+		if aoi, err = aoi.Buffer(0); err != nil {
+			return nil, fmt.Errorf("GeosAOI.Buffer: %w", err)
+		}
+	}
 	if applyBuffer {
 		aoi, err = aoi.Buffer(AOIBuffer)
 		if err != nil {
@@ -184,8 +655,10 @@ func (scene *Scene) fromFeature(feature geojson.Feature) error {
 }
 
 type Scenes struct {
-	Scenes     []*Scene
-	Properties map[string]string
+	Scenes       []*Scene
+	Properties   map[string]string
+	PairNetworks []PairNetwork     // Report of the pairs of bursts (Sentinel-1 only)
+	Selections   []PeriodSelection // Report of the selection of the scenes (if SceneSelection is enabled)
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface for Scenes
@@ -215,7 +688,9 @@ func (scenes Scenes) MarshalJSON() ([]byte, error) {
 		FeatureCollection: geojson.FeatureCollection{
 			Features: make([]geojson.Feature, len(scenes.Scenes)),
 		},
-		Properties: scenes.Properties,
+		Properties:   scenes.Properties,
+		PairNetworks: scenes.PairNetworks,
+		Selections:   scenes.Selections,
 	}
 	for i, scene := range scenes.Scenes {
 		if fc.Features[i], err = scene.toFeature(); err != nil {
@@ -230,5 +705,7 @@ func (scenes Scenes) MarshalJSON() ([]byte, error) {
 
 type featureCollection struct {
 	geojson.FeatureCollection
-	Properties map[string]string `json:"properties,omitempty"`
+	Properties   map[string]string `json:"properties,omitempty"`
+	PairNetworks []PairNetwork     `json:"pair_networks,omitempty"`
+	Selections   []PeriodSelection `json:"selections,omitempty"`
 }
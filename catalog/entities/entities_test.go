@@ -3,6 +3,7 @@ package entities
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/airbusgeo/geocube-ingester/common"
 )
@@ -43,3 +44,67 @@ func TestMarshallScenes(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalAreaFile(t *testing.T) {
+	area := AreaToIngest{}
+	if err := json.Unmarshal([]byte(`{"name":"test","aoi_file":{"uri":"gs://bucket/aoi.gpkg","layer":"aois","where":"name='test'"}}`), &area); err != nil {
+		t.Fatal(err)
+	}
+	if area.AOI != nil || area.AOIFile == nil || area.AOIFile.URI != "gs://bucket/aoi.gpkg" || area.AOIFile.Layer != "aois" || area.AOIFile.Where != "name='test'" {
+		t.Errorf("expecting an aoi file, got %v %v", area.AOI, area.AOIFile)
+	}
+	if err := json.Unmarshal([]byte(`{"name":"test","aoi_file":{"layer":"aois"}}`), &AreaToIngest{}); err == nil {
+		t.Errorf("expecting an error without uri")
+	}
+	area = AreaToIngest{}
+	if err := json.Unmarshal([]byte(`{"name":"test","type":"Polygon","coordinates":[[[1,43],[2,43],[2,44],[1,44],[1,43]]]}`), &area); err != nil || area.AOI == nil {
+		t.Errorf("expecting a geometry, got %v (%v)", area.AOI, err)
+	}
+}
+
+func TestTimeWindows(t *testing.T) {
+	area := AreaToIngest{
+		StartTime: time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2020, 5, 15, 0, 0, 0, 0, time.UTC),
+		TimeFilter: TimeFilter{
+			Seasons: []Season{{Start: "04-01", End: "09-30"}, {Start: "12-15", End: "01-15"}},
+			Dates:   []string{"2019-10-10", "2019-09-30"},
+		},
+	}
+	windows, err := area.TimeWindows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"2018-04-01/2018-09-30", "2018-12-15/2019-01-15", "2019-04-01/2019-09-30", "2019-10-10/2019-10-10",
+		"2019-12-15/2020-01-15", "2020-04-01/2020-05-15",
+	}
+	if len(windows) != len(expected) {
+		t.Fatalf("expecting %d windows, got %v", len(expected), windows)
+	}
+	for i, w := range windows {
+		if s := w.Start.Format("2006-01-02") + "/" + w.End.Format("2006-01-02"); s != expected[i] {
+			t.Errorf("expecting window %s, got %s", expected[i], s)
+		}
+	}
+	for date, index := range map[time.Time]int{
+		time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC):   -1,
+		time.Date(2018, 9, 30, 23, 0, 0, 0, time.UTC):  0,
+		time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC):    1,
+		time.Date(2019, 10, 10, 12, 0, 0, 0, time.UTC): 3,
+		time.Date(2019, 10, 11, 0, 0, 0, 0, time.UTC):  -1,
+	} {
+		if i := windows.Index(date); i != index {
+			t.Errorf("%v: expecting window %d, got %d", date, index, i)
+		}
+	}
+
+	area.TimeFilter.PairAcrossWindows = true
+	if area.LinkageWindows() != nil {
+		t.Errorf("expecting no linkage windows")
+	}
+	area.TimeFilter = TimeFilter{Seasons: []Season{{Start: "04-31", End: "09-30"}}}
+	if _, err := area.TimeWindows(); err == nil {
+		t.Errorf("expecting an error with a wrong season")
+	}
+}
@@ -0,0 +1,133 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+// IngestAreaEstimate is the estimation of the ingestion of an area (dry run of IngestArea)
+type IngestAreaEstimate struct {
+	ScenesNb int `json:"scenes_nb"`
+	TilesNb  int `json:"tiles_nb"`
+	// Volume to download (bytes), from the metadata of the catalogue. The size of UnknownSizeNb scenes is not provided by the catalogue.
+	DownloadSize  int64 `json:"download_size"`
+	UnknownSizeNb int   `json:"unknown_size_nb"`
+	// Volume to store per layer (bytes), from the mean size of the outputs of the tiles already processed with the same graph
+	StorageSize map[string]int64 `json:"storage_size"`
+	// Processing time per graph, from the mean duration of the processings with the same graph
+	Processing []GraphEstimate `json:"processing"`
+	// Price of the order (OneAtlas only)
+	Price     int      `json:"price,omitempty"`
+	PriceUnit string   `json:"price_unit,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// GraphEstimate is the estimation of the processing time of the scenes or the tiles with a graph
+type GraphEstimate struct {
+	Type    string  `json:"type"` // common.ResultTypeScene or common.ResultTypeTile
+	Graph   string  `json:"graph"`
+	Nb      int     `json:"nb"`      // Number of scenes or tiles to process
+	Hours   float64 `json:"hours"`   // Total processing time (hours)
+	History int     `json:"history"` // Number of processings the estimation is based on (0: the processing time is unknown)
+}
+
+// EstimateArea does the inventory of the scenes and the tiles of the area (if not provided) and estimates the volume to download and store,
+// the processing time and the price of the ingestion. Nothing is created (records, scenes...).
+func (c *Catalog) EstimateArea(ctx context.Context, area entities.AreaToIngest, scenes, scenesWithTiles entities.Scenes, outputDir string) (IngestAreaEstimate, error) {
+	if err := c.ValidateArea(ctx, &area); err != nil {
+		return IngestAreaEstimate{}, fmt.Errorf("EstimateArea.%w", err)
+	}
+
+	// Ask OneAtlas for the price of the order
+	switch common.GetConstellationFromString(area.SceneType.Constellation) {
+	case common.PHR, common.SPOT:
+		area.SceneType.Parameters = maps.Clone(area.SceneType.Parameters)
+		if area.SceneType.Parameters == nil {
+			area.SceneType.Parameters = map[string]string{}
+		}
+		area.SceneType.Parameters["GetPrice"] = "true"
+	}
+
+	scenes, _, err := c.inventory(ctx, area, scenes, scenesWithTiles, outputDir)
+	if err != nil {
+		return IngestAreaEstimate{}, fmt.Errorf("EstimateArea.%w", err)
+	}
+
+	var stats []db.GraphStats
+	var warnings []string
+	if c.Workflow == nil {
+		warnings = append(warnings, "workflow server is not defined: storage and processing time cannot be estimated")
+	} else if stats, err = c.Workflow.GraphsStats(ctx); err != nil {
+		log.Logger(ctx).Sugar().Warnf("EstimateArea: %v", err)
+		warnings = append(warnings, fmt.Sprintf("storage and processing time cannot be estimated: %v", err))
+	}
+
+	estimate := estimateArea(area, scenes.Scenes, stats)
+	estimate.Warnings = append(warnings, estimate.Warnings...)
+	return estimate, nil
+}
+
+// estimateArea estimates the ingestion of the scenes (and their tiles) of the area, given the statistics of the previous processings
+func estimateArea(area entities.AreaToIngest, scenes []*entities.Scene, stats []db.GraphStats) IngestAreaEstimate {
+	estimate := IngestAreaEstimate{ScenesNb: len(scenes), StorageSize: map[string]int64{}}
+	for _, scene := range scenes {
+		estimate.TilesNb += len(scene.Tiles)
+		if size, ok := metadataSize(scene.Data.Metadata[common.SizeMetadata]); ok {
+			estimate.DownloadSize += size
+		} else {
+			estimate.UnknownSizeNb++
+		}
+		for _, tag := range []string{"estimatedCost", "EstimatedCost"} {
+			if price, err := strconv.Atoi(scene.Tags[tag]); err == nil {
+				estimate.Price += price
+				estimate.PriceUnit = scene.Tags["amountUnit"]
+			}
+		}
+	}
+
+	graphStats := map[string]db.GraphStats{}
+	for _, s := range stats {
+		graphStats[s.Type+"/"+s.Graph] = s
+	}
+	for _, g := range []GraphEstimate{
+		{Type: common.ResultTypeScene, Graph: area.SceneGraphName, Nb: estimate.ScenesNb},
+		{Type: common.ResultTypeTile, Graph: area.TileGraphName, Nb: estimate.TilesNb},
+	} {
+		s, ok := graphStats[g.Type+"/"+g.Graph]
+		if ok && s.Count > 0 {
+			g.History, g.Hours = s.Count, float64(g.Nb)*s.Duration/3600
+		} else if g.Nb > 0 {
+			estimate.Warnings = append(estimate.Warnings, fmt.Sprintf("no history for the %s graph %s: processing time is unknown", g.Type, g.Graph))
+		}
+		if g.Type == common.ResultTypeTile {
+			if len(s.Layers) == 0 && g.Nb > 0 {
+				estimate.Warnings = append(estimate.Warnings, fmt.Sprintf("no history of the outputs of the tile graph %s: storage is unknown", g.Graph))
+			}
+			for layer, size := range s.Layers {
+				estimate.StorageSize[layer] += int64(g.Nb) * size
+			}
+		}
+		estimate.Processing = append(estimate.Processing, g)
+	}
+	sort.Strings(estimate.Warnings)
+	return estimate
+}
+
+// metadataSize returns the size of the product, provided by the catalogue (int64, or float64 if the scene has been loaded from json)
+func metadataSize(v interface{}) (int64, bool) {
+	switch size := v.(type) {
+	case int64:
+		return size, true
+	case float64:
+		return int64(size), true
+	}
+	return 0, false
+}
@@ -0,0 +1,57 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+)
+
+func TestEstimateArea(t *testing.T) {
+	newScene := func(size interface{}, tilesNb int, tags map[string]string) *entities.Scene {
+		s := &entities.Scene{Tags: tags, Tiles: make([]*entities.Tile, tilesNb)}
+		s.Data.Metadata = map[string]interface{}{}
+		if size != nil {
+			s.Data.Metadata[common.SizeMetadata] = size
+		}
+		return s
+	}
+	area := entities.AreaToIngest{SceneGraphName: "S1Preprocessing", TileGraphName: "S1BackscatterCoherence"}
+	scenes := []*entities.Scene{
+		newScene(int64(1000), 3, map[string]string{"estimatedCost": "10", "amountUnit": "kB"}),
+		newScene(float64(500), 2, map[string]string{"EstimatedCost": "5", "amountUnit": "kB"}),
+		newScene(nil, 1, nil),
+	}
+	stats := []db.GraphStats{
+		{Type: common.ResultTypeScene, Graph: "S1Preprocessing", Count: 4, Duration: 1800},
+		{Type: common.ResultTypeTile, Graph: "S1BackscatterCoherence", Count: 10, Duration: 3600, Layers: map[string]int64{"sigma0_VV": 100, "coh_VV": 50}},
+		{Type: common.ResultTypeTile, Graph: "Other", Count: 10, Duration: 60},
+	}
+
+	e := estimateArea(area, scenes, stats)
+	if e.ScenesNb != 3 || e.TilesNb != 6 {
+		t.Errorf("expecting 3 scenes and 6 tiles, got %d and %d", e.ScenesNb, e.TilesNb)
+	}
+	if e.DownloadSize != 1500 || e.UnknownSizeNb != 1 {
+		t.Errorf("expecting 1500 bytes to download and 1 unknown size, got %d and %d", e.DownloadSize, e.UnknownSizeNb)
+	}
+	if e.Price != 15 || e.PriceUnit != "kB" {
+		t.Errorf("expecting a price of 15 kB, got %d %s", e.Price, e.PriceUnit)
+	}
+	if e.StorageSize["sigma0_VV"] != 600 || e.StorageSize["coh_VV"] != 300 || len(e.StorageSize) != 2 {
+		t.Errorf("expecting the storage of 2 layers, got %v", e.StorageSize)
+	}
+	if len(e.Processing) != 2 || e.Processing[0].Hours != 1.5 || e.Processing[1].Hours != 6 || e.Processing[1].History != 10 {
+		t.Errorf("expecting 1.5 hours of scene processing and 6 hours of tile processing, got %v", e.Processing)
+	}
+	if len(e.Warnings) != 0 {
+		t.Errorf("expecting no warning, got %v", e.Warnings)
+	}
+
+	// Without history
+	e = estimateArea(area, scenes, nil)
+	if len(e.Warnings) != 3 || e.Processing[0].Hours != 0 || e.Processing[1].History != 0 {
+		t.Errorf("expecting unknown processing times and storage, got %v", e)
+	}
+}
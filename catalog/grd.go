@@ -0,0 +1,291 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkt"
+	"github.com/paulsmith/gogeos/geos"
+)
+
+const defaultGRDFrameHeight = 1. // degrees of latitude
+
+// grdStackID returns the identifier of the stack of the GRD tiles of the scene: <orbit direction><relative orbit> (e.g. A044)
+func grdStackID(scene *entities.Scene) (string, error) {
+	direction := strings.ToUpper(scene.Tags[common.TagOrbitDirection])
+	if direction == "" {
+		return "", fmt.Errorf("grdStackID[%s]: orbit direction is missing", scene.SourceID)
+	}
+	relativeOrbit, err := strconv.Atoi(scene.Tags[common.TagRelativeOrbit])
+	if err != nil {
+		return "", fmt.Errorf("grdStackID[%s]: wrong relative orbit: %w", scene.SourceID, err)
+	}
+	return fmt.Sprintf("%s%03d", direction[0:1], relativeOrbit), nil
+}
+
+// grdTile is a tile of a GRD scene
+type grdTile struct {
+	scene *entities.Scene
+	tile  *entities.Tile
+}
+
+// GRDInventory creates the tiles of Sentinel-1 GRD scenes intersecting the AOI, according to the tiling:
+// - GRDTilingSlice: one tile per scene, identified by its stack (<orbit direction><relative orbit>)
+// - GRDTilingFrame: one tile per frame (<stack>_F<frame>) intersecting the AOI. A frame is the intersection of the footprint with a latitude band.
+// If a frame is covered by several scenes of the same date (at the boundary of two slices), each scene has its own tile of the frame,
+// so that the frame is fully covered. GRDSort links each of them to the previous tile acquired at the same time of the orbit.
+// Returns the number of tiles
+func (c *Catalog) GRDInventory(ctx context.Context, tiling entities.GRDTiling, aoi geos.Geometry, scenes []*entities.Scene) (int, error) {
+	paoi := aoi.Prepare()
+	candidates, err := grdTiles(tiling, scenes, func(frameWKT string) (bool, error) {
+		frame, err := geos.FromWKT(frameWKT)
+		if err != nil {
+			return false, fmt.Errorf("FromWKT: %w", err)
+		}
+		return paoi.Intersects(frame)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("GRDInventory.%w", err)
+	}
+
+	// Add tiles to scenes
+	for _, candidate := range candidates {
+		scene := candidate.scene
+		scene.Tiles = append(scene.Tiles, candidate.tile)
+		if scene.Data.TileMappings == nil {
+			scene.Data.TileMappings = map[string]common.TileMapping{}
+		}
+		scene.Data.TileMappings[candidate.tile.SourceID] = common.TileMapping{GeometryWKT: candidate.tile.GeometryWKT}
+	}
+	log.Logger(ctx).Sugar().Debugf("Found %d GRD tiles (%s) intersecting aoi", len(candidates), tiling.Mode)
+	return len(candidates), nil
+}
+
+// grdTiles returns the tiles of the scenes according to the tiling (see GRDInventory).
+// In frame mode, only the frames intersecting the aoi are returned.
+func grdTiles(tiling entities.GRDTiling, scenes []*entities.Scene, intersectsAOI func(frameWKT string) (bool, error)) ([]grdTile, error) {
+	frameHeight := tiling.FrameHeight
+	if frameHeight == 0 {
+		frameHeight = defaultGRDFrameHeight
+	}
+
+	var tiles []grdTile
+	for _, scene := range scenes {
+		stackID, err := grdStackID(scene)
+		if err != nil {
+			return nil, err
+		}
+		if tiling.Mode != entities.GRDTilingFrame {
+			tiles = append(tiles, grdTile{scene: scene, tile: newGRDTile(scene, stackID, scene.GeometryWKT)})
+			continue
+		}
+
+		g, err := wkt.DecodeString(scene.GeometryWKT)
+		if err != nil {
+			return nil, fmt.Errorf("DecodeString[%s]: %w", scene.SourceID, err)
+		}
+		extent, err := geom.NewExtentFromGeometry(g)
+		if err != nil {
+			return nil, fmt.Errorf("NewExtentFromGeometry[%s]: %w", scene.SourceID, err)
+		}
+		for k := int(math.Floor((extent.MinY() + 90) / frameHeight)); float64(k)*frameHeight-90 < extent.MaxY(); k++ {
+			frame, err := clipLatitudes(g, float64(k)*frameHeight-90, float64(k+1)*frameHeight-90)
+			if err != nil {
+				return nil, fmt.Errorf("clipLatitudes[%s]: %w", scene.SourceID, err)
+			}
+			if len(frame) == 0 {
+				continue
+			}
+			frameWKT, err := wkt.EncodeString(frame)
+			if err != nil {
+				return nil, fmt.Errorf("EncodeString[%s]: %w", scene.SourceID, err)
+			}
+			if intersect, err := intersectsAOI(frameWKT); err != nil {
+				return nil, fmt.Errorf("Intersects[%s]: %w", scene.SourceID, err)
+			} else if !intersect {
+				continue
+			}
+			tiles = append(tiles, grdTile{scene: scene, tile: newGRDTile(scene, fmt.Sprintf("%s_F%04d", stackID, k), frameWKT)})
+		}
+	}
+	return tiles, nil
+}
+
+// clipLatitudes returns the part of the polygon or multipolygon between the two latitudes (empty if they do not intersect)
+func clipLatitudes(g geom.Geometry, minLat, maxLat float64) (geom.MultiPolygon, error) {
+	var polygons [][][][2]float64
+	switch g := g.(type) {
+	case geom.Polygon:
+		polygons = [][][][2]float64{g}
+	case geom.MultiPolygon:
+		polygons = g
+	default:
+		return nil, fmt.Errorf("unsupported geometry %T", g)
+	}
+	var clipped geom.MultiPolygon
+	for _, polygon := range polygons {
+		var rings [][][2]float64
+		for i, ring := range polygon {
+			ring = clipRing(clipRing(ring, func(p [2]float64) float64 { return p[1] - minLat }), func(p [2]float64) float64 { return maxLat - p[1] })
+			if len(ring) < 3 || ringArea(ring) == 0 {
+				if i == 0 {
+					break // The exterior ring is outside the band
+				}
+				continue
+			}
+			rings = append(rings, append(ring, ring[0]))
+		}
+		if len(rings) > 0 {
+			clipped = append(clipped, rings)
+		}
+	}
+	return clipped, nil
+}
+
+// ringArea returns the (unsigned) area of a ring
+func ringArea(ring [][2]float64) float64 {
+	area := 0.
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		area += p[0]*q[1] - q[0]*p[1]
+	}
+	return math.Abs(area) / 2
+}
+
+// clipRing clips the ring with the half-plane where inside(p) >= 0 (Sutherland-Hodgman). The returned ring is not closed.
+func clipRing(ring [][2]float64, inside func(p [2]float64) float64) [][2]float64 {
+	if n := len(ring); n > 1 && ring[0] == ring[n-1] {
+		ring = ring[:n-1]
+	}
+	var clipped [][2]float64
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		dp, dq := inside(p), inside(q)
+		if dp >= 0 {
+			clipped = append(clipped, p)
+		}
+		if (dp >= 0) != (dq >= 0) {
+			t := dp / (dp - dq)
+			clipped = append(clipped, [2]float64{p[0] + t*(q[0]-p[0]), p[1] + t*(q[1]-p[1])})
+		}
+	}
+	return clipped
+}
+
+func newGRDTile(scene *entities.Scene, sourceID, geometryWKT string) *entities.Tile {
+	return &entities.Tile{
+		TileLite: entities.TileLite{
+			SourceID: sourceID,
+			SceneID:  scene.SourceID,
+			Date:     scene.Data.Date,
+		},
+		GeometryWKT: geometryWKT,
+	}
+}
+
+// sceneTimeOfDay returns the start and stop time (seconds in the day) of the acquisition of a Sentinel-1 scene
+func sceneTimeOfDay(sceneID string) (float64, float64, error) {
+	info, err := common.Info(sceneID)
+	if err != nil {
+		return 0, 0, err
+	}
+	start, err := time.Parse("20060102T150405", info["DATE"]+"T"+info["TIME"])
+	if err != nil {
+		return 0, 0, err
+	}
+	stop, err := time.Parse("20060102T150405", sceneID[33:48])
+	if err != nil {
+		return 0, 0, err
+	}
+	startTOD := float64(start.Hour()*3600 + start.Minute()*60 + start.Second())
+	return startTOD, startTOD + stop.Sub(start).Seconds(), nil
+}
+
+// timeOfDayOverlap returns the overlap (in seconds) between the acquisitions of two scenes, regardless of the date
+func timeOfDayOverlap(sceneID1, sceneID2 string) float64 {
+	start1, stop1, err1 := sceneTimeOfDay(sceneID1)
+	start2, stop2, err2 := sceneTimeOfDay(sceneID2)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	// Acquisitions around midnight
+	if start2-start1 > 12*3600 {
+		start2, stop2 = start2-24*3600, stop2-24*3600
+	} else if start1-start2 > 12*3600 {
+		start2, stop2 = start2+24*3600, stop2+24*3600
+	}
+	return math.Min(stop1, stop2) - math.Max(start1, start2)
+}
+
+// GRDSort links each new GRD tile to its previous tile: the tile of the same stack (same identifier) acquired at the latest previous date,
+// that overlaps it the most (time of acquisition in the orbit). The ingested tiles can only be the previous tile if they are leaves.
+// GRD tiles have no reference. If windows are defined, the previous tile must be in the same window.
+// Returns the network of pairs of each stack
+func (c *Catalog) GRDSort(ctx context.Context, scenes []*entities.Scene, windows entities.TimeWindows) []entities.PairNetwork {
+	stacks := map[string][]*entities.Tile{}
+	var stackIDs []string
+	for _, scene := range scenes {
+		for _, tile := range scene.Tiles {
+			if _, ok := stacks[tile.SourceID]; !ok {
+				stackIDs = append(stackIDs, tile.SourceID)
+			}
+			stacks[tile.SourceID] = append(stacks[tile.SourceID], tile)
+		}
+	}
+	sort.Strings(stackIDs)
+
+	var networks []entities.PairNetwork
+	for _, stackID := range stackIDs {
+		networks = append(networks, grdStackSort(stacks[stackID], windows))
+	}
+	log.Logger(ctx).Sugar().Debugf("%d stacks of GRD tiles", len(networks))
+	return networks
+}
+
+// grdStackSort links the tiles of a stack to their previous tile (in the same window, if windows are defined)
+func grdStackSort(tiles []*entities.Tile, windows entities.TimeWindows) entities.PairNetwork {
+	sort.SliceStable(tiles, func(i, j int) bool { return tiles[i].Date.Before(tiles[j].Date) })
+	network := entities.PairNetwork{StackID: tiles[0].SourceID, TrackSwath: strings.Split(tiles[0].SourceID, "_")[0]}
+
+	for i, tile := range tiles {
+		if tile.Ingested {
+			continue
+		}
+		previous, overlap := -1, 0.
+		for j := i - 1; j >= 0; j-- {
+			prev := tiles[j]
+			if len(windows) > 0 && (windows.Index(tile.Date) < 0 || windows.Index(prev.Date) != windows.Index(tile.Date)) {
+				break
+			}
+			if tile.Date.Sub(prev.Date) < 12*time.Hour || (prev.Ingested && !prev.Leaf) {
+				continue
+			}
+			if previous != -1 && tiles[previous].Date.Sub(prev.Date) > 12*time.Hour {
+				break // Only the latest previous date
+			}
+			if o := timeOfDayOverlap(tile.SceneID, prev.SceneID); o > overlap {
+				previous, overlap = j, o
+			}
+		}
+		if previous == -1 {
+			network.Unpaired = append(network.Unpaired, tile.TileLite)
+			continue
+		}
+		tile.Previous = &tiles[previous].TileLite
+		network.Pairs = append(network.Pairs, entities.Pair{
+			Tile:         tile.TileLite,
+			Previous:     *tile.Previous,
+			BaselineDays: int(math.Round(tile.Date.Sub(tile.Previous.Date).Hours() / 24)),
+		})
+	}
+	return network
+}
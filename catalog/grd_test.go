@@ -0,0 +1,174 @@
+package catalog
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkt"
+)
+
+// newTestGRDTile creates a GRD tile of the stack A044 acquired at the given day after 2020-01-01 and time of day
+func newTestGRDTile(day int, tod string, duration time.Duration) *entities.Tile {
+	start, _ := time.Parse("20060102T150405", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day).Format("20060102")+"T"+tod)
+	sceneID := "S1A_IW_GRDH_1SDV_" + start.Format("20060102T150405") + "_" + start.Add(duration).Format("20060102T150405") + "_030000_036000_ABCD"
+	return &entities.Tile{TileLite: entities.TileLite{SourceID: "A044", SceneID: sceneID, Date: start}}
+}
+
+func TestGRDStackID(t *testing.T) {
+	scene := &entities.Scene{Tags: map[string]string{common.TagOrbitDirection: "ascending", common.TagRelativeOrbit: "44"}}
+	if stackID, err := grdStackID(scene); err != nil || stackID != "A044" {
+		t.Errorf("expecting A044, got %s (%v)", stackID, err)
+	}
+	delete(scene.Tags, common.TagRelativeOrbit)
+	if _, err := grdStackID(scene); err == nil {
+		t.Error("expecting an error if the relative orbit is missing")
+	}
+}
+
+func TestGRDStackSort(t *testing.T) {
+	ingested := newTestGRDTile(0, "060000", 25*time.Second)
+	ingested.Ingested, ingested.Leaf = true, true
+	notLeaf := newTestGRDTile(0, "060025", 25*time.Second)
+	notLeaf.Ingested = true
+	tiles := []*entities.Tile{
+		newTestGRDTile(12, "060020", 25*time.Second),
+		newTestGRDTile(12, "060045", 25*time.Second),
+		newTestGRDTile(24, "060040", 25*time.Second),
+		notLeaf,
+		ingested,
+	}
+	network := grdStackSort(tiles, nil)
+
+	// tiles are sorted by date
+	if tiles[2].Previous == nil || tiles[2].Previous.SceneID != ingested.SceneID {
+		t.Errorf("expecting previous %s, got %v", ingested.SceneID, tiles[2].Previous)
+	}
+	// The ingested tile is not a leaf and the other one does not overlap
+	if tiles[3].Previous != nil {
+		t.Errorf("expecting no previous, got %v", tiles[3].Previous)
+	}
+	// Largest overlap with the latest previous date
+	if tiles[4].Previous == nil || tiles[4].Previous.SceneID != tiles[3].SceneID {
+		t.Errorf("expecting previous %s, got %v", tiles[3].SceneID, tiles[4].Previous)
+	}
+	if len(network.Pairs) != 2 || network.Pairs[1].BaselineDays != 12 || len(network.Unpaired) != 1 || network.TrackSwath != "A044" {
+		t.Errorf("expecting 2 pairs with a baseline of 12 days and 1 unpaired tile, got %v", network)
+	}
+}
+
+// newTestGRDScene creates a GRD scene of the stack A044 acquired at the given day after 2020-01-01 and time of day, with a footprint between two latitudes
+func newTestGRDScene(day int, tod string, minLat, maxLat float64) *entities.Scene {
+	tile := newTestGRDTile(day, tod, 25*time.Second)
+	return &entities.Scene{
+		Scene:       common.Scene{SourceID: tile.SceneID, Data: common.SceneAttrs{Date: tile.Date}},
+		Tags:        map[string]string{common.TagOrbitDirection: "ascending", common.TagRelativeOrbit: "44"},
+		GeometryWKT: fmt.Sprintf("POLYGON((1 %[1]f,3 %[1]f,3.5 %[2]f,1.5 %[2]f,1 %[1]f))", minLat, maxLat),
+	}
+}
+
+func TestGRDTilesFrames(t *testing.T) {
+	// Two consecutive slices of two dates, straddling the frame 44°-45° (F0134)
+	scenes := []*entities.Scene{
+		newTestGRDScene(0, "060000", 43.2, 44.6),
+		newTestGRDScene(0, "060025", 44.5, 45.9),
+		newTestGRDScene(12, "060001", 43.2, 44.6),
+		newTestGRDScene(12, "060026", 44.5, 45.9),
+	}
+	everywhere := func(string) (bool, error) { return true, nil }
+	candidates, err := grdTiles(entities.GRDTiling{Mode: entities.GRDTilingFrame}, scenes, everywhere)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := map[string][]string{}
+	for _, candidate := range candidates {
+		frames[candidate.scene.SourceID] = append(frames[candidate.scene.SourceID], candidate.tile.SourceID)
+	}
+	for i, expected := range [][]string{{"A044_F0133", "A044_F0134"}, {"A044_F0134", "A044_F0135"}} {
+		for _, scene := range []*entities.Scene{scenes[i], scenes[i+2]} {
+			if !reflect.DeepEqual(frames[scene.SourceID], expected) {
+				t.Errorf("%s: expecting frames %v, got %v", scene.SourceID, expected, frames[scene.SourceID])
+			}
+		}
+	}
+
+	// The part of the frame in each slice is kept
+	for _, candidate := range candidates {
+		if candidate.tile.SourceID != "A044_F0134" {
+			continue
+		}
+		g, err := wkt.DecodeString(candidate.tile.GeometryWKT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		extent, _ := geom.NewExtentFromGeometry(g)
+		minLat, maxLat := 44.5, 45.
+		if candidate.scene == scenes[0] || candidate.scene == scenes[2] {
+			minLat, maxLat = 44., 44.6
+		}
+		if math.Abs(extent.MinY()-minLat) > 1e-9 || math.Abs(extent.MaxY()-maxLat) > 1e-9 {
+			t.Errorf("%s: expecting the frame between %f and %f, got %v", candidate.scene.SourceID, minLat, maxLat, extent)
+		}
+	}
+
+	// Each frame is linked to the frame of the same slice
+	var tiles []*entities.Tile
+	for _, candidate := range candidates {
+		if candidate.tile.SourceID == "A044_F0134" {
+			tiles = append(tiles, candidate.tile)
+		}
+	}
+	network := grdStackSort(tiles, nil)
+	if len(network.Pairs) != 2 {
+		t.Fatalf("expecting 2 pairs, got %v", network)
+	}
+	previous := map[string]string{scenes[2].SourceID: scenes[0].SourceID, scenes[3].SourceID: scenes[1].SourceID}
+	for _, pair := range network.Pairs {
+		if pair.Previous.SceneID != previous[pair.Tile.SceneID] {
+			t.Errorf("%s: expecting previous %s, got %s", pair.Tile.SceneID, previous[pair.Tile.SceneID], pair.Previous.SceneID)
+		}
+	}
+
+	// Frames outside the aoi
+	candidates, err = grdTiles(entities.GRDTiling{Mode: entities.GRDTilingFrame, FrameHeight: 2}, scenes[:1], func(string) (bool, error) { return false, nil })
+	if err != nil || len(candidates) != 0 {
+		t.Errorf("expecting no frame, got %d (%v)", len(candidates), err)
+	}
+}
+
+func TestClipLatitudes(t *testing.T) {
+	square := geom.Polygon{{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}}
+	for _, c := range []struct {
+		minLat, maxLat float64
+		expected       float64 // area
+	}{
+		{0.5, 1.5, 2},
+		{-1, 3, 4},
+		{1.5, 3, 1},
+		{2, 3, 0}, // Only the boundary
+		{3, 4, 0},
+	} {
+		clipped, err := clipLatitudes(square, c.minLat, c.maxLat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.expected == 0 {
+			if len(clipped) != 0 {
+				t.Errorf("[%f, %f]: expecting an empty geometry, got %v", c.minLat, c.maxLat, clipped)
+			}
+			continue
+		}
+		if len(clipped) != 1 || len(clipped[0]) != 1 || clipped[0][0][0] != clipped[0][0][len(clipped[0][0])-1] {
+			t.Fatalf("[%f, %f]: expecting a closed polygon, got %v", c.minLat, c.maxLat, clipped)
+		}
+		extent, _ := geom.NewExtentFromGeometry(clipped)
+		if area := ringArea(clipped[0][0]); area != c.expected || extent.MinY() != math.Max(c.minLat, 0) || extent.MaxY() != math.Min(c.maxLat, 2) {
+			t.Errorf("[%f, %f]: expecting an area of %f, got %f (%v)", c.minLat, c.maxLat, c.expected, area, extent)
+		}
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/http"
 	"strconv"
-	"time"
 
 	catalog "github.com/airbusgeo/geocube-ingester/catalog/entities"
 	"github.com/airbusgeo/geocube-ingester/common"
@@ -23,13 +22,19 @@ const scenesJSONField = "scenes"
 const tilesJSONField = "tiles"
 const pageField = "page"
 const limitField = "limit"
+const dryRunField = "dry_run"
 
 func (c *Catalog) AddHandler(r *mux.Router) {
 	r.HandleFunc("/catalog/scenes", c.ScenesHandler).Methods("GET")
 	r.HandleFunc("/catalog/tiles", c.TilesHandler).Methods("GET")
 	r.HandleFunc("/catalog/scenes", c.ScenesHandler).Methods("POST")
 	r.HandleFunc("/catalog/tiles", c.TilesHandler).Methods("POST")
+	c.initJobs()
 	r.HandleFunc("/catalog/aoi", c.PostAOIHandler).Methods("POST")
+	r.HandleFunc("/catalog/jobs", c.ListJobsHandler).Methods("GET")
+	r.HandleFunc("/catalog/jobs/{job}", c.GetJobHandler).Methods("GET")
+	r.HandleFunc("/catalog/jobs/{job}/cancel", c.CancelJobHandler).Methods("PUT")
+	r.HandleFunc("/catalog/layers", c.ProvisionLayersHandler).Methods("POST")
 }
 
 func readField(req *http.Request, field string) ([]byte, error) {
@@ -48,24 +53,45 @@ func readField(req *http.Request, field string) ([]byte, error) {
 }
 
 func (c *Catalog) loadArea(req *http.Request) (catalog.AreaToIngest, error) {
-	area := catalog.AreaToIngest{}
-	areaJSON, err := readField(req, areaJSONField)
+	areaJSON, page, limit, err := readArea(req)
+	if err != nil {
+		return catalog.AreaToIngest{}, err
+	}
+	area, err := parseArea(areaJSON, page, limit)
 	if err != nil {
 		return area, err
 	}
+	if err := c.LoadAOI(req.Context(), &area); err != nil {
+		return area, fmt.Errorf("loadArea.%w", err)
+	}
+	return area, nil
+}
+
+// readArea reads the area field and the page and limit query parameters
+func readArea(req *http.Request) ([]byte, int, int, error) {
+	areaJSON, err := readField(req, areaJSONField)
+	if err != nil {
+		return nil, 0, 0, err
+	}
 	if len(areaJSON) == 0 {
-		return area, fmt.Errorf("loadArea: missing required field: '%s' (application/json)", areaJSONField)
+		return nil, 0, 0, fmt.Errorf("loadArea: missing required field: '%s' (application/json)", areaJSONField)
 	}
+	page, _ := strconv.Atoi(req.URL.Query().Get(pageField))
+	limit, _ := strconv.Atoi(req.URL.Query().Get(limitField))
+	return areaJSON, page, limit, nil
+}
+
+func parseArea(areaJSON []byte, page, limit int) (catalog.AreaToIngest, error) {
+	area := catalog.AreaToIngest{}
 	if err := json.Unmarshal(areaJSON, &area); err != nil {
 		return area, fmt.Errorf("loadArea: %w\nJSON:\n%s", err, areaJSON)
 	}
-	if page, err := strconv.Atoi(req.URL.Query().Get(pageField)); err == nil {
+	if page != 0 {
 		area.Page = page
 	}
-	if limit, err := strconv.Atoi(req.URL.Query().Get(limitField)); err == nil {
+	if limit != 0 {
 		area.Limit = limit
 	}
-
 	return area, nil
 }
 
@@ -99,25 +125,22 @@ func loadScenes(w http.ResponseWriter, req *http.Request, field string, ignore_e
 	return scenes, nil
 }
 
-func (c *Catalog) FindTiles(ctx context.Context, area catalog.AreaToIngest, scenes catalog.Scenes) (int, error) {
-	var rootLeafTiles []common.Tile
+func (c *Catalog) FindTiles(ctx context.Context, area catalog.AreaToIngest, scenes *catalog.Scenes) (int, error) {
+	var rootTiles, leafTiles []common.Tile
 	var err error
-	switch common.GetConstellationFromString(area.SceneType.Constellation) {
-	case common.Sentinel1:
+	if common.GetConstellationFromString(area.SceneType.Constellation) == common.Sentinel1 || area.OpticalLinkage.Enabled() {
 		if c.Workflow == nil {
 			return 0, fmt.Errorf("FindTiles: WorkflowServer is not defined")
 		}
-		if rootLeafTiles, err = c.Workflow.RootTiles(ctx, area.AOIID); err != nil {
+		if rootTiles, err = c.Workflow.RootTiles(ctx, area.AOIID); err != nil {
 			return 0, err
 		}
-		leafTiles, err := c.Workflow.LeafTiles(ctx, area.AOIID)
-		if err != nil {
+		if leafTiles, err = c.Workflow.LeafTiles(ctx, area.AOIID); err != nil {
 			return 0, err
 		}
-		rootLeafTiles = append(rootLeafTiles, leafTiles...)
 	}
 
-	tileNb, err := c.DoTilesInventory(ctx, area, scenes, rootLeafTiles)
+	tileNb, err := c.DoTilesInventory(ctx, area, scenes, rootTiles, leafTiles)
 	if err != nil {
 		return 0, err
 	}
@@ -134,6 +157,11 @@ func (c Catalog) PostScenes(ctx context.Context, area catalog.AreaToIngest, scen
 	if err := c.Workflow.CreateAOI(ctx, area.AOIID); err != nil && !errors.As(err, &db.ErrAlreadyExists{}) {
 		return nil, fmt.Errorf("postScenes.%w", err)
 	}
+	if len(area.ConsolidationLayouts) > 0 {
+		if err := c.Workflow.SetAOIConsolidationLayouts(ctx, area.AOIID, area.ConsolidationLayouts); err != nil {
+			return nil, fmt.Errorf("postScenes.%w", err)
+		}
+	}
 
 	// Then, create scenes
 	ids, err := c.Workflow.IngestScenes(ctx, area.AOIID, scenesToIngest...)
@@ -186,7 +214,7 @@ func (c Catalog) TilesHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if _, err = c.FindTiles(ctx, area, scenes); err != nil {
+	if _, err = c.FindTiles(ctx, area, &scenes); err != nil {
 		log.Logger(ctx).Sugar().Warnf("wf.TilesHandler: %v", err)
 		w.WriteHeader(500)
 		fmt.Fprintf(w, "%v", err)
@@ -200,39 +228,143 @@ func (c Catalog) TilesHandler(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// PostAOIHandler posts a request to ingest scenes
+// readDryRun reads the optional dry_run field of the request
+func readDryRun(req *http.Request) (bool, error) {
+	v := req.FormValue(dryRunField)
+	if v == "" {
+		return false, nil
+	}
+	dryRun, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", dryRunField, err)
+	}
+	return dryRun, nil
+}
+
+// PostAOIHandler starts a catalog job to ingest scenes (or only to estimate the ingestion if dry_run=true)
+// and returns the job (see GetJobHandler to follow its progress and get the result)
 func (c Catalog) PostAOIHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
-	area, err := c.loadArea(req)
+	var input jobInput
+	var err error
+	if input.Area, input.Page, input.Limit, err = readArea(req); err == nil {
+		// Tiles or scenes (optional)
+		input.Tiles, _ = readField(req, tilesJSONField)
+		if len(input.Tiles) == 0 {
+			input.Scenes, _ = readField(req, scenesJSONField)
+		}
+	}
+	var area catalog.AreaToIngest
+	if err == nil {
+		input.DryRun, err = readDryRun(req)
+	}
+	if err == nil {
+		if area, _, _, err = input.decode(); err == nil {
+			err = c.ValidateArea(ctx, &area)
+		}
+	}
+	if err == nil {
+		// The AOI is loaded once: the job uses the geometry instead of the vector file
+		input.Area, err = embedAOI(input.Area, area.AOI)
+	}
 	if err != nil {
 		w.WriteHeader(400)
 		fmt.Fprintf(w, "%v", err)
 		return
 	}
 
-	var scenes, tiles catalog.Scenes
-	// Try to load tiles
-	if tiles, err = loadScenes(w, req, tilesJSONField, true); errors.As(err, &EmptyError{}) {
-		// Or try to load scenes
-		if scenes, err = loadScenes(w, req, scenesJSONField, true); errors.As(err, &EmptyError{}) {
-			// Or do scene inventory
-			scenes, err = c.DoScenesInventory(ctx, area)
+	job, err := c.newJob(ctx, area.AOIID, input)
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.PostAOIHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+
+	w.WriteHeader(202)
+	json.NewEncoder(w).Encode(job)
+}
+
+// ListJobsHandler lists the catalog jobs (optionally filtered by aoi), from the most recent
+func (c Catalog) ListJobsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	jobs, err := c.jobs.backend.CatalogJobs(ctx, req.URL.Query().Get("aoi"))
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.ListJobsHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(jobs)
+}
+
+// GetJobHandler returns the status, the progress and the result of a catalog job
+func (c Catalog) GetJobHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	job, err := c.jobs.backend.CatalogJob(ctx, mux.Vars(req)["job"])
+	if err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
 		}
+		log.Logger(ctx).Sugar().Warnf("wf.GetJobHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
 	}
+	json.NewEncoder(w).Encode(job)
+}
 
-	t := time.Now()
-	var result IngestAreaResult
-	if err == nil {
-		result, err = c.IngestArea(ctx, area, scenes, tiles, "")
+// CancelJobHandler cancels a catalog job (no effect if the job is finished) and returns the job
+func (c Catalog) CancelJobHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	job, err := c.cancelJob(ctx, mux.Vars(req)["job"])
+	if err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.CancelJobHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
 	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// ProvisionLayersHandler creates the variables and the instances of the layers of the area that do not exist (or only lists them if dry_run=true)
+func (c Catalog) ProvisionLayersHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	area, err := c.loadArea(req)
 	if err != nil {
-		log.Logger(ctx).Sugar().Warnf("wf.PostAOIHandler: %v", err)
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	dryRun, err := readDryRun(req)
+	if err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+
+	actions, err := c.ProvisionLayers(ctx, &area, dryRun)
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.ProvisionLayersHandler: %v", err)
 		w.WriteHeader(500)
 		fmt.Fprintf(w, "%v", err)
 		return
 	}
 
-	w.WriteHeader(200)
-	fmt.Fprintf(w, "Ingestion of %d scenes and %d tiles in progress (%v)\n", len(result.ScenesID), result.TilesNb, time.Since(t))
+	instances := area.InstancesID()
+	if err := json.NewEncoder(w).Encode(struct {
+		Actions   []string          `json:"actions"`
+		Instances map[string]string `json:"instances"`
+	}{Actions: actions, Instances: instances}); err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.ProvisionLayersHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+	}
 }
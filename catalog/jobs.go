@@ -0,0 +1,358 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+	"github.com/google/uuid"
+)
+
+const (
+	// A running job is saved every jobHeartbeat
+	jobHeartbeat = 10 * time.Second
+	// A job that is not finished and has not been saved for jobTimeout is considered as interrupted
+	jobTimeout = 6 * jobHeartbeat
+)
+
+// Steps of a catalog job
+const (
+	jobStepScenes  = "scenes inventory"
+	jobStepTiles   = "tiles inventory"
+	jobStepRecords = "records creation"
+	jobStepPost    = "posting scenes"
+)
+
+// JobsBackend saves the catalog jobs (implemented by the workflow database, see db.WorkflowBackend)
+type JobsBackend interface {
+	CreateCatalogJob(ctx context.Context, job db.CatalogJob) error
+	CatalogJob(ctx context.Context, id string) (db.CatalogJob, error)
+	CatalogJobs(ctx context.Context, aoi string) ([]db.CatalogJob, error)
+	UpdateCatalogJob(ctx context.Context, job db.CatalogJob) error
+	ClaimCatalogJobs(ctx context.Context, notUpdatedSince, now time.Time) ([]db.CatalogJob, error)
+}
+
+// jobInput is the input of a catalog job (as posted to /catalog/aoi)
+type jobInput struct {
+	Area   json.RawMessage `json:"area"`
+	Page   int             `json:"page,omitempty"`
+	Limit  int             `json:"limit,omitempty"`
+	Scenes json.RawMessage `json:"scenes,omitempty"`
+	Tiles  json.RawMessage `json:"tiles,omitempty"`
+	DryRun bool            `json:"dry_run,omitempty"` // Only estimate the ingestion (see EstimateArea)
+}
+
+// decode returns the area, the scenes and the tiles of the input.
+// If the tiles are not defined, tiles.Scenes is nil. If the scenes are not defined, scenes.Scenes is nil.
+func (input jobInput) decode() (area entities.AreaToIngest, scenes, tiles entities.Scenes, err error) {
+	if area, err = parseArea(input.Area, input.Page, input.Limit); err != nil {
+		return
+	}
+	if len(input.Tiles) > 0 {
+		if err = json.Unmarshal(input.Tiles, &tiles); err != nil {
+			err = fmt.Errorf("decode.Tiles: %w", err)
+		}
+	} else if len(input.Scenes) > 0 {
+		if err = json.Unmarshal(input.Scenes, &scenes); err != nil {
+			err = fmt.Errorf("decode.Scenes: %w", err)
+		}
+	}
+	return
+}
+
+// jobProgress is the progress of a running job, shared through the context
+type jobProgress struct {
+	mu sync.Mutex
+	p  db.CatalogJobProgress
+}
+
+type jobProgressKey struct{}
+
+// withJobProgress returns a context that holds the progress of a job
+func withJobProgress(ctx context.Context, p *jobProgress) context.Context {
+	return context.WithValue(ctx, jobProgressKey{}, p)
+}
+
+// progress returns the progress of the job running in the context, or nil if the context is not a job (the methods of a nil progress do nothing)
+func progress(ctx context.Context) *jobProgress {
+	p, _ := ctx.Value(jobProgressKey{}).(*jobProgress)
+	return p
+}
+
+func (p *jobProgress) update(f func(p *db.CatalogJobProgress)) {
+	if p != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		f(&p.p)
+	}
+}
+
+func (p *jobProgress) step(step string) {
+	p.update(func(p *db.CatalogJobProgress) { p.Step = step })
+}
+
+func (p *jobProgress) setScenes(n int) {
+	p.update(func(p *db.CatalogJobProgress) { p.ScenesNb = n })
+}
+
+func (p *jobProgress) setTiles(n int) {
+	p.update(func(p *db.CatalogJobProgress) { p.TilesNb = n })
+}
+
+func (p *jobProgress) addAnnotations(n int) {
+	p.update(func(p *db.CatalogJobProgress) { p.AnnotationsNb += n })
+}
+
+func (p *jobProgress) addCachedScenes(n int) {
+	p.update(func(p *db.CatalogJobProgress) { p.CachedScenesNb += n })
+}
+
+func (p *jobProgress) addRecords(n int) {
+	p.update(func(p *db.CatalogJobProgress) { p.RecordsNb += n })
+}
+
+func (p *jobProgress) get() db.CatalogJobProgress {
+	var progress db.CatalogJobProgress
+	p.update(func(p *db.CatalogJobProgress) { progress = *p })
+	return progress
+}
+
+// jobs runs the catalog jobs in background
+type jobs struct {
+	backend JobsBackend
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc // Jobs running on this server
+}
+
+// initJobs initializes the runner of the catalog jobs. If c.Jobs is not defined, the jobs are only kept in memory.
+func (c *Catalog) initJobs() {
+	if c.jobs != nil {
+		return
+	}
+	if c.Jobs == nil {
+		c.Jobs = &memoryJobs{jobs: map[string]db.CatalogJob{}}
+	}
+	c.jobs = &jobs{backend: c.Jobs, cancels: map[string]context.CancelFunc{}}
+}
+
+// newJob creates a catalog job to ingest an area and starts it in background
+func (c Catalog) newJob(ctx context.Context, aoi string, input jobInput) (db.CatalogJob, error) {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return db.CatalogJob{}, fmt.Errorf("newJob.Marshal: %w", err)
+	}
+	now := time.Now()
+	job := db.CatalogJob{
+		ID:        uuid.New().String(),
+		AOI:       aoi,
+		Status:    db.CatalogJobPending,
+		Input:     inputJSON,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := c.jobs.backend.CreateCatalogJob(ctx, job); err != nil {
+		return db.CatalogJob{}, fmt.Errorf("newJob.%w", err)
+	}
+	c.startJob(log.CopyContext(ctx, context.Background()), job)
+	return job, nil
+}
+
+// cancelJob cancels a job that is not finished. May return db.ErrNotFound
+func (c Catalog) cancelJob(ctx context.Context, id string) (db.CatalogJob, error) {
+	job, err := c.jobs.backend.CatalogJob(ctx, id)
+	if err != nil {
+		return job, fmt.Errorf("cancelJob.%w", err)
+	}
+	if !job.Finished() {
+		job.Status, job.Message, job.UpdatedAt = db.CatalogJobCancelled, "cancelled by user", time.Now()
+		if err := c.jobs.backend.UpdateCatalogJob(ctx, job); err != nil && !errors.As(err, &db.ErrNotFound{}) {
+			return job, fmt.Errorf("cancelJob.%w", err)
+		}
+		// The job is running on this server: stop it now (otherwise, it will be stopped at its next heartbeat)
+		c.jobs.mu.Lock()
+		if cancel, ok := c.jobs.cancels[id]; ok {
+			cancel()
+		}
+		c.jobs.mu.Unlock()
+	}
+	return job, nil
+}
+
+// ResumeJobs resumes the jobs that have been interrupted (e.g. by a restart of the server), every period until the context is done
+// The jobs that are not finished and that have not been saved for a while are claimed and restarted from the beginning
+// (the scenes already ingested are skipped)
+func (c *Catalog) ResumeJobs(ctx context.Context, period time.Duration) {
+	c.initJobs()
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		jobs, err := c.jobs.backend.ClaimCatalogJobs(ctx, now.Add(-jobTimeout), now)
+		if err != nil {
+			log.Logger(ctx).Sugar().Errorf("ResumeJobs.%v", err)
+		}
+		for _, job := range jobs {
+			log.Logger(ctx).Sugar().Infof("resume catalog job %s (aoi: %s)", job.ID, job.AOI)
+			c.startJob(ctx, job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startJob runs the job in background, saves its progress every jobHeartbeat and its result at the end
+func (c Catalog) startJob(ctx context.Context, job db.CatalogJob) {
+	ctx, cancel := context.WithCancel(log.With(ctx, "job", job.ID))
+	c.jobs.mu.Lock()
+	c.jobs.cancels[job.ID] = cancel
+	c.jobs.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.jobs.mu.Lock()
+			delete(c.jobs.cancels, job.ID)
+			c.jobs.mu.Unlock()
+			cancel()
+		}()
+		progress := &jobProgress{}
+		ctx := withJobProgress(ctx, progress)
+
+		// Heartbeat
+		var wg sync.WaitGroup
+		done := make(chan struct{})
+		wg.Add(1)
+		go func(job db.CatalogJob) {
+			defer wg.Done()
+			ticker := time.NewTicker(jobHeartbeat)
+			defer ticker.Stop()
+			for {
+				job.Status, job.Progress, job.UpdatedAt = db.CatalogJobRunning, progress.get(), time.Now()
+				if err := c.jobs.backend.UpdateCatalogJob(ctx, job); errors.As(err, &db.ErrNotFound{}) {
+					// The job has been cancelled
+					cancel()
+					return
+				} else if err != nil {
+					log.Logger(ctx).Sugar().Warnf("catalog job: %v", err)
+				}
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+			}
+		}(job)
+
+		result, err := c.runJob(ctx, job)
+		close(done)
+		wg.Wait()
+
+		job.Status, job.Progress, job.UpdatedAt = db.CatalogJobDone, progress.get(), time.Now()
+		if err != nil {
+			job.Status, job.Message = db.CatalogJobFailed, err.Error()
+			log.Logger(ctx).Sugar().Warnf("catalog job failed: %v", err)
+		} else if job.Result, err = json.Marshal(result); err != nil {
+			job.Status, job.Message = db.CatalogJobFailed, err.Error()
+		}
+		// The job may have been cancelled in the meantime: in that case, the update fails with ErrNotFound
+		if err := c.jobs.backend.UpdateCatalogJob(context.Background(), job); err != nil && !errors.As(err, &db.ErrNotFound{}) {
+			log.Logger(ctx).Sugar().Errorf("catalog job: %v", err)
+		}
+	}()
+}
+
+// runJob runs the inventory of the scenes (if not provided) and ingests the area (or only estimates the ingestion if dry run)
+func (c Catalog) runJob(ctx context.Context, job db.CatalogJob) (interface{}, error) {
+	var input jobInput
+	if err := json.Unmarshal(job.Input, &input); err != nil {
+		return nil, fmt.Errorf("runJob.Unmarshal: %w", err)
+	}
+	area, scenes, tiles, err := input.decode()
+	if err != nil {
+		return nil, fmt.Errorf("runJob.%w", err)
+	}
+	if input.DryRun {
+		return c.EstimateArea(ctx, area, scenes, tiles, "")
+	}
+	return c.IngestArea(ctx, area, scenes, tiles, "")
+}
+
+// memoryJobs implements JobsBackend in memory
+type memoryJobs struct {
+	mu   sync.Mutex
+	jobs map[string]db.CatalogJob
+}
+
+// CreateCatalogJob implements JobsBackend
+func (m *memoryJobs) CreateCatalogJob(ctx context.Context, job db.CatalogJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.jobs[job.ID]; ok {
+		return db.ErrAlreadyExists{Type: "catalog job", ID: job.ID}
+	}
+	m.jobs[job.ID] = job
+	return nil
+}
+
+// CatalogJob implements JobsBackend
+func (m *memoryJobs) CatalogJob(ctx context.Context, id string) (db.CatalogJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return job, db.ErrNotFound{Type: "catalog job", ID: id}
+	}
+	return job, nil
+}
+
+// CatalogJobs implements JobsBackend
+func (m *memoryJobs) CatalogJobs(ctx context.Context, aoi string) ([]db.CatalogJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []db.CatalogJob{}
+	for _, job := range m.jobs {
+		if aoi == "" || job.AOI == aoi {
+			job.Input = nil
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+// UpdateCatalogJob implements JobsBackend
+func (m *memoryJobs) UpdateCatalogJob(ctx context.Context, job db.CatalogJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[job.ID]
+	if !ok || j.Finished() {
+		return db.ErrNotFound{Type: "catalog job", ID: job.ID}
+	}
+	j.Status, j.Message, j.Progress, j.Result, j.UpdatedAt = job.Status, job.Message, job.Progress, job.Result, job.UpdatedAt
+	m.jobs[job.ID] = j
+	return nil
+}
+
+// ClaimCatalogJobs implements JobsBackend
+func (m *memoryJobs) ClaimCatalogJobs(ctx context.Context, notUpdatedSince, now time.Time) ([]db.CatalogJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var jobs []db.CatalogJob
+	for id, job := range m.jobs {
+		if !job.Finished() && job.UpdatedAt.Before(notUpdatedSince) {
+			job.UpdatedAt = now
+			m.jobs[id] = job
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
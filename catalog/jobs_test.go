@@ -0,0 +1,80 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+)
+
+// waitJob waits for the job to be finished
+func waitJob(t *testing.T, c *Catalog, id string) db.CatalogJob {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		job, err := c.jobs.backend.CatalogJob(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Finished() {
+			return job
+		}
+	}
+	t.Fatalf("job %s is not finished", id)
+	return db.CatalogJob{}
+}
+
+func TestJobFailed(t *testing.T) {
+	c := &Catalog{}
+	c.initJobs()
+	job, err := c.newJob(context.Background(), "test", jobInput{Area: json.RawMessage("[]")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job = waitJob(t, c, job.ID); job.Status != db.CatalogJobFailed || job.Message == "" {
+		t.Errorf("expecting a failed job with a message, got %s '%s'", job.Status, job.Message)
+	}
+	if _, err := c.cancelJob(context.Background(), job.ID); err != nil {
+		t.Errorf("cancelling a finished job must not fail: %v", err)
+	}
+	if job, _ = c.jobs.backend.CatalogJob(context.Background(), job.ID); job.Status != db.CatalogJobFailed {
+		t.Errorf("a finished job must not be cancelled, got %s", job.Status)
+	}
+	if _, err := c.cancelJob(context.Background(), "unknown"); !errors.As(err, &db.ErrNotFound{}) {
+		t.Errorf("expecting ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryJobs(t *testing.T) {
+	ctx := context.Background()
+	m := &memoryJobs{jobs: map[string]db.CatalogJob{}}
+	now := time.Now()
+	for _, job := range []db.CatalogJob{
+		{ID: "running", AOI: "a", Status: db.CatalogJobRunning, CreatedAt: now, UpdatedAt: now.Add(-time.Hour)},
+		{ID: "done", AOI: "b", Status: db.CatalogJobDone, CreatedAt: now.Add(time.Second), UpdatedAt: now.Add(-time.Hour)},
+	} {
+		if err := m.CreateCatalogJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.CreateCatalogJob(ctx, db.CatalogJob{ID: "done"}); !errors.As(err, &db.ErrAlreadyExists{}) {
+		t.Errorf("expecting ErrAlreadyExists, got %v", err)
+	}
+	if jobs, _ := m.CatalogJobs(ctx, ""); len(jobs) != 2 || jobs[0].ID != "done" {
+		t.Errorf("expecting 2 jobs from the most recent, got %v", jobs)
+	}
+	if jobs, _ := m.CatalogJobs(ctx, "a"); len(jobs) != 1 || jobs[0].ID != "running" {
+		t.Errorf("expecting the jobs of aoi a, got %v", jobs)
+	}
+	if err := m.UpdateCatalogJob(ctx, db.CatalogJob{ID: "done", Status: db.CatalogJobRunning}); !errors.As(err, &db.ErrNotFound{}) {
+		t.Errorf("a finished job must not be updated, got %v", err)
+	}
+	jobs, _ := m.ClaimCatalogJobs(ctx, now.Add(-time.Minute), now)
+	if len(jobs) != 1 || jobs[0].ID != "running" || !jobs[0].UpdatedAt.Equal(now) {
+		t.Errorf("expecting to claim the running job, got %v", jobs)
+	}
+	if jobs, _ = m.ClaimCatalogJobs(ctx, now.Add(-time.Minute), now); len(jobs) != 0 {
+		t.Errorf("a claimed job must not be claimed again, got %v", jobs)
+	}
+}
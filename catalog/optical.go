@@ -0,0 +1,139 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+	"github.com/paulsmith/gogeos/geos"
+)
+
+const defaultMinOverlap = 0.5
+
+// opticalTileStackID returns the identifier of the stack of an optical tile given its scene:
+// the MGRS tile (Sentinel-2, e.g. T31TCJ) or the WRS path/row (Landsat, e.g. 198030).
+// Returns an empty string if the constellation has no tiling grid
+func opticalTileStackID(sceneID string) string {
+	info, err := common.Info(sceneID)
+	if err != nil {
+		return ""
+	}
+	switch common.GetConstellationFromProductId(sceneID) {
+	case common.Sentinel2:
+		return info["TILE"]
+	case common.Landsat89, common.Landsat457:
+		return info["PATH"] + info["ROW"]
+	}
+	return ""
+}
+
+// footprintStack is a stack of tiles defined by the footprint of its first tile
+type footprintStack struct {
+	id        string
+	footprint *geos.Geometry
+	area      float64
+}
+
+// overlap returns the overlap between the footprint of the stack and the geometry, as a ratio of the smallest one
+func (s footprintStack) overlap(g *geos.Geometry, area float64) (float64, error) {
+	inter, err := s.footprint.Intersection(g)
+	if err != nil {
+		return 0, fmt.Errorf("overlap.Intersection: %w", err)
+	}
+	interArea, err := inter.Area()
+	if err != nil {
+		return 0, fmt.Errorf("overlap.Area: %w", err)
+	}
+	if minArea := math.Min(s.area, area); minArea > 0 {
+		return interArea / minArea, nil
+	}
+	return 0, nil
+}
+
+// OpticalSort stacks the optical tiles according to the linkage and defines the previous and reference tile of each tile.
+// The tiles are stacked by MGRS tile or WRS path/row (StackByTile), or by footprint (StackByFootprint or if the constellation has no tiling grid):
+// a tile is added to the stack that overlaps it the most (at least linkage.MinOverlap of the smallest footprint).
+// The ingested tiles keep their stack (common.TileAttrs.StackID).
+// If previousTiles > 1, the k-th previous tiles (k in [2, previousTiles]) are added as inputs with the role PreviousRole(k).
+// Returns the network of pairs of each stack
+func (c *Catalog) OpticalSort(ctx context.Context, scenes []*entities.Scene, linkage entities.OpticalLinkage, previousTiles int) ([]entities.PairNetwork, error) {
+	minOverlap := linkage.MinOverlap
+	if minOverlap == 0 {
+		minOverlap = defaultMinOverlap
+	}
+
+	// Ingested tiles first, to initialize the stacks
+	var tiles []*entities.Tile
+	for _, scene := range scenes {
+		tiles = append(tiles, scene.Tiles...)
+	}
+	sort.SliceStable(tiles, func(i, j int) bool {
+		if tiles[i].Ingested != tiles[j].Ingested {
+			return tiles[i].Ingested
+		}
+		return tiles[i].Date.Before(tiles[j].Date)
+	})
+
+	stacks := map[string][]*entities.Tile{}
+	var footprintStacks []footprintStack
+	for _, tile := range tiles {
+		stackID := tile.Data.StackID
+		if stackID == "" && linkage.StackBy == entities.StackByTile {
+			stackID = opticalTileStackID(tile.SceneID)
+		}
+
+		// Stack by footprint
+		if (stackID == "" || linkage.StackBy == entities.StackByFootprint) && tile.GeometryWKT != "" {
+			g, err := geos.FromWKT(tile.GeometryWKT)
+			if err != nil {
+				return nil, fmt.Errorf("OpticalSort.FromWKT: %w", err)
+			}
+			area, err := g.Area()
+			if err != nil {
+				return nil, fmt.Errorf("OpticalSort.Area: %w", err)
+			}
+			if stackID == "" {
+				bestOverlap := 0.
+				for _, s := range footprintStacks {
+					o, err := s.overlap(g, area)
+					if err != nil {
+						return nil, fmt.Errorf("OpticalSort.%w", err)
+					}
+					if o >= minOverlap && o > bestOverlap {
+						stackID, bestOverlap = s.id, o
+					}
+				}
+			}
+			if stackID == "" {
+				stackID = tile.SourceID
+			}
+			if _, ok := stacks[stackID]; !ok {
+				footprintStacks = append(footprintStacks, footprintStack{id: stackID, footprint: g, area: area})
+			}
+		}
+
+		if stackID == "" {
+			log.Logger(ctx).Sugar().Warnf("ignore tile %s/%s: unable to find its stack", tile.SceneID, tile.SourceID)
+			continue
+		}
+		tile.Data.StackID = stackID
+		stacks[stackID] = append(stacks[stackID], tile)
+	}
+
+	// Find previous and reference for each tile
+	var networks []entities.PairNetwork
+	for id, stiles := range stacks {
+		sort.SliceStable(stiles, func(j, k int) bool { return stiles[j].Date.Before(stiles[k].Date) })
+		network := stackSort(ctx, stiles, linkage.Strategy(), previousTiles, 0)
+		network.StackID, network.TrackSwath = id, id
+		networks = append(networks, network)
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].StackID < networks[j].StackID })
+	log.Logger(ctx).Sugar().Debugf("%d stacks of optical tiles", len(networks))
+
+	return networks, nil
+}
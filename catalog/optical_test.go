@@ -0,0 +1,73 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+)
+
+// newOpticalScene creates a scene with one tile (the whole scene)
+func newOpticalScene(sceneID string, date time.Time) *entities.Scene {
+	return &entities.Scene{
+		Scene: common.Scene{SourceID: sceneID},
+		Tiles: []*entities.Tile{{TileLite: entities.TileLite{SourceID: sceneID, SceneID: sceneID, Date: date}}},
+	}
+}
+
+// newS2Scene creates a Sentinel-2 scene of the MGRS tile acquired at the given day after 2020-01-01
+func newS2Scene(mgrsTile string, day int) *entities.Scene {
+	date := time.Date(2020, 1, 1, 10, 54, 41, 0, time.UTC).AddDate(0, 0, day)
+	return newOpticalScene("S2A_MSIL1C_"+date.Format("20060102T150405")+"_N0208_R051_"+mgrsTile+"_"+date.Format("20060102T150405"), date)
+}
+
+func TestOpticalTileStackID(t *testing.T) {
+	for sceneID, expected := range map[string]string{
+		"S2A_MSIL1C_20200101T105441_N0208_R051_T31TCJ_20200101T105441": "T31TCJ",
+		"LC08_L1TP_198030_20200101_20200113_02_T1":                     "198030",
+		"DS_PHR1A_201901011037034_FR1_PX_E001N43_0615_01234":           "",
+	} {
+		if stackID := opticalTileStackID(sceneID); stackID != expected {
+			t.Errorf("%s: expecting stack '%s', got '%s'", sceneID, expected, stackID)
+		}
+	}
+}
+
+func TestOpticalSortByTile(t *testing.T) {
+	root, leaf := newS2Scene("T31TCJ", 0), newS2Scene("T31TCJ", 5)
+	for _, s := range []*entities.Scene{root, leaf} {
+		s.Ingested, s.Tiles[0].Ingested, s.Tiles[0].Data.StackID = true, true, "T31TCJ"
+	}
+	root.Tiles[0].Root, leaf.Tiles[0].Leaf = true, true
+	scenes := []*entities.Scene{
+		newS2Scene("T31TCJ", 15),
+		newS2Scene("T31TCJ", 10),
+		newS2Scene("T31TCK", 10),
+		root,
+		leaf,
+	}
+
+	networks, err := (&Catalog{}).OpticalSort(context.Background(), scenes, entities.OpticalLinkage{StackBy: entities.StackByTile}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 2 || networks[0].StackID != "T31TCJ" || networks[1].StackID != "T31TCK" {
+		t.Fatalf("expecting the stacks T31TCJ and T31TCK, got %v", networks)
+	}
+	if len(networks[0].Pairs) != 2 || networks[0].Reference.SceneID != root.SourceID {
+		t.Errorf("expecting 2 pairs with the ingested reference, got %v", networks[0])
+	}
+
+	day10, day15 := scenes[1].Tiles[0], scenes[0].Tiles[0]
+	if day10.Previous == nil || day10.Previous.SceneID != leaf.SourceID || day10.Reference == nil || day10.Reference.SceneID != root.SourceID {
+		t.Errorf("%s: expecting previous %s and reference %s, got %v %v", day10.SceneID, leaf.SourceID, root.SourceID, day10.Previous, day10.Reference)
+	}
+	if day15.Previous == nil || day15.Previous.SceneID != day10.SceneID || day15.Data.StackID != "T31TCJ" {
+		t.Errorf("%s: expecting previous %s in stack T31TCJ, got %v %s", day15.SceneID, day10.SceneID, day15.Previous, day15.Data.StackID)
+	}
+	if other := scenes[2].Tiles[0]; other.Previous != nil || other.Reference != nil {
+		t.Errorf("%s: expecting no previous and no reference (reference of its stack)", other.SceneID)
+	}
+}
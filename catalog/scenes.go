@@ -9,28 +9,29 @@ import (
 	"slices"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/asf"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/copernicus"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/creodias"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/landsataws"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/oneatlas"
 
-	geocube "github.com/airbusgeo/geocube-client-go/client"
 	"github.com/airbusgeo/geocube-ingester/catalog/entities"
 	"github.com/airbusgeo/geocube-ingester/common"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
 	"github.com/airbusgeo/geocube-ingester/service"
 	"github.com/airbusgeo/geocube-ingester/service/geometry"
 	"github.com/airbusgeo/geocube-ingester/service/log"
-	"github.com/go-spatial/geom"
 	"github.com/go-spatial/geom/encoding/wkt"
 	"github.com/paulsmith/gogeos/geos"
-	"google.golang.org/grpc/codes"
 )
 
-// ScenesInventory makes an inventory of all the scenes covering the area between startDate and endDate
+// maxQueryWindows is the maximum number of windows of time queried separately to a scenes provider
+const maxQueryWindows = 12
+
+// ScenesInventory makes an inventory of all the scenes covering the area between startDate and endDate (and in the windows of its time filter)
 // The scenes are retrieved from different providers
 func (c *Catalog) ScenesInventory(ctx context.Context, area *entities.AreaToIngest, aoi geos.Geometry) (entities.Scenes, error) {
 	// Search
@@ -45,6 +46,9 @@ func (c *Catalog) ScenesInventory(ctx context.Context, area *entities.AreaToInge
 	if c.CreodiasCatalog {
 		sceneProviders = append(sceneProviders, &creodias.Provider{})
 	}
+	if c.ASFCatalog {
+		sceneProviders = append(sceneProviders, &asf.Provider{})
+	}
 	if c.LandsatAwsCatalog {
 		sceneProviders = append(sceneProviders, &landsataws.Provider{})
 	}
@@ -66,13 +70,18 @@ func (c *Catalog) ScenesInventory(ctx context.Context, area *entities.AreaToInge
 		return entities.Scenes{}, fmt.Errorf("no catalog is configured for '%s'", area.SceneType.Constellation)
 	}
 
-	var err, e error
+	windows, err := area.TimeWindows()
+	if err != nil {
+		return entities.Scenes{}, fmt.Errorf("ScenesInventory.%w", err)
+	}
+
+	var e error
 	var scenes entities.Scenes
 	for _, sceneProvider := range sceneProviders {
 		if !sceneProvider.Supports(constellation) {
 			continue
 		}
-		scenes, e = sceneProvider.SearchScenes(ctx, area, aoi)
+		scenes, e = searchScenes(ctx, sceneProvider, area, aoi, windows)
 		if err = service.MergeErrors(false, err, e); err == nil {
 			break
 		}
@@ -83,11 +92,15 @@ func (c *Catalog) ScenesInventory(ctx context.Context, area *entities.AreaToInge
 
 	// Define common attributes:
 	for _, scene := range scenes.Scenes {
+		if scene.GeometryWKT, err = geometry.NormalizeWKT(scene.GeometryWKT); err != nil {
+			return entities.Scenes{}, fmt.Errorf("ScenesInventory[%s].%w", scene.SourceID, err)
+		}
 		scene.AOI = area.AOIID
 		scene.Data.GraphName = area.SceneGraphName
 		scene.Data.GraphConfig = area.GraphConfig
 		scene.Data.IsRetriable = area.IsRetriable
 		scene.Data.StorageURI = area.StorageURI
+		scene.Data.OrbitType = area.OrbitType
 
 		// Copy area tags
 		for k, v := range area.RecordTags {
@@ -112,17 +125,23 @@ func (c *Catalog) ScenesInventory(ctx context.Context, area *entities.AreaToInge
 
 	// Refine inventory
 	scenesCount := len(scenes.Scenes)
-	scenes.Scenes, err = refineInventory(area, scenes.Scenes, aoi)
+	scenes.Scenes, err = refineInventory(area, scenes.Scenes, aoi, windows)
 	if err != nil {
 		return entities.Scenes{}, fmt.Errorf("ScenesInventory.%w", err)
 	}
 
+	// Select the best scenes of each period
+	if area.SceneSelection.Enabled() {
+		if scenes.Scenes, scenes.Selections, err = selectScenes(ctx, area, scenes.Scenes, aoi); err != nil {
+			return entities.Scenes{}, fmt.Errorf("ScenesInventory.%w", err)
+		}
+	}
+
 	if scenes.Properties == nil {
 		scenes.Properties = map[string]string{}
 	}
-	if area.Limit > 0 && scenesCount == area.Limit {
-		scenes.Properties["next"] = "true"
-	} else {
+	// "next" is set by searchScenes
+	if scenes.Properties["next"] != "true" {
 		scenes.Properties["next"] = "false"
 	}
 
@@ -131,30 +150,42 @@ func (c *Catalog) ScenesInventory(ctx context.Context, area *entities.AreaToInge
 	return scenes, nil
 }
 
-// IngestedScenesInventoryFromTiles retrieves the ingested scenes from a list of tiles
-func (c *Catalog) IngestedScenesInventoryFromTiles(ctx context.Context, tiles []common.Tile) ([]*entities.Scene, error) {
+// IngestedScenesInventoryFromTiles retrieves the ingested scenes from the root and leaf tiles of the workflow
+func (c *Catalog) IngestedScenesInventoryFromTiles(ctx context.Context, rootTiles, leafTiles []common.Tile) ([]*entities.Scene, error) {
 	scenesID := map[string]*entities.Scene{}
+	tilesID := map[string]*entities.Tile{}
 	var scenes []*entities.Scene
-	for _, tile := range tiles {
-		scene, ok := scenesID[tile.Scene.SourceID]
+	for i, tile := range slices.Concat(rootTiles, leafTiles) {
+		// A tile can be a root and a leaf
+		t, ok := tilesID[tile.Scene.SourceID+"/"+tile.SourceID]
 		if !ok {
-			scene = &entities.Scene{Scene: common.Scene{SourceID: tile.Scene.SourceID}, Ingested: true}
-			scenesID[tile.Scene.SourceID] = scene
-			scenes = append(scenes, scene)
-		}
-		tile := &entities.Tile{
-			TileLite: entities.TileLite{
-				SourceID: tile.SourceID,
-				SceneID:  tile.Scene.SourceID,
-				Date:     tile.Scene.Data.Date,
-			},
-			Ingested: true,
-			Data:     tile.Data,
+			scene, ok := scenesID[tile.Scene.SourceID]
+			if !ok {
+				scene = &entities.Scene{Scene: common.Scene{SourceID: tile.Scene.SourceID}, Ingested: true}
+				scenesID[tile.Scene.SourceID] = scene
+				scenes = append(scenes, scene)
+			}
+			t = &entities.Tile{
+				TileLite: entities.TileLite{
+					SourceID: tile.SourceID,
+					SceneID:  tile.Scene.SourceID,
+					Date:     tile.Scene.Data.Date,
+				},
+				Ingested:    true,
+				Data:        tile.Data,
+				GeometryWKT: tile.Scene.Data.TileMappings[tile.SourceID].GeometryWKT,
+			}
+			if common.GetConstellationFromString(scene.SourceID) == common.Sentinel1 {
+				_, t.AnxTime, _ = parseLegacyBurstID(tileLegacyID(t))
+			}
+			scene.Tiles = append(scene.Tiles, t)
+			tilesID[tile.Scene.SourceID+"/"+tile.SourceID] = t
 		}
-		if common.GetConstellationFromString(scene.SourceID) == common.Sentinel1 {
-			tile.AnxTime, _ = strconv.Atoi(strings.Split(tile.SourceID, "_")[2])
+		if i < len(rootTiles) {
+			t.Root = true
+		} else {
+			t.Leaf = true
 		}
-		scene.Tiles = append(scene.Tiles, tile)
 	}
 
 	return scenes, nil
@@ -168,13 +199,17 @@ func (c *Catalog) ScenesToIngest(ctx context.Context, area entities.AreaToIngest
 		return scenesToIngest, nil
 	}
 
-	if c.GeocubeClient == nil {
-		return nil, fmt.Errorf("scenesToIngest: no connection to the geocube")
+	if c.Indexer == nil {
+		return nil, fmt.Errorf("scenesToIngest: no indexer configured")
 	}
 
 	if err := c.ValidateArea(ctx, &area); err != nil {
 		return nil, fmt.Errorf("scenesToIngest.%w", err)
 	}
+	// Create the variables and the instances of the layers that do not exist
+	if _, err := c.ProvisionLayers(ctx, &area, false); err != nil {
+		return nil, fmt.Errorf("scenesToIngest.%w", err)
+	}
 	instances := area.InstancesID()
 
 	// Get Union of scenes AOIs
@@ -186,19 +221,14 @@ func (c *Catalog) ScenesToIngest(ctx context.Context, area entities.AreaToIngest
 	if err != nil {
 		return nil, fmt.Errorf("scenesToIngest.%w", err)
 	}
-	geocubeAOI, err := wktToGeocubeAOI(wktAoi)
-	if err != nil {
-		return nil, fmt.Errorf("scenesToIngest.%w", err)
-	}
 
-	recordsList, err := c.GeocubeClient.ListRecords(ctx, "", area.RecordTags, geocubeAOI,
+	recordsList, err := c.Indexer.ListRecords(ctx, area.RecordTags, wktAoi,
 		slices.MinFunc(scenes.Scenes, func(s1, s2 *entities.Scene) int { return s1.Data.Date.Compare(s2.Data.Date) }).Data.Date,
-		slices.MaxFunc(scenes.Scenes, func(s1, s2 *entities.Scene) int { return s1.Data.Date.Compare(s2.Data.Date) }).Data.Date,
-		0, 0, false)
+		slices.MaxFunc(scenes.Scenes, func(s1, s2 *entities.Scene) int { return s1.Data.Date.Compare(s2.Data.Date) }).Data.Date)
 	if err != nil {
-		return nil, fmt.Errorf("scenesToIngest.ListRecords: %w", err)
+		return nil, fmt.Errorf("scenesToIngest.%w", err)
 	}
-	records := map[string][]*geocube.Record{}
+	records := map[string][]indexer.Record{}
 	for _, r := range recordsList {
 		delete(r.Tags, common.TagProcessingDate)
 		records[r.Name] = append(records[r.Name], r)
@@ -239,6 +269,10 @@ func (c *Catalog) ScenesToIngest(ctx context.Context, area entities.AreaToIngest
 				t.ReferenceSceneID = tile.Reference.SceneID
 				refScenes.Push(tile.Reference.SceneID)
 			}
+			for _, input := range tile.Inputs {
+				t.Inputs = append(t.Inputs, common.TileInputToIngest{Role: input.Role, TileID: input.Tile.SourceID, SceneID: input.Tile.SceneID})
+				prevScenes.Push(input.Tile.SceneID)
+			}
 			sceneToIngest.Tiles[tile.SourceID] = t
 		}
 
@@ -257,7 +291,7 @@ func (c *Catalog) ScenesToIngest(ctx context.Context, area entities.AreaToIngest
 		// Find if the record already exists
 		if recordsList, ok := records[scene.SourceID]; ok {
 			for _, r := range recordsList {
-				if r.Time == scene.Data.Date && reflect.DeepEqual(r.Tags, scene.Tags) {
+				if r.Date == scene.Data.Date && reflect.DeepEqual(r.Tags, scene.Tags) {
 					scene.Data.RecordID = r.ID
 					break
 				}
@@ -287,9 +321,79 @@ func (c *Catalog) ScenesToIngest(ctx context.Context, area entities.AreaToIngest
 	return scenesToIngest, nil
 }
 
-func refineInventory(area *entities.AreaToIngest, scenes []*entities.Scene, aoi geos.Geometry) ([]*entities.Scene, error) {
+// searchScenes searches the scenes in the windows of time of the area
+// If the area has a time filter, the provider is queried for each window or, if there are too many windows or the results are paginated,
+// over the extent of the windows (the scenes outside the windows are then removed by refineInventory).
+// If the AOI crosses the antimeridian, the provider is queried for each side (see geometry.AntimeridianParts), as the providers search using the convex hull of the AOI.
+// If the results are paginated, Properties["next"] is "true" if one of the queries returned a full page, even if its scenes are filtered out afterwards.
+func searchScenes(ctx context.Context, provider catalog.ScenesProvider, area *entities.AreaToIngest, aoi geos.Geometry, windows entities.TimeWindows) (entities.Scenes, error) {
+	if !area.TimeFilter.Enabled() {
+		windows = entities.TimeWindows{{Start: area.StartTime, End: area.EndTime}}
+	} else if len(windows) == 0 {
+		return entities.Scenes{}, nil
+	} else if area.Limit > 0 || len(windows) > maxQueryWindows {
+		windows = entities.TimeWindows{windows.Extent()}
+	}
+	aois := []*geos.Geometry{&aoi}
+	if parts := geometry.AntimeridianParts(area.AOI); len(parts) > 1 {
+		aois = nil
+		for _, part := range parts {
+			g, err := geos.FromWKT(wkt.MustEncode(part))
+			if err != nil {
+				return entities.Scenes{}, fmt.Errorf("searchScenes.FromWKT: %w", err)
+			}
+			aois = append(aois, g)
+		}
+	}
+	if len(windows) == 1 && len(aois) == 1 && !area.TimeFilter.Enabled() {
+		scenes, err := provider.SearchScenes(ctx, area, aoi)
+		if err != nil {
+			return entities.Scenes{}, err
+		}
+		return withNextPage(scenes, area.Limit > 0 && len(scenes.Scenes) == area.Limit), nil
+	}
+
+	var scenes entities.Scenes
+	next := false
+	sourceIDs := service.StringSet{}
+	for _, window := range windows {
+		for _, partAOI := range aois {
+			windowArea := *area
+			windowArea.StartTime, windowArea.EndTime = window.Start, window.End
+			windowScenes, err := provider.SearchScenes(ctx, &windowArea, *partAOI)
+			if err != nil {
+				return entities.Scenes{}, fmt.Errorf("searchScenes[%v-%v].%w", window.Start, window.End, err)
+			}
+			next = next || (area.Limit > 0 && len(windowScenes.Scenes) == area.Limit)
+			for _, scene := range windowScenes.Scenes {
+				if !sourceIDs.Exists(scene.SourceID) {
+					sourceIDs.Push(scene.SourceID)
+					scenes.Scenes = append(scenes.Scenes, scene)
+				}
+			}
+			scenes.Properties = windowScenes.Properties
+		}
+	}
+	return withNextPage(scenes, next), nil
+}
+
+// withNextPage sets Properties["next"] of the scenes
+func withNextPage(scenes entities.Scenes, next bool) entities.Scenes {
+	properties := map[string]string{}
+	for k, v := range scenes.Properties {
+		properties[k] = v
+	}
+	properties["next"] = strconv.FormatBool(next)
+	scenes.Properties = properties
+	return scenes
+}
+
+func refineInventory(area *entities.AreaToIngest, scenes []*entities.Scene, aoi geos.Geometry, windows entities.TimeWindows) ([]*entities.Scene, error) {
 	var err error
 	scenes = removeDoubleEntries(scenes)
+	if area.TimeFilter.Enabled() {
+		scenes = removeOutsideTimeWindows(scenes, windows)
+	}
 	if scenes, err = removeOutsideAOI(scenes, aoi); err != nil {
 		return nil, fmt.Errorf("refineInventory.%w", err)
 	}
@@ -325,6 +429,18 @@ func removeDoubleEntries(scenes []*entities.Scene) []*entities.Scene {
 	return scenes[0:j]
 }
 
+// removeOutsideTimeWindows removes scenes acquired outside the windows of time
+func removeOutsideTimeWindows(scenes []*entities.Scene, windows entities.TimeWindows) []*entities.Scene {
+	j := 0
+	for _, scene := range scenes {
+		if windows.Contains(scene.Data.Date) {
+			scenes[j] = scene
+			j++
+		}
+	}
+	return scenes[0:j]
+}
+
 // removeOutsideAOI removes scenes that are located outside the AOI
 // The search routine works over a simplified representation of the AOI.
 // This may then include acquisitions that do not overlap with the AOI.
@@ -425,65 +541,32 @@ func handleNonContinuousSwath(scenes []*entities.Scene) error {
 
 // createRecords for the scenes
 func (c *Catalog) createRecords(ctx context.Context, scenes map[int]*entities.Scene) error {
-	if c.GeocubeClient == nil {
-		return fmt.Errorf("createRecords: no connection to the geocube")
-	}
-	names := make([]string, len(scenes))
-	aois := make([]string, len(scenes))
-	tags := make([]map[string]string, len(scenes))
-	dates := make([]time.Time, len(scenes))
-	ind := make([]int, len(scenes))
-	i := 0
+	if c.Indexer == nil {
+		return fmt.Errorf("createRecords: no indexer configured")
+	}
+	if len(scenes) == 0 {
+		return nil
+	}
+	records := make([]indexer.Record, 0, len(scenes))
+	ind := make([]int, 0, len(scenes))
 	for j, scene := range scenes {
-		ind[i] = j
-		names[i] = scene.SourceID
-		tags[i] = scene.Tags
-		dates[i] = scene.Data.Date
-		// CreateAOI
-		{
-			aoi, err := wktToGeocubeAOI(scene.GeometryWKT)
-			if err != nil {
-				return fmt.Errorf("CreateRecords.%w", err)
-			}
-			if aois[i], err = c.GeocubeClient.CreateAOI(ctx, aoi); err != nil && geocube.Code(err) != codes.AlreadyExists {
-				return fmt.Errorf("CreateRecords.%w", err)
-			}
-		}
-		i++
+		ind = append(ind, j)
+		records = append(records, indexer.Record{
+			Name:        scene.SourceID,
+			AOI:         scene.AOI,
+			Date:        scene.Data.Date,
+			Tags:        scene.Tags,
+			GeometryWKT: scene.GeometryWKT,
+		})
 	}
 
-	// CreateRecords
-	recordsId, err := c.GeocubeClient.CreateRecords(ctx, names, aois, dates, tags)
+	recordsId, err := c.Indexer.CreateRecords(ctx, records)
 	if err != nil {
-		return fmt.Errorf("CreateRecords.%w", err)
+		return fmt.Errorf("createRecords.%w", err)
 	}
 	for i, r := range recordsId {
 		scenes[ind[i]].Data.RecordID = r
 	}
+	progress(ctx).addRecords(len(recordsId))
 	return nil
 }
-
-func wktToGeocubeAOI(wktAOI string) (geocube.AOI, error) {
-	geo, err := wkt.DecodeString(wktAOI)
-	if err != nil {
-		return nil, fmt.Errorf("wktToGeocubeAOI: %w", err)
-	}
-	var mp [][][][2]float64
-	switch g := geo.(type) {
-	case geom.Polygoner:
-		mp = [][][][2]float64{g.LinearRings()}
-	case geom.MultiPolygoner:
-		mp = g.Polygons()
-	default:
-		return geocube.AOI{}, fmt.Errorf("unsupported geometry: %v", g)
-	}
-
-	for i, polygon := range mp {
-		for j, linearring := range polygon {
-			if linearring[0][0] != linearring[len(linearring)-1][0] || linearring[0][1] != linearring[len(linearring)-1][1] {
-				mp[i][j] = append(mp[i][j], mp[i][j][0])
-			}
-		}
-	}
-	return geocube.AOIFromMultiPolygonArray(mp), nil
-}
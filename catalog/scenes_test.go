@@ -1,10 +1,15 @@
 package catalog
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/airbusgeo/geocube-ingester/catalog/entities"
 	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/go-spatial/geom"
+	"github.com/paulsmith/gogeos/geos"
 )
 
 func TestRemoveDoubleEntries(t *testing.T) {
@@ -22,3 +27,63 @@ func TestRemoveDoubleEntries(t *testing.T) {
 		t.Errorf("expecting scene %s found %s", scenes[1].SourceID, newscenes[0].SourceID)
 	}
 }
+
+// pageProvider returns a page of limit scenes, one every 3 days from the start of the area
+type pageProvider struct {
+	queries int
+}
+
+func (p *pageProvider) Supports(c common.Constellation) bool { return true }
+
+func (p *pageProvider) SearchScenes(ctx context.Context, area *entities.AreaToIngest, aoi geos.Geometry) (entities.Scenes, error) {
+	p.queries++
+	var scenes entities.Scenes
+	for i := 0; i < area.Limit; i++ {
+		scenes.Scenes = append(scenes.Scenes, &entities.Scene{Scene: common.Scene{SourceID: fmt.Sprintf("scene-%d-%d", p.queries, i),
+			Data: common.SceneAttrs{Date: area.StartTime.Add(time.Duration(3*i) * 24 * time.Hour)}}})
+	}
+	return scenes, nil
+}
+
+func TestSearchScenesNextPage(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	area := entities.AreaToIngest{
+		AOI:       geom.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}},
+		StartTime: start,
+		EndTime:   start.AddDate(0, 3, 0),
+		TimeFilter: entities.TimeFilter{Windows: []entities.TimeWindow{
+			{Start: start.AddDate(0, 1, 0), End: start.AddDate(0, 1, 10)},
+			{Start: start.AddDate(0, 2, 0), End: start.AddDate(0, 2, 10)},
+		}},
+		Limit: 10,
+	}
+	windows, err := area.TimeWindows()
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &pageProvider{}
+	scenes, err := searchScenes(context.Background(), provider, &area, geos.Geometry{}, windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The page is queried once over the extent of the windows
+	if provider.queries != 1 || len(scenes.Scenes) != 10 {
+		t.Errorf("expecting 1 query and 10 scenes, got %d queries and %d scenes", provider.queries, len(scenes.Scenes))
+	}
+	if scenes.Properties["next"] != "true" {
+		t.Errorf("expecting a next page, got %v", scenes.Properties)
+	}
+	// Most of the scenes of the page are outside the windows, but the next page may have scenes in the windows
+	if scenes := removeOutsideTimeWindows(scenes.Scenes, windows); len(scenes) != 4 {
+		t.Errorf("expecting 4 scenes in the windows, got %d", len(scenes))
+	}
+
+	area.Limit = 0
+	provider.queries = 0
+	if scenes, err = searchScenes(context.Background(), provider, &area, geos.Geometry{}, windows); err != nil {
+		t.Fatal(err)
+	}
+	if provider.queries != 2 || scenes.Properties["next"] != "false" {
+		t.Errorf("expecting 2 queries without next page, got %d queries, %v", provider.queries, scenes.Properties)
+	}
+}
@@ -0,0 +1,242 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+	"github.com/paulsmith/gogeos/geos"
+)
+
+const (
+	defaultMinCoverage = 0.01
+	// The AOI is considered as covered above this ratio
+	fullCoverage = 0.999
+)
+
+// Reasons of the rejection of a scene
+const (
+	rejectedCovered   = "aoi already covered by scenes with a lower cost"
+	rejectedRedundant = "redundant with the other selected scenes"
+)
+
+// periodStart returns the start of the period containing the date
+func periodStart(selection entities.SceneSelection, start, date time.Time) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	switch selection.Period {
+	case entities.PeriodWeekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case entities.PeriodMonthly:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	period := time.Duration(selection.Days) * 24 * time.Hour
+	return start.Add(date.Sub(start) / period * period)
+}
+
+// periodEnd returns the end of the period starting at periodStart
+func periodEnd(selection entities.SceneSelection, periodStart time.Time) time.Time {
+	switch selection.Period {
+	case entities.PeriodWeekly:
+		return periodStart.AddDate(0, 0, 7)
+	case entities.PeriodMonthly:
+		return periodStart.AddDate(0, 1, 0)
+	}
+	return periodStart.AddDate(0, 0, selection.Days)
+}
+
+// sceneCost returns the cloud cover, the sun elevation and the cost of a scene (the lower, the better).
+// A scene without cloud cover is considered as fully cloudy.
+func sceneCost(selection entities.SceneSelection, scene *entities.Scene) (float64, float64, float64) {
+	cloudCover, err := strconv.ParseFloat(scene.Tags[common.TagCloudCoverPercentage], 64)
+	if err != nil {
+		cloudCover = 100
+	}
+	sunElevation, _ := strconv.ParseFloat(scene.Tags[common.TagSunElevation], 64)
+	return cloudCover, sunElevation, cloudCover - selection.SunElevationWeight*sunElevation
+}
+
+// footprint is the part of the AOI covered by one or several scenes
+type footprint interface {
+	Area() (float64, error)
+	Union(other footprint) (footprint, error)
+	Difference(other footprint) (footprint, error)
+}
+
+// geosFootprint implements footprint
+type geosFootprint struct {
+	*geos.Geometry
+}
+
+func (f geosFootprint) Union(other footprint) (footprint, error) {
+	g, err := f.Geometry.Union(other.(geosFootprint).Geometry)
+	return geosFootprint{g}, err
+}
+
+func (f geosFootprint) Difference(other footprint) (footprint, error) {
+	g, err := f.Geometry.Difference(other.(geosFootprint).Geometry)
+	return geosFootprint{g}, err
+}
+
+// candidate is a scene that may be selected
+type candidate struct {
+	scene            *entities.Scene
+	footprint        footprint // Footprint inside the AOI
+	cloud, sun, cost float64
+	coverage         float64
+	selected         bool
+}
+
+// selectScenes selects, for each period, the minimal set of scenes covering the AOI with the lowest cost (see sceneCost).
+// The scenes are added by increasing cost, as long as they cover a part of the AOI that is not already covered
+// (at least selection.MinCoverage). Then, the selected scenes that are redundant with the others are removed.
+// Returns the selected scenes and the report of the selection of each period
+func selectScenes(ctx context.Context, area *entities.AreaToIngest, scenes []*entities.Scene, aoi geos.Geometry) ([]*entities.Scene, []entities.PeriodSelection, error) {
+	selection := area.SceneSelection
+	if err := selection.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("selectScenes: %w", err)
+	}
+	minCoverage := selection.MinCoverage
+	if minCoverage == 0 {
+		minCoverage = defaultMinCoverage
+	}
+	aoiArea, err := aoi.Area()
+	if err != nil {
+		return nil, nil, fmt.Errorf("selectScenes.Area: %w", err)
+	}
+	if aoiArea == 0 {
+		return nil, nil, fmt.Errorf("selectScenes: empty aoi")
+	}
+
+	// Group scenes by period
+	periods := map[time.Time][]*candidate{}
+	for _, scene := range scenes {
+		footprint, err := geos.FromWKT(scene.GeometryWKT)
+		if err != nil {
+			return nil, nil, fmt.Errorf("selectScenes.FromWKT: %w", err)
+		}
+		if footprint, err = footprint.Intersection(&aoi); err != nil {
+			return nil, nil, fmt.Errorf("selectScenes.Intersection: %w", err)
+		}
+		c := &candidate{scene: scene, footprint: geosFootprint{footprint}}
+		c.cloud, c.sun, c.cost = sceneCost(selection, scene)
+		start := periodStart(selection, area.StartTime, scene.Data.Date)
+		periods[start] = append(periods[start], c)
+	}
+
+	var selected []*entities.Scene
+	var reports []entities.PeriodSelection
+	for start, candidates := range periods {
+		report, err := selectPeriodScenes(candidates, aoiArea, minCoverage)
+		if err != nil {
+			return nil, nil, fmt.Errorf("selectScenes.%w", err)
+		}
+		report.Start, report.End = start, periodEnd(selection, start)
+		reports = append(reports, report)
+		for _, c := range candidates {
+			if c.selected {
+				selected = append(selected, c.scene)
+			}
+		}
+	}
+	runtime.KeepAlive(aoi)
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Start.Before(reports[j].Start) })
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].Data.Date.Before(selected[j].Data.Date) })
+	log.Logger(ctx).Sugar().Debugf("%d scenes selected in %d periods (%d rejected)", len(selected), len(reports), len(scenes)-len(selected))
+
+	return selected, reports, nil
+}
+
+// selectPeriodScenes selects the scenes of a period and returns the report of the selection
+func selectPeriodScenes(candidates []*candidate, aoiArea, minCoverage float64) (entities.PeriodSelection, error) {
+	var report entities.PeriodSelection
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].cost < candidates[j].cost })
+
+	// Add the scenes by increasing cost
+	var covered footprint
+	for _, c := range candidates {
+		newArea, err := c.footprint.Area()
+		if err != nil {
+			return report, fmt.Errorf("selectPeriodScenes.Area: %w", err)
+		}
+		if covered != nil && newArea > 0 {
+			diff, err := c.footprint.Difference(covered)
+			if err != nil {
+				return report, fmt.Errorf("selectPeriodScenes.Difference: %w", err)
+			}
+			if newArea, err = diff.Area(); err != nil {
+				return report, fmt.Errorf("selectPeriodScenes.Area: %w", err)
+			}
+		}
+		if c.coverage = newArea / aoiArea; report.Coverage >= fullCoverage || c.coverage < minCoverage {
+			report.Rejected = append(report.Rejected, entities.RejectedScene{SceneID: c.scene.SourceID, Cost: c.cost, Reason: rejectedCovered})
+			continue
+		}
+		c.selected = true
+		if covered == nil {
+			covered = c.footprint
+		} else if covered, err = covered.Union(c.footprint); err != nil {
+			return report, fmt.Errorf("selectPeriodScenes.Union: %w", err)
+		}
+		if report.Coverage, err = coverage(covered, aoiArea); err != nil {
+			return report, fmt.Errorf("selectPeriodScenes.%w", err)
+		}
+	}
+
+	// Remove the redundant scenes, starting with the most expensive
+	for i := len(candidates) - 1; i >= 0; i-- {
+		c := candidates[i]
+		if !c.selected {
+			continue
+		}
+		var others footprint
+		for _, o := range candidates {
+			if o == c || !o.selected {
+				continue
+			}
+			var err error
+			if others == nil {
+				others = o.footprint
+			} else if others, err = others.Union(o.footprint); err != nil {
+				return report, fmt.Errorf("selectPeriodScenes.Union: %w", err)
+			}
+		}
+		if others == nil {
+			continue
+		}
+		if cov, err := coverage(others, aoiArea); err != nil {
+			return report, fmt.Errorf("selectPeriodScenes.%w", err)
+		} else if cov >= report.Coverage-minCoverage/2 {
+			c.selected = false
+			report.Rejected = append(report.Rejected, entities.RejectedScene{SceneID: c.scene.SourceID, Cost: c.cost, Reason: rejectedRedundant})
+		}
+	}
+
+	for _, c := range candidates {
+		if c.selected {
+			report.Selected = append(report.Selected, entities.SelectedScene{
+				SceneID:      c.scene.SourceID,
+				CloudCover:   c.cloud,
+				SunElevation: c.sun,
+				Cost:         c.cost,
+				Coverage:     c.coverage,
+			})
+		}
+	}
+	return report, nil
+}
+
+// coverage returns the ratio of the AOI covered by the footprint
+func coverage(g footprint, aoiArea float64) (float64, error) {
+	area, err := g.Area()
+	if err != nil {
+		return 0, fmt.Errorf("coverage.Area: %w", err)
+	}
+	return area / aoiArea, nil
+}
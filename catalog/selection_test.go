@@ -0,0 +1,137 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+)
+
+func TestPeriodStart(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2020, 1, 16, 10, 54, 41, 0, time.UTC) // Thursday
+	for _, test := range []struct {
+		selection  entities.SceneSelection
+		start, end time.Time
+	}{
+		{entities.SceneSelection{Period: entities.PeriodWeekly}, time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 20, 0, 0, 0, 0, time.UTC)},
+		{entities.SceneSelection{Period: entities.PeriodMonthly}, start, time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{entities.SceneSelection{Period: entities.PeriodCustom, Days: 10}, time.Date(2020, 1, 11, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 21, 0, 0, 0, 0, time.UTC)},
+	} {
+		if s := periodStart(test.selection, start, date); !s.Equal(test.start) {
+			t.Errorf("%s: expecting start %v, got %v", test.selection.Period, test.start, s)
+		}
+		if e := periodEnd(test.selection, test.start); !e.Equal(test.end) {
+			t.Errorf("%s: expecting end %v, got %v", test.selection.Period, test.end, e)
+		}
+	}
+}
+
+func TestSceneCost(t *testing.T) {
+	selection := entities.SceneSelection{Period: entities.PeriodWeekly, SunElevationWeight: 0.5}
+	scene := &entities.Scene{Tags: map[string]string{common.TagCloudCoverPercentage: "20", common.TagSunElevation: "30"}}
+	if cloud, sun, cost := sceneCost(selection, scene); cloud != 20 || sun != 30 || cost != 5 {
+		t.Errorf("expecting cloud=20, sun=30 and cost=5, got %f, %f, %f", cloud, sun, cost)
+	}
+	// Unknown cloud cover
+	if cloud, _, _ := sceneCost(selection, &entities.Scene{}); cloud != 100 {
+		t.Errorf("expecting a cloud cover of 100 if unknown, got %f", cloud)
+	}
+}
+
+// cellsFootprint implements footprint as a set of cells of unit area
+type cellsFootprint map[int]bool
+
+func newCellsFootprint(from, to int) cellsFootprint {
+	f := cellsFootprint{}
+	for i := from; i <= to; i++ {
+		f[i] = true
+	}
+	return f
+}
+
+func (f cellsFootprint) Area() (float64, error) {
+	return float64(len(f)), nil
+}
+
+func (f cellsFootprint) Union(other footprint) (footprint, error) {
+	u := cellsFootprint{}
+	for _, g := range []cellsFootprint{f, other.(cellsFootprint)} {
+		for i := range g {
+			u[i] = true
+		}
+	}
+	return u, nil
+}
+
+func (f cellsFootprint) Difference(other footprint) (footprint, error) {
+	d := cellsFootprint{}
+	for i := range f {
+		if !other.(cellsFootprint)[i] {
+			d[i] = true
+		}
+	}
+	return d, nil
+}
+
+func TestSelectPeriodScenes(t *testing.T) {
+	// The AOI is made of 10 cells
+	newCandidates := func() []*candidate {
+		var candidates []*candidate
+		for _, c := range []struct {
+			id       string
+			from, to int
+			cost     float64
+		}{
+			{"A", 0, 5, 10},
+			{"B", 4, 9, 20},
+			{"C", 0, 9, 50},
+			{"D", 0, 1, 5},
+			{"E", 9, 9, 15},
+		} {
+			candidates = append(candidates, &candidate{scene: &entities.Scene{Scene: common.Scene{SourceID: c.id}}, footprint: newCellsFootprint(c.from, c.to), cost: c.cost})
+		}
+		return candidates
+	}
+
+	for _, test := range []struct {
+		minCoverage float64
+		selected    []string
+		rejected    map[string]string
+	}{
+		// D, A, E and B are added by increasing cost, C covers nothing new, then E and D are redundant
+		{0.01, []string{"A", "B"}, map[string]string{"C": rejectedCovered, "E": rejectedRedundant, "D": rejectedRedundant}},
+		// E covers less than min_coverage
+		{0.15, []string{"A", "B"}, map[string]string{"C": rejectedCovered, "E": rejectedCovered, "D": rejectedRedundant}},
+	} {
+		report, err := selectPeriodScenes(newCandidates(), 10, test.minCoverage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var selected []string
+		for _, s := range report.Selected {
+			selected = append(selected, s.SceneID)
+		}
+		if !reflect.DeepEqual(selected, test.selected) {
+			t.Errorf("min_coverage=%f: expecting %v to be selected, got %v", test.minCoverage, test.selected, selected)
+		}
+		rejected := map[string]string{}
+		for _, r := range report.Rejected {
+			rejected[r.SceneID] = r.Reason
+		}
+		if !reflect.DeepEqual(rejected, test.rejected) {
+			t.Errorf("min_coverage=%f: expecting %v to be rejected, got %v", test.minCoverage, test.rejected, rejected)
+		}
+		if report.Coverage != 1 {
+			t.Errorf("min_coverage=%f: expecting a full coverage, got %f", test.minCoverage, report.Coverage)
+		}
+	}
+
+	// Partial coverage
+	candidates := newCandidates()[:1]
+	if report, err := selectPeriodScenes(candidates, 10, 0.01); err != nil || len(report.Selected) != 1 || report.Coverage != 0.6 || report.Selected[0].Coverage != 0.6 {
+		t.Errorf("expecting a coverage of 0.6, got %v (%v)", report, err)
+	}
+}
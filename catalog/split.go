@@ -0,0 +1,307 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/annotations"
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+	"github.com/airbusgeo/geocube-ingester/service"
+	"github.com/airbusgeo/geocube-ingester/service/geometry"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkt"
+	"github.com/paulsmith/gogeos/geos"
+)
+
+// SplitArea splits the area into child areas along the grid of area.Split (see entities.AOISplit).
+// The AOI of a child area is the intersection of the AOI with a cell of the grid and its name is derived from the name of the area and the name of the cell.
+// If scenes are provided, they are dispatched to the child areas whose AOI they intersect (or by bursts with the burst grid).
+// Otherwise, the scenes of a child area are nil (to be inventoried), except with the burst grid that requires the scenes and the bursts inventories of the whole area.
+func (c *Catalog) SplitArea(ctx context.Context, area entities.AreaToIngest, scenes entities.Scenes) ([]entities.AreaToIngest, []entities.Scenes, error) {
+	if err := c.LoadAOI(ctx, &area); err != nil {
+		return nil, nil, fmt.Errorf("SplitArea.%w", err)
+	}
+	aoi, err := area.GeosAOI(false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SplitArea.%w", err)
+	}
+
+	var cells []splitCell
+	switch area.Split.Grid {
+	case entities.SplitGridDegree, entities.SplitGridMGRS:
+		if cells, err = gridCells(area.AOI, area.Split); err != nil {
+			return nil, nil, fmt.Errorf("SplitArea.%w", err)
+		}
+	case entities.SplitGridBurst:
+		if scenes.Scenes == nil {
+			if scenes, err = c.DoScenesInventory(ctx, area); err != nil {
+				return nil, nil, fmt.Errorf("SplitArea.%w", err)
+			}
+		}
+		if cells, err = c.burstCells(ctx, area, scenes.Scenes); err != nil {
+			return nil, nil, fmt.Errorf("SplitArea.%w", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("SplitArea: unknown grid '%s'", area.Split.Grid)
+	}
+
+	var children []entities.AreaToIngest
+	var childrenScenes []entities.Scenes
+	for _, cell := range cells {
+		childAOI, err := intersectCell(aoi, cell.geometry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("SplitArea[%s].%w", cell.name, err)
+		}
+		if childAOI == nil {
+			continue
+		}
+		child := area
+		child.AOIID, child.AOI, child.AOIFile, child.Split = area.ChildAOIID(cell.name), childAOI, nil, entities.AOISplit{}
+
+		childScenes := entities.Scenes{Properties: scenes.Properties}
+		if cell.scenes != nil {
+			childScenes.Scenes = cell.scenes
+		} else if scenes.Scenes != nil {
+			if childScenes.Scenes, err = intersectingScenes(childAOI, scenes.Scenes); err != nil {
+				return nil, nil, fmt.Errorf("SplitArea[%s].%w", cell.name, err)
+			}
+			if len(childScenes.Scenes) == 0 {
+				continue
+			}
+		}
+		// A scene may belong to several child areas, each one doing its own tiles inventory
+		for i, scene := range childScenes.Scenes {
+			childScenes.Scenes[i] = copyScene(scene)
+		}
+		children = append(children, child)
+		childrenScenes = append(childrenScenes, childScenes)
+	}
+	return children, childrenScenes, nil
+}
+
+// splitCell is a cell of the split of an area
+type splitCell struct {
+	name     string
+	geometry geom.Geometry
+	scenes   []*entities.Scene // Scenes of the cell, if they are known (burst grid)
+}
+
+// gridCells returns the cells of the grid (degree or MGRS) intersecting the extent of the AOI
+// The extent is computed for each side of the antimeridian (see geometry.AntimeridianParts), as the AOI is normalized before the split.
+func gridCells(aoi geom.Geometry, split entities.AOISplit) ([]splitCell, error) {
+	var cells []splitCell
+	names := service.StringSet{}
+	for _, part := range geometry.AntimeridianParts(aoi) {
+		extent, err := geom.NewExtentFromGeometry(part)
+		if err != nil {
+			return nil, fmt.Errorf("gridCells.NewExtentFromGeometry: %w", err)
+		}
+		var grid []geometry.GridCell
+		if split.Grid == entities.SplitGridDegree {
+			grid = geometry.DegreeGrid(extent, split.CellSize())
+		} else {
+			grid = geometry.MGRSGrid(extent)
+		}
+		for _, cell := range grid {
+			if !names.Exists(cell.Name) {
+				names.Push(cell.Name)
+				cells = append(cells, splitCell{name: cell.Name, geometry: cell.Polygon})
+			}
+		}
+	}
+	return cells, nil
+}
+
+// burstCells returns the cells of the bursts of the scenes intersecting the AOI (see burstGroups): the cell of a group is the union of the footprints of its bursts
+// The bursts inventory is done on copies of the scenes, as each child area does its own bursts inventory.
+func (c *Catalog) burstCells(ctx context.Context, area entities.AreaToIngest, scenes []*entities.Scene) ([]splitCell, error) {
+	aoi, err := area.GeosAOI(true)
+	if err != nil {
+		return nil, fmt.Errorf("burstCells.%w", err)
+	}
+	copies := make([]*entities.Scene, len(scenes))
+	origins := map[*entities.Scene]*entities.Scene{}
+	for i, scene := range scenes {
+		copies[i] = copyScene(scene)
+		origins[copies[i]] = scene
+	}
+	burstsScenes, _, err := c.BurstsInventory(ctx, area, *aoi, copies)
+	if err != nil {
+		return nil, fmt.Errorf("burstCells.%w", err)
+	}
+
+	groups, err := burstGroups(burstsScenes, area.Split.CellBursts())
+	if err != nil {
+		return nil, fmt.Errorf("burstCells.%w", err)
+	}
+	var cells []splitCell
+	for _, group := range groups {
+		footprint, err := geometry.WKTUnion(group.footprints, geometry.TOLERANCE_GEOG)
+		if err != nil {
+			return nil, fmt.Errorf("burstCells[%s].%w", group.name, err)
+		}
+		g, err := wkt.DecodeString(footprint)
+		if err != nil {
+			return nil, fmt.Errorf("burstCells[%s].DecodeString: %w", group.name, err)
+		}
+		cell := splitCell{name: group.name, geometry: g}
+		for _, scene := range group.scenes {
+			cell.scenes = append(cell.scenes, origins[scene])
+		}
+		cells = append(cells, cell)
+	}
+	return cells, nil
+}
+
+// burstGroup is a group of consecutive burst IDs of a track, with the footprints of its bursts and the scenes they belong to
+type burstGroup struct {
+	name       string
+	footprints []string
+	scenes     []*entities.Scene
+}
+
+// burstGroups groups the bursts of the scenes by track and by size consecutive burst IDs (all swaths), aligned on the ESA burst IDs,
+// so that the groups (and their names: t<track>_<first burst ID>, e.g. t044_093111) do not depend on the scenes.
+// The bursts of a stack are in the same group and the size of a group is bounded by size bursts along the track.
+func burstGroups(scenes []*entities.Scene, size int) ([]burstGroup, error) {
+	groups := map[string]*burstGroup{}
+	for _, scene := range scenes {
+		for _, burst := range scene.Tiles {
+			track, burstID, _, err := annotations.ParseBurstID(burst.SourceID)
+			if err != nil {
+				return nil, fmt.Errorf("burstGroups.%w", err)
+			}
+			name := fmt.Sprintf("t%03d_%06d", track, (burstID-1)/size*size+1)
+			group, ok := groups[name]
+			if !ok {
+				group = &burstGroup{name: name}
+				groups[name] = group
+			}
+			group.footprints = append(group.footprints, burst.GeometryWKT)
+			if n := len(group.scenes); n == 0 || group.scenes[n-1] != scene {
+				group.scenes = append(group.scenes, scene)
+			}
+		}
+	}
+
+	var sorted []burstGroup
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	return sorted, nil
+}
+
+// copyScene returns a copy of the scene whose tiles can be inventoried independently of the original scene
+func copyScene(scene *entities.Scene) *entities.Scene {
+	cp := *scene
+	cp.Tiles = slices.Clone(scene.Tiles)
+	cp.Data.TileMappings = maps.Clone(scene.Data.TileMappings)
+	return &cp
+}
+
+// intersectCell returns the intersection of the AOI and the cell, or nil if it is empty
+func intersectCell(aoi *geos.Geometry, cell geom.Geometry) (geom.Geometry, error) {
+	cellWKT, err := wkt.EncodeString(cell)
+	if err != nil {
+		return nil, fmt.Errorf("intersectCell.EncodeString: %w", err)
+	}
+	cellGeom, err := geos.FromWKT(cellWKT)
+	if err != nil {
+		return nil, fmt.Errorf("intersectCell.FromWKT: %w", err)
+	}
+	inter, err := aoi.Intersection(cellGeom)
+	if err != nil {
+		return nil, fmt.Errorf("intersectCell.Intersection: %w", err)
+	}
+	if area, err := inter.Area(); err != nil {
+		return nil, fmt.Errorf("intersectCell.Area: %w", err)
+	} else if area == 0 {
+		return nil, nil
+	}
+	g, err := geometry.GeosToGeom(inter)
+	if err != nil {
+		return nil, fmt.Errorf("intersectCell.%w", err)
+	}
+	return g, nil
+}
+
+// intersectingScenes returns the scenes whose footprint intersects the AOI
+func intersectingScenes(aoi geom.Geometry, scenes []*entities.Scene) ([]*entities.Scene, error) {
+	aoiWKT, err := wkt.EncodeString(aoi)
+	if err != nil {
+		return nil, fmt.Errorf("intersectingScenes.EncodeString: %w", err)
+	}
+	g, err := geos.FromWKT(aoiWKT)
+	if err != nil {
+		return nil, fmt.Errorf("intersectingScenes.FromWKT: %w", err)
+	}
+	var intersecting []*entities.Scene
+	for _, scene := range scenes {
+		footprint, err := geos.FromWKT(scene.GeometryWKT)
+		if err != nil {
+			return nil, fmt.Errorf("intersectingScenes.FromWKT[%s]: %w", scene.SourceID, err)
+		}
+		if ok, err := g.Intersects(footprint); err != nil {
+			return nil, fmt.Errorf("intersectingScenes.Intersects[%s]: %w", scene.SourceID, err)
+		} else if ok {
+			intersecting = append(intersecting, scene)
+		}
+	}
+	return intersecting, nil
+}
+
+// ingestSplitArea splits the area (see SplitArea) and ingests each child area independently, under the parent area in the workflow.
+// A child area that has already been ingested is completed with the new scenes.
+func (c *Catalog) ingestSplitArea(ctx context.Context, area entities.AreaToIngest, scenes, scenesWithTiles entities.Scenes, outputDir string) (IngestAreaResult, error) {
+	result := IngestAreaResult{ScenesID: map[string]int{}, Children: map[string]IngestAreaResult{}}
+	if scenesWithTiles.Scenes != nil {
+		return result, fmt.Errorf("ingestSplitArea: an area to split cannot be ingested from a list of tiles")
+	}
+	if c.Workflow == nil {
+		return result, fmt.Errorf("ingestSplitArea: WorkflowServer is not defined")
+	}
+
+	children, childrenScenes, err := c.SplitArea(ctx, area, scenes)
+	if err != nil {
+		return result, fmt.Errorf("ingestSplitArea.%w", err)
+	}
+	if err := c.Workflow.CreateAOI(ctx, area.AOIID); err != nil && !errors.As(err, &db.ErrAlreadyExists{}) {
+		return result, fmt.Errorf("ingestSplitArea.%w", err)
+	}
+
+	for i, child := range children {
+		if err := c.Workflow.CreateAOI(ctx, child.AOIID); err != nil && !errors.As(err, &db.ErrAlreadyExists{}) {
+			return result, fmt.Errorf("ingestSplitArea[%s].%w", child.AOIID, err)
+		}
+		if err := c.Workflow.SetAOIParent(ctx, child.AOIID, area.AOIID); err != nil {
+			return result, fmt.Errorf("ingestSplitArea[%s].%w", child.AOIID, err)
+		}
+		childDir := outputDir
+		if outputDir != "" {
+			childDir = filepath.Join(outputDir, child.AOIID)
+			if err := os.MkdirAll(childDir, 0766); err != nil {
+				return result, fmt.Errorf("ingestSplitArea.MkdirAll: %w", err)
+			}
+		}
+		childResult, err := c.IngestArea(ctx, child, childrenScenes[i], entities.Scenes{}, childDir)
+		if err != nil {
+			return result, fmt.Errorf("ingestSplitArea[%s].%w", child.AOIID, err)
+		}
+		result.Children[child.AOIID] = childResult
+		result.TilesNb += childResult.TilesNb
+		for sourceID, id := range childResult.ScenesID {
+			if _, ok := result.ScenesID[sourceID]; !ok {
+				result.ScenesID[sourceID] = id
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/go-spatial/geom"
+)
+
+func TestGridCellsPacific(t *testing.T) {
+	split := entities.AOISplit{Grid: entities.SplitGridDegree}
+	for _, aoi := range []geom.Polygon{
+		{{{179.5, -17.5}, {-179.5, -17.5}, {-179.5, -16.5}, {179.5, -16.5}, {179.5, -17.5}}}, // 179.5 -> -179.5
+		{{{179.5, -17.5}, {180.5, -17.5}, {180.5, -16.5}, {179.5, -16.5}, {179.5, -17.5}}},   // [0, 360]
+	} {
+		cells, err := gridCells(aoi, split)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := map[string]bool{}
+		for _, cell := range cells {
+			names[cell.name] = true
+		}
+		if len(cells) != 4 || !names["S18E179"] || !names["S17E179"] || !names["S18W180"] || !names["S17W180"] {
+			t.Errorf("expecting the cells on both sides of the antimeridian, got %v", names)
+		}
+	}
+
+	// MGRS: zones 60 and 1
+	cells, err := gridCells(geom.Polygon{{{179.9, -17.1}, {-179.9, -17.1}, {-179.9, -17}, {179.9, -17}, {179.9, -17.1}}}, entities.AOISplit{Grid: entities.SplitGridMGRS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zones := map[string]bool{}
+	for _, cell := range cells {
+		zones[cell.name[:2]] = true
+	}
+	if len(zones) != 2 || !zones["60"] || !zones["01"] {
+		t.Errorf("expecting cells of the zones 60 and 01, got %v", zones)
+	}
+}
+
+func TestBurstGroups(t *testing.T) {
+	newScene := func(id string, bursts ...string) *entities.Scene {
+		scene := &entities.Scene{Scene: common.Scene{SourceID: id}}
+		for _, burst := range bursts {
+			scene.Tiles = append(scene.Tiles, &entities.Tile{TileLite: entities.TileLite{SourceID: burst, SceneID: id}, GeometryWKT: "POLYGON((0 0,1 0,1 1,0 1,0 0))"})
+		}
+		return scene
+	}
+	// S1 and S2 are two acquisitions of the track 44 and S3 an acquisition of the track 117
+	s1 := newScene("S1", "t044_093108_iw1", "t044_093109_iw1", "t044_093110_iw2", "t044_093111_iw1", "t044_093111_iw3")
+	s2 := newScene("S2", "t044_093109_iw1", "t044_093110_iw2", "t044_093111_iw1")
+	s3 := newScene("S3", "t117_250001_iw1")
+
+	groups, err := burstGroups([]*entities.Scene{s1, s2, s3}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.name)
+	}
+	if !reflect.DeepEqual(names, []string{"t044_093101", "t044_093111", "t117_250001"}) {
+		t.Fatalf("expecting the groups t044_093101, t044_093111 and t117_250001, got %v", names)
+	}
+	if len(groups[0].footprints) != 5 || !reflect.DeepEqual(groups[0].scenes, []*entities.Scene{s1, s2}) {
+		t.Errorf("t044_093101: expecting 5 bursts of S1 and S2, got %d bursts of %d scenes", len(groups[0].footprints), len(groups[0].scenes))
+	}
+	if len(groups[1].footprints) != 3 || !reflect.DeepEqual(groups[1].scenes, []*entities.Scene{s1, s2}) {
+		t.Errorf("t044_093111: expecting 3 bursts of S1 and S2, got %d bursts of %d scenes", len(groups[1].footprints), len(groups[1].scenes))
+	}
+	if len(groups[2].footprints) != 1 || !reflect.DeepEqual(groups[2].scenes, []*entities.Scene{s3}) {
+		t.Errorf("t117_250001: expecting 1 burst of S3, got %d bursts of %d scenes", len(groups[2].footprints), len(groups[2].scenes))
+	}
+
+	// One burst ID per group
+	if groups, err = burstGroups([]*entities.Scene{s1}, 1); err != nil || len(groups) != 4 {
+		t.Errorf("expecting 4 groups, got %d (%v)", len(groups), err)
+	}
+
+	// Legacy burst ID
+	if _, err := burstGroups([]*entities.Scene{newScene("S4", "A44_IW1_8951")}, 10); err == nil {
+		t.Error("expecting an error")
+	}
+}
+
+func TestCopyScene(t *testing.T) {
+	scene := &entities.Scene{Scene: common.Scene{SourceID: "S1"}}
+	scene.Data.TileMappings = map[string]common.TileMapping{"t044_093108_iw1": {SwathID: "IW1", TileNr: 1}}
+	scene.Tiles = []*entities.Tile{{TileLite: entities.TileLite{SourceID: "t044_093108_iw1"}}}
+
+	cp := copyScene(scene)
+	cp.Tiles = append(cp.Tiles, &entities.Tile{TileLite: entities.TileLite{SourceID: "t044_093109_iw1"}})
+	cp.Data.TileMappings["t044_093109_iw1"] = common.TileMapping{SwathID: "IW1", TileNr: 2}
+	if len(scene.Tiles) != 1 || len(scene.Data.TileMappings) != 1 {
+		t.Errorf("the original scene must not be modified, got %d tiles and %d tile mappings", len(scene.Tiles), len(scene.Data.TileMappings))
+	}
+	if cp.SourceID != "S1" || len(cp.Tiles) != 2 {
+		t.Errorf("expecting a copy of S1 with 2 tiles, got %s with %d tiles", cp.SourceID, len(cp.Tiles))
+	}
+}
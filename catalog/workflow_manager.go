@@ -21,9 +21,15 @@ type WorkflowManager interface {
 	LeafTiles(ctx context.Context, aoi string) ([]common.Tile, error)
 	// Create an AOI in the workflow server
 	CreateAOI(ctx context.Context, aoi string) error
+	// SetAOIConsolidationLayouts sets the layouts to consolidate the AOI in, when it is DONE
+	SetAOIConsolidationLayouts(ctx context.Context, aoi string, layouts []string) error
+	// SetAOIParent sets the parent of the AOI (child of a split AOI)
+	SetAOIParent(ctx context.Context, aoi, parent string) error
 	// IngestScenes adds new scenes to the workflow and starts the processing
 	// returns id per sourceID of the scenes ingested. If a scene already exists, the sourceID is not in the returned map
 	IngestScenes(ctx context.Context, aoi string, scene ...common.SceneToIngest) (map[string]int, error)
+	// GraphsStats returns the statistics of the processings per graph
+	GraphsStats(ctx context.Context) ([]db.GraphStats, error)
 }
 
 type RemoteWorkflowManager struct {
@@ -53,6 +59,19 @@ func (rwm RemoteWorkflowManager) getTiles(ctx context.Context, aoi, endpoint str
 	return tiles, nil
 }
 
+// GraphsStats implements WorkflowManager
+func (rwm RemoteWorkflowManager) GraphsStats(ctx context.Context) ([]db.GraphStats, error) {
+	body, err := service.HTTPGetWithAuth(ctx, rwm.Server+"/graphs/stats", "", "", rwm.Token)
+	if err != nil {
+		return nil, fmt.Errorf("GraphsStats.%w", err)
+	}
+	stats := []db.GraphStats{}
+	if err = json.Unmarshal(body, &stats); err != nil {
+		return nil, fmt.Errorf("GraphsStats.Unmarshal: %w", err)
+	}
+	return stats, nil
+}
+
 // CreateAOI implements WorkflowManager
 func (rwm RemoteWorkflowManager) CreateAOI(ctx context.Context, aoi string) error {
 	resp, err := service.HTTPPostWithAuth(ctx, rwm.Server+"/aoi/"+aoi, bytes.NewBuffer(nil), "", "", rwm.Token)
@@ -65,6 +84,36 @@ func (rwm RemoteWorkflowManager) CreateAOI(ctx context.Context, aoi string) erro
 	return nil
 }
 
+// SetAOIConsolidationLayouts implements WorkflowManager
+func (rwm RemoteWorkflowManager) SetAOIConsolidationLayouts(ctx context.Context, aoi string, layouts []string) error {
+	body, err := json.Marshal(db.Consolidation{Layouts: layouts})
+	if err != nil {
+		return fmt.Errorf("SetAOIConsolidationLayouts.Marshal: %w", err)
+	}
+	resp, err := service.HTTPPostWithAuth(ctx, rwm.Server+"/aoi/"+aoi+"/consolidation", bytes.NewBuffer(body), "", "", rwm.Token)
+	if err != nil {
+		return fmt.Errorf("SetAOIConsolidationLayouts: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 && resp.StatusCode != 204 {
+		return fmt.Errorf("SetAOIConsolidationLayouts: %s", resp.Status)
+	}
+	return nil
+}
+
+// SetAOIParent implements WorkflowManager
+func (rwm RemoteWorkflowManager) SetAOIParent(ctx context.Context, aoi, parent string) error {
+	resp, err := service.HTTPPostWithAuth(ctx, rwm.Server+"/aoi/"+aoi+"/parent/"+parent, bytes.NewBuffer(nil), "", "", rwm.Token)
+	if err != nil {
+		return fmt.Errorf("SetAOIParent: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 && resp.StatusCode != 204 {
+		return fmt.Errorf("SetAOIParent: %s", resp.Status)
+	}
+	return nil
+}
+
 // IngestScene implements WorkflowManager
 func (rwm RemoteWorkflowManager) IngestScene(ctx context.Context, aoi string, scene common.SceneToIngest) (int, error) {
 	sceneb, err := json.Marshal(scene)
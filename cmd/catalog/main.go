@@ -16,7 +16,12 @@ import (
 	"github.com/airbusgeo/geocube-ingester/catalog"
 	"github.com/airbusgeo/geocube-ingester/catalog/entities"
 	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/annotations"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/oneatlas"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/remotezip"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer/geocube"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer/stac"
+	"github.com/airbusgeo/geocube-ingester/interface/provider"
 	"github.com/airbusgeo/geocube-ingester/service"
 	"github.com/airbusgeo/geocube-ingester/service/log"
 	"github.com/google/uuid"
@@ -25,12 +30,15 @@ import (
 )
 
 type config struct {
-	Area   string
-	Scenes string
+	Area            string
+	Scenes          string
+	ProvisionDryRun bool
+	DryRun          bool
 
 	GeocubeServer         string
 	GeocubeServerInsecure bool
 	GeocubeServerApiKey   string
+	StacURI               string
 	AnnotationsURLs       []string
 	WorkflowServer        string
 	WorkflowToken         string
@@ -40,7 +48,13 @@ type config struct {
 	OneAtlasEndpoint      string
 	CopernicusCatalog     bool
 	CreodiasCatalog       bool
+	ASFCatalog            bool
 	LandsatAwsCatalog     bool
+
+	AnnotationsCopernicusUsername string
+	AnnotationsCopernicusPassword string
+	AnnotationsASFToken           string
+	BurstsCache                   string
 }
 
 func newAppConfig() (*config, error) {
@@ -48,11 +62,18 @@ func newAppConfig() (*config, error) {
 	config := config{}
 	flag.StringVar(&config.Area, "area", "", "Json of the area to process")
 	flag.StringVar(&config.Scenes, "scenes", "", "Json of the scenes to send to the workflow server (shortcut to reuse intermediate results)")
+	flag.BoolVar(&config.DryRun, "dry-run", false, "print the estimation of the ingestion of the area (with -area): number of scenes and tiles, volume to download and to store, processing time and price; and exit")
+	flag.BoolVar(&config.ProvisionDryRun, "provision-dry-run", false, "print the variables and instances that would be created for the layers of the area (with -area) and exit")
 
 	flag.StringVar(&config.GeocubeServer, "geocube-server", "", "address of geocube server")
 	flag.BoolVar(&config.GeocubeServerInsecure, "geocube-insecure", false, "connection to geocube server is insecure (if geocube-server is started without -tls option)")
 	flag.StringVar(&config.GeocubeServerApiKey, "geocube-apikey", "", "geocube server api key")
+	flag.StringVar(&config.StacURI, "stac-uri", "", "uri of a static STAC catalog (currently supported: local, gs) to create the records instead of the Geocube (optional)")
 	flag.StringVar(&annotationsURLs, "annotations-urls", "", "URL (local/gs/aws) containing S1-scenes (as zip) to read annotations without downloading the whole file (optional, contains identifiers between brackets that will be replaced by those of the scene. E.g: gs://bucket/{DATE}/{SCENE}.zip), several urls are coma separated")
+	flag.StringVar(&config.AnnotationsCopernicusUsername, "annotations-copernicus-username", "", "copernicus account username (optional). To read S1 annotations from the remote archives of Copernicus (scenes found with -copernicus-catalog) without downloading the whole file")
+	flag.StringVar(&config.AnnotationsCopernicusPassword, "annotations-copernicus-password", "", "copernicus account password (optional)")
+	flag.StringVar(&config.AnnotationsASFToken, "annotations-asf-token", "", "ASF token (optional). To read S1 annotations from the remote archives of Alaska Satellite Facility without downloading the whole file")
+	flag.StringVar(&config.BurstsCache, "bursts-cache", "", "URI (local/gs/aws) of a cache of the bursts parsed from the S1 annotations (optional, can be shared between several catalogues)")
 	flag.StringVar(&config.WorkflowServer, "workflow-server", "", "address of workflow server")
 	flag.StringVar(&config.WorkflowToken, "workflow-token", "", "address of workflow server")
 	flag.StringVar(&config.ProcessingDir, "workdir", "", "working directory to store intermediate results (could be empty or temporary)")
@@ -61,6 +82,7 @@ func newAppConfig() (*config, error) {
 	flag.StringVar(&config.OneAtlasEndpoint, "oneatlas-endpoint", oneatlas.OneAtlasSearchEndpoint, "oneatlas endpoint to search products from the catalogue")
 	flag.BoolVar(&config.CopernicusCatalog, "copernicus-catalog", false, "Use the copernicus catalog service (search data)")
 	flag.BoolVar(&config.CreodiasCatalog, "creodias-catalog", false, "Use the creodias catalog service (search data)")
+	flag.BoolVar(&config.ASFCatalog, "asf-catalog", false, "Use the ASF Search catalog service (search data, Sentinel-1 only)")
 	flag.BoolVar(&config.LandsatAwsCatalog, "landsat-aws-catalog", false, "Use the Landsat AWS catalog service (search data)")
 	flag.Parse()
 
@@ -89,21 +111,42 @@ func run(ctx context.Context) error {
 
 	c = catalog.Catalog{}
 	{
-		// Geocube client
-		if config.GeocubeServer != "" {
+		// Indexer
+		if config.StacURI != "" {
+			if c.Indexer, err = stac.New(ctx, config.StacURI); err != nil {
+				return err
+			}
+		} else if config.GeocubeServer != "" {
 			var tlsConfig *tls.Config
 			if !config.GeocubeServerInsecure {
 				tlsConfig = &tls.Config{}
 			}
-			if c.GeocubeClient, err = service.NewGeocubeClient(ctx, config.GeocubeServer, config.GeocubeServerApiKey, tlsConfig); err != nil {
+			gcclient, err := service.NewGeocubeClient(ctx, config.GeocubeServer, config.GeocubeServerApiKey, tlsConfig)
+			if err != nil {
 				return err
 			}
+			c.Indexer = geocube.New(gcclient)
 		}
 
 		// Connection to the external catalogue service
 		// GCS Storage
 		c.AnnotationsURLs = config.AnnotationsURLs
 
+		// Cache of the bursts
+		if config.BurstsCache != "" {
+			if c.BurstsCache, err = annotations.NewBurstsCache(ctx, config.BurstsCache); err != nil {
+				return err
+			}
+		}
+
+		// Remote archives (annotations)
+		if config.AnnotationsCopernicusUsername != "" {
+			c.AnnotationsProviders = append(c.AnnotationsProviders, remotezip.AnnotationsProvider{Source: provider.NewCopernicusImageProvider(config.AnnotationsCopernicusUsername, config.AnnotationsCopernicusPassword)})
+		}
+		if config.AnnotationsASFToken != "" {
+			c.AnnotationsProviders = append(c.AnnotationsProviders, remotezip.AnnotationsProvider{Source: provider.NewASFImageProvider(config.AnnotationsASFToken)})
+		}
+
 		// Workflow Server
 		if config.WorkflowServer != "" {
 			c.Workflow = catalog.RemoteWorkflowManager{Server: config.WorkflowServer, Token: config.WorkflowToken}
@@ -123,11 +166,22 @@ func run(ctx context.Context) error {
 		// Creodias catalogue
 		c.CreodiasCatalog = config.CreodiasCatalog
 
+		// ASF catalogue
+		c.ASFCatalog = config.ASFCatalog
+
 		// Landsat AWS catalogue
 		c.LandsatAwsCatalog = config.LandsatAwsCatalog
 	}
 
 	if config.Area != "" {
+		// Command line: the aoi_file may be a local file (not allowed through the HTTP server)
+		c.LocalAOIFiles = true
+		if config.ProvisionDryRun {
+			return provisionDryRun(ctx, config.Area)
+		}
+		if config.DryRun {
+			return estimateArea(ctx, config.Area)
+		}
 		if config.Scenes != "" {
 			return sendScenes(ctx, config.Area, config.Scenes)
 		}
@@ -165,6 +219,10 @@ func ingestArea(ctx context.Context, jsonPath string) error {
 	if err = json.Unmarshal(byteValue, &area); err != nil {
 		return err
 	}
+	// Load the AOI before changing the working directory (the path of the vector file may be relative)
+	if err = c.LoadAOI(ctx, &area); err != nil {
+		return err
+	}
 
 	var workingDir string
 	if c.WorkingDir != "" {
@@ -189,6 +247,51 @@ func ingestArea(ctx context.Context, jsonPath string) error {
 	return err
 }
 
+func provisionDryRun(ctx context.Context, jsonPath string) error {
+	area := entities.AreaToIngest{}
+	byteValue, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(byteValue, &area); err != nil {
+		return err
+	}
+
+	actions, err := c.ProvisionLayers(ctx, &area, true)
+	if err != nil {
+		return err
+	}
+	if len(actions) == 0 {
+		fmt.Println("Nothing to create")
+	}
+	for _, action := range actions {
+		fmt.Println(action)
+	}
+	return nil
+}
+
+func estimateArea(ctx context.Context, jsonPath string) error {
+	area := entities.AreaToIngest{}
+	byteValue, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(byteValue, &area); err != nil {
+		return err
+	}
+
+	estimate, err := c.EstimateArea(ctx, area, entities.Scenes{}, entities.Scenes{}, "")
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(estimate, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
 func sendScenes(ctx context.Context, areaJsonPath, scenesJsonPath string) error {
 	area := entities.AreaToIngest{}
 	jsonFile, err := os.Open(areaJsonPath)
@@ -3,15 +3,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/airbusgeo/geocube-ingester/common"
 	"github.com/airbusgeo/geocube-ingester/downloader"
 	"github.com/airbusgeo/geocube-ingester/graph"
+	"github.com/airbusgeo/geocube-ingester/interface/orbit"
 	"github.com/airbusgeo/geocube-ingester/interface/provider"
 	"github.com/airbusgeo/geocube-ingester/service"
 	"github.com/airbusgeo/geocube-ingester/service/log"
@@ -53,6 +57,10 @@ type config struct {
 	LandsatAwsAccessKeyId             string
 	LandsatAwsSecretAccessKey         string
 
+	OrbitProviders []string
+	OrbitMirror    string
+	SnapAuxdataDir string
+
 	WithDockerEngine bool
 	Docker           graph.DockerConfig
 }
@@ -97,6 +105,11 @@ func newAppConfig() (*config, error) {
 	flag.StringVar(&config.FTPUsername, "ftp-username", "", "ftp username (optional).")
 	flag.StringVar(&config.FTPPassword, "ftp-password", "", "ftp password (optional)")
 
+	// Orbit files
+	orbitProviders := flag.String("orbit-providers", "", "providers of the orbit files of the Sentinel-1 scenes, comma-separated (optional, among copernicus (requires -copernicus-username), asf (uses -asf-token if defined)). The orbit file is fetched before the scene and stored in the auxdata directory of SNAP (see -snap-auxdata).")
+	flag.StringVar(&config.OrbitMirror, "orbit-mirror", "", "storage uri (local, gs, s3) to store the orbit files fetched from the providers and share them between the downloaders (optional)")
+	flag.StringVar(&config.SnapAuxdataDir, "snap-auxdata", "", "auxdata directory of SNAP, where the orbit files are stored for the Apply-Orbit-File operator (default: $HOME/.snap/auxdata)")
+
 	// Docker processing Images connection
 	flag.BoolVar(&config.WithDockerEngine, "with-docker-engine", false, "activate the support of graph.engine == 'docker' (require a running docker-daemon)")
 	dockerEnvsStr := config.Docker.SetFlags()
@@ -113,6 +126,16 @@ func newAppConfig() (*config, error) {
 	if config.StorageURI == "" {
 		return nil, fmt.Errorf("missing storage-uri config flag")
 	}
+	if *orbitProviders != "" {
+		config.OrbitProviders = strings.Split(*orbitProviders, ",")
+	}
+	if config.SnapAuxdataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("snap-auxdata: %w", err)
+		}
+		config.SnapAuxdataDir = filepath.Join(home, ".snap", "auxdata")
+	}
 	if *gsProviderBuckets != "" {
 		config.GSProviderBuckets = strings.Split(*gsProviderBuckets, ",")
 	}
@@ -250,6 +273,30 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("no image providers defined... ")
 	}
 
+	// Load orbit providers
+	var orbits *orbit.Manager
+	if len(config.OrbitProviders) != 0 || config.OrbitMirror != "" {
+		orbits = &orbit.Manager{}
+		for _, orbitProvider := range config.OrbitProviders {
+			switch strings.ToLower(strings.TrimSpace(orbitProvider)) {
+			case "copernicus":
+				if config.CopernicusUsername == "" {
+					return fmt.Errorf("orbit provider copernicus requires -copernicus-username")
+				}
+				orbits.Providers = append(orbits.Providers, &orbit.CopernicusProvider{Tokens: provider.NewCopernicusImageProvider(config.CopernicusUsername, config.CopernicusPassword)})
+			case "asf":
+				orbits.Providers = append(orbits.Providers, &orbit.ASFProvider{Token: config.ASFToken})
+			default:
+				return fmt.Errorf("unknown orbit provider: %s", orbitProvider)
+			}
+		}
+		if config.OrbitMirror != "" {
+			if orbits.Mirror, err = orbit.NewStorageMirror(ctx, config.OrbitMirror); err != nil {
+				return fmt.Errorf("orbit mirror %s: %w", config.OrbitMirror, err)
+			}
+		}
+	}
+
 	jobStarted := time.Time{}
 	go func() {
 		http.HandleFunc("/termination_cost", func(w http.ResponseWriter, r *http.Request) {
@@ -283,6 +330,7 @@ func run(ctx context.Context) error {
 			log.Logger(log.With(ctx, "body", string(msg.Data))).Sugar().Debugf("message '%s' try %d", msg.ID, msg.TryCount)
 			scene := common.Scene{}
 			message := ""
+			var duration float64
 
 			if err = json.Unmarshal(msg.Data, &scene); err != nil {
 				return fmt.Errorf("invalid payload: %w", err)
@@ -308,10 +356,11 @@ func run(ctx context.Context) error {
 					message = err.Error()
 				}
 				res := common.Result{
-					Type:    common.ResultTypeScene,
-					ID:      scene.ID,
-					Status:  status,
-					Message: message,
+					Type:     common.ResultTypeScene,
+					ID:       scene.ID,
+					Status:   status,
+					Message:  message,
+					Duration: duration,
 				}
 				resb, e := json.Marshal(res)
 				if e != nil {
@@ -324,7 +373,12 @@ func run(ctx context.Context) error {
 				return fmt.Errorf("too many retries")
 			}
 
-			if err = downloader.ProcessScene(ctx, imageProviders, storageService, scene, config.WorkingDir, graphOpts); err != nil {
+			if err = downloader.ProcessScene(ctx, imageProviders, orbits, config.SnapAuxdataDir, storageService, scene, config.WorkingDir, graphOpts); err != nil {
+				if errors.Is(err, orbit.ErrNotAvailable) {
+					// Wait for the orbit file
+					status = common.StatusRETRY
+					return err
+				}
 				if msg.TryCount >= maxTries {
 					return fmt.Errorf("too many retries: %w", err)
 				}
@@ -332,6 +386,7 @@ func run(ctx context.Context) error {
 			}
 			log.Logger(ctx).Sugar().Infof("successfully processed scene %s", scene.SourceID)
 			status = common.StatusDONE
+			duration = time.Since(jobStarted).Seconds()
 			return
 		})
 		if err != nil {
@@ -13,6 +13,9 @@ import (
 	"github.com/airbusgeo/geocube-ingester/graph"
 
 	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer/geocube"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer/stac"
 	"github.com/airbusgeo/geocube-ingester/processor"
 	"github.com/airbusgeo/geocube-ingester/service"
 	"github.com/airbusgeo/geocube-ingester/service/log"
@@ -35,6 +38,10 @@ type config struct {
 	GeocubeServerInsecure bool
 	GeocubeServerApiKey   string
 
+	StacURI string
+
+	COGValidation string
+
 	WithDockerEngine bool
 	Docker           graph.DockerConfig
 }
@@ -56,6 +63,12 @@ func newAppConfig() (*config, error) {
 	flag.BoolVar(&config.GeocubeServerInsecure, "geocube-insecure", false, "connection to geocube server is insecure")
 	flag.StringVar(&config.GeocubeServerApiKey, "geocube-apikey", "", "geocube server api key")
 
+	// STAC indexer
+	flag.StringVar(&config.StacURI, "stac-uri", "", "uri of a static STAC catalog (currently supported: local, gs) to index the outputs instead of the Geocube (optional)")
+
+	// Outputs validation
+	flag.StringVar(&config.COGValidation, "cog-validation", "", "validation of the GeoTIFF outputs to be indexed (layout, CRS, nodata): none, convert (in-place conversion to COG) or fail (fatal error) (default: none)")
+
 	// Docker processing Images connection
 	flag.BoolVar(&config.WithDockerEngine, "with-docker-engine", false, "activate the support of graph.engine == 'docker' (require a running docker-daemon)")
 	dockerEnvsStr := config.Docker.SetFlags()
@@ -72,8 +85,8 @@ func newAppConfig() (*config, error) {
 	if config.StorageURI == "" {
 		return nil, fmt.Errorf("wrong storage-uri config flag")
 	}
-	if config.GeocubeServer == "" {
-		return nil, fmt.Errorf("missing geocube server flag")
+	if config.GeocubeServer == "" && config.StacURI == "" {
+		return nil, fmt.Errorf("missing geocube server or stac uri flag")
 	}
 	return &config, nil
 }
@@ -141,12 +154,25 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("storage[%s].%w", config.StorageURI, err)
 	}
 
-	// Geocube client
-	var tlsConfig *tls.Config
-	if !config.GeocubeServerInsecure {
-		tlsConfig = &tls.Config{}
+	// Indexer
+	var idx indexer.Indexer
+	if config.StacURI != "" {
+		if idx, err = stac.New(ctx, config.StacURI); err != nil {
+			return err
+		}
+	} else {
+		var tlsConfig *tls.Config
+		if !config.GeocubeServerInsecure {
+			tlsConfig = &tls.Config{}
+		}
+		gcclient, err := service.NewGeocubeClient(ctx, config.GeocubeServer, config.GeocubeServerApiKey, tlsConfig)
+		if err != nil {
+			return err
+		}
+		idx = geocube.New(gcclient)
 	}
-	gcclient, err := service.NewGeocubeClient(ctx, config.GeocubeServer, config.GeocubeServerApiKey, tlsConfig)
+
+	cogValidation, err := processor.COGValidationFromString(config.COGValidation)
 	if err != nil {
 		return err
 	}
@@ -185,6 +211,8 @@ func run(ctx context.Context) error {
 			log.Logger(log.With(ctx, "body", string(msg.Data))).Sugar().Debugf("message %s try %d", msg.ID, msg.TryCount)
 			tile := common.TileToProcess{}
 			message := ""
+			var duration float64
+			var outputs []common.TileOutput
 			if err := json.Unmarshal(msg.Data, &tile); err != nil {
 				return fmt.Errorf("invalid payload: %w", err)
 			} else if tile.ID == 0 {
@@ -209,10 +237,12 @@ func run(ctx context.Context) error {
 					message = err.Error()
 				}
 				res := common.Result{
-					Type:    common.ResultTypeTile,
-					ID:      tile.ID,
-					Status:  status,
-					Message: message,
+					Type:     common.ResultTypeTile,
+					ID:       tile.ID,
+					Status:   status,
+					Message:  message,
+					Duration: duration,
+					Outputs:  outputs,
 				}
 				resb, e := json.Marshal(res)
 				if e != nil {
@@ -225,7 +255,7 @@ func run(ctx context.Context) error {
 				return fmt.Errorf("too many retries")
 			}
 
-			if err = processor.ProcessTile(ctx, storageService, gcclient, tile, config.WorkingDir, graphOpts); err != nil {
+			if outputs, err = processor.ProcessTile(ctx, storageService, idx, tile, config.WorkingDir, graphOpts, cogValidation); err != nil {
 				if msg.TryCount >= maxTries {
 					return fmt.Errorf("too many retries: %w", err)
 				}
@@ -236,6 +266,7 @@ func run(ctx context.Context) error {
 			}
 			log.Logger(ctx).Sugar().Infof("successfully processed tile %s/%s", tile.Scene.SourceID, tile.SourceID)
 			status = common.StatusDONE
+			duration = time.Since(jobStarted).Seconds()
 			return
 		})
 		if err != nil {
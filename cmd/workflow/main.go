@@ -12,8 +12,13 @@ import (
 
 	"github.com/airbusgeo/geocube-ingester/catalog"
 	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/annotations"
 	"github.com/airbusgeo/geocube-ingester/interface/catalog/oneatlas"
+	"github.com/airbusgeo/geocube-ingester/interface/catalog/remotezip"
 	"github.com/airbusgeo/geocube-ingester/interface/database/pg"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer/geocube"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer/stac"
+	"github.com/airbusgeo/geocube-ingester/interface/provider"
 	"github.com/airbusgeo/geocube-ingester/service"
 	"github.com/airbusgeo/geocube-ingester/service/log"
 	"github.com/airbusgeo/geocube-ingester/workflow"
@@ -37,6 +42,11 @@ type catalogConfig struct {
 	GeocubeServer                  string
 	GeocubeServerInsecure          bool
 	GeocubeServerApiKey            string
+	StacURI                        string
+	AnnotationsCopernicusUsername  string
+	AnnotationsCopernicusPassword  string
+	AnnotationsASFToken            string
+	BurstsCache                    string
 	AnnotationsURLs                []string
 	OneAtlasUsername               string
 	OneAtlasApikey                 string
@@ -45,6 +55,7 @@ type catalogConfig struct {
 	OneAtlasAuthenticationEndpoint string
 	CopernicusCatalog              bool
 	CreodiasCatalog                bool
+	ASFCatalog                     bool
 	LandsatAwsCatalog              bool
 }
 
@@ -60,6 +71,7 @@ type config struct {
 	ProcessorQueue   string
 	AutoscalerConfig autoscalerConfig
 	CatalogConfig    catalogConfig
+	Subscriptions    time.Duration
 }
 
 func newAppConfig() (*config, error) {
@@ -90,9 +102,14 @@ func newAppConfig() (*config, error) {
 	flag.StringVar(&config.CatalogConfig.GeocubeServer, "geocube-server", "", "address of geocube server (e.g. 127.0.0.1:8080)")
 	flag.BoolVar(&config.CatalogConfig.GeocubeServerInsecure, "geocube-insecure", false, "connection to geocube server is insecure")
 	flag.StringVar(&config.CatalogConfig.GeocubeServerApiKey, "geocube-apikey", "", "geocube server api key")
+	flag.StringVar(&config.CatalogConfig.StacURI, "stac-uri", "", "uri of a static STAC catalog (currently supported: local, gs) to create the records instead of the Geocube (optional)")
 
 	// Providers
 	flag.StringVar(&annotationsURLs, "annotations-urls", "", "URL (local/gs/aws) containing S1-scenes (as zip) to read annotations without downloading the whole file (optional, contains identifiers between brackets that will be replaced by those of the scene. E.g: gs://bucket/{DATE}/{SCENE}.zip), several urls are coma separated")
+	flag.StringVar(&config.CatalogConfig.AnnotationsCopernicusUsername, "annotations-copernicus-username", "", "copernicus account username (optional). To read S1 annotations from the remote archives of Copernicus (scenes found with -copernicus-catalog) without downloading the whole file")
+	flag.StringVar(&config.CatalogConfig.AnnotationsCopernicusPassword, "annotations-copernicus-password", "", "copernicus account password (optional)")
+	flag.StringVar(&config.CatalogConfig.AnnotationsASFToken, "annotations-asf-token", "", "ASF token (optional). To read S1 annotations from the remote archives of Alaska Satellite Facility without downloading the whole file")
+	flag.StringVar(&config.CatalogConfig.BurstsCache, "bursts-cache", "", "URI (local/gs/aws) of a cache of the bursts parsed from the S1 annotations (optional, can be shared between several catalogues)")
 	flag.StringVar(&config.CatalogConfig.OneAtlasUsername, "oneatlas-username", "APIKEY", "oneatlas account username (optional). To configure Oneatlas as a potential image Provider.")
 	flag.StringVar(&config.CatalogConfig.OneAtlasApikey, "oneatlas-apikey", "", fmt.Sprintf("oneatlas account apikey (to generate an api key for your account: %s)", oneatlas.OneAtlasCreateApiKeyEndpoint))
 	flag.StringVar(&config.CatalogConfig.OneAtlasEndpoint, "oneatlas-endpoint", oneatlas.OneAtlasSearchEndpoint, "oneatlas endpoint to search products from the catalogue")
@@ -100,8 +117,12 @@ func newAppConfig() (*config, error) {
 	flag.StringVar(&config.CatalogConfig.OneAtlasAuthenticationEndpoint, "oneatlas-auth-endpoint", oneatlas.OneAtlasAuthenticationEndpoint, "oneatlas order endpoint to use")
 	flag.BoolVar(&config.CatalogConfig.CopernicusCatalog, "copernicus-catalog", false, "Use the Copernicus catalog service (search data)")
 	flag.BoolVar(&config.CatalogConfig.CreodiasCatalog, "creodias-catalog", false, "Use the creodias catalog service (search data)")
+	flag.BoolVar(&config.CatalogConfig.ASFCatalog, "asf-catalog", false, "Use the ASF Search catalog service (search data, Sentinel-1 only)")
 	flag.BoolVar(&config.CatalogConfig.LandsatAwsCatalog, "landsat-aws-catalog", false, "Use the Landsat AWS catalog service (search data)")
 
+	// Subscriptions
+	flag.DurationVar(&config.Subscriptions, "subscriptions-polling", time.Minute, "period of the polling of the due subscriptions (0 to disable the subscriptions)")
+
 	flag.Parse()
 
 	if config.AppPort == "" {
@@ -227,29 +248,53 @@ func run(ctx context.Context) error {
 
 	catalog := catalog.Catalog{}
 	{
-		// Geocube client
-		if config.CatalogConfig.GeocubeServer != "" {
+		// Indexer
+		if config.CatalogConfig.StacURI != "" {
+			if catalog.Indexer, err = stac.New(ctx, config.CatalogConfig.StacURI); err != nil {
+				return fmt.Errorf("stac indexer: %w", err)
+			}
+		} else if config.CatalogConfig.GeocubeServer != "" {
 			var tlsConfig *tls.Config
 			if !config.CatalogConfig.GeocubeServerInsecure {
 				tlsConfig = &tls.Config{}
 			}
-			if catalog.GeocubeClient, err = service.NewGeocubeClient(ctx, config.CatalogConfig.GeocubeServer, config.CatalogConfig.GeocubeServerApiKey, tlsConfig); err != nil {
+			gcclient, err := service.NewGeocubeClient(ctx, config.CatalogConfig.GeocubeServer, config.CatalogConfig.GeocubeServerApiKey, tlsConfig)
+			if err != nil {
 				return fmt.Errorf("connection to geocube: %w", err)
 			}
+			catalog.Indexer = geocube.New(gcclient)
 		} else {
-			log.Logger(ctx).Warn("Geocube server is not configured. Some catalogue functions are disabled.")
+			log.Logger(ctx).Warn("Neither Geocube server nor STAC catalog is configured. Some catalogue functions are disabled.")
 		}
 
 		// Connection to the external catalogue service
 		// GCStorage
 		catalog.AnnotationsURLs = config.CatalogConfig.AnnotationsURLs
 
+		// Cache of the bursts
+		if config.CatalogConfig.BurstsCache != "" {
+			if catalog.BurstsCache, err = annotations.NewBurstsCache(ctx, config.CatalogConfig.BurstsCache); err != nil {
+				return fmt.Errorf("bursts cache: %w", err)
+			}
+		}
+
+		// Remote archives (annotations)
+		if config.CatalogConfig.AnnotationsCopernicusUsername != "" {
+			catalog.AnnotationsProviders = append(catalog.AnnotationsProviders, remotezip.AnnotationsProvider{Source: provider.NewCopernicusImageProvider(config.CatalogConfig.AnnotationsCopernicusUsername, config.CatalogConfig.AnnotationsCopernicusPassword)})
+		}
+		if config.CatalogConfig.AnnotationsASFToken != "" {
+			catalog.AnnotationsProviders = append(catalog.AnnotationsProviders, remotezip.AnnotationsProvider{Source: provider.NewASFImageProvider(config.CatalogConfig.AnnotationsASFToken)})
+		}
+
 		// Copernicus Catalogue
 		catalog.CopernicusCatalog = config.CatalogConfig.CopernicusCatalog
 
 		// Creodias Catalogue
 		catalog.CreodiasCatalog = config.CatalogConfig.CreodiasCatalog
 
+		// ASF Catalogue
+		catalog.ASFCatalog = config.CatalogConfig.ASFCatalog
+
 		// Landsat AWS catalogue
 		catalog.LandsatAwsCatalog = config.CatalogConfig.LandsatAwsCatalog
 
@@ -266,7 +311,13 @@ func run(ctx context.Context) error {
 	// New handler
 	router := wf.NewRouter()
 	catalog.Workflow = wf
+	catalog.Jobs = wf
+	if config.Subscriptions > 0 {
+		go wf.RunSubscriptions(ctx, config.Subscriptions)
+	}
+	go wf.RunConsolidations(ctx, time.Minute)
 	catalog.AddHandler(router)
+	go catalog.ResumeJobs(ctx, time.Minute)
 	headersOk := handlers.AllowedHeaders([]string{"*", AuthorizationHeader})
 	originsOk := handlers.AllowedOrigins([]string{"*"})
 	methodsOk := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "OPTIONS", "DELETE"})
@@ -62,4 +62,78 @@ func TestInfo(t *testing.T) {
 		checkKeyValue(t, format, "MISSION", "02D361")
 		checkKeyValue(t, format, "UNIQUE_ID", "7F7C")
 	}
+	if _, err := Info("S3A_OL_1_EFR____20230101T100000_20230101T100300_20230102T150000_0179_094_122_2160_PS1_O_NT_00"); err == nil {
+		t.Errorf("too short file name")
+	}
+	if format, err := Info("S3A_OL_1_EFR____20230101T100000_20230101T100300_20230102T150000_0179_094_122_2160_PS1_O_NT_003.SEN3"); err != nil {
+		t.Error(err.Error())
+	} else {
+		checkKeyValue(t, format, "MISSION_ID", "S3A")
+		checkKeyValue(t, format, "MISSION_VERSION", "A")
+		checkKeyValue(t, format, "INSTRUMENT", "OL")
+		checkKeyValue(t, format, "PROCESSING_LEVEL", "1")
+		checkKeyValue(t, format, "PRODUCT_TYPE", "EFR")
+		checkKeyValue(t, format, "DATE", "20230101")
+		checkKeyValue(t, format, "TIME", "100000")
+		checkKeyValue(t, format, "DURATION", "0179")
+		checkKeyValue(t, format, "CYCLE", "094")
+		checkKeyValue(t, format, "ORBIT", "122")
+		checkKeyValue(t, format, "FRAME", "2160")
+		checkKeyValue(t, format, "CENTRE", "PS1")
+		checkKeyValue(t, format, "PLATFORM", "O")
+		checkKeyValue(t, format, "TIMELINESS", "NT")
+		checkKeyValue(t, format, "BASELINE", "003")
+	}
+	if c := GetConstellationFromString("sentinel-3"); c != Sentinel3 {
+		t.Errorf("expected Sentinel3, got %s", c)
+	}
+	if format, err := Info("LT05_L2SP_196026_20000805_20200906_02_T1"); err != nil {
+		t.Error(err.Error())
+	} else {
+		checkKeyValue(t, format, "MISSION_ID", "L05")
+		checkKeyValue(t, format, "PROCESSING_LEVEL", "L2SP")
+		checkKeyValue(t, format, "DATE", "20000805")
+		checkKeyValue(t, format, "COLLECTION", "tm")
+		checkKeyValue(t, format, "COLLECTION_NUMBER", "02")
+		checkKeyValue(t, format, "COLLECTION_CATEGORY", "T1")
+		checkKeyValue(t, format, "PATH", "196")
+		checkKeyValue(t, format, "ROW", "026")
+	}
+	for sceneName, expected := range map[string]Constellation{
+		"LT05_L1TP_196026_20000805_20200906_02_T1": Landsat457,
+		"LE07_L2SP_196026_20000813_20200917_02_T1": Landsat457,
+		"LT09_L1GT_166003_20250603_20250603_02_T2": Landsat89,
+		"LC08_L2SP_196026_20200805_20200916_02_T1": Landsat89,
+	} {
+		if c := GetConstellationFromProductId(sceneName); c != expected {
+			t.Errorf("%s: expected %s, got %s", sceneName, expected, c)
+		}
+	}
+	if format, err := Info("LT09_L1GT_166003_20250603_20250603_02_T2"); err != nil {
+		t.Error(err.Error())
+	} else {
+		checkKeyValue(t, format, "COLLECTION", "tirs")
+	}
+}
+
+func TestTileToProcessTiles(t *testing.T) {
+	cur, prev := Tile{SourceID: "current"}, Tile{SourceID: "previous"}
+	tile := TileToProcess{
+		Tile:     cur,
+		Previous: prev,
+		Inputs: []TileInput{
+			{Role: "previous_2", Tile: Tile{SourceID: "previous_2"}},
+			{Role: "previous_3"},
+		},
+	}
+	tiles := tile.Tiles()
+	expected := []string{"current", "previous", "current", "previous_2", "current"}
+	if len(tiles) != len(expected) {
+		t.Fatalf("expected %d tiles, got %d", len(expected), len(tiles))
+	}
+	for i, sourceID := range expected {
+		if tiles[i].SourceID != sourceID {
+			t.Errorf("tile %d: expected %s, got %s", i, sourceID, tiles[i].SourceID)
+		}
+	}
 }
@@ -8,11 +8,11 @@ import (
 	"strings"
 )
 
-const _ConstellationName = "UnknownSentinel1Sentinel2PHRSPOTLandsat89"
+const _ConstellationName = "UnknownSentinel1Sentinel2PHRSPOTLandsat89Sentinel3Landsat457"
 
-var _ConstellationIndex = [...]uint8{0, 7, 16, 25, 28, 32, 41}
+var _ConstellationIndex = [...]uint8{0, 7, 16, 25, 28, 32, 41, 50, 60}
 
-const _ConstellationLowerName = "unknownsentinel1sentinel2phrspotlandsat89"
+const _ConstellationLowerName = "unknownsentinel1sentinel2phrspotlandsat89sentinel3landsat457"
 
 func (i Constellation) String() string {
 	if i < 0 || i >= Constellation(len(_ConstellationIndex)-1) {
@@ -31,9 +31,11 @@ func _ConstellationNoOp() {
 	_ = x[PHR-(3)]
 	_ = x[SPOT-(4)]
 	_ = x[Landsat89-(5)]
+	_ = x[Sentinel3-(6)]
+	_ = x[Landsat457-(7)]
 }
 
-var _ConstellationValues = []Constellation{Unknown, Sentinel1, Sentinel2, PHR, SPOT, Landsat89}
+var _ConstellationValues = []Constellation{Unknown, Sentinel1, Sentinel2, PHR, SPOT, Landsat89, Sentinel3, Landsat457}
 
 var _ConstellationNameToValueMap = map[string]Constellation{
 	_ConstellationName[0:7]:        Unknown,
@@ -48,6 +50,10 @@ var _ConstellationNameToValueMap = map[string]Constellation{
 	_ConstellationLowerName[28:32]: SPOT,
 	_ConstellationName[32:41]:      Landsat89,
 	_ConstellationLowerName[32:41]: Landsat89,
+	_ConstellationName[41:50]:      Sentinel3,
+	_ConstellationLowerName[41:50]: Sentinel3,
+	_ConstellationName[50:60]:      Landsat457,
+	_ConstellationLowerName[50:60]: Landsat457,
 }
 
 var _ConstellationNames = []string{
@@ -57,6 +63,8 @@ var _ConstellationNames = []string{
 	_ConstellationName[25:28],
 	_ConstellationName[28:32],
 	_ConstellationName[32:41],
+	_ConstellationName[41:50],
+	_ConstellationName[50:60],
 }
 
 // ConstellationString retrieves an enum value from the enum constants string name.
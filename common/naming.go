@@ -13,12 +13,14 @@ import (
 type Constellation int
 
 const (
-	Unknown   Constellation = iota
-	Sentinel1               // MMM_BB_TTTR_LFPP_YYYYMMDDTHHMMSS_YYYMMDDTHHMMSS_OOOOOO_DDDDDD_CCCC.SAFE
-	Sentinel2               // MMM_MSIXXX_YYYYMMDDTHHMMSS_Nxxyy_ROOO_Txxxxx_<Product Discriminator>.SAFE or MMM_CCCC_FFFFDDDDDD_ssss_YYYYMMDDTHHMMSS_ROOO_VYYYYMMTDDHHMMSS_YYYYMMTDDHHMMSS.SAFE
-	PHR                     // DS_PHR1B_201706161037358_XXX_XX_XXXXXXX_XXXX_XXXXX
-	SPOT                    // DS_SPOT7_201806232333174_XXX_XXX_XXX_XXX_XXXXXXX_XXXXX
-	Landsat89               // LXSS_LLLL_PPPRRR_YYYYMMDD_yyyymmdd_CX_TX
+	Unknown    Constellation = iota
+	Sentinel1                // MMM_BB_TTTR_LFPP_YYYYMMDDTHHMMSS_YYYMMDDTHHMMSS_OOOOOO_DDDDDD_CCCC.SAFE
+	Sentinel2                // MMM_MSIXXX_YYYYMMDDTHHMMSS_Nxxyy_ROOO_Txxxxx_<Product Discriminator>.SAFE or MMM_CCCC_FFFFDDDDDD_ssss_YYYYMMDDTHHMMSS_ROOO_VYYYYMMTDDHHMMSS_YYYYMMTDDHHMMSS.SAFE
+	PHR                      // DS_PHR1B_201706161037358_XXX_XX_XXXXXXX_XXXX_XXXXX
+	SPOT                     // DS_SPOT7_201806232333174_XXX_XXX_XXX_XXX_XXXXXXX_XXXXX
+	Landsat89                // LXSS_LLLL_PPPRRR_YYYYMMDD_yyyymmdd_CX_TX
+	Sentinel3                // MMM_SS_L_TTTTTT_YYYYMMDDTHHMMSS_YYYYMMDDTHHMMSS_YYYYMMDDTHHMMSS_DDDD_CCC_LLL_FFFF_GGG_P_XX_NNN.SEN3
+	Landsat457               // LXSS_LLLL_PPPRRR_YYYYMMDD_yyyymmdd_CX_TX (Landsat 4, 5 & 7: TM, ETM+, MSS)
 )
 
 // GetConstellation returns the constellation from the user input
@@ -28,8 +30,12 @@ func GetConstellationFromString(input string) Constellation {
 		return Sentinel1
 	case "sentinel2", "sentinel-2":
 		return Sentinel2
+	case "sentinel3", "sentinel-3":
+		return Sentinel3
 	case "landsat89":
 		return Landsat89
+	case "landsat457":
+		return Landsat457
 	case "phr", "pleiades":
 		return PHR
 	case "spot":
@@ -45,6 +51,9 @@ func GetConstellationFromProductId(sceneName string) Constellation {
 	if strings.HasPrefix(sceneName, "S2") {
 		return Sentinel2
 	}
+	if strings.HasPrefix(sceneName, "S3") {
+		return Sentinel3
+	}
 	if strings.HasPrefix(sceneName, "DS_PHR") {
 		return PHR
 	}
@@ -54,6 +63,9 @@ func GetConstellationFromProductId(sceneName string) Constellation {
 	if regexp.MustCompile("^L[OTC]0[89]").MatchString(sceneName) {
 		return Landsat89
 	}
+	if regexp.MustCompile("^L[TEM]0[457]").MatchString(sceneName) {
+		return Landsat457
+	}
 	return Unknown
 }
 
@@ -128,6 +140,35 @@ func Info(sceneName string) (map[string]string, error) {
 			"PRODUCT_LEVEL": sceneName[16:19],
 			"ORBIT":         sceneName[42:45],
 		}, nil
+	case Sentinel3:
+		// S3A_OL_1_EFR____20230101T100000_20230101T100300_20230102T150000_0179_094_122_2160_PS1_O_NT_003
+		if len(sceneName) < len("MMM_SS_L_TTTTTT_YYYYMMDDTHHMMSS_YYYYMMDDTHHMMSS_YYYYMMDDTHHMMSS_DDDD_CCC_LLL_FFFF_GGG_P_XX_NNN") {
+			return nil, fmt.Errorf("invalid Sentinel3 file name: %s", sceneName)
+		}
+		return map[string]string{
+			"SCENE":            sceneName,
+			"MISSION_ID":       sceneName[0:3],
+			"MISSION_VERSION":  sceneName[2:3],
+			"INSTRUMENT":       sceneName[4:6],
+			"PROCESSING_LEVEL": sceneName[7:8],
+			"PRODUCT_TYPE":     strings.TrimRight(sceneName[9:15], "_"),
+			"DATE":             sceneName[16:24],
+			"YEAR":             sceneName[16:20],
+			"MONTH":            sceneName[20:22],
+			"DAY":              sceneName[22:24],
+			"TIME":             sceneName[25:31],
+			"HOUR":             sceneName[25:27],
+			"MINUTE":           sceneName[27:29],
+			"SECOND":           sceneName[29:31],
+			"DURATION":         sceneName[64:68],
+			"CYCLE":            sceneName[69:72],
+			"ORBIT":            sceneName[73:76],
+			"FRAME":            sceneName[77:81],
+			"CENTRE":           sceneName[82:85],
+			"PLATFORM":         sceneName[86:87],
+			"TIMELINESS":       sceneName[88:90],
+			"BASELINE":         sceneName[91:94],
+		}, nil
 	case PHR:
 		// DS_PHR1A_201006181052297_FR1_PX_E001N43_0612_06488
 		if len(sceneName) < len("DS_PHRNN_YYYYMMDDHHMMSSS_RRR_PP_XxxxYyy_KKLL_TTTTT") {
@@ -169,28 +210,40 @@ func Info(sceneName string) (map[string]string, error) {
 			"LONGITUDE":  sceneName[41:46],
 			"LATITUDE":   sceneName[46:49],
 		}, nil
-	case Landsat89:
-		// LC09_L1GT_166003_20250603_20250603_02_T2
+	case Landsat89, Landsat457:
+		// LC09_L1GT_166003_20250603_20250603_02_T2, LT05_L2SP_196026_20000805_20200906_02_T1
 		if len(sceneName) < len("LXSS_LLLL_PPPRRR_YYYYMMDD_yyyymmdd_CX_TX") {
-			return nil, fmt.Errorf("invalid Landsat8/9 file name: %s", sceneName)
+			return nil, fmt.Errorf("invalid Landsat file name: %s", sceneName)
 		}
-		collectionChar := sceneName[1:2]
-		sensorCollection := "oli-tirs"
-		if collectionChar == "O" {
+		var sensorCollection string
+		switch sceneName[1:2] {
+		case "C":
+			sensorCollection = "oli-tirs"
+		case "O":
 			sensorCollection = "oli"
-		} else if collectionChar == "T" {
+		case "T":
 			sensorCollection = "tirs"
+			if sceneName[2:4] < "08" {
+				sensorCollection = "tm"
+			}
+		case "E":
+			sensorCollection = "etm"
+		case "M":
+			sensorCollection = "mss"
 		}
 
 		return map[string]string{
-			"MISSION_ID": sceneName[0:1] + sceneName[2:4],
-			"DATE":       sceneName[17:25],
-			"YEAR":       sceneName[17:21],
-			"MONTH":      sceneName[21:23],
-			"DAY":        sceneName[23:25],
-			"COLLECTION": sensorCollection,
-			"PATH":       sceneName[10:13],
-			"ROW":        sceneName[13:16],
+			"MISSION_ID":          sceneName[0:1] + sceneName[2:4],
+			"PROCESSING_LEVEL":    sceneName[5:9],
+			"DATE":                sceneName[17:25],
+			"YEAR":                sceneName[17:21],
+			"MONTH":               sceneName[21:23],
+			"DAY":                 sceneName[23:25],
+			"COLLECTION":          sensorCollection,
+			"COLLECTION_NUMBER":   sceneName[35:37],
+			"COLLECTION_CATEGORY": sceneName[38:40],
+			"PATH":                sceneName[10:13],
+			"ROW":                 sceneName[13:16],
 		}, nil
 	}
 	return nil, fmt.Errorf("Info: constellation not supported")
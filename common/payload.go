@@ -15,11 +15,15 @@ const (
 const (
 	UUIDMetadata         = "uuid"
 	DownloadLinkMetadata = "download_link"
+	SizeMetadata         = "size"       // Size of the product (bytes), if provided by the catalogue
+	OrbitFileMetadata    = "orbit_file" // Local path of the orbit file of the scene, set by the downloader (Sentinel-1 only)
+	OrbitTypeMetadata    = "orbit_type" // Type of the orbit file of the scene (POEORB or RESORB), set by the downloader
 )
 
 type TileMapping struct {
-	SwathID string `json:"swath_id"`
-	TileNr  int    `json:"tile_nr"`
+	SwathID     string `json:"swath_id"`
+	TileNr      int    `json:"tile_nr"`
+	GeometryWKT string `json:"geometry,omitempty"` // Geometry of the tile, if it has to be extracted from the scene (e.g. Sentinel-1 GRD frames)
 }
 
 type SceneAttrs struct {
@@ -32,6 +36,7 @@ type SceneAttrs struct {
 	Metadata     map[string]interface{} `json:"metadata"`
 	IsRetriable  bool                   `json:"is_retriable"`
 	StorageURI   string                 `json:"storage_uri"`
+	OrbitType    string                 `json:"orbit_type,omitempty"` // Orbit file required by the downloader (Sentinel-1 only): POEORB, RESORB or empty (best available, optional)
 }
 
 type TileAttrs struct {
@@ -39,6 +44,8 @@ type TileAttrs struct {
 	TileNr      int    `json:"tile_nr"`
 	GraphName   string `json:"graph_name"`
 	IsRetriable bool   `json:"is_retriable"`
+	LegacyID    string `json:"legacy_id,omitempty"` // Sentinel-1 bursts: former identifier (<orbitdir><relorbit>_<swath>_<anxtime>)
+	StackID     string `json:"stack_id,omitempty"`  // Linked optical tiles: identifier of the stack of the tile
 }
 
 type Scene struct {
@@ -48,12 +55,37 @@ type Scene struct {
 	Data     SceneAttrs `json:"data,omitempty"`
 }
 
+// Roles of the inputs of a tile.
+// Previous and reference tiles have a specific role in the workflow (when a tile fails, its next tiles are linked to its previous tile).
+// Other roles are free (e.g. "previous_2", "secondary"...)
+const (
+	RolePrevious  = "previous"
+	RoleReference = "reference"
+)
+
+// Index of the tiles in the list of tiles given to the processing graph: the tile itself, its previous,
+// its reference and then the additional inputs (in the order of TileToIngest.Inputs)
+const (
+	TileIndexCurrent   = 0
+	TileIndexPrevious  = 1
+	TileIndexReference = 2
+	TileIndexInputs    = 3
+)
+
+// TileInputToIngest is an additional input of a tile, that must be processed before the tile
+type TileInputToIngest struct {
+	Role    string `json:"role"`
+	TileID  string `json:"tile_id"`
+	SceneID string `json:"scene_id"`
+}
+
 type TileToIngest struct {
-	PreviousTileID   string    `json:"previous_tile_id"`
-	PreviousSceneID  string    `json:"previous_scene_id"`
-	ReferenceTileID  string    `json:"reference_tile_id"`
-	ReferenceSceneID string    `json:"reference_scene_id"`
-	Data             TileAttrs `json:"data"`
+	PreviousTileID   string              `json:"previous_tile_id"`
+	PreviousSceneID  string              `json:"previous_scene_id"`
+	ReferenceTileID  string              `json:"reference_tile_id"`
+	ReferenceSceneID string              `json:"reference_scene_id"`
+	Inputs           []TileInputToIngest `json:"inputs,omitempty"` // Additional inputs
+	Data             TileAttrs           `json:"data"`
 }
 
 type SceneToIngest struct {
@@ -69,17 +101,66 @@ type Tile struct {
 	Data     TileAttrs `json:"data,omitempty"`
 }
 
+// TileInput is an additional input of a tile to process
+type TileInput struct {
+	Role string `json:"role"`
+	Tile Tile   `json:"tile"` // Empty if the input tile failed
+}
+
 type TileToProcess struct {
 	Tile
-	Previous  Tile `json:"tile_previous"`
-	Reference Tile `json:"tile_reference"`
+	Previous  Tile        `json:"tile_previous"`
+	Reference Tile        `json:"tile_reference"`
+	Inputs    []TileInput `json:"tile_inputs,omitempty"`
+}
+
+// Tiles returns the list of the tiles given to the processing graph (see TileIndex).
+// A missing input is replaced by the tile itself.
+func (t TileToProcess) Tiles() []Tile {
+	tiles := []Tile{t.Tile, t.Previous, t.Reference}
+	for _, input := range t.Inputs {
+		tiles = append(tiles, input.Tile)
+	}
+	for i := range tiles {
+		if tiles[i].SourceID == "" {
+			tiles[i] = t.Tile
+		}
+	}
+	return tiles
+}
+
+// OutputDFormat is the format of an indexed output layer
+type OutputDFormat struct {
+	DType    string  `json:"datatype"`
+	NoData   float64 `json:"nodata"`
+	Min      float64 `json:"min_value"`
+	Max      float64 `json:"max_value"`
+	ExtMin   float64 `json:"ext_min_value"`
+	ExtMax   float64 `json:"ext_max_value"`
+	Exponent float64 `json:"exponent"`
+	Nbands   int     `json:"nbands"`
+}
+
+// TileOutput describes a layer created, indexed or deleted during the processing of a tile
+type TileOutput struct {
+	TileID     int            `json:"tile_id"` // Can be the tile, its previous or its reference
+	Layer      string         `json:"layer"`
+	Extension  string         `json:"extension"`
+	Action     string         `json:"action"` // to_create, to_index or to_delete
+	URI        string         `json:"uri,omitempty"`
+	InstanceID string         `json:"instance_id,omitempty"`
+	DFormat    *OutputDFormat `json:"dformat,omitempty"`
+	Size       int64          `json:"size,omitempty"` // Size of the file (bytes, to_create and to_index only)
 }
 
 type Result struct {
-	Type    string `json:"type"` // scene (ResultTypeScene) or tile (ResultTypeTile)
-	ID      int    `json:"id"`
-	Status  Status `json:"status"`
-	Message string `json:"message"`
+	Type    string       `json:"type"` // scene (ResultTypeScene) or tile (ResultTypeTile)
+	ID      int          `json:"id"`
+	Status  Status       `json:"status"`
+	Message string       `json:"message"`
+	Outputs []TileOutput `json:"outputs,omitempty"` // Only for ResultTypeTile
+	// Duration of the processing (seconds, only if Status=DONE)
+	Duration float64 `json:"duration,omitempty"`
 }
 
 // Value implements the driver.Value interface
@@ -117,3 +198,21 @@ func (a *TileAttrs) Scan(value interface{}) error {
 	}
 	return json.Unmarshal(b, &a)
 }
+
+// Value implements the driver.Value interface
+func (a OutputDFormat) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// Scan implements the sql.Scanner interface.
+func (a *OutputDFormat) Scan(value interface{}) error {
+	if value == nil {
+		*a = OutputDFormat{}
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &a)
+}
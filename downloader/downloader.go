@@ -8,6 +8,7 @@ import (
 
 	"github.com/airbusgeo/geocube-ingester/common"
 	"github.com/airbusgeo/geocube-ingester/graph"
+	"github.com/airbusgeo/geocube-ingester/interface/orbit"
 	"github.com/airbusgeo/geocube-ingester/interface/provider"
 	"github.com/airbusgeo/geocube-ingester/service"
 	"github.com/airbusgeo/geocube-ingester/service/log"
@@ -15,7 +16,8 @@ import (
 )
 
 // ProcessScene processes a scene.
-func ProcessScene(ctx context.Context, imageProviders []provider.ImageProvider, storageService service.Storage, scene common.Scene, workdir string, opts []graph.Option) error {
+// If orbits is not nil, the orbit file of a Sentinel-1 scene is fetched and stored in the auxdata directory of SNAP (see fetchOrbit).
+func ProcessScene(ctx context.Context, imageProviders []provider.ImageProvider, orbits *orbit.Manager, auxdataDir string, storageService service.Storage, scene common.Scene, workdir string, opts []graph.Option) error {
 	// Working dir
 	workdir = filepath.Join(workdir, uuid.New().String())
 
@@ -36,6 +38,11 @@ func ProcessScene(ctx context.Context, imageProviders []provider.ImageProvider,
 		}
 	}
 
+	// Fetch the orbit file first, not to download the scene if the required orbit file is not available yet
+	if err := fetchOrbit(ctx, orbits, &scene, auxdataDir); err != nil {
+		return fmt.Errorf("ProcessScene.%w", err)
+	}
+
 	// Download with the first successful imageProvider
 	log.Logger(ctx).Sugar().Infof("downloading %s", scene.SourceID)
 	var err error
@@ -62,6 +69,50 @@ func ProcessScene(ctx context.Context, imageProviders []provider.ImageProvider,
 	return nil
 }
 
+// fetchOrbit fetches the orbit file of a Sentinel-1 scene, writes it in the auxdata directory of SNAP (see orbit.AuxdataPath)
+// and adds its path and its type to the metadata of the scene (available to the graphs as tile arguments)
+// If the scene requires an orbit type (POEORB or RESORB) that is not available yet, it returns orbit.ErrNotAvailable.
+// Otherwise, if the orbit file cannot be fetched, the scene is processed without it.
+func fetchOrbit(ctx context.Context, orbits *orbit.Manager, scene *common.Scene, auxdataDir string) error {
+	if common.GetConstellationFromProductId(scene.SourceID) != common.Sentinel1 {
+		return nil
+	}
+	required, err := orbit.ParseType(scene.Data.OrbitType)
+	if err != nil {
+		return fmt.Errorf("fetchOrbit.%w", err)
+	}
+	if orbits == nil {
+		if required != "" {
+			return fmt.Errorf("fetchOrbit: %s orbit file required, but no orbit provider is configured", required)
+		}
+		return nil
+	}
+
+	orbitFile, orbitType, err := orbits.Fetch(ctx, scene.SourceID, required, auxdataDir)
+	if err != nil {
+		if required != "" {
+			return fmt.Errorf("fetchOrbit.%w", err)
+		}
+		log.Logger(ctx).Sugar().Warnf("fetchOrbit.%v", err)
+		return nil
+	}
+	if orbitFile == "" {
+		log.Logger(ctx).Sugar().Warnf("no orbit file available for %s", scene.SourceID)
+		return nil
+	}
+
+	// Copy the metadata not to modify the map of the caller
+	metadata := map[string]interface{}{}
+	for k, v := range scene.Data.Metadata {
+		metadata[k] = v
+	}
+	metadata[common.OrbitFileMetadata] = orbitFile
+	metadata[common.OrbitTypeMetadata] = string(orbitType)
+	scene.Data.Metadata = metadata
+	log.Logger(ctx).Sugar().Infof("%s orbit file of %s: %s", orbitType, scene.SourceID, filepath.Base(orbitFile))
+	return nil
+}
+
 // ProcessTile extracts the tile from the scene and preprocesses it
 func ProcessTile(ctx context.Context, storageService service.Storage, scene common.Scene, tile, workdir string, opts []graph.Option) error {
 	ctx = log.With(ctx, "tile", tile)
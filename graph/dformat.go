@@ -0,0 +1,187 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+// gdalinfoCmd is the command used to detect the data format of the output files
+const gdalinfoCmd = "gdalinfo"
+
+// RasterInfo is the data format of a raster file, as detected by GDAL
+type RasterInfo struct {
+	DType  DType
+	Nbands int
+	NoData *float64 // nil if the file has no nodata value
+	Min    float64  // Minimum of the valid values of all the bands
+	Max    float64  // Maximum of the valid values of all the bands
+}
+
+// detectDFormat reads the data format of the file and applies it to the OutFile
+func (of *OutFile) detectDFormat(ctx context.Context, filename string) error {
+	info, err := readRasterInfo(ctx, filename)
+	if err != nil {
+		return fmt.Errorf("detectDFormat[%s].%w", of.Layer, err)
+	}
+	log.Logger(ctx).Sugar().Debugf("layer %s: detected dformat %+v", of.Layer, info)
+	if err := of.applyRasterInfo(info); err != nil {
+		return fmt.Errorf("detectDFormat[%s].%w", of.Layer, err)
+	}
+	return nil
+}
+
+// applyRasterInfo sets the data format of the OutFile with the information detected in the file.
+// The data format declared by the graph (datatype or dformat_out) is validated: DType, NoData and Nbands must be
+// the same and the valid values must be in [Min, Max].
+// Otherwise, the range is set to [ExtMin, ExtMax] if it is defined, to the range of the valid values if not (ExtMin, ExtMax included).
+func (of *OutFile) applyRasterInfo(info RasterInfo) error {
+	var mismatches []string
+	if of.Nbands == 0 {
+		of.Nbands = info.Nbands
+	} else if of.Nbands != info.Nbands {
+		mismatches = append(mismatches, fmt.Sprintf("nbands: %d != %d", of.Nbands, info.Nbands))
+	}
+
+	if of.DType == Undefined {
+		if info.DType == Undefined {
+			return fmt.Errorf("applyRasterInfo: unsupported datatype")
+		}
+		of.DType = info.DType
+		if info.NoData != nil {
+			of.NoData = *info.NoData
+		}
+		if of.ExtMin == of.ExtMax {
+			of.ExtMin, of.ExtMax = info.Min, info.Max
+		}
+		of.Min, of.Max = of.ExtMin, of.ExtMax
+	} else {
+		if of.DType != info.DType {
+			mismatches = append(mismatches, fmt.Sprintf("datatype: %s != %s", of.DType, info.DType))
+		}
+		if info.NoData != nil && !SameValue(of.NoData, *info.NoData) {
+			mismatches = append(mismatches, fmt.Sprintf("nodata: %v != %v", of.NoData, *info.NoData))
+		}
+		if of.DType != Complex64 && of.Min < of.Max && (info.Min < of.Min || info.Max > of.Max) {
+			mismatches = append(mismatches, fmt.Sprintf("values [%v, %v] out of range [%v, %v]", info.Min, info.Max, of.Min, of.Max))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("applyRasterInfo: the file does not match the declaration of the graph (declared != detected): %s", strings.Join(mismatches, ", "))
+	}
+	return nil
+}
+
+// readRasterInfo runs gdalinfo to read the data format and the statistics of the file
+func readRasterInfo(ctx context.Context, filename string) (RasterInfo, error) {
+	cmd := exec.CommandContext(ctx, gdalinfoCmd, "-json", "-stats", filename)
+	cmd.Env = append(os.Environ(), "GDAL_PAM_ENABLED=NO") // Do not write the statistics in an .aux.xml file
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return RasterInfo{}, fmt.Errorf("readRasterInfo[%s]: %w (%s)", filename, err, strings.TrimSpace(stderr.String()))
+	}
+	info, err := parseGdalInfo(output)
+	if err != nil {
+		return RasterInfo{}, fmt.Errorf("readRasterInfo[%s].%w", filename, err)
+	}
+	return info, nil
+}
+
+// parseGdalInfo parses the json output of "gdalinfo -json -stats"
+func parseGdalInfo(data []byte) (RasterInfo, error) {
+	var gdalinfo struct {
+		Bands []struct {
+			Type        string          `json:"type"`
+			NoDataValue json.RawMessage `json:"noDataValue"`
+			Minimum     *float64        `json:"minimum"`
+			Maximum     *float64        `json:"maximum"`
+		} `json:"bands"`
+	}
+	if err := json.Unmarshal(data, &gdalinfo); err != nil {
+		return RasterInfo{}, fmt.Errorf("parseGdalInfo: %w", err)
+	}
+	if len(gdalinfo.Bands) == 0 {
+		return RasterInfo{}, fmt.Errorf("parseGdalInfo: no band found")
+	}
+
+	info := RasterInfo{
+		DType:  gdalDType(gdalinfo.Bands[0].Type),
+		Nbands: len(gdalinfo.Bands),
+		Min:    math.Inf(1),
+		Max:    math.Inf(-1),
+	}
+	for i, band := range gdalinfo.Bands {
+		if gdalDType(band.Type) != info.DType {
+			return RasterInfo{}, fmt.Errorf("parseGdalInfo: bands have different datatypes (%s, %s)", gdalinfo.Bands[0].Type, band.Type)
+		}
+		nodata, err := ParseGdalNoData(band.NoDataValue)
+		if err != nil {
+			return RasterInfo{}, fmt.Errorf("parseGdalInfo.%w", err)
+		}
+		if i == 0 {
+			info.NoData = nodata
+		} else if (nodata == nil) != (info.NoData == nil) || (nodata != nil && !SameValue(*nodata, *info.NoData)) {
+			return RasterInfo{}, fmt.Errorf("parseGdalInfo: bands have different nodata values")
+		}
+		if band.Minimum != nil {
+			info.Min = math.Min(info.Min, *band.Minimum)
+		}
+		if band.Maximum != nil {
+			info.Max = math.Max(info.Max, *band.Maximum)
+		}
+	}
+	if info.Min > info.Max {
+		// No valid value
+		info.Min, info.Max = 0, 0
+	}
+	return info, nil
+}
+
+// ParseGdalNoData parses the nodata value of a band, that is a number or a string (nan, inf, -inf)
+func ParseGdalNoData(data json.RawMessage) (*float64, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("ParseGdalNoData: %w", err)
+	}
+	var nodata float64
+	switch t := v.(type) {
+	case float64:
+		nodata = t
+	case string:
+		var err error
+		if nodata, err = strconv.ParseFloat(t, 64); err != nil {
+			return nil, fmt.Errorf("ParseGdalNoData: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("ParseGdalNoData: unsupported value %s", string(data))
+	}
+	return &nodata, nil
+}
+
+// gdalDType converts the name of a GDAL datatype
+func gdalDType(gdalType string) DType {
+	switch gdalType {
+	case "CFloat32":
+		return Complex64
+	}
+	return DTypeFromString(gdalType)
+}
+
+// SameValue returns true if v1 == v2 or if both are NaN
+func SameValue(v1, v2 float64) bool {
+	return v1 == v2 || (math.IsNaN(v1) && math.IsNaN(v2))
+}
@@ -0,0 +1,114 @@
+package graph_test
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/airbusgeo/geocube-ingester/graph"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DetectDFormat", func() {
+
+	var gdalinfo = `{"bands":[
+		{"band":1,"type":"UInt16","noDataValue":0,"minimum":12,"maximum":9500},
+		{"band":2,"type":"UInt16","noDataValue":0,"minimum":3,"maximum":8000}]}`
+
+	Describe("Parsing gdalinfo", func() {
+		var info graph.RasterInfo
+		var err error
+
+		Context("multiband file", func() {
+			BeforeEach(func() {
+				info, err = graph.ParseGdalInfo([]byte(gdalinfo))
+			})
+			It("should return the dformat", func() {
+				Expect(err).To(BeNil())
+				Expect(info.DType).To(Equal(graph.UInt16))
+				Expect(info.Nbands).To(Equal(2))
+				Expect(*info.NoData).To(Equal(0.))
+				Expect(info.Min).To(Equal(3.))
+				Expect(info.Max).To(Equal(9500.))
+			})
+		})
+
+		Context("nan nodata", func() {
+			BeforeEach(func() {
+				info, err = graph.ParseGdalInfo([]byte(`{"bands":[{"band":1,"type":"Float32","noDataValue":"nan","minimum":-1.5,"maximum":2}]}`))
+			})
+			It("should return the dformat", func() {
+				Expect(err).To(BeNil())
+				Expect(info.DType).To(Equal(graph.Float32))
+				Expect(math.IsNaN(*info.NoData)).To(BeTrue())
+			})
+		})
+
+		Context("different datatypes", func() {
+			BeforeEach(func() {
+				info, err = graph.ParseGdalInfo([]byte(`{"bands":[{"band":1,"type":"Byte"},{"band":2,"type":"Int16"}]}`))
+			})
+			It("should raise error", func() {
+				Expect(err).NotTo(BeNil())
+			})
+		})
+	})
+
+	Describe("Applying detected dformat", func() {
+		var outfile graph.OutFile
+		var err error
+		var info graph.RasterInfo
+
+		BeforeEach(func() {
+			info, _ = graph.ParseGdalInfo([]byte(gdalinfo))
+		})
+
+		Context("nothing declared", func() {
+			BeforeEach(func() {
+				Expect(json.Unmarshal([]byte(`{"layer":"img","extension":"tif","action":"to_index","auto_dformat":true}`), &outfile)).To(Succeed())
+				err = outfile.ApplyRasterInfo(info)
+			})
+			It("should set the dformat", func() {
+				Expect(err).To(BeNil())
+				Expect(outfile.DType).To(Equal(graph.UInt16))
+				Expect(outfile.Nbands).To(Equal(2))
+				Expect([]float64{outfile.Min, outfile.Max, outfile.ExtMin, outfile.ExtMax}).To(Equal([]float64{3, 9500, 3, 9500}))
+			})
+		})
+
+		Context("external range declared", func() {
+			BeforeEach(func() {
+				Expect(json.Unmarshal([]byte(`{"layer":"img","extension":"tif","action":"to_index","auto_dformat":true,"ext_min_value":0,"ext_max_value":10000}`), &outfile)).To(Succeed())
+				err = outfile.ApplyRasterInfo(info)
+			})
+			It("should use the external range", func() {
+				Expect(err).To(BeNil())
+				Expect([]float64{outfile.Min, outfile.Max}).To(Equal([]float64{0, 10000}))
+			})
+		})
+
+		Context("matching declaration", func() {
+			BeforeEach(func() {
+				Expect(json.Unmarshal([]byte(`{"layer":"img","extension":"tif","action":"to_index","auto_dformat":true,"nbands":2,"datatype":"uint16","min_value":1,"max_value":10000}`), &outfile)).To(Succeed())
+				err = outfile.ApplyRasterInfo(info)
+			})
+			It("should not raise error", func() {
+				Expect(err).To(BeNil())
+			})
+		})
+
+		Context("mismatching declaration", func() {
+			BeforeEach(func() {
+				Expect(json.Unmarshal([]byte(`{"layer":"img","extension":"tif","action":"to_index","auto_dformat":true,"datatype":"int16","nodata":-1,"min_value":0,"max_value":5000}`), &outfile)).To(Succeed())
+				err = outfile.ApplyRasterInfo(info)
+			})
+			It("should flag all the mismatches", func() {
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("datatype"))
+				Expect(err.Error()).To(ContainSubstring("nodata"))
+				Expect(err.Error()).To(ContainSubstring("out of range"))
+				Expect(outfile.Nbands).To(Equal(2))
+			})
+		})
+	})
+})
@@ -28,6 +28,10 @@ const (
 	keySceneName     = "scene"
 	keySceneDate     = "date"
 	keyConstellation = "constellation"
+	keyTileGeometry  = "geometry"        // WKT of the tile, if defined in the tile mappings of the scene (e.g. Sentinel-1 GRD frames)
+	keyOrbitFile     = "orbit_file"      // Path of the orbit file fetched by the downloader (Sentinel-1 only, empty if not available)
+	keyOrbitType     = "orbit_type"      // Type of the orbit file fetched by the downloader (POEORB or RESORB, empty if not available)
+	keySnapOrbitType = "snap_orbit_type" // orbitType of the Apply-Orbit-File operator of SNAP to use the orbit file fetched by the downloader (see snapOrbitType)
 
 	pythonEngine  = "python"
 	snapEngine    = "snap"
@@ -88,7 +92,7 @@ func (so dockerOpt) setOpt(opts *graphOpts) {
 type Arg interface{}
 
 type ArgIn struct { // input of the graph
-	Input     int               `json:"tile_index"` // Index of input [0, 1, 2]
+	Input     int               `json:"tile_index"` // Index of input: 0 (tile), 1 (previous), 2 (reference), 3+ (additional inputs)
 	Layer     service.Layer     `json:"layer"`
 	Extension service.Extension `json:"extension"`
 }
@@ -146,6 +150,29 @@ func DTypeFromString(dtype string) DType {
 	}
 }
 
+// String returns the lowercase name of the DType
+func (dtype DType) String() string {
+	switch dtype {
+	case UInt8:
+		return "uint8"
+	case UInt16:
+		return "uint16"
+	case UInt32:
+		return "uint32"
+	case Int16:
+		return "int16"
+	case Int32:
+		return "int32"
+	case Float32:
+		return "float32"
+	case Float64:
+		return "float64"
+	case Complex64:
+		return "complex64"
+	}
+	return "undefined"
+}
+
 // OutFileAction
 type OutFileAction int32
 
@@ -157,6 +184,19 @@ const (
 	ToDelete
 )
 
+// String returns the name of the action as in the json graph
+func (a OutFileAction) String() string {
+	switch a {
+	case ToCreate:
+		return "to_create"
+	case ToIndex:
+		return "to_index"
+	case ToDelete:
+		return "to_delete"
+	}
+	return "to_ignore"
+}
+
 // File is a layer with an extension
 type File struct {
 	Layer     service.Layer     `json:"layer"`
@@ -179,8 +219,9 @@ type OutFile struct {
 	Max            float64       `json:"max_value"`
 	ExtMin         float64       `json:"ext_min_value"`
 	ExtMax         float64       `json:"ext_max_value"`
-	Exponent       float64       `json:"exponent"` // JSON default: 1
-	Nbands         int           `json:"nbands"`   // JSON default: 1
+	Exponent       float64       `json:"exponent"`     // JSON default: 1
+	Nbands         int           `json:"nbands"`       // JSON default: 1 (0 if AutoDFormat)
+	AutoDFormat    bool          `json:"auto_dformat"` // Detect dtype, nbands, nodata and range from the output file (validate them if they are declared)
 	Action         OutFileAction `json:"action"`
 	Condition      Condition     `json:"condition"`       // JSON default: pass
 	ErrorCondition Condition     `json:"error_condition"` // JSON default: pass
@@ -239,7 +280,7 @@ type GraphEnvs []string
 // ProcessingGraph is a set of steps
 type ProcessingGraph struct {
 	steps    []ProcessingStep
-	InFiles  [3][]InFile
+	InFiles  [][]InFile // Input files per tile (see ArgIn.Input)
 	outFiles [][]OutFile
 	opts     graphOpts
 }
@@ -314,7 +355,7 @@ func getFile(ctx context.Context, workdir, file string, makeExecutable bool) (st
 	return localpath.Name(), nil
 }
 
-func NewProcessingGraph(ctx context.Context, steps []ProcessingStep, infiles [3][]InFile, outfiles [][]OutFile, opts ...Option) (*ProcessingGraph, error) {
+func NewProcessingGraph(ctx context.Context, steps []ProcessingStep, infiles [][]InFile, outfiles [][]OutFile, opts ...Option) (*ProcessingGraph, error) {
 	// Check commands
 	snapRequired, dockerRequired := false, false
 	for i, step := range steps {
@@ -387,6 +428,18 @@ func LoadGraph(ctx context.Context, graphName string, opts ...Option) (*Processi
 			return nil, nil, nil, err
 		}
 		return g, S1DefaultConfig(), nil, nil
+	case "S1GRDPreprocessing":
+		g, err := newS1GRDPreProcessingGraph(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return g, S1GRDDefaultConfig(), nil, nil
+	case "S1GRDBackscatter":
+		g, err := newS1GRDBackscatterGraph(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return g, S1GRDDefaultConfig(), nil, nil
 	case "PhrPreProcessing":
 		g, err := newPhrPreProcessingGraph(ctx)
 		if err != nil {
@@ -472,6 +525,15 @@ func S1DefaultConfig() GraphConfig {
 	}
 }
 
+// S1GRDDefaultConfig returns a basic configuration for Sentinel-1 GRD products
+func S1GRDDefaultConfig() GraphConfig {
+	config := S1DefaultConfig()
+	config["polarisations"] = "VV,VH"
+	config["speckle_filter"] = "Lee"
+	config["speckle_filter_size"] = "5"
+	return config
+}
+
 func PhrDefaultConfig() GraphConfig {
 	return GraphConfig{
 		"dformat_out": "Int16,0,0,32767",
@@ -501,7 +563,7 @@ var (
 // newS1PreProcessingGraph creates a new preprocessing graph for S1 (to use with )
 func newS1PreProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 	// Define inputs
-	infiles := [3][]InFile{}
+	infiles := [][]InFile{}
 
 	// Define outputs
 	outfiles := [][]OutFile{
@@ -524,6 +586,7 @@ func newS1PreProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 				"swath":  ArgTile(keyBurstSwath),
 				"polar":  ArgFixed("\"VV VH\""),
 				"burst":  ArgTile(keyTileNumber),
+				"orbit":  ArgTile(keySnapOrbitType),
 			},
 		},
 	}
@@ -534,7 +597,7 @@ func newS1PreProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 // newS1BsCohGraph creates a new processing graph to compute Backscatter and Coherence of S1 images
 func newS1BsCohGraph(ctx context.Context) (*ProcessingGraph, error) {
 	// Define inputs
-	infiles := [3][]InFile{
+	infiles := [][]InFile{
 		{{File{service.LayerPreprocessed, service.ExtensionDIMAP}, Condition(pass)}},
 		{{File{service.LayerCoregExtract, service.ExtensionDIMAP}, Condition(condDiffT1T2)}},
 		{{File{service.LayerPreprocessed, service.ExtensionDIMAP}, Condition(condDiffT0T2)}},
@@ -771,7 +834,7 @@ func newS1BsCohGraph(ctx context.Context) (*ProcessingGraph, error) {
 // newS1CoregExtractGraph creates a new processing graph to compute Coherence of S1 images
 func newS1CoregExtractGraph(ctx context.Context) (*ProcessingGraph, error) {
 	// Define inputs
-	infiles := [3][]InFile{
+	infiles := [][]InFile{
 		{{File{service.LayerPreprocessed, service.ExtensionDIMAP}, Condition(pass)}},
 		{},
 		{{File{service.LayerPreprocessed, service.ExtensionDIMAP}, Condition(condDiffT0T2)}},
@@ -825,10 +888,119 @@ func newS1CoregExtractGraph(ctx context.Context) (*ProcessingGraph, error) {
 	return NewProcessingGraph(ctx, steps, infiles, outfiles, WithSnap())
 }
 
+// newS1GRDPreProcessingGraph creates a new preprocessing graph for S1 GRD products (calibration and extraction of the tile)
+func newS1GRDPreProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
+	// Define inputs
+	infiles := [][]InFile{}
+
+	// Define outputs
+	outfiles := [][]OutFile{
+		{newOutFile(service.LayerPreprocessed, service.ExtensionDIMAP, ArgFixed("float32,0,0,1"), 0, 1, 1, 1, ToCreate, Condition(pass))},
+		{},
+		{},
+	}
+
+	// Create processing steps
+	steps := []ProcessingStep{
+		// Remove thermal noise, calibrate and extract the tile
+		{
+			Engine:    snapEngine,
+			Command:   path.Join(snapEngine, "S1_GRD_AO_TNR_CAL_Subset.xml"),
+			Condition: pass,
+
+			Args: map[string]Arg{
+				"input":    ArgTile(keySceneName),
+				"output":   ArgOut{service.LayerPreprocessed, service.ExtensionDIMAP},
+				"polar":    ArgConfig("polarisations"),
+				"geometry": ArgTile(keyTileGeometry),
+				"orbit":    ArgTile(keySnapOrbitType),
+			},
+		},
+	}
+
+	return NewProcessingGraph(ctx, steps, infiles, outfiles, WithSnap())
+}
+
+// newS1GRDBackscatterGraph creates a new processing graph to compute the backscatter of S1 GRD tiles
+// (speckle filtering and terrain correction of the preprocessed tile)
+func newS1GRDBackscatterGraph(ctx context.Context) (*ProcessingGraph, error) {
+	// Define inputs
+	infiles := [][]InFile{
+		{{File{service.LayerPreprocessed, service.ExtensionDIMAP}, Condition(pass)}},
+	}
+
+	// Define outputs
+	outfiles := [][]OutFile{
+		{
+			newOutFile(service.LayerBackscatterVV, service.ExtensionGTiff, ArgConfig("dformat_out"), 0, 1, 1, 1, ToIndex, Condition(pass)),
+			newOutFile(service.LayerBackscatterVH, service.ExtensionGTiff, ArgConfig("dformat_out"), 0, 1, 1, 1, ToIndex, Condition(pass)),
+			{File: File{Layer: service.LayerPreprocessed, Extension: service.ExtensionDIMAP}, Action: ToDelete, Condition: Condition(pass)},
+		},
+	}
+
+	// Create processing steps
+	steps := []ProcessingStep{
+		// Speckle filtering and terrain correction
+		{
+			Engine:    snapEngine,
+			Command:   path.Join(snapEngine, "S1_GRD_Spk_TC.xml"),
+			Condition: pass,
+
+			Args: map[string]Arg{
+				"input":               ArgIn{Input: 0, Layer: service.LayerPreprocessed, Extension: service.ExtensionDIMAP},
+				"outputVV":            ArgOut{service.LayerBackscatterVV, service.ExtensionGTiff},
+				"outputVH":            ArgOut{service.LayerBackscatterVH, service.ExtensionGTiff},
+				"speckle_filter":      ArgConfig("speckle_filter"),
+				"speckle_filter_size": ArgConfig("speckle_filter_size"),
+				"dem_name":            ArgConfig("dem_name"),
+				"dem_file":            ArgConfig("dem_file"),
+				"dem_nodata":          ArgConfig("dem_nodata"),
+				"dem_egm":             ArgConfig("dem_egm_correction"),
+				"dem_resampling":      ArgConfig("dem_resampling"),
+				"img_resampling":      ArgConfig("img_resampling"),
+				"projection":          ArgConfig("projection"),
+				"resolution":          ArgConfig("resolution"),
+				"grid_align":          ArgFixed("true"),
+			},
+		},
+	}
+
+	// Erode the borders and convert the backscatters
+	for _, layer := range []service.Layer{service.LayerBackscatterVV, service.LayerBackscatterVH} {
+		steps = append(steps,
+			ProcessingStep{
+				Engine:    pythonEngine,
+				Command:   path.Join(pythonEngine, "erodeMask.py"),
+				Condition: pass,
+
+				Args: map[string]Arg{
+					"file-in":    ArgOut{layer, service.ExtensionGTiff},
+					"file-out":   ArgOut{layer, service.ExtensionGTiff},
+					"no-data":    ArgFixed("0"),
+					"iterations": ArgConfig("bs_erode_iterations"),
+				},
+			},
+			ProcessingStep{
+				Engine:    commandEngine,
+				Command:   path.Join(pythonEngine, "convert.py"),
+				Condition: pass,
+
+				Args: map[string]Arg{
+					"file-in":     ArgOut{layer, service.ExtensionGTiff},
+					"file-out":    ArgOut{layer, service.ExtensionGTiff},
+					"range-in":    ArgFixed("0,1"),
+					"dformat-out": ArgConfig("dformat_out"),
+				},
+			})
+	}
+
+	return NewProcessingGraph(ctx, steps, infiles, outfiles, WithSnap())
+}
+
 // newS1CleanGraph creates a new graph to clean temporary images
 func newS1CleanGraph(ctx context.Context) (*ProcessingGraph, error) {
 	// Define inputs
-	infiles := [3][]InFile{{}, {}, {}}
+	infiles := [][]InFile{{}, {}, {}}
 
 	// Define outputs
 	outfiles := [][]OutFile{
@@ -860,7 +1032,7 @@ func newPhrPreProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 	}
 
 	// Define inputs
-	infiles := [3][]InFile{
+	infiles := [][]InFile{
 		{},
 		{},
 		{},
@@ -894,7 +1066,7 @@ func newSpotPreProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 	}
 
 	// Define inputs
-	infiles := [3][]InFile{
+	infiles := [][]InFile{
 		{},
 		{},
 		{},
@@ -913,7 +1085,7 @@ func newSpotPreProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 
 func newPhrProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 	// Define inputs
-	infiles := [3][]InFile{
+	infiles := [][]InFile{
 		{
 			{File{service.LayerMultiSpectral, service.ExtensionGTiff}, Condition(pass)},
 			{File{service.LayerPanchromatic, service.ExtensionGTiff}, Condition(pass)},
@@ -940,7 +1112,7 @@ func newPhrProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 
 func newSpotProcessingGraph(ctx context.Context) (*ProcessingGraph, error) {
 	// Define inputs
-	infiles := [3][]InFile{
+	infiles := [][]InFile{
 		{{File{service.LayerMultiSpectral, service.ExtensionGTiff}, Condition(pass)}},
 		{{File{service.LayerPanchromatic, service.ExtensionGTiff}, Condition(pass)}},
 		{},
@@ -1013,6 +1185,9 @@ func (g *ProcessingGraph) Process(ctx context.Context, config GraphConfig, graph
 	pythonFilter := PythonLogFilter{}
 	snapFilter := SNAPLogFilter{}
 	cmdFilter := CmdLogFilter{}
+	if len(g.InFiles) > len(tiles) || len(g.outFiles) > len(tiles) {
+		return nil, service.MakeFatal(fmt.Errorf("process: the graph expects %d input tiles, but only %d are provided", max(len(g.InFiles), len(g.outFiles)), len(tiles)))
+	}
 	for _, step := range g.steps {
 		if !step.Condition.Pass(tiles) {
 			continue
@@ -1095,6 +1270,11 @@ func (g *ProcessingGraph) Process(ctx context.Context, config GraphConfig, graph
 			if err := f.setDFormatOut(config); err != nil {
 				return g.onFailureGetOutFiles(err, tiles), fmt.Errorf("process.%w", err)
 			}
+			if f.AutoDFormat && f.Action == ToIndex {
+				if err := f.detectDFormat(ctx, path.Join(config["workdir"], service.LayerFileName(tiles[i], f.Layer, f.Extension))); err != nil {
+					return g.onFailureGetOutFiles(err, tiles), fmt.Errorf("process.%w", err)
+				}
+			}
 			outfiles[i] = append(outfiles[i], f)
 		}
 	}
@@ -1338,6 +1518,15 @@ func formatArgs(arg Arg, config GraphConfig, tiles []common.Tile) (string, error
 			valstr = tiles[0].Scene.SourceID
 		case keyConstellation:
 			valstr = common.GetConstellationFromProductId(tiles[0].Scene.SourceID).String()
+		case keyTileGeometry:
+			valstr = tiles[0].Scene.Data.TileMappings[tiles[0].SourceID].GeometryWKT
+		case keyOrbitFile:
+			valstr, _ = tiles[0].Scene.Data.Metadata[common.OrbitFileMetadata].(string)
+		case keyOrbitType:
+			valstr, _ = tiles[0].Scene.Data.Metadata[common.OrbitTypeMetadata].(string)
+		case keySnapOrbitType:
+			orbitType, _ := tiles[0].Scene.Data.Metadata[common.OrbitTypeMetadata].(string)
+			valstr = snapOrbitType(orbitType)
 		default:
 			return "", fmt.Errorf("key '%s' not found in tile", key)
 		}
@@ -1356,6 +1545,19 @@ func formatArgs(arg Arg, config GraphConfig, tiles []common.Tile) (string, error
 	return valstr, nil
 }
 
+// snapOrbitType returns the orbitType of the Apply-Orbit-File operator of SNAP.
+// If the downloader has fetched the orbit file (in the auxdata directory of SNAP, see orbit.AuxdataPath), SNAP uses it without downloading it.
+// Otherwise, SNAP downloads the precise orbit file on its own.
+func snapOrbitType(orbitType string) string {
+	switch orbitType {
+	case "POEORB":
+		return "Sentinel Precise"
+	case "RESORB":
+		return "Sentinel Restituted"
+	}
+	return "Sentinel Precise (Auto Download)"
+}
+
 func getInterpreter(fileName string) (string, error) {
 	f, err := os.Open(fileName)
 	if err != nil {
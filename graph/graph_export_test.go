@@ -15,6 +15,14 @@ var NewS1PreProcessingGraph = newS1PreProcessingGraph
 
 var NewOutFile = newOutFile
 
+var ParseGdalInfo = parseGdalInfo
+
+var FormatArgs = formatArgs
+
+func (of *OutFile) ApplyRasterInfo(info RasterInfo) error {
+	return of.applyRasterInfo(info)
+}
+
 func (tc TileCondition) MarshalJSON() ([]byte, error) {
 	return json.Marshal(tc.Name)
 }
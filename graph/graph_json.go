@@ -10,7 +10,7 @@ type ProcessingGraphJSON struct {
 	Config   map[string]string `json:"config"`
 	Envs     []string          `json:"envs,omitempty"`
 	Steps    []ProcessingStep  `json:"processing_steps"`
-	InFiles  [3][]InFile       `json:"in_files"`
+	InFiles  [][]InFile        `json:"in_files"`
 	OutFiles [][]OutFile       `json:"out_files"`
 }
 
@@ -19,6 +19,7 @@ func (of *OutFile) UnmarshalJSON(data []byte) error {
 	type outFileJSON struct {
 		outFileAlias
 		DFormatOut ArgJSON `json:"dformat_out"`
+		Nbands     *int    `json:"nbands"`
 	}
 	alias := &outFileJSON{outFileAlias: outFileAlias{
 		Condition: Condition(pass),
@@ -30,6 +31,11 @@ func (of *OutFile) UnmarshalJSON(data []byte) error {
 		return err
 	}
 	alias.outFileAlias.dformatOut = alias.DFormatOut.Arg
+	if alias.Nbands != nil {
+		alias.outFileAlias.Nbands = *alias.Nbands
+	} else if alias.AutoDFormat {
+		alias.outFileAlias.Nbands = 0 // To be detected
+	}
 
 	*of = OutFile(alias.outFileAlias)
 	return nil
@@ -244,7 +244,7 @@ var _ = Describe("LoadGraph", func() {
 			BeforeEach(func() {
 				expected_graph = graph.ProcessingGraphJSON{
 					Steps: []graph.ProcessingStep{snapStep, pythonStep},
-					InFiles: [3][]graph.InFile{
+					InFiles: [][]graph.InFile{
 						{{graph.File{service.LayerPreprocessed, service.ExtensionDIMAP}, graph.Condition(graph.ConditionPass)}},
 						{},
 						{{graph.File{service.LayerPreprocessed, service.ExtensionDIMAP}, graph.Condition(graph.ConditionDiffT0T2)}},
@@ -313,6 +313,13 @@ var _ = Describe("LoadGraph", func() {
 			})
 			itShouldNotRaiseError()
 		})
+
+		Describe("ExtractS3OLCIBands", func() {
+			BeforeEach(func() {
+				jsonPath = "library/ExtractS3OLCIBands.json"
+			})
+			itShouldNotRaiseError()
+		})
 	})
 })
 
@@ -362,7 +369,7 @@ var _ = Describe("ExecuteGraph", func() {
 			BeforeEach(func() {
 				processing_graph = graph.ProcessingGraphJSON{
 					Steps: []graph.ProcessingStep{},
-					InFiles: [3][]graph.InFile{
+					InFiles: [][]graph.InFile{
 						{},
 						{},
 						{},
@@ -390,7 +397,7 @@ var _ = Describe("ExecuteGraph", func() {
 							Args: map[string]graph.Arg{},
 						},
 					},
-					InFiles: [3][]graph.InFile{
+					InFiles: [][]graph.InFile{
 						{},
 						{},
 						{},
@@ -407,3 +414,21 @@ var _ = Describe("ExecuteGraph", func() {
 		})
 	})
 })
+
+var _ = Describe("Formatting the orbit type of SNAP", func() {
+	var formatOrbitType = func(metadata map[string]interface{}) string {
+		tile := common.Tile{Scene: common.Scene{SourceID: "S1A_IW_GRDH_1SDV_20210225T060000_20210225T060027_036589_044D5A_1A2B", Data: common.SceneAttrs{Metadata: metadata}}}
+		value, err := graph.FormatArgs(graph.ArgTile("snap_orbit_type"), nil, []common.Tile{tile})
+		Expect(err).NotTo(HaveOccurred())
+		return value
+	}
+
+	It("should use the orbit file fetched by the downloader", func() {
+		Expect(formatOrbitType(map[string]interface{}{common.OrbitTypeMetadata: "POEORB"})).To(Equal("Sentinel Precise"))
+		Expect(formatOrbitType(map[string]interface{}{common.OrbitTypeMetadata: "RESORB"})).To(Equal("Sentinel Restituted"))
+	})
+
+	It("should let SNAP download the orbit file if none was fetched", func() {
+		Expect(formatOrbitType(nil)).To(Equal("Sentinel Precise (Auto Download)"))
+	})
+})
@@ -0,0 +1,62 @@
+#!/usr/bin/env python3
+import glob
+import os
+import argparse
+from osgeo import gdal
+
+# Bands of the Sentinel-3 Level-1 products: instrument -> (pattern of the band files, geolocation file, longitude, latitude)
+INSTRUMENTS = {
+    "OL": ("Oa??_radiance.nc", "geo_coordinates.nc", "longitude", "latitude"),
+    "SL": ("S?_radiance_an.nc", "geodetic_an.nc", "longitude_an", "latitude_an"),
+}
+
+
+def band_name(file):
+    # Oa01_radiance.nc -> Oa01, S1_radiance_an.nc -> S1
+    return os.path.basename(file).split("_")[0]
+
+
+def extract_s3_files(workdir, pattern_out):
+    products = glob.glob(os.path.join(workdir, "*.SEN3"))
+    if len(products) != 1:
+        print("ERROR: expecting one SEN3 product in " + workdir)
+        exit(1)
+    product = products[0]
+    instrument = os.path.basename(product)[4:6]
+    if instrument not in INSTRUMENTS:
+        print("ERROR: instrument not supported: " + instrument)
+        exit(1)
+    pattern, geofile, lon, lat = INSTRUMENTS[instrument]
+    geofile = os.path.join(product, geofile)
+
+    for file in sorted(glob.glob(os.path.join(product, pattern))):
+        band = band_name(file)
+        print(file)
+        # Apply scale factor and offset of the netCDF
+        vrt = os.path.join(workdir, band + ".vrt")
+        gdal.Translate(vrt, 'NETCDF:"{}":{}'.format(file, os.path.basename(file)[:-3]), format="VRT", outputType=gdal.GDT_Float32, unscale=True)
+        ds = gdal.Open(vrt, gdal.GA_Update)
+        ds.SetMetadata({
+            "X_DATASET": 'NETCDF:"{}":{}'.format(geofile, lon),
+            "Y_DATASET": 'NETCDF:"{}":{}'.format(geofile, lat),
+            "X_BAND": "1",
+            "Y_BAND": "1",
+            "PIXEL_OFFSET": "0",
+            "LINE_OFFSET": "0",
+            "PIXEL_STEP": "1",
+            "LINE_STEP": "1",
+            "SRS": "EPSG:4326",
+        }, "GEOLOCATION")
+        ds = None
+        # Orthorectify using the geolocation arrays
+        gdal.Warp(os.path.join(workdir, pattern_out.replace("*", band)), vrt, format="GTiff", geoloc=True, dstSRS="EPSG:4326", dstNodata=float("nan"))
+        os.remove(vrt)
+
+
+if __name__ == '__main__':
+    args_parser = argparse.ArgumentParser(description='Extract s3 files (OLCI or SLSTR Level-1)')
+    args_parser.add_argument('--workdir', type=str, required=True)
+    args_parser.add_argument('--pattern-out', type=str, required=True)
+    args = args_parser.parse_args()
+
+    extract_s3_files(args.workdir, args.pattern_out)
@@ -7,13 +7,17 @@ import (
 )
 
 type Burst struct {
-	SwathID     string
-	TileNr      int
-	AnxTime     int
-	GeometryWKT string
+	SwathID     string `json:"swath_id"`
+	TileNr      int    `json:"tile_nr"`
+	AnxTime     int    `json:"anx_time"` // Legacy AnxTime (tenth of seconds since the ascending node crossing, modulo the orbit period)
+	BurstID     int    `json:"burst_id"` // ESA relative burst ID
+	Track       int    `json:"track"`    // Relative orbit of the burst
+	GeometryWKT string `json:"geometry"`
 }
 
-func BurstsFromAnnotation(annotationFile []byte, annotationURL string) (map[int]*Burst, error) {
+// BurstsFromAnnotation loads the bursts of an annotation file, indexed by their legacy AnxTime
+// relativeOrbit is the relative orbit of the product, used to compute the ESA burst ID if it is not provided by the annotation (IPF < 3.40)
+func BurstsFromAnnotation(annotationFile []byte, annotationURL string, relativeOrbit int) (map[int]*Burst, error) {
 	// XML GridPoint structure
 	type GridPoint struct {
 		Pixel     int     `xml:"pixel"`
@@ -22,13 +26,21 @@ func BurstsFromAnnotation(annotationFile []byte, annotationURL string) (map[int]
 		Longitude float64 `xml:"longitude"`
 	}
 
+	if relativeOrbit <= 0 || relativeOrbit > relativeOrbits {
+		return nil, fmt.Errorf("readAnnotation: wrong relative orbit %d for %s", relativeOrbit, annotationURL)
+	}
+
 	// Read annotations file
 	annotation := struct {
-		XMLName         xml.Name  `xml:"product"`
-		Swath           string    `xml:"adsHeader>swath"`
-		LinesPerBurst   int       `xml:"swathTiming>linesPerBurst"`
-		SamplesPerBurst int       `xml:"swathTiming>samplesPerBurst"`
-		AzimuthAnxTime  []float64 `xml:"swathTiming>burstList>burst>azimuthAnxTime"`
+		XMLName             xml.Name `xml:"product"`
+		Swath               string   `xml:"adsHeader>swath"`
+		AzimuthTimeInterval float64  `xml:"imageAnnotation>imageInformation>azimuthTimeInterval"`
+		LinesPerBurst       int      `xml:"swathTiming>linesPerBurst"`
+		SamplesPerBurst     int      `xml:"swathTiming>samplesPerBurst"`
+		Bursts              []struct {
+			AzimuthAnxTime float64 `xml:"azimuthAnxTime"`
+			BurstID        int     `xml:"burstId"` // Since IPF 3.40
+		} `xml:"swathTiming>burstList>burst"`
 
 		GridPoint []GridPoint `xml:"geolocationGrid>geolocationGridPointList>geolocationGridPoint"`
 	}{}
@@ -49,7 +61,8 @@ func BurstsFromAnnotation(annotationFile []byte, annotationURL string) (map[int]
 
 	// Burst
 	bursts := map[int]*Burst{}
-	for i, anxTime := range annotation.AzimuthAnxTime {
+	for i, burst := range annotation.Bursts {
+		anxTime := burst.AzimuthAnxTime
 		// First/Last lines of the burst
 		firstline := i * annotation.LinesPerBurst
 		lastline := (i + 1) * annotation.LinesPerBurst
@@ -66,12 +79,21 @@ func BurstsFromAnnotation(annotationFile []byte, annotationURL string) (map[int]
 			}
 		}
 
+		// ESA burst ID (computed with the time of the middle of the burst if not provided)
+		midAnxTime := anxTime + float64(annotation.LinesPerBurst)*annotation.AzimuthTimeInterval/2
+		burstID := burst.BurstID
+		if burstID == 0 {
+			burstID = ESABurstID(relativeOrbit, midAnxTime)
+		}
+
 		// Set bursts
 		intAnxTime := int(math.Round(math.Mod(anxTime, float64(12*24*60*60/175)) * 10))
 		bursts[intAnxTime] = &Burst{
 			SwathID: annotation.Swath,
 			TileNr:  i + 1,
 			AnxTime: intAnxTime,
+			BurstID: burstID,
+			Track:   BurstTrack(relativeOrbit, midAnxTime),
 			GeometryWKT: fmt.Sprintf("POLYGON((%f %f, %f %f, %f %f, %f %f, %f %f))",
 				first[firstline].Longitude, first[firstline].Latitude,
 				first[lastline].Longitude, first[lastline].Latitude,
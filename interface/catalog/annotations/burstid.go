@@ -0,0 +1,57 @@
+package annotations
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Constants of the definition of the ESA burst IDs (Sentinel-1 Level 1 Detailed Algorithm Definition, burst ID map)
+const (
+	burstCycleTime = 2.758273              // Burst cycle time (s)
+	preambleTime   = 2.299849              // Preamble time interval (s)
+	orbitTime      = 12 * 24 * 3600 / 175. // Nominal orbit period (s)
+	relativeOrbits = 175
+	maxBurstID     = 375887
+)
+
+var burstIDRegexp = regexp.MustCompile(`^t\d{3}_\d{6}_[a-z]+\d$`)
+
+// ESABurstID computes the ESA relative burst ID of a burst whose middle is acquired anxTime seconds after
+// the ascending node crossing of the given relative orbit
+// It is the same burst ID as the one of the ESA burst ID map and the one provided in the annotations since IPF 3.40
+func ESABurstID(relativeOrbit int, anxTime float64) int {
+	id := int(math.Floor((float64(relativeOrbit-1)*orbitTime + anxTime - preambleTime) / burstCycleTime))
+	return (id%maxBurstID+maxBurstID)%maxBurstID + 1
+}
+
+// BurstTrack returns the relative orbit of a burst acquired anxTime seconds after the ascending node crossing of the given relative orbit
+func BurstTrack(relativeOrbit int, anxTime float64) int {
+	return (relativeOrbit-1+int(math.Floor(anxTime/orbitTime)))%relativeOrbits + 1
+}
+
+// FormatBurstID returns the identifier of a burst given its track, its ESA burst ID and its swath: t<track>_<burstID>_<swath> (e.g. t044_093118_iw1)
+func FormatBurstID(track, burstID int, swath string) string {
+	return fmt.Sprintf("t%03d_%06d_%s", track, burstID, strings.ToLower(swath))
+}
+
+// ParseBurstID returns the track, the ESA burst ID and the swath of an identifier created by FormatBurstID
+func ParseBurstID(id string) (track, burstID int, swath string, err error) {
+	if !IsBurstID(id) {
+		return 0, 0, "", fmt.Errorf("ParseBurstID: wrong format: %s", id)
+	}
+	if track, err = strconv.Atoi(id[1:4]); err != nil {
+		return 0, 0, "", fmt.Errorf("ParseBurstID[%s]: %w", id, err)
+	}
+	if burstID, err = strconv.Atoi(id[5:11]); err != nil {
+		return 0, 0, "", fmt.Errorf("ParseBurstID[%s]: %w", id, err)
+	}
+	return track, burstID, id[12:], nil
+}
+
+// IsBurstID returns true if the identifier has been created by FormatBurstID
+func IsBurstID(id string) bool {
+	return burstIDRegexp.MatchString(id)
+}
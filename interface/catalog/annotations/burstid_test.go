@@ -0,0 +1,45 @@
+package annotations
+
+import (
+	"testing"
+)
+
+func TestESABurstID(t *testing.T) {
+	for _, c := range []struct {
+		relativeOrbit int
+		anxTime       float64
+		burstID       int
+		track         int
+	}{
+		{1, preambleTime + burstCycleTime/2, 1, 1},
+		{71, 151199.5*burstCycleTime + preambleTime - 70*orbitTime, 151200, 71},
+		{175, orbitTime - 0.1, maxBurstID, 175},
+		{175, orbitTime + preambleTime + burstCycleTime/2, 1, 1}, // Burst acquired after the ascending node crossing
+	} {
+		if id := ESABurstID(c.relativeOrbit, c.anxTime); id != c.burstID {
+			t.Errorf("ESABurstID(%d, %f): expecting %d, got %d", c.relativeOrbit, c.anxTime, c.burstID, id)
+		}
+		if track := BurstTrack(c.relativeOrbit, c.anxTime); track != c.track {
+			t.Errorf("BurstTrack(%d, %f): expecting %d, got %d", c.relativeOrbit, c.anxTime, c.track, track)
+		}
+	}
+}
+
+func TestFormatBurstID(t *testing.T) {
+	id := FormatBurstID(71, 151200, "IW2")
+	if id != "t071_151200_iw2" {
+		t.Errorf("expecting t071_151200_iw2, got %s", id)
+	}
+	if !IsBurstID(id) {
+		t.Errorf("%s must be a burst ID", id)
+	}
+	if IsBurstID("A44_IW1_8951") {
+		t.Errorf("A44_IW1_8951 must not be a burst ID")
+	}
+	if track, burstID, swath, err := ParseBurstID(id); err != nil || track != 71 || burstID != 151200 || swath != "iw2" {
+		t.Errorf("ParseBurstID(%s): expecting 71, 151200, iw2, got %d, %d, %s (%v)", id, track, burstID, swath, err)
+	}
+	if _, _, _, err := ParseBurstID("A44_IW1_8951"); err == nil {
+		t.Errorf("ParseBurstID(A44_IW1_8951): expecting an error")
+	}
+}
@@ -0,0 +1,77 @@
+package annotations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/airbusgeo/geocube/interface/storage"
+	"github.com/airbusgeo/geocube/interface/storage/uri"
+)
+
+// ParserVersion is the version of the bursts parsed from the annotations (BurstsFromAnnotation).
+// It must be incremented each time the parsing changes the bursts, to invalidate the entries of the BurstsCache.
+const ParserVersion = 1
+
+// BurstsCache is a persistent cache of the bursts parsed from the annotations of the scenes, stored in a storage:
+//   - <root>/v<ParserVersion>/<scene>.json
+//
+// It can be shared between several catalogues (the entries of a scene are immutable).
+type BurstsCache struct {
+	storage storage.Strategy
+	root    string
+}
+
+type burstsCacheEntry struct {
+	Version int      `json:"version"`
+	SceneID string   `json:"scene_id"`
+	Bursts  []*Burst `json:"bursts"`
+}
+
+// NewBurstsCache creates a cache of bursts stored in cacheURI (local, gs or s3)
+func NewBurstsCache(ctx context.Context, cacheURI string) (*BurstsCache, error) {
+	u, err := uri.ParseUri(cacheURI)
+	if err != nil {
+		return nil, fmt.Errorf("NewBurstsCache.ParseUri: %w", err)
+	}
+	strategy, err := u.NewStorageStrategy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("NewBurstsCache: %w", err)
+	}
+	return &BurstsCache{storage: strategy, root: strings.TrimSuffix(cacheURI, "/") + "/"}, nil
+}
+
+func (c *BurstsCache) file(sceneID string) string {
+	return fmt.Sprintf("%sv%d/%s.json", c.root, ParserVersion, sceneID)
+}
+
+// Get returns the bursts of the scene, or false if the scene is not in the cache
+func (c *BurstsCache) Get(ctx context.Context, sceneID string) ([]*Burst, bool, error) {
+	b, err := c.storage.Download(ctx, c.file(sceneID))
+	if err != nil {
+		if errors.Is(err, storage.ErrFileNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("BurstsCache.Get[%s].Download: %w", sceneID, err)
+	}
+	var entry burstsCacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil || entry.Version != ParserVersion || entry.SceneID != sceneID {
+		// Corrupted entry (e.g. partially written): considered as missing
+		return nil, false, nil
+	}
+	return entry.Bursts, true, nil
+}
+
+// Put stores the bursts of the scene
+func (c *BurstsCache) Put(ctx context.Context, sceneID string, bursts []*Burst) error {
+	b, err := json.Marshal(burstsCacheEntry{Version: ParserVersion, SceneID: sceneID, Bursts: bursts})
+	if err != nil {
+		return fmt.Errorf("BurstsCache.Put[%s].Marshal: %w", sceneID, err)
+	}
+	if err := c.storage.Upload(ctx, c.file(sceneID), b); err != nil {
+		return fmt.Errorf("BurstsCache.Put[%s].Upload: %w", sceneID, err)
+	}
+	return nil
+}
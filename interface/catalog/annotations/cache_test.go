@@ -0,0 +1,46 @@
+package annotations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBurstsCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cache, err := NewBurstsCache(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sceneID := "S1A_IW_SLC__1SDV_20230101T060000_20230101T060027_046589_059557_1A2B"
+
+	if _, ok, err := cache.Get(ctx, sceneID); err != nil || ok {
+		t.Fatalf("expecting a cache miss, got %v (err: %v)", ok, err)
+	}
+
+	bursts := []*Burst{
+		{SwathID: "IW1", TileNr: 1, AnxTime: 8951, BurstID: 93118, Track: 44, GeometryWKT: "POLYGON((0 0,1 0,1 1,0 1,0 0))"},
+		{SwathID: "IW2", TileNr: 1, AnxTime: 8963, BurstID: 93119, Track: 44, GeometryWKT: "POLYGON((1 0,2 0,2 1,1 1,1 0))"},
+	}
+	if err := cache.Put(ctx, sceneID, bursts); err != nil {
+		t.Fatal(err)
+	}
+	cached, ok, err := cache.Get(ctx, sceneID)
+	if err != nil || !ok {
+		t.Fatalf("expecting a cache hit, got %v (err: %v)", ok, err)
+	}
+	if !reflect.DeepEqual(cached, bursts) {
+		t.Errorf("expecting %v, got %v", bursts, cached)
+	}
+
+	// A corrupted entry is a cache miss
+	if err := os.WriteFile(filepath.Join(dir, "v1", sceneID+".json"), []byte(`{"version":1,"bur`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := cache.Get(ctx, sceneID); err != nil || ok {
+		t.Errorf("expecting a cache miss, got %v (err: %v)", ok, err)
+	}
+}
@@ -0,0 +1,221 @@
+package asf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/service"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/go-spatial/geom/encoding/wkt"
+	"github.com/paulsmith/gogeos/geos"
+)
+
+const (
+	ASFSearchURL   = "https://api.daac.asf.alaska.edu/services/search/param"
+	ASFSearchLimit = 10000
+)
+
+// Feature is a result of the ASF Search API (output=geojson)
+type Feature struct {
+	Geometry   geojson.Geometry `json:"geometry"`
+	Properties struct {
+		SceneName       string `json:"sceneName"`
+		FileID          string `json:"fileID"`
+		StartTime       string `json:"startTime"`
+		ProcessingDate  string `json:"processingDate"`
+		ProcessingLevel string `json:"processingLevel"`
+		BeamModeType    string `json:"beamModeType"`
+		FlightDirection string `json:"flightDirection"`
+		PathNumber      int    `json:"pathNumber"`
+		FrameNumber     int    `json:"frameNumber"`
+		Orbit           int    `json:"orbit"`
+		Polarization    string `json:"polarization"`
+		Bytes           int64  `json:"bytes"`
+	} `json:"properties"`
+}
+
+// Provider implements catalog.ScenesProvider using the ASF Search API (Sentinel-1 only)
+type Provider struct {
+	Limit int
+}
+
+func (p *Provider) Supports(c common.Constellation) bool {
+	return c == common.Sentinel1
+}
+
+func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest, aoi geos.Geometry) (entities.Scenes, error) {
+	if s.Limit == 0 {
+		s.Limit = ASFSearchLimit
+	}
+	if common.GetConstellationFromString(area.SceneType.Constellation) != common.Sentinel1 {
+		return entities.Scenes{}, fmt.Errorf("ASF: constellation not supported: %s", area.SceneType.Constellation)
+	}
+
+	// Append aoi
+	convexhull, err := aoi.ConvexHull()
+	if err != nil {
+		return entities.Scenes{}, fmt.Errorf("ASF.SearchScenes.ConvexHull: %w", err)
+	}
+	convexhullWKT, err := convexhull.ToWKT()
+	if err != nil {
+		return entities.Scenes{}, fmt.Errorf("ASF.SearchScenes.ToWKT: %w", err)
+	}
+	query, err := queryParameters(ctx, area, convexhullWKT)
+	if err != nil {
+		return entities.Scenes{}, fmt.Errorf("ASF.SearchScenes.%w", err)
+	}
+
+	// The ASF Search API does not support pagination: the first pages are retrieved and skipped
+	maxResults, first := s.Limit, 0
+	if area.Limit > 0 {
+		maxResults, first = (area.Page+1)*area.Limit, area.Page*area.Limit
+	}
+	query.Set("maxResults", strconv.Itoa(maxResults))
+
+	// Execute query
+	log.Logger(ctx).Sugar().Debugf("[ASF] Search %s", query.Encode())
+	jsonResults, err := service.GetBodyRetry(ASFSearchURL+"?"+query.Encode(), 3)
+	if err != nil {
+		return entities.Scenes{}, fmt.Errorf("ASF.SearchScenes.GetBodyRetry: %w", err)
+	}
+	results := struct {
+		Features []Feature `json:"features"`
+	}{}
+	if err := json.Unmarshal(jsonResults, &results); err != nil {
+		return entities.Scenes{}, fmt.Errorf("ASF.SearchScenes.Unmarshal: %w (response: %s)", err, jsonResults)
+	}
+	if first >= len(results.Features) {
+		return entities.Scenes{}, nil
+	}
+
+	scenes, err := Parse(results.Features[first:])
+	if err != nil {
+		return entities.Scenes{}, fmt.Errorf("ASF.SearchScenes.%w", err)
+	}
+	return entities.Scenes{
+		Scenes:     scenes,
+		Properties: nil,
+	}, nil
+}
+
+// queryParameters creates the parameters of the query from the area and the wkt of the aoi
+// Parameters (same as Copernicus/Creodias): producttype (SLC (default), GRD, GRDH, GRDM...), polarisationmode (default: "VV VH"),
+// sensoroperationalmode (default: IW), relativeorbitnumber, orbitdirection (ASCENDING or DESCENDING) and frame (ASF frame number or range, e.g. "150-160")
+func queryParameters(ctx context.Context, area *entities.AreaToIngest, aoiWKT string) (neturl.Values, error) {
+	parameters := map[string]string{
+		"producttype":           "SLC",
+		"polarisationmode":      "VV VH",
+		"sensoroperationalmode": "IW",
+	}
+	for k, v := range area.SceneType.Parameters {
+		parameters[strings.ToLower(k)] = v
+	}
+
+	query := neturl.Values{}
+	query.Set("platform", "Sentinel-1")
+	query.Set("output", "geojson")
+	query.Set("intersectsWith", aoiWKT)
+	query.Set("start", area.StartTime.Format("2006-01-02T15:04:05Z"))
+	query.Set("end", area.EndTime.Format("2006-01-02T15:04:05Z"))
+	for k, v := range parameters {
+		switch k {
+		case "producttype":
+			processingLevel, err := processingLevel(v)
+			if err != nil {
+				return nil, fmt.Errorf("queryParameters: %w", err)
+			}
+			query.Set("processingLevel", processingLevel)
+		case "polarisationmode":
+			query.Set("polarization", strings.Join(strings.FieldsFunc(v, func(r rune) bool { return r == ' ' || r == '&' || r == '+' }), "+"))
+		case "sensoroperationalmode":
+			query.Set("beamMode", v)
+		case "relativeorbitnumber":
+			query.Set("relativeOrbit", v)
+		case "orbitdirection":
+			query.Set("flightDirection", strings.ToUpper(v))
+		case "frame":
+			query.Set("frame", v)
+		default:
+			log.Logger(ctx).Sugar().Debugf("ASF: parameter %s not supported", k)
+		}
+	}
+	return query, nil
+}
+
+// processingLevel returns the ASF processing level of the product type
+func processingLevel(productType string) (string, error) {
+	pt := strings.ToUpper(productType)
+	switch {
+	case pt == "SLC":
+		return "SLC", nil
+	case pt == "GRD_HD" || pt == "GRD_MD" || pt == "GRD_HS" || pt == "GRD_MS":
+		return pt, nil
+	case pt == "GRD" || strings.Contains(pt, "GRDH"):
+		return "GRD_HD", nil
+	case strings.Contains(pt, "GRDM"):
+		return "GRD_MD", nil
+	}
+	return "", fmt.Errorf("product type not supported: %s", productType)
+}
+
+// Parse converts the features to scenes, with the same tags as opensearch.Parse
+func Parse(features []Feature) ([]*entities.Scene, error) {
+	scenes := make([]*entities.Scene, len(features))
+	for i, feature := range features {
+		properties := feature.Properties
+		// Parse date
+		date, err := time.Parse(time.RFC3339Nano, properties.StartTime)
+		if err != nil {
+			// Some dates are returned without timezone
+			if date, err = time.Parse("2006-01-02T15:04:05.999999", properties.StartTime); err != nil {
+				return nil, fmt.Errorf("Parse.TimeParse: %w", err)
+			}
+		}
+		if feature.Geometry.Geometry == nil {
+			return nil, fmt.Errorf("Parse: missing geometry of %s", properties.SceneName)
+		}
+		productType := properties.ProcessingLevel
+		if strings.HasPrefix(productType, "GRD_") {
+			// Same product type as Copernicus: <mode>_GRD<resolution>_1S
+			productType = properties.BeamModeType + "_GRD" + productType[4:5] + "_1S"
+		}
+
+		// Create scene
+		scenes[i] = &entities.Scene{
+			Scene: common.Scene{
+				SourceID: properties.SceneName,
+				Data: common.SceneAttrs{
+					Date:         date,
+					TileMappings: map[string]common.TileMapping{},
+					Metadata:     map[string]interface{}{},
+				},
+			},
+			Tags: map[string]string{
+				common.TagSourceID:         properties.SceneName,
+				common.TagIngestionDate:    properties.ProcessingDate,
+				common.TagOrbitDirection:   properties.FlightDirection,
+				common.TagRelativeOrbit:    fmt.Sprintf("%d", properties.PathNumber),
+				common.TagOrbit:            fmt.Sprintf("%d", properties.Orbit),
+				common.TagProductType:      productType,
+				common.TagPolarisationMode: strings.ReplaceAll(properties.Polarization, "+", "&"),
+				common.TagSliceNumber:      "undefined",
+			},
+			GeometryWKT: wkt.MustEncode(feature.Geometry.Geometry),
+		}
+		if properties.Bytes > 0 {
+			scenes[i].Data.Metadata[common.SizeMetadata] = properties.Bytes
+		}
+
+		// Autofill some fields
+		scenes[i].AutoFill()
+	}
+	return scenes, nil
+}
@@ -0,0 +1,103 @@
+package asf
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+)
+
+func TestQueryParameters(t *testing.T) {
+	area := entities.AreaToIngest{
+		StartTime: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2022, 1, 17, 23, 0, 0, 0, time.UTC),
+		SceneType: entities.SceneType{
+			Constellation: "sentinel1",
+			Parameters: map[string]string{
+				"producttype":         "GRD",
+				"relativeorbitnumber": "44",
+				"orbitdirection":      "ascending",
+				"frame":               "150-160",
+			},
+		},
+	}
+	query, err := queryParameters(context.Background(), &area, "POLYGON ((1 43,2 43,2 44,1 43))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]string{
+		"platform":        "Sentinel-1",
+		"processingLevel": "GRD_HD",
+		"polarization":    "VV+VH",
+		"beamMode":        "IW",
+		"relativeOrbit":   "44",
+		"flightDirection": "ASCENDING",
+		"frame":           "150-160",
+		"start":           "2022-01-01T00:00:00Z",
+		"end":             "2022-01-17T23:00:00Z",
+		"intersectsWith":  "POLYGON ((1 43,2 43,2 44,1 43))",
+	} {
+		if query.Get(k) != v {
+			t.Errorf("%s: expecting %s, got %s", k, v, query.Get(k))
+		}
+	}
+
+	area.SceneType.Parameters = map[string]string{"producttype": "OCN"}
+	if _, err := queryParameters(context.Background(), &area, ""); err == nil {
+		t.Errorf("expecting an error with an unsupported product type")
+	}
+}
+
+func TestParse(t *testing.T) {
+	results := struct {
+		Features []Feature `json:"features"`
+	}{}
+	if err := json.Unmarshal([]byte(`{"type": "FeatureCollection", "features": [{
+		"type": "Feature",
+		"geometry": {"type": "Polygon", "coordinates": [[[9.6, 54.5], [13.5, 54.9], [13.1, 56.5], [9.1, 56.1], [9.6, 54.5]]]},
+		"properties": {
+			"beamModeType": "IW", "bytes": 4374586291, "fileID": "S1A_IW_SLC__1SDV_20220105T053515_20220105T053542_041320_04E93D_6A6B-SLC",
+			"flightDirection": "DESCENDING", "frameNumber": 382, "orbit": 41320, "pathNumber": 66, "platform": "Sentinel-1A",
+			"polarization": "VV+VH", "processingDate": "2022-01-05T05:35:15.000Z", "processingLevel": "SLC",
+			"sceneName": "S1A_IW_SLC__1SDV_20220105T053515_20220105T053542_041320_04E93D_6A6B", "startTime": "2022-01-05T05:35:15.000Z"
+		}}, {
+		"type": "Feature",
+		"geometry": {"type": "Polygon", "coordinates": [[[9.6, 54.5], [13.5, 54.9], [13.1, 56.5], [9.1, 56.1], [9.6, 54.5]]]},
+		"properties": {
+			"beamModeType": "IW", "flightDirection": "ASCENDING", "orbit": 41327, "pathNumber": 117, "polarization": "VV+VH",
+			"processingDate": "2022-01-05T17:02:11.000Z", "processingLevel": "GRD_HD",
+			"sceneName": "S1A_IW_GRDH_1SDV_20220105T170211_20220105T170236_041327_04E978_2D83", "startTime": "2022-01-05T17:02:11.000Z"
+		}}]}`), &results); err != nil {
+		t.Fatal(err)
+	}
+	scenes, err := Parse(results.Features)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenes) != 2 {
+		t.Fatalf("expecting 2 scenes, got %d", len(scenes))
+	}
+	for k, v := range map[string]string{
+		common.TagSourceID:         "S1A_IW_SLC__1SDV_20220105T053515_20220105T053542_041320_04E93D_6A6B",
+		common.TagOrbitDirection:   "DESCENDING",
+		common.TagRelativeOrbit:    "66",
+		common.TagOrbit:            "41320",
+		common.TagProductType:      "SLC",
+		common.TagPolarisationMode: "VV&VH",
+		common.TagConstellation:    "SENTINEL1",
+		common.TagSatellite:        "SENTINEL1A",
+	} {
+		if scenes[0].Tags[k] != v {
+			t.Errorf("%s: expecting %s, got %s", k, v, scenes[0].Tags[k])
+		}
+	}
+	if !scenes[0].Data.Date.Equal(time.Date(2022, 1, 5, 5, 35, 15, 0, time.UTC)) || scenes[0].Data.Metadata[common.SizeMetadata] != int64(4374586291) {
+		t.Errorf("wrong date or size: %v %v", scenes[0].Data.Date, scenes[0].Data.Metadata)
+	}
+	if scenes[1].Tags[common.TagProductType] != "IW_GRDH_1S" {
+		t.Errorf("expecting product type IW_GRDH_1S, got %s", scenes[1].Tags[common.TagProductType])
+	}
+}
@@ -26,6 +26,7 @@ const (
 	CopernicusQueryURL      = "http://catalogue.dataspace.copernicus.eu/resto/api/collections/search.json?"
 	Sentinel1QueryURL       = "https://catalogue.dataspace.copernicus.eu/resto/api/collections/Sentinel1/search.json?"
 	Sentinel2QueryURL       = "https://catalogue.dataspace.copernicus.eu/resto/api/collections/Sentinel2/search.json?"
+	Sentinel3QueryURL       = "https://catalogue.dataspace.copernicus.eu/resto/api/collections/Sentinel3/search.json?"
 	CopernicusODataQueryURL = "https://catalogue.dataspace.copernicus.eu/odata/v1/Products?$filter="
 )
 
@@ -35,7 +36,7 @@ type Provider struct {
 
 func (p *Provider) Supports(c common.Constellation) bool {
 	switch c {
-	case common.Sentinel1, common.Sentinel2:
+	case common.Sentinel1, common.Sentinel2, common.Sentinel3:
 		return true
 	}
 	return false
@@ -49,6 +50,8 @@ func (s *Provider) OpenSearchScenes(ctx context.Context, area *entities.AreaToIn
 		hostUrl = Sentinel1QueryURL
 	case common.Sentinel2:
 		hostUrl = Sentinel2QueryURL
+	case common.Sentinel3:
+		hostUrl = Sentinel3QueryURL
 	}
 	query, err := opensearch.ConstructQuery(ctx, area, aoi, hostUrl)
 	if err != nil {
@@ -95,6 +98,9 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 	case common.Sentinel2:
 		parametersMap[mapKey["platformname"]] = "SENTINEL-2"
 		parametersMap[mapKey["producttype"]] = "S2MSI1C"
+	case common.Sentinel3:
+		parametersMap[mapKey["platformname"]] = "SENTINEL-3"
+		parametersMap[mapKey["producttype"]] = "OL_1_EFR___"
 	default:
 		return entities.Scenes{}, fmt.Errorf("Copernicus: constellation not supported: %s", area.SceneType.Constellation)
 	}
@@ -107,6 +113,14 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 		parametersMap[k] = v
 	}
 
+	// Sentinel-1 GRD: the OData productType is <mode>_GRD<resolution>_1S (High resolution by default)
+	if pt := parametersMap[mapKey["producttype"]]; strings.HasPrefix(pt, "GRD") && !strings.Contains(pt, "_") {
+		if pt == "GRD" {
+			pt = "GRDH"
+		}
+		parametersMap[mapKey["producttype"]] = parametersMap[mapKey["sensoroperationalmode"]] + "_" + pt + "_1S"
+	}
+
 	// Create query
 	var parameters []string
 	{
@@ -166,7 +180,7 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 		if err != nil {
 			return entities.Scenes{}, fmt.Errorf("Copernicus.searchScenes.TimeParse: %w", err)
 		}
-		sourceID := strings.TrimSuffix(rawscene.Identifier, ".SAFE")
+		sourceID := strings.TrimSuffix(strings.TrimSuffix(rawscene.Identifier, ".SAFE"), ".SEN3")
 
 		// Create scene
 		scenes[i] = &entities.Scene{
@@ -194,6 +208,9 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 
 		// Autofill some fields
 		scenes[i].AutoFill()
+		if rawscene.ContentLength > 0 {
+			scenes[i].Data.Metadata[common.SizeMetadata] = rawscene.ContentLength
+		}
 
 		// Optional tags
 		switch common.GetConstellationFromString(area.SceneType.Constellation) {
@@ -202,6 +219,10 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 			scenes[i].Tags[common.TagSliceNumber] = rawscene.AttributesMap["sliceNumber"]
 		case common.Sentinel2:
 			scenes[i].Tags[common.TagCloudCoverPercentage] = rawscene.AttributesMap["cloudCover"]
+		case common.Sentinel3:
+			if cloudCover, ok := rawscene.AttributesMap["cloudCover"]; ok {
+				scenes[i].Tags[common.TagCloudCoverPercentage] = cloudCover
+			}
 		}
 	}
 
@@ -212,10 +233,11 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 }
 
 type Hits struct {
-	Uuid        string           `json:"Id"`
-	Identifier  string           `json:"Name"`
-	Footprint   geojson.Geometry `json:"GeoFootprint"`
-	ContentDate struct {
+	Uuid          string           `json:"Id"`
+	Identifier    string           `json:"Name"`
+	Footprint     geojson.Geometry `json:"GeoFootprint"`
+	ContentLength int64            `json:"ContentLength"`
+	ContentDate   struct {
 		BeginPosition string `json:"Start"`
 	} `json:"ContentDate"`
 	Attributes []struct {
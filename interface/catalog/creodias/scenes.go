@@ -28,7 +28,7 @@ type Provider struct {
 
 func (p *Provider) Supports(c common.Constellation) bool {
 	switch c {
-	case common.Sentinel1, common.Sentinel2:
+	case common.Sentinel1, common.Sentinel2, common.Sentinel3:
 		return true
 	}
 	return false
@@ -63,6 +63,9 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 	case common.Sentinel2:
 		parametersMap["constellation"] = "SENTINEL-2"
 		parametersMap[mapKey["producttype"]] = "S2MSI1C"
+	case common.Sentinel3:
+		parametersMap["constellation"] = "SENTINEL-3"
+		parametersMap[mapKey["producttype"]] = "OL_1_EFR___"
 	default:
 		return entities.Scenes{}, fmt.Errorf("Creodias: constellation not supported: %s", area.SceneType.Constellation)
 	}
@@ -86,6 +89,8 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 				hostUrl = "https://datahub.creodias.eu/resto/api/collections/Sentinel1/search.json?"
 			case common.Sentinel2:
 				hostUrl = "https://datahub.creodias.eu/resto/api/collections/Sentinel2/search.json?"
+			case common.Sentinel3:
+				hostUrl = "https://datahub.creodias.eu/resto/api/collections/Sentinel3/search.json?"
 			}
 			continue
 		}
@@ -133,7 +138,7 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 		// Create scene
 		scenes[i] = &entities.Scene{
 			Scene: common.Scene{
-				SourceID: strings.TrimSuffix(rawscene.Properties.Identifier, ".SAFE"),
+				SourceID: strings.TrimSuffix(strings.TrimSuffix(rawscene.Properties.Identifier, ".SAFE"), ".SEN3"),
 				Data: common.SceneAttrs{
 					Date:         date,
 					TileMappings: map[string]common.TileMapping{},
@@ -155,13 +160,16 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 		}
 
 		scenes[i].AutoFill()
+		if size := rawscene.Properties.Services.Download.Size; size > 0 {
+			scenes[i].Data.Metadata[common.SizeMetadata] = size
+		}
 
 		// Optional tags
 		switch common.GetConstellationFromString(area.SceneType.Constellation) {
 		case common.Sentinel1:
 			scenes[i].Tags[common.TagPolarisationMode] = rawscene.Properties.Polarisation
 			scenes[i].Tags[common.TagSliceNumber] = "undefined"
-		case common.Sentinel2:
+		case common.Sentinel2, common.Sentinel3:
 			scenes[i].Tags[common.TagCloudCoverPercentage] = fmt.Sprintf("%f", rawscene.Properties.CloudCoverPercentage)
 		}
 	}
@@ -182,6 +190,11 @@ type creodiasHits struct {
 		RelativeOrbitNumber  int     `json:"relativeOrbitNumber"`
 		OrbitNumber          int     `json:"orbitNumber"`
 		Polarisation         string  `json:"polarisation"`
+		Services             struct {
+			Download struct {
+				Size int64 `json:"size"`
+			} `json:"download"`
+		} `json:"services"`
 	} `json:"properties"`
 }
 
@@ -23,6 +23,8 @@ import (
 const (
 	LandsatAwsURL         = "https://landsatlook.usgs.gov/stac-server/search"
 	LandsatCollectionC2L1 = "landsat-c2l1"
+	LandsatCollectionC2SR = "landsat-c2l2-sr" // Level-2 surface reflectance (L2SP & L2SR products)
+	LandsatCollectionC2ST = "landsat-c2l2-st" // Level-2 surface temperature (L2SP products)
 	LandsatCatalogLimit   = 1000
 )
 
@@ -74,7 +76,7 @@ type Provider struct {
 
 func (p *Provider) Supports(c common.Constellation) bool {
 	switch c {
-	case common.Landsat89:
+	case common.Landsat89, common.Landsat457:
 		return true
 	}
 	return false
@@ -99,17 +101,31 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 		Datetime:   startDate + "/" + endDate,
 	}
 
-	// Only LANDSAT_8 and LANDSAT_9 are currently supported
+	// Default collection is Collection 2 Level-1
+	req.Collections = []string{LandsatCollectionC2L1}
 	switch common.GetConstellationFromString(area.SceneType.Constellation) {
 	case common.Landsat89:
 		req.Query["platform"] = map[string][]string{"in": {"LANDSAT_8", "LANDSAT_9"}}
-		req.Collections = []string{LandsatCollectionC2L1}
+	case common.Landsat457:
+		req.Query["platform"] = map[string][]string{"in": {"LANDSAT_4", "LANDSAT_5", "LANDSAT_7"}}
 	default:
 		return entities.Scenes{}, fmt.Errorf("SearchScenes(LandsatAws): constellation not supported: %s", area.SceneType.Constellation)
 	}
 
 	for k, v := range area.SceneType.Parameters {
-		if k == "cloudcoverpercentage" {
+		switch k {
+		case "collection":
+			switch v {
+			case LandsatCollectionC2L1, LandsatCollectionC2SR, LandsatCollectionC2ST:
+				req.Collections = []string{v}
+			default:
+				return entities.Scenes{}, fmt.Errorf("SearchScenes(LandsatAws): collection not supported: %s", v)
+			}
+		case "producttype":
+			req.Query["landsat:correction"] = map[string][]string{"in": strings.Fields(v)}
+		case "platform":
+			req.Query["platform"] = map[string][]string{"in": strings.Fields(v)}
+		case "cloudcoverpercentage":
 			vs := strings.Split(strings.Trim(v[1:], "]"), " TO ")
 			if len(vs) != 2 {
 				return entities.Scenes{}, fmt.Errorf("SearchScenes(LandsatAws): cloudcoverpercentage must be 'Min TO Max'")
@@ -133,7 +149,8 @@ func (s *Provider) SearchScenes(ctx context.Context, area *entities.AreaToIngest
 	scenes := make([]*entities.Scene, len(landsatFeatures))
 	for i, landsatFeature := range landsatFeatures {
 		properties := landsatFeature.Properties
-		sourceId := landsatFeature.Id
+		// Level-2 items are suffixed by the kind of product (_SR, _ST)
+		sourceId := strings.TrimSuffix(strings.TrimSuffix(landsatFeature.Id, "_SR"), "_ST")
 		// Parse date
 		date, err := time.Parse(time.RFC3339Nano, properties["datetime"].(string))
 		if err != nil {
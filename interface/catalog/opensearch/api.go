@@ -59,6 +59,8 @@ func ConstructQuery(ctx context.Context, area *entities.AreaToIngest, aoi geos.G
 		parametersMap[mapKey["sensoroperationalmode"]] = "IW"
 	case common.Sentinel2:
 		parametersMap[mapKey["producttype"]] = "S2MSI1C"
+	case common.Sentinel3:
+		parametersMap[mapKey["producttype"]] = "OL_1_EFR___"
 	default:
 		return "", fmt.Errorf("OpenSearch: constellation not supported: %s", area.SceneType.Constellation)
 	}
@@ -174,7 +176,7 @@ func Parse(area *entities.AreaToIngest, hits []Hits) (entities.Scenes, error) {
 		// Create scene
 		scenes[i] = &entities.Scene{
 			Scene: common.Scene{
-				SourceID: strings.TrimSuffix(rawscene.Properties.Identifier, ".SAFE"),
+				SourceID: strings.TrimSuffix(strings.TrimSuffix(rawscene.Properties.Identifier, ".SAFE"), ".SEN3"),
 				Data: common.SceneAttrs{
 					Date:         date,
 					TileMappings: map[string]common.TileMapping{},
@@ -203,7 +205,7 @@ func Parse(area *entities.AreaToIngest, hits []Hits) (entities.Scenes, error) {
 		case common.Sentinel1:
 			scenes[i].Tags[common.TagPolarisationMode] = rawscene.Properties.Polarisation
 			scenes[i].Tags[common.TagSliceNumber] = "undefined"
-		case common.Sentinel2:
+		case common.Sentinel2, common.Sentinel3:
 			scenes[i].Tags[common.TagCloudCoverPercentage] = fmt.Sprintf("%f", rawscene.Properties.CloudCoverPercentage)
 		}
 	}
@@ -0,0 +1,83 @@
+package remotezip
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/service"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+// ArchiveSource returns the url of the archive of a scene and the value of the Authorization header to download it
+// (e.g. provider.CopernicusImageProvider or provider.ASFImageProvider)
+type ArchiveSource interface {
+	Name() string
+	ArchiveURL(scene common.Scene) (url string, authorization string, err error)
+	// AuthDomains returns the domains trusted to receive the Authorization header when the download is redirected
+	AuthDomains() []string
+}
+
+// AnnotationsProvider retrieves the annotations from the archive of the scene available on a remote server (e.g. Copernicus, ASF),
+// reading only the central directory and the annotation files with HTTP range requests.
+type AnnotationsProvider struct {
+	Source ArchiveSource
+}
+
+// AnnotationFiles retrieves them from the remote archive
+func (ap AnnotationsProvider) AnnotationsFiles(ctx context.Context, scene *common.Scene) (map[string][]byte, error) {
+	reg, err := regexp.Compile(scene.SourceID + ".SAFE/annotation/s1[^/]*xml")
+	if err != nil {
+		return nil, fmt.Errorf("annotationFiles.Compile[%s]: %w", scene.SourceID+".SAFE/annotation/*xml", err)
+	}
+
+	url, authorization, err := ap.Source.ArchiveURL(*scene)
+	if err != nil {
+		return nil, fmt.Errorf("AnnotationsFiles.%w", err)
+	}
+	annotationsFiles, err := extract(ctx, url, authorization, ap.Source.AuthDomains(), *reg)
+	if err != nil {
+		return nil, fmt.Errorf("annotationsFiles[%s:%s].%w", ap.Source.Name(), scene.SourceID, err)
+	}
+	return annotationsFiles, nil
+}
+
+// extract files from a remote archive
+func extract(ctx context.Context, url, authorization string, authDomains []string, reg regexp.Regexp) (map[string][]byte, error) {
+	client := &http.Client{CheckRedirect: service.CheckRedirectAndCopyAuth(authDomains...)}
+	reader, err := newRangeReader(ctx, client, url, authorization)
+	if err != nil {
+		return nil, fmt.Errorf("extract.%w", err)
+	}
+
+	zipf, err := zip.NewReader(reader, reader.Size())
+	if err != nil {
+		return nil, fmt.Errorf("extract.NewReader: %w", err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range zipf.File {
+		if reg.MatchString(f.Name) {
+			if err := func() error {
+				fr, err := f.Open()
+				if err != nil {
+					return fmt.Errorf("open[%s]: %w", f.Name, err)
+				}
+				defer fr.Close()
+
+				if files[f.Name], err = io.ReadAll(fr); err != nil {
+					return fmt.Errorf("ReadAll[%s]: %w", f.Name, err)
+				}
+				return nil
+			}(); err != nil {
+				return nil, fmt.Errorf("extract.%w", err)
+			}
+		}
+	}
+	log.Logger(ctx).Sugar().Debugf("%d annotations read from %s with %d requests", len(files), url, reader.requests)
+	return files, nil
+}
@@ -0,0 +1,156 @@
+package remotezip
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/common"
+)
+
+type testSource struct {
+	url string
+}
+
+func (s testSource) Name() string {
+	return "test"
+}
+
+func (s testSource) AuthDomains() []string {
+	return nil
+}
+
+func (s testSource) ArchiveURL(scene common.Scene) (string, string, error) {
+	return s.url + "/" + scene.SourceID + ".zip", "Bearer token", nil
+}
+
+const testScene = "S1A_IW_SLC__1SDV_20230101T060000_20230101T060027_046589_059557_1A2B"
+
+// testArchive creates a SAFE archive with annotations and a large measurement file
+func testArchive(t *testing.T) ([]byte, map[string][]byte) {
+	rnd := rand.New(rand.NewSource(0))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rnd.Read(b)
+		return b
+	}
+	files := map[string][]byte{
+		testScene + ".SAFE/manifest.safe":                            []byte("<manifest/>"),
+		testScene + ".SAFE/annotation/s1a-iw1-slc-vh-001.xml":        bytes.Repeat([]byte("<product>iw1</product>"), 10000),
+		testScene + ".SAFE/annotation/s1a-iw2-slc-vh-002.xml":        random(3 * minReadSize / 2),
+		testScene + ".SAFE/annotation/calibration/calibration-1.xml": []byte("<calibration/>"),
+		testScene + ".SAFE/measurement/s1a-iw1-slc-vh-001.tiff":      random(4 * minReadSize),
+	}
+	annotations := map[string][]byte{}
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, name := range []string{
+		testScene + ".SAFE/manifest.safe",
+		testScene + ".SAFE/measurement/s1a-iw1-slc-vh-001.tiff",
+		testScene + ".SAFE/annotation/s1a-iw1-slc-vh-001.xml",
+		testScene + ".SAFE/annotation/s1a-iw2-slc-vh-002.xml",
+		testScene + ".SAFE/annotation/calibration/calibration-1.xml",
+	} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains([]byte(name), []byte("annotation/s1")) {
+			annotations[name] = files[name]
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), annotations
+}
+
+func TestAnnotationsFiles(t *testing.T) {
+	archive, annotations := testArchive(t)
+	var requested int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/redirect/"+testScene+".zip" {
+			http.Redirect(w, r, "/"+testScene+".zip", http.StatusTemporaryRedirect)
+			return
+		}
+		rw := &countingWriter{ResponseWriter: w}
+		http.ServeContent(rw, r, testScene+".zip", time.Time{}, bytes.NewReader(archive))
+		requested += rw.n
+	}))
+	defer server.Close()
+
+	for _, url := range []string{server.URL, server.URL + "/redirect"} {
+		requested = 0
+		ap := AnnotationsProvider{Source: testSource{url: url}}
+		files, err := ap.AnnotationsFiles(context.Background(), &common.Scene{SourceID: testScene})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != len(annotations) {
+			t.Errorf("expected %d annotations, got %d", len(annotations), len(files))
+		}
+		for name, data := range annotations {
+			if !bytes.Equal(files[name], data) {
+				t.Errorf("%s: wrong content", name)
+			}
+		}
+		if requested >= int64(len(archive))/2 {
+			t.Errorf("%d bytes read over %d", requested, len(archive))
+		}
+	}
+}
+
+func TestAnnotationsFilesRangeNotSupported(t *testing.T) {
+	archive, _ := testArchive(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	ap := AnnotationsProvider{Source: testSource{url: server.URL}}
+	if _, err := ap.AnnotationsFiles(context.Background(), &common.Scene{SourceID: testScene}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		contentRange string
+		size         int64
+		err          bool
+	}{
+		{"bytes 0-99/1000", 1000, false},
+		{"bytes 900-999/1000", 1000, false},
+		{"bytes 0-99/*", 0, true},
+		{"", 0, true},
+	}
+	for _, test := range tests {
+		size, err := parseContentRange(test.contentRange)
+		if (err != nil) != test.err || size != test.size {
+			t.Errorf("%s: expected %d (err: %v), got %d (err: %v)", test.contentRange, test.size, test.err, size, err)
+		}
+	}
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+	return n, err
+}
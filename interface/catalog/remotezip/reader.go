@@ -0,0 +1,159 @@
+package remotezip
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/airbusgeo/geocube-ingester/service"
+)
+
+const (
+	// tailSize is the size of the end of the archive read by the first request, expected to contain the central directory
+	tailSize = 1 << 16
+	// minReadSize is the minimum size of a range request (read-ahead, as the entries are decompressed by small chunks)
+	minReadSize = 1 << 20
+)
+
+// block is a range of the remote file that has been read
+type block struct {
+	offset int64
+	data   []byte
+}
+
+func (b block) contains(off int64, n int) bool {
+	return off >= b.offset && off+int64(n) <= b.offset+int64(len(b.data))
+}
+
+// rangeReader implements io.ReaderAt on a remote file using HTTP range requests
+// It caches the end of the file (central directory of the zip) and the last block read.
+type rangeReader struct {
+	ctx           context.Context
+	client        *http.Client
+	url           string
+	authorization string
+	size          int64
+	tail          block
+	last          block
+	mutex         sync.Mutex
+	requests      int
+}
+
+// newRangeReader reads the end of the remote file to get its size
+func newRangeReader(ctx context.Context, client *http.Client, url, authorization string) (*rangeReader, error) {
+	r := &rangeReader{ctx: ctx, client: client, url: url, authorization: authorization}
+	data, size, err := r.get(fmt.Sprintf("bytes=-%d", tailSize))
+	if err != nil {
+		return nil, fmt.Errorf("newRangeReader.%w", err)
+	}
+	r.size = size
+	r.tail = block{offset: size - int64(len(data)), data: data}
+	return r, nil
+}
+
+// Size returns the size of the remote file
+func (r *rangeReader) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("ReadAt: negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if off+int64(n) > r.size {
+		n = int(r.size - off)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var b block
+	switch {
+	case r.tail.contains(off, n):
+		b = r.tail
+	case r.last.contains(off, n):
+		b = r.last
+	default:
+		end := off + int64(max(n, minReadSize))
+		if end > r.size {
+			end = r.size
+		}
+		data, _, err := r.get(fmt.Sprintf("bytes=%d-%d", off, end-1))
+		if err != nil {
+			return 0, fmt.Errorf("ReadAt.%w", err)
+		}
+		if int64(len(data)) != end-off {
+			return 0, fmt.Errorf("ReadAt: unexpected length: %d (expected %d)", len(data), end-off)
+		}
+		b = block{offset: off, data: data}
+		r.last = b
+	}
+	copy(p, b.data[off-b.offset:off-b.offset+int64(n)])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// get executes a range request and returns the data and the total size of the file
+func (r *rangeReader) get(rangeHeader string) ([]byte, int64, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get.NewRequest: %w", err)
+	}
+	req.Header.Set("Range", rangeHeader)
+	if r.authorization != "" {
+		req.Header.Set("Authorization", r.authorization)
+	}
+	r.requests++
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, service.MakeTemporary(fmt.Errorf("get[%s]: %w", rangeHeader, err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return nil, 0, fmt.Errorf("get[%s]: range requests are not supported by the server", rangeHeader)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("get[%s]: %s: %s", rangeHeader, resp.Status, body)
+		switch resp.StatusCode {
+		case 408, 429, 500, 502, 503, 504:
+			return nil, 0, service.MakeTemporary(err)
+		}
+		return nil, 0, err
+	}
+
+	size, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, 0, fmt.Errorf("get[%s].%w", rangeHeader, err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, service.MakeTemporary(fmt.Errorf("get[%s].ReadAll: %w", rangeHeader, err))
+	}
+	return data, size, nil
+}
+
+// parseContentRange returns the total size of the file from a Content-Range header ("bytes <start>-<end>/<size>")
+func parseContentRange(contentRange string) (int64, error) {
+	i := strings.LastIndex(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || i < 0 {
+		return 0, fmt.Errorf("parseContentRange: unexpected Content-Range: '%s'", contentRange)
+	}
+	size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parseContentRange[%s]: %w", contentRange, err)
+	}
+	return size, nil
+}
@@ -2,7 +2,11 @@ package db
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/airbusgeo/geocube-ingester/common"
 )
@@ -10,6 +14,7 @@ import (
 type AOI struct {
 	ID     string        `json:"ID"`
 	Status common.Status `json:"status"`
+	Parent string        `json:"parent,omitempty"` // Parent AOI, if the AOI is a child of a split AOI
 }
 
 type Scene struct {
@@ -25,9 +30,188 @@ type Tile struct {
 	Message        string        `json:"message"`
 	PreviousID     *int
 	ReferenceID    *int
+	Inputs         []TileInput // Additional inputs, sorted by TileIndex
 	RetryCountDown int
 }
 
+// TileInput is an additional input of a tile
+type TileInput struct {
+	Role      string
+	TileIndex int  // Index of the input in the list of tiles given to the graph (see common.TileIndexInputs)
+	ID        *int // Nil if the input tile failed
+}
+
+// InputIDs returns the ids of all the inputs of the tile (previous, reference and additional inputs)
+func (t Tile) InputIDs() []int {
+	var ids []int
+	for _, id := range []*int{t.PreviousID, t.ReferenceID} {
+		if id != nil {
+			ids = append(ids, *id)
+		}
+	}
+	for _, input := range t.Inputs {
+		if input.ID != nil {
+			ids = append(ids, *input.ID)
+		}
+	}
+	return ids
+}
+
+// TileOutput is an output layer of a tile, with the tile and scene it belongs to
+type TileOutput struct {
+	common.TileOutput
+	TileSourceID  string    `json:"tile_source_id"`
+	SceneSourceID string    `json:"scene_source_id"`
+	Date          time.Time `json:"date"`
+}
+
+// Consolidation is the configuration and the state of the consolidation of an AOI, started when the AOI is DONE
+type Consolidation struct {
+	Layouts []string           `json:"layouts"`           // Layouts to consolidate the instances of the AOI in
+	Pending bool               `json:"pending,omitempty"` // The AOI is DONE and the jobs have not been started yet
+	Jobs    []ConsolidationJob `json:"jobs,omitempty"`    // Jobs of the last consolidation
+}
+
+// ConsolidationJob is a consolidation job of an instance in a layout
+type ConsolidationJob struct {
+	Layout     string `json:"layout"`
+	InstanceID string `json:"instance_id"`
+	JobID      string `json:"job_id"`
+	Status     string `json:"status"`
+	Terminated bool   `json:"terminated"`
+	Message    string `json:"message,omitempty"`
+}
+
+// Value implements the driver.Value interface
+func (c Consolidation) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface.
+func (c *Consolidation) Scan(value interface{}) error {
+	if value == nil {
+		*c = Consolidation{}
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &c)
+}
+
+// GraphStats are the statistics of the processings of the scenes or the tiles with a graph (to estimate the ingestion of an area)
+type GraphStats struct {
+	Type     string           `json:"type"` // common.ResultTypeScene or common.ResultTypeTile
+	Graph    string           `json:"graph"`
+	Count    int              `json:"count"`            // Number of processings whose duration is known
+	Duration float64          `json:"duration"`         // Mean duration of a processing (seconds)
+	Layers   map[string]int64 `json:"layers,omitempty"` // Mean size of the outputs of a tile per layer (bytes, tiles only)
+}
+
+// Subscription is a standing area to ingest: the catalogue is polled periodically to ingest the new scenes of the area
+type Subscription struct {
+	ID              string          `json:"id"`
+	Area            json.RawMessage `json:"area"`             // Area to ingest (catalog.AreaToIngest). Its end_time is ignored
+	PollingInterval string          `json:"polling_interval"` // Duration between two runs (e.g. 24h)
+	// Duration before the end of the previous window, to catch the scenes published late in the catalogue (e.g. 72h, optional)
+	Lookback  string          `json:"lookback,omitempty"`
+	Enabled   bool            `json:"enabled"`
+	NextRun   time.Time       `json:"next_run"`
+	WindowEnd time.Time       `json:"window_end"` // End of the window of the last successful run (zero: the window starts at the start_time of the area)
+	LastRun   SubscriptionRun `json:"last_run"`
+}
+
+// SubscriptionRun is the status of a run of a subscription
+type SubscriptionRun struct {
+	Start    time.Time `json:"start"` // Start of the window
+	End      time.Time `json:"end"`   // End of the window
+	RunAt    time.Time `json:"run_at"`
+	Status   string    `json:"status"` // SubscriptionRunDone or SubscriptionRunFailed
+	Message  string    `json:"message,omitempty"`
+	ScenesNb int       `json:"scenes_nb"` // Number of scenes ingested
+	TilesNb  int       `json:"tiles_nb"`  // Number of tiles ingested
+}
+
+// Status of the run of a subscription
+const (
+	SubscriptionRunDone   = "DONE"
+	SubscriptionRunFailed = "FAILED"
+)
+
+// Value implements the driver.Value interface
+func (r SubscriptionRun) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan implements the sql.Scanner interface.
+func (r *SubscriptionRun) Scan(value interface{}) error {
+	if value == nil {
+		*r = SubscriptionRun{}
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &r)
+}
+
+// CatalogJob is a job of the catalog running in background (ingestion of an area)
+type CatalogJob struct {
+	ID        string             `json:"id"`
+	AOI       string             `json:"aoi"`
+	Status    string             `json:"status"` // CatalogJobPending, CatalogJobRunning, CatalogJobDone, CatalogJobFailed or CatalogJobCancelled
+	Message   string             `json:"message,omitempty"`
+	Progress  CatalogJobProgress `json:"progress"`
+	Input     json.RawMessage    `json:"-"`                // Inputs of the job, to run it again after a restart
+	Result    json.RawMessage    `json:"result,omitempty"` // Output of the job (catalog.IngestAreaResult)
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"` // Updated periodically while the job is running
+}
+
+// CatalogJobProgress is the progress of a catalog job
+type CatalogJobProgress struct {
+	Step           string `json:"step"`
+	ScenesNb       int    `json:"scenes_nb"`        // Number of scenes found
+	AnnotationsNb  int    `json:"annotations_nb"`   // Number of annotation files read
+	CachedScenesNb int    `json:"cached_scenes_nb"` // Number of scenes whose bursts are loaded from the cache
+	RecordsNb      int    `json:"records_nb"`       // Number of records created
+	TilesNb        int    `json:"tiles_nb"`         // Number of tiles found
+}
+
+// Status of a catalog job
+const (
+	CatalogJobPending   = "PENDING"
+	CatalogJobRunning   = "RUNNING"
+	CatalogJobDone      = "DONE"
+	CatalogJobFailed    = "FAILED"
+	CatalogJobCancelled = "CANCELLED"
+)
+
+// Finished returns true if the job is done, failed or cancelled
+func (j CatalogJob) Finished() bool {
+	return j.Status != CatalogJobPending && j.Status != CatalogJobRunning
+}
+
+// Value implements the driver.Value interface
+func (p CatalogJobProgress) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements the sql.Scanner interface.
+func (p *CatalogJobProgress) Scan(value interface{}) error {
+	if value == nil {
+		*p = CatalogJobProgress{}
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(b, &p)
+}
+
 type ErrAlreadyExists struct {
 	Type, ID string
 }
@@ -83,14 +267,30 @@ type WorkflowBackend interface {
 	// AOIs returns the list of the aois fitting the pattern
 	// pattern [optional=""] aoi_patern, support  and * for any number of character and ? for a character
 	AOIs(ctx context.Context, pattern string) ([]AOI, error)
+	// SetAOIParent sets the parent of the AOI (the status of a parent AOI is the aggregation of the status of its children, see UpdateAOIStatus)
+	// The status of the parent is not updated. May return ErrNotFound
+	SetAOIParent(ctx context.Context, aoi, parent string) error
+	// ChildAOIs returns the children of the AOI
+	ChildAOIs(ctx context.Context, parent string) ([]AOI, error)
+	// ParentAOI returns the parent of the AOI (empty if the AOI has no parent). May return ErrNotFound
+	ParentAOI(ctx context.Context, aoi string) (string, error)
 	// UpdateAOIStatus update the status of the AOI regarding the status of all the scenes and the tiles
+	// or, if the AOI has children, regarding the status of all its children
 	// Priority is RETRY>PENDING>NEW>DONE>FAILED
+	// The status of its parent (if any) is not updated
 	// Return new status and if it changed
 	UpdateAOIStatus(ctx context.Context, aoi string, isRetry bool) (common.Status, bool, error)
 	// Delete an AOI from the database
 	DeleteAOI(ctx context.Context, aoi string) error
+	// AOIConsolidation returns the consolidation of the AOI. May return ErrNotFound
+	AOIConsolidation(ctx context.Context, aoi string) (Consolidation, error)
+	// UpdateAOIConsolidation sets the consolidation of the AOI. May return ErrNotFound
+	UpdateAOIConsolidation(ctx context.Context, aoi string, consolidation Consolidation) error
+	// PendingConsolidations returns the AOIs whose consolidation is pending
+	// In a transaction, the AOIs are locked (and the AOIs locked by another transaction are skipped)
+	PendingConsolidations(ctx context.Context) ([]string, error)
 
-	// Returns the status of the scenes of the aoi
+	// Returns the status of the scenes of the aoi (and of its children)
 	ScenesStatus(ctx context.Context, aoi string) (Status, error)
 	// Create a new scene, returning its id
 	CreateScene(ctx context.Context, sourceID, aoi string, status common.Status, data common.SceneAttrs, retryCount int) (int, error)
@@ -106,15 +306,16 @@ type WorkflowBackend interface {
 	// Returns the id of a scene. May return ErrNotFound
 	SceneId(ctx context.Context, aoi, sourceID string) (int, error)
 
-	// Returns the status of the tiles of the aoi
+	// Returns the status of the tiles of the aoi (and of its children)
 	TilesStatus(ctx context.Context, aoi string) (Status, error)
 	// Create a new tile, returning its id
-	// prevTileSource == "" || refTileSource == "" => root tile
-	CreateTile(ctx context.Context, sourceID string, sceneID int, data common.TileAttrs, aoi, prevTileSource, prevSceneSource, refTileSource, refSceneSource string, retryCount int) (int, error)
-	// Get tile with the given id and status of the scene. May return ErrNotFound
+	// tile.PreviousTileID == "" && tile.ReferenceTileID == "" => root tile
+	// The inputs are linked to the tiles of the aoi, that must exist and must not be FAILED
+	CreateTile(ctx context.Context, sourceID string, sceneID int, aoi string, tile common.TileToIngest, retryCount int) (int, error)
+	// Get tile with the given id (and its inputs) and status of the scene. May return ErrNotFound
 	// If loadScene, the scene is also loaded
 	Tile(ctx context.Context, id int, loadScene bool) (Tile, common.Status, error)
-	// Tiles returns the list of tiles fitting the given parameters
+	// Tiles returns the list of tiles (and their inputs) fitting the given parameters
 	// aoi [optional=""] can be a pattern, supporting ? and *
 	// sceneID [optional=0] sceneID
 	// status [optional=""] status of the tile
@@ -128,22 +329,64 @@ type WorkflowBackend interface {
 	UpdateTile(ctx context.Context, id int, status common.Status, message *string, resetPrev bool) error
 	// Set status of given tiles, decrease retry_countdown if status=PENDING
 	SetTilesStatus(ctx context.Context, ids []int, status common.Status) error
-	// Update status of tiles given previous tile ID, current status and scene status, decrease retry_countdown if status=PENDING
-	// Returns updated tiles and their scenesID
-	UpdateNextTilesStatus(ctx context.Context, prevID int, status, sceneStatus, newStatus common.Status) ([]Tile, []int, error)
-	// Update status of tiles given scene ID, current status and status of previous tile, decrease retry_countdown if status=PENDING
-	// Returns updated tiles, previous tiles and previous scenes ID
-	UpdateSceneTilesStatus(ctx context.Context, sceneID int, status, prevStatus, newStatus common.Status) ([]Tile, []Tile, []int, error)
-	// Same as UpdateSceneTilesStatus, but only updates root tiles
-	UpdateSceneRootTilesStatus(ctx context.Context, sceneID int, status, newStatus common.Status) ([]Tile, error)
+	// Update status of tiles having inputID as input, given their current status, the status of their scene and the status of all their inputs,
+	// decrease retry_countdown if status=PENDING
+	// Returns the id of the updated tiles
+	UpdateNextTilesStatus(ctx context.Context, inputID int, status, sceneStatus, inputsStatus, newStatus common.Status) ([]int, error)
+	// Update status of tiles given scene ID, current status and status of all their inputs (root tiles included), decrease retry_countdown if status=PENDING
+	// Returns the id of the updated tiles
+	UpdateSceneTilesStatus(ctx context.Context, sceneID int, status, inputsStatus, newStatus common.Status) ([]int, error)
 	// Update next tile, setting newPrevID
 	// Returns list of modified tiles
 	UpdateNextTilesPrevId(ctx context.Context, oldPrevID int, newPrevID *int) ([]int, error)
-	// Update ref tile, setting newRefID
+	// Update ref tile, setting newRefID (newRefID becomes a root tile)
+	// Returns list of modified tiles
+	UpdateRefTiles(ctx context.Context, oldRefID int, newRefID *int) ([]int, error)
+	// Remove the tile from the additional inputs of the other tiles (previous and reference are not concerned)
 	// Returns list of modified tiles
-	UpdateRefTiles(ctx context.Context, oldRefID int, newRefID int) error
+	RemoveTilesInput(ctx context.Context, inputID int) ([]int, error)
 	// Update tile data
 	UpdateTileAttrs(ctx context.Context, id int, data common.TileAttrs) error
+
+	// Save the outputs of tiles: to_create and to_index outputs are added (or replaced), to_delete outputs are removed
+	SaveTileOutputs(ctx context.Context, outputs []common.TileOutput) error
+	// TileOutputs returns the outputs of the tile
+	TileOutputs(ctx context.Context, tileID int) ([]common.TileOutput, error)
+	// AOIOutputs returns the outputs of all the tiles of the aoi (AOI can be a pattern, supporting ? and *)
+	// layer [optional=""] filters the outputs of a given layer
+	AOIOutputs(ctx context.Context, aoi, layer string, page, limit int) ([]TileOutput, error)
+
+	// AddProcessingDuration adds the duration (seconds) of the processing of a scene or a tile (resultType) to the statistics of its graph
+	AddProcessingDuration(ctx context.Context, resultType string, id int, duration float64) error
+	// GraphsStats returns the statistics of the processings of the scenes and the tiles per graph
+	GraphsStats(ctx context.Context) ([]GraphStats, error)
+
+	// CreateSubscription creates a subscription, may return ErrAlreadyExists
+	CreateSubscription(ctx context.Context, subscription Subscription) error
+	// Subscription returns the subscription with the given id, may return ErrNotFound
+	Subscription(ctx context.Context, id string) (Subscription, error)
+	// Subscriptions returns all the subscriptions
+	Subscriptions(ctx context.Context) ([]Subscription, error)
+	// DueSubscriptions returns the enabled subscriptions whose next run is before the date
+	// In a transaction, the subscriptions are locked (and the ones locked by another transaction are skipped)
+	DueSubscriptions(ctx context.Context, date time.Time) ([]Subscription, error)
+	// UpdateSubscription updates the subscription, may return ErrNotFound
+	UpdateSubscription(ctx context.Context, subscription Subscription) error
+	// DeleteSubscription deletes the subscription, may return ErrNotFound
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// CreateCatalogJob creates a catalog job, may return ErrAlreadyExists
+	CreateCatalogJob(ctx context.Context, job CatalogJob) error
+	// CatalogJob returns the catalog job with the given id (including its input), may return ErrNotFound
+	CatalogJob(ctx context.Context, id string) (CatalogJob, error)
+	// CatalogJobs returns the catalog jobs (without their input) of an aoi [optional=""], from the most recent
+	CatalogJobs(ctx context.Context, aoi string) ([]CatalogJob, error)
+	// UpdateCatalogJob updates the status, the message, the progress, the result and the update date of a job that is not finished
+	// May return ErrNotFound if the job does not exist or is finished
+	UpdateCatalogJob(ctx context.Context, job CatalogJob) error
+	// ClaimCatalogJobs sets the update date of the jobs that are not finished and have not been updated since the given date
+	// and returns them (including their input)
+	ClaimCatalogJobs(ctx context.Context, notUpdatedSince, now time.Time) ([]CatalogJob, error)
 }
 
 // UnitOfWork runs a function and commit the database at the end or rollback if the function returns an error
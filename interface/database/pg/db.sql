@@ -8,8 +8,12 @@ SET default_tablespace = '';
 CREATE TABLE public.aoi (
     id text NOT NULL,
     status text NOT NULL DEFAULT 'NEW',
-    UNIQUE (id)
+    consolidation jsonb,
+    parent_id text,
+    UNIQUE (id),
+    FOREIGN KEY (parent_id) REFERENCES public.aoi(id) ON DELETE SET NULL
 );
+CREATE INDEX idx_aoi_parent ON public.aoi (parent_id);
 
 CREATE TABLE public.scene (
     id integer NOT NULL,
@@ -39,20 +43,14 @@ CREATE TABLE public.tile (
     scene_id integer NOT NULL,
     status text NOT NULL,
     message text NOT NULL DEFAULT '',
-    prev integer,
-    ref integer,
     source_id text NOT NULL,
     data jsonb,
     retry_countdown int NOT NULL DEFAULT 0,
     PRIMARY KEY (id),
     UNIQUE (source_id, scene_id),
-    FOREIGN KEY (prev) REFERENCES public.tile(id),
-    FOREIGN KEY (ref) REFERENCES public.tile(id),
     FOREIGN KEY (scene_id) REFERENCES public.scene(id) ON DELETE CASCADE
 );
 CREATE INDEX idx_tile_scene ON public.tile (scene_id);
-CREATE INDEX idx_tile_prev ON public.tile (prev);
-CREATE INDEX idx_tile_ref ON public.tile (ref);
 
 CREATE SEQUENCE public.tile_nid_seq
     AS integer
@@ -64,6 +62,69 @@ CREATE SEQUENCE public.tile_nid_seq
 ALTER SEQUENCE public.tile_nid_seq OWNED BY public.tile.id;
 ALTER TABLE ONLY public.tile ALTER COLUMN id SET DEFAULT nextval('public.tile_nid_seq'::regclass);
 
+-- Inputs of a tile (previous, reference and additional inputs)
+-- input_id is NULL if the input tile has failed
+CREATE TABLE public.tile_input (
+    tile_id integer NOT NULL,
+    role text NOT NULL,
+    tile_index integer NOT NULL,
+    input_id integer,
+    PRIMARY KEY (tile_id, role),
+    FOREIGN KEY (tile_id) REFERENCES public.tile(id) ON DELETE CASCADE,
+    FOREIGN KEY (input_id) REFERENCES public.tile(id)
+);
+CREATE INDEX idx_tile_input_input ON public.tile_input (input_id);
+
+CREATE TABLE public.tile_output (
+    tile_id integer NOT NULL,
+    layer text NOT NULL,
+    extension text NOT NULL,
+    action text NOT NULL,
+    uri text NOT NULL,
+    instance_id text NOT NULL DEFAULT '',
+    dformat jsonb,
+    size bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (tile_id, layer, extension),
+    FOREIGN KEY (tile_id) REFERENCES public.tile(id) ON DELETE CASCADE
+);
+
+-- Statistics of the durations of the processings per graph (to estimate the ingestion of an area)
+CREATE TABLE public.processing_stats (
+    type text NOT NULL,
+    graph text NOT NULL,
+    count integer NOT NULL DEFAULT 0,
+    duration double precision NOT NULL DEFAULT 0,
+    PRIMARY KEY (type, graph)
+);
+
+-- Standing areas to ingest, whose catalogue is polled periodically
+CREATE TABLE public.subscription (
+    id text NOT NULL,
+    area jsonb NOT NULL,
+    polling_interval text NOT NULL,
+    lookback text NOT NULL DEFAULT '',
+    enabled boolean NOT NULL DEFAULT true,
+    next_run timestamp with time zone NOT NULL,
+    window_end timestamp with time zone,
+    last_run jsonb,
+    PRIMARY KEY (id)
+);
+
+-- Jobs of the catalog running in background
+CREATE TABLE public.catalog_job (
+    id text NOT NULL,
+    aoi text NOT NULL,
+    status text NOT NULL,
+    message text NOT NULL DEFAULT '',
+    progress jsonb,
+    input jsonb,
+    result jsonb,
+    created_at timestamp with time zone NOT NULL,
+    updated_at timestamp with time zone NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_catalog_job_aoi ON public.catalog_job (aoi);
+
 
 --GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.scene TO ingester;
 --GRANT SELECT,UPDATE ON SEQUENCE public.scene_nid_seq TO ingester;
@@ -71,5 +132,15 @@ ALTER TABLE ONLY public.tile ALTER COLUMN id SET DEFAULT nextval('public.tile_ni
 --GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.tile TO ingester;
 --GRANT SELECT,UPDATE ON SEQUENCE public.tile_nid_seq TO ingester;
 
+--GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.tile_input TO ingester;
+
+--GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.tile_output TO ingester;
+
+--GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.processing_stats TO ingester;
+
+--GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.subscription TO ingester;
+
+--GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.catalog_job TO ingester;
+
 --ALTER TABLE public.aoi OWNER TO postgres;
 --GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.aoi TO ingester;
@@ -3,9 +3,11 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/airbusgeo/geocube-ingester/common"
 	db "github.com/airbusgeo/geocube-ingester/interface/database"
@@ -93,20 +95,33 @@ func (b Backend) AOIs(ctx context.Context, aoi string) ([]db.AOI, error) {
 		err  error
 	)
 	if aoi == "" {
-		rows, err = b.QueryContext(ctx, "select id, status from aoi ORDER BY id")
+		rows, err = b.QueryContext(ctx, "select id, status, coalesce(parent_id, '') from aoi ORDER BY id")
 	} else {
 		aoi = strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(aoi, "_", "\\_"), "%", "\\%"), "*", "%"), "?", "_")
-		rows, err = b.QueryContext(ctx, "select id, status from aoi where id LIKE $1 ORDER BY id", aoi)
+		rows, err = b.QueryContext(ctx, "select id, status, coalesce(parent_id, '') from aoi where id LIKE $1 ORDER BY id", aoi)
 	}
 
 	if err != nil {
 		return nil, fmt.Errorf("aois.QueryContext: %w", err)
 	}
+	return scanAOIs(rows)
+}
+
+// ChildAOIs implements WorkflowBackend
+func (b Backend) ChildAOIs(ctx context.Context, parent string) ([]db.AOI, error) {
+	rows, err := b.QueryContext(ctx, "select id, status, parent_id from aoi where parent_id = $1 ORDER BY id", parent)
+	if err != nil {
+		return nil, fmt.Errorf("ChildAOIs.QueryContext: %w", err)
+	}
+	return scanAOIs(rows)
+}
+
+func scanAOIs(rows *sql.Rows) ([]db.AOI, error) {
 	defer rows.Close()
 	aois := make([]db.AOI, 0)
 	for rows.Next() {
 		var aoi db.AOI
-		if err := rows.Scan(&aoi.ID, &aoi.Status); err != nil {
+		if err := rows.Scan(&aoi.ID, &aoi.Status, &aoi.Parent); err != nil {
 			return nil, fmt.Errorf("aois.Scan: %w", err)
 		}
 		aois = append(aois, aoi)
@@ -117,6 +132,34 @@ func (b Backend) AOIs(ctx context.Context, aoi string) ([]db.AOI, error) {
 	return aois, nil
 }
 
+// SetAOIParent implements WorkflowBackend
+func (b Backend) SetAOIParent(ctx context.Context, aoi, parent string) error {
+	res, err := b.ExecContext(ctx, "update aoi set parent_id=$1 where id = $2", parent, aoi)
+	switch pqErrorCode(err) {
+	case noError:
+	case foreignKeyViolation:
+		return db.ErrNotFound{Type: "aoi", ID: parent}
+	default:
+		return fmt.Errorf("SetAOIParent.exec: %w", err)
+	}
+	if nb, _ := res.RowsAffected(); nb == 0 {
+		return db.ErrNotFound{Type: "aoi", ID: aoi}
+	}
+	return nil
+}
+
+// ParentAOI implements WorkflowBackend
+func (b Backend) ParentAOI(ctx context.Context, aoi string) (string, error) {
+	var parent sql.NullString
+	if err := b.QueryRowContext(ctx, "select parent_id from aoi where id = $1", aoi).Scan(&parent); err != nil {
+		if err == sql.ErrNoRows {
+			return "", db.ErrNotFound{Type: "aoi", ID: aoi}
+		}
+		return "", fmt.Errorf("ParentAOI.QueryRowContext: %w", err)
+	}
+	return parent.String, nil
+}
+
 // CreateAOI implements WorkflowBackend
 func (b Backend) CreateAOI(ctx context.Context, aoi string) error {
 	_, err := b.ExecContext(ctx, "insert into aoi(id) values($1)", aoi)
@@ -151,15 +194,33 @@ func extractStatus(status service.StringSet) common.Status {
 }
 
 func (b Backend) findAOIStatus(ctx context.Context, aoi string) (common.Status, error) {
+	// The status of a parent AOI is the aggregation of the status of its children
+	rows, err := b.QueryContext(ctx, "select status from aoi where parent_id = $1 GROUP BY status", aoi)
+	if err != nil {
+		return common.StatusNEW, fmt.Errorf("findChildrenStatus.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	status := service.StringSet{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return common.StatusNEW, fmt.Errorf("findChildrenStatus.Scan: %w", err)
+		}
+		status.Push(s)
+	}
+	if err := rows.Err(); err != nil {
+		return common.StatusNEW, fmt.Errorf("findChildrenStatus.rows.err: %w", err)
+	}
+	if len(status) > 0 {
+		return extractStatus(status), nil
+	}
 
 	// Get status of scenes
-	rows, err := b.QueryContext(ctx, "select status from scene where aoi_id = $1 GROUP BY status", aoi)
-
-	if err != nil {
+	if rows, err = b.QueryContext(ctx, "select status from scene where aoi_id = $1 GROUP BY status", aoi); err != nil {
 		return common.StatusNEW, fmt.Errorf("findScenesStatus.QueryContext: %w", err)
 	}
 	defer rows.Close()
-	status := service.StringSet{}
+	status = service.StringSet{}
 	for rows.Next() {
 		var s string
 		if err := rows.Scan(&s); err != nil {
@@ -224,10 +285,59 @@ func (b Backend) DeleteAOI(ctx context.Context, aoi string) error {
 	return nil
 }
 
+// AOIConsolidation implements WorkflowBackend
+func (b Backend) AOIConsolidation(ctx context.Context, aoi string) (db.Consolidation, error) {
+	var consolidation db.Consolidation
+	if err := b.QueryRowContext(ctx, "select consolidation from aoi where id = $1", aoi).Scan(&consolidation); err != nil {
+		if err == sql.ErrNoRows {
+			return consolidation, db.ErrNotFound{Type: "aoi", ID: aoi}
+		}
+		return consolidation, fmt.Errorf("AOIConsolidation.QueryRowContext: %w", err)
+	}
+	return consolidation, nil
+}
+
+// UpdateAOIConsolidation implements WorkflowBackend
+func (b Backend) UpdateAOIConsolidation(ctx context.Context, aoi string, consolidation db.Consolidation) error {
+	res, err := b.ExecContext(ctx, "update aoi set consolidation=$1 where id = $2", consolidation, aoi)
+	if err != nil {
+		return fmt.Errorf("UpdateAOIConsolidation.exec: %w", err)
+	}
+	if nb, _ := res.RowsAffected(); nb == 0 {
+		return db.ErrNotFound{Type: "aoi", ID: aoi}
+	}
+	return nil
+}
+
+// PendingConsolidations implements WorkflowBackend
+func (b Backend) PendingConsolidations(ctx context.Context) ([]string, error) {
+	query := "select id from aoi where (consolidation->>'pending')::boolean ORDER BY id"
+	if _, ok := b.pgInterface.(*sql.Tx); ok {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+	rows, err := b.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("PendingConsolidations.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	var aois []string
+	for rows.Next() {
+		var aoi string
+		if err := rows.Scan(&aoi); err != nil {
+			return nil, fmt.Errorf("PendingConsolidations.Scan: %w", err)
+		}
+		aois = append(aois, aoi)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("PendingConsolidations.Rows: %w", err)
+	}
+	return aois, nil
+}
+
 // ScenesStatus implements WorkflowBackend
 func (b Backend) ScenesStatus(ctx context.Context, aoi string) (db.Status, error) {
 	s := db.Status{}
-	rows, err := b.QueryContext(ctx, "select status, count(status) from scene where aoi_id=$1 group by status", aoi)
+	rows, err := b.QueryContext(ctx, "select status, count(status) from scene where aoi_id IN (select id from aoi where id=$1 or parent_id=$1) group by status", aoi)
 	if err != nil {
 		return s, fmt.Errorf("ScenesStatus.QueryContext: %w", err)
 	}
@@ -365,7 +475,7 @@ func (b Backend) SceneId(ctx context.Context, aoi, sourceID string) (int, error)
 // TilesStatus implements WorkflowBackend
 func (b Backend) TilesStatus(ctx context.Context, aoi string) (db.Status, error) {
 	s := db.Status{}
-	rows, err := b.QueryContext(ctx, "select tile.status, count(tile.status) from tile join scene on tile.scene_id = scene.id where scene.aoi_id=$1 group by tile.status", aoi)
+	rows, err := b.QueryContext(ctx, "select tile.status, count(tile.status) from tile join scene on tile.scene_id = scene.id where scene.aoi_id IN (select id from aoi where id=$1 or parent_id=$1) group by tile.status", aoi)
 	if err != nil {
 		return s, fmt.Errorf("TilesStatus.QueryContext: %w", err)
 	}
@@ -385,23 +495,50 @@ func (b Backend) TilesStatus(ctx context.Context, aoi string) (db.Status, error)
 }
 
 // CreateTile implements WorkflowBackend
-func (b Backend) CreateTile(ctx context.Context, sourceID string, sceneID int, data common.TileAttrs, aoi, prevTileSource, prevSceneSource, refTileSource, refSceneSource string, retryCount int) (int, error) {
+func (b Backend) CreateTile(ctx context.Context, sourceID string, sceneID int, aoi string, tile common.TileToIngest, retryCount int) (int, error) {
 	bid := 0
-	if prevTileSource != "" && refTileSource != "" {
-		if err := b.QueryRowContext(ctx, `insert into tile(source_id,scene_id,status,data,retry_countdown,prev,ref)
-		select $1,$2,$3,$4,$5,tprev.id,tref.id from tile tprev, scene sprev, tile tref, scene sref
-		where sprev.aoi_id=$6 and tprev.source_id=$7 and sprev.source_id=$8 and tprev.scene_id=sprev.id and tprev.status != $11
-		and   sref.aoi_id =$6 and tref.source_id =$9 and sref.source_id =$10 and tref.scene_id=sref.id  and tref.status  != $11
-		RETURNING tile.id`,
-			sourceID, sceneID, common.StatusNEW, data, retryCount, aoi, prevTileSource, prevSceneSource, refTileSource, refSceneSource, common.StatusFAILED).Scan(&bid); err != nil {
-			return 0, fmt.Errorf("CreateTile: insert tile %s (parent %s, ref %s) (hint: check parent tile is not FAILED): %w", sourceID, prevSceneSource, refSceneSource, err)
-		}
-	} else if err := b.QueryRowContext(ctx, "insert into tile(source_id,scene_id,status,data,retry_countdown) values($1,$2,$3,$4,$5) RETURNING tile.id", sourceID, sceneID, common.StatusNEW, data, retryCount).Scan(&bid); err != nil {
-		return 0, fmt.Errorf("CreateTile: insert root tile %s: %w", sourceID, err)
+	if err := b.QueryRowContext(ctx, "insert into tile(source_id,scene_id,status,data,retry_countdown) values($1,$2,$3,$4,$5) RETURNING tile.id", sourceID, sceneID, common.StatusNEW, tile.Data, retryCount).Scan(&bid); err != nil {
+		return 0, fmt.Errorf("CreateTile: insert tile %s: %w", sourceID, err)
+	}
+	if tile.PreviousTileID != "" {
+		if err := b.createTileInput(ctx, bid, aoi, common.RolePrevious, common.TileIndexPrevious, tile.PreviousTileID, tile.PreviousSceneID); err != nil {
+			return 0, fmt.Errorf("CreateTile[%s].%w", sourceID, err)
+		}
+	}
+	if tile.ReferenceTileID != "" {
+		if err := b.createTileInput(ctx, bid, aoi, common.RoleReference, common.TileIndexReference, tile.ReferenceTileID, tile.ReferenceSceneID); err != nil {
+			return 0, fmt.Errorf("CreateTile[%s].%w", sourceID, err)
+		}
+	}
+	for i, input := range tile.Inputs {
+		if err := b.createTileInput(ctx, bid, aoi, input.Role, common.TileIndexInputs+i, input.TileID, input.SceneID); err != nil {
+			return 0, fmt.Errorf("CreateTile[%s].%w", sourceID, err)
+		}
 	}
 	return bid, nil
 }
 
+// createTileInput links the tile to the input tile identified by its source id and the source id of its scene
+func (b Backend) createTileInput(ctx context.Context, tileID int, aoi, role string, tileIndex int, inputTileSource, inputSceneSource string) error {
+	res, err := b.ExecContext(ctx, `insert into tile_input(tile_id,role,tile_index,input_id)
+		select $1,$2,$3,t.id from tile t join scene s on t.scene_id = s.id
+		where s.aoi_id=$4 and t.source_id=$5 and s.source_id=$6 and t.status != $7`,
+		tileID, role, tileIndex, aoi, inputTileSource, inputSceneSource, common.StatusFAILED)
+	if err != nil {
+		return fmt.Errorf("createTileInput[%s]: %w", role, err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("createTileInput[%s].RowsAffected: %w", role, err)
+	} else if n == 0 {
+		return fmt.Errorf("createTileInput[%s]: input %s/%s not found (hint: check input tile is not FAILED)", role, inputSceneSource, inputTileSource)
+	}
+	return nil
+}
+
+// tileInputsColumns selects the previous and reference tiles of the tile t
+const tileInputsColumns = `(select input_id from tile_input where tile_id=t.id and role='` + common.RolePrevious + `'),
+	(select input_id from tile_input where tile_id=t.id and role='` + common.RoleReference + `')`
+
 // Tile implements WorkflowBackend
 func (b Backend) Tile(ctx context.Context, tile int, loadScene bool) (db.Tile, common.Status, error) {
 	ti := db.Tile{}
@@ -410,10 +547,10 @@ func (b Backend) Tile(ctx context.Context, tile int, loadScene bool) (db.Tile, c
 	var sceneStatus common.Status
 	if loadScene {
 		err = b.QueryRowContext(ctx,
-			"select t.source_id,t.scene_id,t.prev,t.ref,t.status,t.message,t.data,t.retry_countdown, s.source_id,s.aoi_id,s.status,s.data from tile t, scene s where t.id=$1 and t.scene_id = s.id", tile).Scan(
+			"select t.source_id,t.scene_id,"+tileInputsColumns+",t.status,t.message,t.data,t.retry_countdown, s.source_id,s.aoi_id,s.status,s.data from tile t, scene s where t.id=$1 and t.scene_id = s.id", tile).Scan(
 			&ti.SourceID, &ti.Scene.ID, &ti.PreviousID, &ti.ReferenceID, &ti.Status, &ti.Message, &ti.Data, &ti.RetryCountDown, &ti.Scene.SourceID, &ti.Scene.AOI, &sceneStatus, &ti.Scene.Data)
 	} else {
-		err = b.QueryRowContext(ctx, "select source_id,scene_id,prev,ref,status,message,data,retry_countdown from tile where tile.id=$1", tile).Scan(
+		err = b.QueryRowContext(ctx, "select t.source_id,t.scene_id,"+tileInputsColumns+",t.status,t.message,t.data,t.retry_countdown from tile t where t.id=$1", tile).Scan(
 			&ti.SourceID, &ti.Scene.ID, &ti.PreviousID, &ti.ReferenceID, &ti.Status, &ti.Message, &ti.Data, &ti.RetryCountDown)
 	}
 	if err != nil {
@@ -422,13 +559,41 @@ func (b Backend) Tile(ctx context.Context, tile int, loadScene bool) (db.Tile, c
 		}
 		return ti, sceneStatus, fmt.Errorf("Tile.Scan: %w", err)
 	}
+	inputs, err := b.tilesInputs(ctx, []int{tile})
+	if err != nil {
+		return ti, sceneStatus, fmt.Errorf("Tile.%w", err)
+	}
+	ti.Inputs = inputs[tile]
 	return ti, sceneStatus, nil
 }
 
+// tilesInputs returns the additional inputs of the given tiles, sorted by tile_index
+func (b Backend) tilesInputs(ctx context.Context, ids []int) (map[int][]db.TileInput, error) {
+	inputs := map[int][]db.TileInput{}
+	rows, err := b.QueryContext(ctx, "select tile_id, role, tile_index, input_id from tile_input where tile_id=ANY($1) and tile_index >= $2 order by tile_id, tile_index",
+		pq.Array(ids), common.TileIndexInputs)
+	if err != nil {
+		return nil, fmt.Errorf("tilesInputs.QueryContext: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tileID int
+		input := db.TileInput{}
+		if err := rows.Scan(&tileID, &input.Role, &input.TileIndex, &input.ID); err != nil {
+			return nil, fmt.Errorf("tilesInputs.Scan: %w", err)
+		}
+		inputs[tileID] = append(inputs[tileID], input)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("tilesInputs.Rows.err: %w", err)
+	}
+	return inputs, nil
+}
+
 // Tiles implements WorkflowBackend
 func (b Backend) Tiles(ctx context.Context, aoi string, sceneID int, status string, loadScene bool, page, limit int) ([]db.Tile, error) {
 	// Construct the query
-	query := "select t.id, t.source_id, t.scene_id, t.prev, t.ref, t.status, t.message, t.data, t.retry_countdown"
+	query := "select t.id, t.source_id, t.scene_id, " + tileInputsColumns + ", t.status, t.message, t.data, t.retry_countdown"
 
 	if loadScene {
 		query += ", s.source_id, s.aoi_id, s.data"
@@ -480,6 +645,19 @@ func (b Backend) Tiles(ctx context.Context, aoi string, sceneID int, status stri
 			return nil, fmt.Errorf("Tiles.Rows.err: %w", err)
 		}
 	}
+
+	// Load additional inputs
+	ids := make([]int, len(tiles))
+	for i, tile := range tiles {
+		ids[i] = tile.ID
+	}
+	inputs, err := b.tilesInputs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("Tiles.%w", err)
+	}
+	for i := range tiles {
+		tiles[i].Inputs = inputs[tiles[i].ID]
+	}
 	return tiles, nil
 }
 
@@ -489,7 +667,9 @@ func (b Backend) RootTiles(ctx context.Context, aoi string) ([]common.Tile, erro
 	rows, err := b.QueryContext(ctx,
 		`select t.id, t.source_id, t.data, s.id, s.source_id, s.data
 			from tile t join scene s on t.scene_id = s.id
-			where t.status != $1 AND t.ref IS NULL AND t.prev IS NULL AND s.aoi_id=$2`, common.StatusFAILED, aoi)
+			where t.status != $1 AND s.aoi_id=$2
+			AND NOT EXISTS (SELECT NULL FROM tile_input i WHERE i.tile_id = t.id AND i.role IN ($3, $4) AND i.input_id IS NOT NULL)`,
+		common.StatusFAILED, aoi, common.RolePrevious, common.RoleReference)
 	if err != nil {
 		return nil, fmt.Errorf("RootTiles.Scan: %w", err)
 	}
@@ -514,7 +694,7 @@ func (b Backend) LeafTiles(ctx context.Context, aoi string) ([]common.Tile, erro
 	rows, err := b.QueryContext(ctx,
 		`select t.id, t.source_id, t.data, s.id, s.source_id, s.data
 			from tile t join scene s on t.scene_id = s.id
-			where t.status != $1 AND NOT EXISTS (SELECT NULL FROM tile t2 WHERE t.id = t2.prev) AND s.aoi_id=$2`, common.StatusFAILED, aoi)
+			where t.status != $1 AND NOT EXISTS (SELECT NULL FROM tile_input i WHERE i.input_id = t.id AND i.role = $3) AND s.aoi_id=$2`, common.StatusFAILED, aoi, common.RolePrevious)
 	if err != nil {
 		return nil, fmt.Errorf("scan: %w", err)
 	}
@@ -551,13 +731,15 @@ func (b Backend) UpdateTile(ctx context.Context, id int, status common.Status, m
 		parameters = append(parameters, *message)
 		query += ", message=$3"
 	}
-	if resetPrev {
-		query += ", prev=NULL"
-	}
 
 	if _, err = b.ExecContext(ctx, query+" where id=$2", parameters...); err != nil {
 		return fmt.Errorf("UpdateTile: %w", err)
 	}
+	if resetPrev {
+		if _, err = b.ExecContext(ctx, "update tile_input set input_id=NULL where tile_id=$1 and role=$2", id, common.RolePrevious); err != nil {
+			return fmt.Errorf("UpdateTile.resetPrev: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -570,145 +752,460 @@ func (b Backend) SetTilesStatus(ctx context.Context, ids []int, status common.St
 }
 
 // UpdateNextTilesStatus implements WorkflowBackend
-func (b Backend) UpdateNextTilesStatus(ctx context.Context, prevID int, status, sceneStatus, newStatus common.Status) ([]db.Tile, []int, error) {
+func (b Backend) UpdateNextTilesStatus(ctx context.Context, inputID int, status, sceneStatus, inputsStatus, newStatus common.Status) ([]int, error) {
 	rows, err := b.QueryContext(ctx, updateTileStatusQuery(status)+
 		` FROM scene where
-			tile.prev=$2 and tile.status=$3 and tile.scene_id=scene.id and scene.status=$4
-			RETURNING tile.id, tile.source_id, tile.scene_id, tile.ref, tile.data`,
-		newStatus, prevID, status, sceneStatus)
+			tile.status=$3 and tile.scene_id=scene.id and scene.status=$4
+			and EXISTS (SELECT NULL FROM tile_input i WHERE i.tile_id=tile.id and i.input_id=$2)
+			and NOT EXISTS (SELECT NULL FROM tile_input i JOIN tile it ON it.id=i.input_id WHERE i.tile_id=tile.id and it.status!=$5)
+			RETURNING tile.id`,
+		newStatus, inputID, status, sceneStatus, inputsStatus)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateNextTilesStatus.QueryContext: %w", err)
+	}
+	return scanTileIDs(rows, "UpdateNextTilesStatus")
+}
+
+// UpdateSceneTilesStatus implements WorkflowBackend
+func (b Backend) UpdateSceneTilesStatus(ctx context.Context, sceneID int, status, inputsStatus, newStatus common.Status) ([]int, error) {
+	rows, err := b.QueryContext(ctx, updateTileStatusQuery(status)+
+		` where tile.scene_id=$2 and tile.status=$3
+			and NOT EXISTS (SELECT NULL FROM tile_input i JOIN tile it ON it.id=i.input_id WHERE i.tile_id=tile.id and it.status!=$4)
+			RETURNING tile.id`,
+		newStatus, sceneID, status, inputsStatus)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateSceneTilesStatus.QueryContext: %w", err)
+	}
+	return scanTileIDs(rows, "UpdateSceneTilesStatus")
+}
+
+// UpdateNextTilesPrevId implements WorkflowBackend
+func (b Backend) UpdateNextTilesPrevId(ctx context.Context, oldPrevID int, newPrevID *int) ([]int, error) {
+	rows, err := b.QueryContext(ctx, "update tile_input set input_id=$1 where input_id=$2 and role=$3 returning tile_id",
+		newPrevID, oldPrevID, common.RolePrevious)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateNextTilesPrevId.QueryContext: %w", err)
+	}
+	return scanTileIDs(rows, "UpdateNextTilesPrevId")
+}
+
+// UpdateRefTiles implements WorkflowBackend
+func (b Backend) UpdateRefTiles(ctx context.Context, oldRefID int, newRefID *int) ([]int, error) {
+	if newRefID != nil {
+		if *newRefID == oldRefID {
+			return nil, nil
+		}
+		// Tiles that become roots
+		if _, err := b.ExecContext(ctx, "update tile_input set input_id=NULL where tile_id=$1 and input_id=$2 and role=$3", *newRefID, oldRefID, common.RoleReference); err != nil {
+			return nil, fmt.Errorf("UpdateRefTiles.QueryContext: %w", err)
+		}
+	}
+
+	// Other tiles
+	rows, err := b.QueryContext(ctx, "update tile_input set input_id=$1 where input_id=$2 and role=$3 returning tile_id", newRefID, oldRefID, common.RoleReference)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateRefTiles.QueryContext: %w", err)
+	}
+	return scanTileIDs(rows, "UpdateRefTiles")
+}
+
+// RemoveTilesInput implements WorkflowBackend
+func (b Backend) RemoveTilesInput(ctx context.Context, inputID int) ([]int, error) {
+	rows, err := b.QueryContext(ctx, "update tile_input set input_id=NULL where input_id=$1 and role NOT IN ($2, $3) returning tile_id",
+		inputID, common.RolePrevious, common.RoleReference)
 	if err != nil {
-		return nil, nil, fmt.Errorf("UpdateNextTilesStatus.QueryContext: %w", err)
+		return nil, fmt.Errorf("RemoveTilesInput.QueryContext: %w", err)
 	}
+	return scanTileIDs(rows, "RemoveTilesInput")
+}
+
+// scanTileIDs returns the list of ids returned by the query and closes the rows
+func scanTileIDs(rows *sql.Rows, fname string) ([]int, error) {
 	defer rows.Close()
 
-	var tiles []db.Tile
-	var scenesID []int
+	var bids []int
 	for rows.Next() {
-		tile := db.Tile{}
-		scID := 0
-		if err := rows.Scan(&tile.ID, &tile.SourceID, &scID, &tile.ReferenceID, &tile.Data); err != nil {
-			return nil, nil, fmt.Errorf("UpdateNextTilesStatus.Scan: %w", err)
+		bid := 0
+		if err := rows.Scan(&bid); err != nil {
+			return nil, fmt.Errorf("%s.Scan: %w", fname, err)
 		}
-		tiles = append(tiles, tile)
-		scenesID = append(scenesID, scID)
+		bids = append(bids, bid)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, nil, fmt.Errorf("UpdateNextTilesStatus.Rows.err: %w", err)
+		return nil, fmt.Errorf("%s.Rows.err: %w", fname, err)
 	}
-	return tiles, scenesID, nil
+	return bids, nil
 }
 
-// UpdateSceneTilesStatus implements WorkflowBackend
-func (b Backend) UpdateSceneTilesStatus(ctx context.Context, sceneID int, status, prevStatus, newStatus common.Status) ([]db.Tile, []db.Tile, []int, error) {
-	rows, err := b.QueryContext(ctx, updateTileStatusQuery(status)+
-		` FROM tile prev_tile where tile.scene_id=$2 and tile.status=$3
-				and tile.prev=prev_tile.id and prev_tile.status=$4
-				RETURNING tile.id, tile.source_id, tile.ref, tile.data, prev_tile.id,prev_tile.source_id,prev_tile.scene_id,prev_tile.data`,
-		newStatus, sceneID, status, prevStatus)
+// UpdateTileAttrs implements WorkflowBackend
+func (b Backend) UpdateTileAttrs(ctx context.Context, id int, data common.TileAttrs) error {
+	if _, err := b.ExecContext(ctx, "update tile set data=$1 where id=$2", data, id); err != nil {
+		return fmt.Errorf("UpdateTileAttrs: %w", err)
+	}
+	return nil
+}
+
+// SaveTileOutputs implements WorkflowBackend
+func (b Backend) SaveTileOutputs(ctx context.Context, outputs []common.TileOutput) error {
+	for _, output := range outputs {
+		if output.Action == "to_delete" {
+			if _, err := b.ExecContext(ctx, "delete from tile_output where tile_id=$1 and layer=$2 and extension=$3",
+				output.TileID, output.Layer, output.Extension); err != nil {
+				return fmt.Errorf("SaveTileOutputs.Delete: %w", err)
+			}
+			continue
+		}
+		_, err := b.ExecContext(ctx, `insert into tile_output(tile_id,layer,extension,action,uri,instance_id,dformat,size) values($1,$2,$3,$4,$5,$6,$7,$8)
+			ON CONFLICT (tile_id,layer,extension) DO UPDATE SET action=EXCLUDED.action, uri=EXCLUDED.uri, instance_id=EXCLUDED.instance_id, dformat=EXCLUDED.dformat, size=EXCLUDED.size`,
+			output.TileID, output.Layer, output.Extension, output.Action, output.URI, output.InstanceID, output.DFormat, output.Size)
+		switch pqErrorCode(err) {
+		case noError:
+		case foreignKeyViolation:
+			return db.ErrNotFound{Type: "tile", ID: fmt.Sprintf("%d", output.TileID)}
+		default:
+			return fmt.Errorf("SaveTileOutputs.Insert: %w", err)
+		}
+	}
+	return nil
+}
+
+// TileOutputs implements WorkflowBackend
+func (b Backend) TileOutputs(ctx context.Context, tileID int) ([]common.TileOutput, error) {
+	rows, err := b.QueryContext(ctx, "select tile_id, layer, extension, action, uri, instance_id, dformat, size from tile_output where tile_id=$1 ORDER BY layer, extension", tileID)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("UpdateSceneTilesStatus.QueryContext: %w", err)
+		return nil, fmt.Errorf("TileOutputs.QueryContext: %w", err)
 	}
 	defer rows.Close()
-
-	var ctiles []db.Tile
-	var ptiles []db.Tile
-	var pscenesID []int
+	outputs := []common.TileOutput{}
 	for rows.Next() {
-		ctile := db.Tile{}
-		ptile := db.Tile{}
-		pscID := 0
-		if err := rows.Scan(
-			&ctile.ID, &ctile.SourceID, &ctile.ReferenceID, &ctile.Data,
-			&ptile.ID, &ptile.SourceID, &pscID, &ptile.Data); err != nil {
-			return nil, nil, nil, fmt.Errorf("UpdateSceneTilesStatus.Scan: %w", err)
-		}
-		ctiles = append(ctiles, ctile)
-		ptiles = append(ptiles, ptile)
-		pscenesID = append(pscenesID, pscID)
+		var o common.TileOutput
+		if err := rows.Scan(&o.TileID, &o.Layer, &o.Extension, &o.Action, &o.URI, &o.InstanceID, &o.DFormat, &o.Size); err != nil {
+			return nil, fmt.Errorf("TileOutputs.Scan: %w", err)
+		}
+		outputs = append(outputs, o)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, nil, nil, fmt.Errorf("UpdateSceneTilesStatus.Rows.err: %w", err)
+		return nil, fmt.Errorf("TileOutputs.Rows.err: %w", err)
 	}
-	return ctiles, ptiles, pscenesID, nil
+	return outputs, nil
 }
 
-// UpdateSceneRootTilesStatus implements WorkflowBackend
-func (b Backend) UpdateSceneRootTilesStatus(ctx context.Context, sceneID int, status, newStatus common.Status) ([]db.Tile, error) {
-	rows, err := b.QueryContext(ctx, updateTileStatusQuery(status)+
-		` where scene_id=$2 and status=$3 and prev IS NULL
-		RETURNING tile.id, tile.source_id, tile.ref, tile.data`,
-		newStatus, sceneID, status)
+// AOIOutputs implements WorkflowBackend
+func (b Backend) AOIOutputs(ctx context.Context, aoi, layer string, page, limit int) ([]db.TileOutput, error) {
+	query := `select o.tile_id, o.layer, o.extension, o.action, o.uri, o.instance_id, o.dformat, o.size, t.source_id, s.source_id, s.data
+		from tile_output o JOIN tile t ON t.id = o.tile_id JOIN scene s ON s.id = t.scene_id`
+
+	wc := joinClause{}
+	aoi, operator := parseLike(aoi)
+	wc.append(" s.aoi_id "+operator+" $%d", aoi)
+	if layer != "" {
+		wc.append(" o.layer = $%d", layer)
+	}
+	query += wc.WhereClause()
+	query += " ORDER BY s.aoi_id, s.id, t.id, o.layer"
+	query += limitOffsetClause(page, limit)
+
+	rows, err := b.QueryContext(ctx, query, wc.Parameters...)
 	if err != nil {
-		return nil, fmt.Errorf("UpdateSceneRootTilesStatus.QueryContext: %w", err)
+		return nil, fmt.Errorf("AOIOutputs.QueryContext: %w", err)
 	}
 	defer rows.Close()
-
-	var ctiles []db.Tile
+	outputs := []db.TileOutput{}
 	for rows.Next() {
-		ctile := db.Tile{}
-		if err := rows.Scan(&ctile.ID, &ctile.SourceID, &ctile.ReferenceID, &ctile.Data); err != nil {
-			return nil, fmt.Errorf("UpdateSceneRootTilesStatus.Scan: %w", err)
+		var o db.TileOutput
+		var sceneData common.SceneAttrs
+		if err := rows.Scan(&o.TileID, &o.Layer, &o.Extension, &o.Action, &o.URI, &o.InstanceID, &o.DFormat, &o.Size, &o.TileSourceID, &o.SceneSourceID, &sceneData); err != nil {
+			return nil, fmt.Errorf("AOIOutputs.Scan: %w", err)
 		}
-		ctiles = append(ctiles, ctile)
+		o.Date = sceneData.Date
+		outputs = append(outputs, o)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("UpdateSceneRootTilesStatus.Rows.err: %w", err)
+		return nil, fmt.Errorf("AOIOutputs.Rows.err: %w", err)
 	}
-	return ctiles, nil
+	return outputs, nil
 }
 
-// UpdateNextTilesPrevId implements WorkflowBackend
-func (b Backend) UpdateNextTilesPrevId(ctx context.Context, oldPrevID int, newPrevID *int) ([]int, error) {
-	var rows *sql.Rows
-	var err error
-	if newPrevID == nil {
-		rows, err = b.QueryContext(ctx, "update tile set prev=NULL where prev=$1 returning tile.ID",
-			oldPrevID)
-	} else {
-		rows, err = b.QueryContext(ctx, "update tile set prev=$1 where prev=$2 returning tile.ID",
-			*newPrevID, oldPrevID)
+// AddProcessingDuration implements WorkflowBackend
+func (b Backend) AddProcessingDuration(ctx context.Context, resultType string, id int, duration float64) error {
+	table := "tile"
+	if resultType == common.ResultTypeScene {
+		table = "scene"
 	}
+	_, err := b.ExecContext(ctx, `insert into processing_stats(type, graph, count, duration) select $1, coalesce(data->>'graph_name', ''), 1, $3 from `+table+` where id=$2
+		ON CONFLICT (type, graph) DO UPDATE SET count=processing_stats.count+1, duration=processing_stats.duration+EXCLUDED.duration`,
+		resultType, id, duration)
 	if err != nil {
-		return nil, fmt.Errorf("UpdateNextTilesPrevId.QueryContext: %w", err)
+		return fmt.Errorf("AddProcessingDuration.exec: %w", err)
+	}
+	return nil
+}
+
+// GraphsStats implements WorkflowBackend
+func (b Backend) GraphsStats(ctx context.Context) ([]db.GraphStats, error) {
+	stats := []db.GraphStats{}
+	tileStats := map[string]int{}
+
+	// Durations
+	rows, err := b.QueryContext(ctx, "select type, graph, count, duration/count from processing_stats where count > 0 ORDER BY type, graph")
+	if err != nil {
+		return nil, fmt.Errorf("GraphsStats.QueryContext: %w", err)
 	}
 	defer rows.Close()
+	for rows.Next() {
+		var s db.GraphStats
+		if err := rows.Scan(&s.Type, &s.Graph, &s.Count, &s.Duration); err != nil {
+			return nil, fmt.Errorf("GraphsStats.Scan: %w", err)
+		}
+		if s.Type == common.ResultTypeTile {
+			tileStats[s.Graph] = len(stats)
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GraphsStats.Rows.err: %w", err)
+	}
 
-	var bids []int
+	// Mean size of the outputs of a tile per layer
+	rows, err = b.QueryContext(ctx, `select coalesce(t.data->>'graph_name', ''), o.layer, (sum(o.size)/count(distinct o.tile_id))::bigint
+		from tile_output o JOIN tile t ON t.id = o.tile_id where o.size > 0 GROUP BY 1, 2`)
+	if err != nil {
+		return nil, fmt.Errorf("GraphsStats.QueryContext: %w", err)
+	}
+	defer rows.Close()
 	for rows.Next() {
-		bid := 0
-		if err = rows.Scan(&bid); err != nil {
-			return nil, fmt.Errorf("UpdateNextTilesPrevId.Scan: %w", err)
+		var graph, layer string
+		var size int64
+		if err := rows.Scan(&graph, &layer, &size); err != nil {
+			return nil, fmt.Errorf("GraphsStats.Scan: %w", err)
 		}
-		bids = append(bids, bid)
+		i, ok := tileStats[graph]
+		if !ok {
+			i, tileStats[graph] = len(stats), len(stats)
+			stats = append(stats, db.GraphStats{Type: common.ResultTypeTile, Graph: graph})
+		}
+		if stats[i].Layers == nil {
+			stats[i].Layers = map[string]int64{}
+		}
+		stats[i].Layers[layer] = size
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("UpdateNextTilesPrevId.Rows.err: %w", err)
+		return nil, fmt.Errorf("GraphsStats.Rows.err: %w", err)
 	}
-	return bids, nil
+	return stats, nil
 }
 
-// UpdateRefTiles implements WorkflowBackend
-func (b Backend) UpdateRefTiles(ctx context.Context, oldRefID int, newRefID int) error {
-	if newRefID == oldRefID {
+// CreateSubscription implements WorkflowBackend
+func (b Backend) CreateSubscription(ctx context.Context, s db.Subscription) error {
+	_, err := b.ExecContext(ctx, "insert into subscription(id, area, polling_interval, lookback, enabled, next_run, window_end, last_run) values($1, $2, $3, $4, $5, $6, $7, $8)",
+		s.ID, []byte(s.Area), s.PollingInterval, s.Lookback, s.Enabled, s.NextRun, nullTime(s.WindowEnd), s.LastRun)
+	switch pqErrorCode(err) {
+	case noError:
 		return nil
+	case uniqueViolation:
+		return db.ErrAlreadyExists{Type: "subscription", ID: s.ID}
+	default:
+		return fmt.Errorf("CreateSubscription.exec: %w", err)
+	}
+}
+
+const subscriptionColumns = "id, area, polling_interval, lookback, enabled, next_run, window_end, last_run"
+
+// scanSubscriptions scans the rows of a query on subscriptionColumns
+func scanSubscriptions(rows *sql.Rows) ([]db.Subscription, error) {
+	defer rows.Close()
+	subscriptions := []db.Subscription{}
+	for rows.Next() {
+		var s db.Subscription
+		var area []byte
+		var windowEnd sql.NullTime
+		if err := rows.Scan(&s.ID, &area, &s.PollingInterval, &s.Lookback, &s.Enabled, &s.NextRun, &windowEnd, &s.LastRun); err != nil {
+			return nil, fmt.Errorf("scanSubscriptions.Scan: %w", err)
+		}
+		s.Area, s.WindowEnd = area, windowEnd.Time
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanSubscriptions.Rows.err: %w", err)
 	}
+	return subscriptions, nil
+}
 
-	// Tiles that become roots
-	if _, err := b.ExecContext(ctx, "update tile set ref=NULL where id=$1 and ref=$2", newRefID, oldRefID); err != nil {
-		return fmt.Errorf("UpdateRefTiles.QueryContext: %w", err)
+// Subscription implements WorkflowBackend
+func (b Backend) Subscription(ctx context.Context, id string) (db.Subscription, error) {
+	rows, err := b.QueryContext(ctx, "select "+subscriptionColumns+" from subscription where id = $1", id)
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("Subscription.QueryContext: %w", err)
+	}
+	subscriptions, err := scanSubscriptions(rows)
+	if err != nil {
+		return db.Subscription{}, fmt.Errorf("Subscription.%w", err)
+	}
+	if len(subscriptions) == 0 {
+		return db.Subscription{}, db.ErrNotFound{Type: "subscription", ID: id}
 	}
+	return subscriptions[0], nil
+}
 
-	// Other tiles
-	if _, err := b.ExecContext(ctx, "update tile set ref=$1 where ref=$2", newRefID, oldRefID); err != nil {
-		return fmt.Errorf("UpdateRefTiles.QueryContext: %w", err)
+// Subscriptions implements WorkflowBackend
+func (b Backend) Subscriptions(ctx context.Context) ([]db.Subscription, error) {
+	rows, err := b.QueryContext(ctx, "select "+subscriptionColumns+" from subscription ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("Subscriptions.QueryContext: %w", err)
+	}
+	subscriptions, err := scanSubscriptions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("Subscriptions.%w", err)
+	}
+	return subscriptions, nil
+}
+
+// DueSubscriptions implements WorkflowBackend
+func (b Backend) DueSubscriptions(ctx context.Context, date time.Time) ([]db.Subscription, error) {
+	query := "select " + subscriptionColumns + " from subscription where enabled and next_run <= $1 ORDER BY next_run"
+	if _, ok := b.pgInterface.(*sql.Tx); ok {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+	rows, err := b.QueryContext(ctx, query, date)
+	if err != nil {
+		return nil, fmt.Errorf("DueSubscriptions.QueryContext: %w", err)
+	}
+	subscriptions, err := scanSubscriptions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("DueSubscriptions.%w", err)
+	}
+	return subscriptions, nil
+}
+
+// UpdateSubscription implements WorkflowBackend
+func (b Backend) UpdateSubscription(ctx context.Context, s db.Subscription) error {
+	res, err := b.ExecContext(ctx, "update subscription set area=$2, polling_interval=$3, lookback=$4, enabled=$5, next_run=$6, window_end=$7, last_run=$8 where id = $1",
+		s.ID, []byte(s.Area), s.PollingInterval, s.Lookback, s.Enabled, s.NextRun, nullTime(s.WindowEnd), s.LastRun)
+	if err != nil {
+		return fmt.Errorf("UpdateSubscription.exec: %w", err)
+	}
+	if nb, _ := res.RowsAffected(); nb == 0 {
+		return db.ErrNotFound{Type: "subscription", ID: s.ID}
 	}
 	return nil
 }
 
-// UpdateTileAttrs implements WorkflowBackend
-func (b Backend) UpdateTileAttrs(ctx context.Context, id int, data common.TileAttrs) error {
-	if _, err := b.ExecContext(ctx, "update tile set data=$1 where id=$2", data, id); err != nil {
-		return fmt.Errorf("UpdateTileAttrs: %w", err)
+// DeleteSubscription implements WorkflowBackend
+func (b Backend) DeleteSubscription(ctx context.Context, id string) error {
+	res, err := b.ExecContext(ctx, "delete from subscription where id = $1", id)
+	if err != nil {
+		return fmt.Errorf("DeleteSubscription.exec: %w", err)
+	}
+	if nb, _ := res.RowsAffected(); nb == 0 {
+		return db.ErrNotFound{Type: "subscription", ID: id}
+	}
+	return nil
+}
+
+// CreateCatalogJob implements WorkflowBackend
+func (b Backend) CreateCatalogJob(ctx context.Context, j db.CatalogJob) error {
+	_, err := b.ExecContext(ctx, "insert into catalog_job(id, aoi, status, message, progress, input, result, created_at, updated_at) values($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		j.ID, j.AOI, j.Status, j.Message, j.Progress, nullJSON(j.Input), nullJSON(j.Result), j.CreatedAt, j.UpdatedAt)
+	switch pqErrorCode(err) {
+	case noError:
+		return nil
+	case uniqueViolation:
+		return db.ErrAlreadyExists{Type: "catalog job", ID: j.ID}
+	default:
+		return fmt.Errorf("CreateCatalogJob.exec: %w", err)
+	}
+}
+
+const catalogJobColumns = "id, aoi, status, message, progress, result, created_at, updated_at"
+
+// scanCatalogJobs scans the rows of a query on catalogJobColumns (and input, if withInput)
+func scanCatalogJobs(rows *sql.Rows, withInput bool) ([]db.CatalogJob, error) {
+	defer rows.Close()
+	jobs := []db.CatalogJob{}
+	for rows.Next() {
+		var j db.CatalogJob
+		var result, input []byte
+		dest := []interface{}{&j.ID, &j.AOI, &j.Status, &j.Message, &j.Progress, &result, &j.CreatedAt, &j.UpdatedAt}
+		if withInput {
+			dest = append(dest, &input)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scanCatalogJobs.Scan: %w", err)
+		}
+		j.Result, j.Input = result, input
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("scanCatalogJobs.Rows.err: %w", err)
+	}
+	return jobs, nil
+}
+
+// CatalogJob implements WorkflowBackend
+func (b Backend) CatalogJob(ctx context.Context, id string) (db.CatalogJob, error) {
+	rows, err := b.QueryContext(ctx, "select "+catalogJobColumns+", input from catalog_job where id = $1", id)
+	if err != nil {
+		return db.CatalogJob{}, fmt.Errorf("CatalogJob.QueryContext: %w", err)
+	}
+	jobs, err := scanCatalogJobs(rows, true)
+	if err != nil {
+		return db.CatalogJob{}, fmt.Errorf("CatalogJob.%w", err)
+	}
+	if len(jobs) == 0 {
+		return db.CatalogJob{}, db.ErrNotFound{Type: "catalog job", ID: id}
+	}
+	return jobs[0], nil
+}
+
+// CatalogJobs implements WorkflowBackend
+func (b Backend) CatalogJobs(ctx context.Context, aoi string) ([]db.CatalogJob, error) {
+	rows, err := b.QueryContext(ctx, "select "+catalogJobColumns+" from catalog_job where $1 = '' or aoi = $1 ORDER BY created_at DESC", aoi)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogJobs.QueryContext: %w", err)
+	}
+	jobs, err := scanCatalogJobs(rows, false)
+	if err != nil {
+		return nil, fmt.Errorf("CatalogJobs.%w", err)
+	}
+	return jobs, nil
+}
+
+// UpdateCatalogJob implements WorkflowBackend
+func (b Backend) UpdateCatalogJob(ctx context.Context, j db.CatalogJob) error {
+	res, err := b.ExecContext(ctx, "update catalog_job set status=$2, message=$3, progress=$4, result=$5, updated_at=$6 where id = $1 and status in ($7, $8)",
+		j.ID, j.Status, j.Message, j.Progress, nullJSON(j.Result), j.UpdatedAt, db.CatalogJobPending, db.CatalogJobRunning)
+	if err != nil {
+		return fmt.Errorf("UpdateCatalogJob.exec: %w", err)
+	}
+	if nb, _ := res.RowsAffected(); nb == 0 {
+		return db.ErrNotFound{Type: "catalog job", ID: j.ID}
 	}
 	return nil
 }
+
+// ClaimCatalogJobs implements WorkflowBackend
+func (b Backend) ClaimCatalogJobs(ctx context.Context, notUpdatedSince, now time.Time) ([]db.CatalogJob, error) {
+	rows, err := b.QueryContext(ctx, "update catalog_job set updated_at = $2 where status in ($3, $4) and updated_at < $1 returning "+catalogJobColumns+", input",
+		notUpdatedSince, now, db.CatalogJobPending, db.CatalogJobRunning)
+	if err != nil {
+		return nil, fmt.Errorf("ClaimCatalogJobs.QueryContext: %w", err)
+	}
+	jobs, err := scanCatalogJobs(rows, true)
+	if err != nil {
+		return nil, fmt.Errorf("ClaimCatalogJobs.%w", err)
+	}
+	return jobs, nil
+}
+
+// nullJSON returns a NULL json if b is empty
+func nullJSON(b json.RawMessage) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return []byte(b)
+}
+
+// nullTime returns a NULL time if t is zero
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,2 @@
+-- Migrates a database created before the consolidation of the AOIs (layouts and jobs of the consolidation, started when the AOI is DONE)
+ALTER TABLE public.aoi ADD COLUMN consolidation jsonb;
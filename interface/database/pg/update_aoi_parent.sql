@@ -0,0 +1,4 @@
+-- Migrates a database created before the split of the AOIs (parent of the child AOIs)
+ALTER TABLE public.aoi ADD COLUMN parent_id text;
+ALTER TABLE public.aoi ADD FOREIGN KEY (parent_id) REFERENCES public.aoi(id) ON DELETE SET NULL;
+CREATE INDEX idx_aoi_parent ON public.aoi (parent_id);
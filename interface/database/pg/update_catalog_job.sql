@@ -0,0 +1,14 @@
+-- Migrates a database created before the catalog_job table (jobs of the catalog running in background)
+CREATE TABLE public.catalog_job (
+    id text NOT NULL,
+    aoi text NOT NULL,
+    status text NOT NULL,
+    message text NOT NULL DEFAULT '',
+    progress jsonb,
+    input jsonb,
+    result jsonb,
+    created_at timestamp with time zone NOT NULL,
+    updated_at timestamp with time zone NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_catalog_job_aoi ON public.catalog_job (aoi);
@@ -0,0 +1,11 @@
+-- Migrates a database created before the statistics of the processings (size of the outputs and processing_stats table)
+ALTER TABLE public.tile_output ADD COLUMN size bigint NOT NULL DEFAULT 0;
+
+-- Statistics of the durations of the processings per graph (to estimate the ingestion of an area)
+CREATE TABLE public.processing_stats (
+    type text NOT NULL,
+    graph text NOT NULL,
+    count integer NOT NULL DEFAULT 0,
+    duration double precision NOT NULL DEFAULT 0,
+    PRIMARY KEY (type, graph)
+);
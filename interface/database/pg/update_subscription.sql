@@ -0,0 +1,12 @@
+-- Migrates a database created before the subscription table (standing areas to ingest)
+CREATE TABLE public.subscription (
+    id text NOT NULL,
+    area jsonb NOT NULL,
+    polling_interval text NOT NULL,
+    lookback text NOT NULL DEFAULT '',
+    enabled boolean NOT NULL DEFAULT true,
+    next_run timestamp with time zone NOT NULL,
+    window_end timestamp with time zone,
+    last_run jsonb,
+    PRIMARY KEY (id)
+);
@@ -0,0 +1,17 @@
+-- Migrates a database created before the tile_input table: the prev and ref columns of the tiles are moved to tile_input
+CREATE TABLE public.tile_input (
+    tile_id integer NOT NULL,
+    role text NOT NULL,
+    tile_index integer NOT NULL,
+    input_id integer,
+    PRIMARY KEY (tile_id, role),
+    FOREIGN KEY (tile_id) REFERENCES public.tile(id) ON DELETE CASCADE,
+    FOREIGN KEY (input_id) REFERENCES public.tile(id)
+);
+CREATE INDEX idx_tile_input_input ON public.tile_input (input_id);
+
+INSERT INTO public.tile_input (tile_id, role, tile_index, input_id) SELECT id, 'previous', 1, prev FROM public.tile WHERE prev IS NOT NULL;
+INSERT INTO public.tile_input (tile_id, role, tile_index, input_id) SELECT id, 'reference', 2, ref FROM public.tile WHERE ref IS NOT NULL;
+
+ALTER TABLE public.tile DROP COLUMN prev;
+ALTER TABLE public.tile DROP COLUMN ref;
@@ -0,0 +1,15 @@
+-- Migrates a database created before the tile_output table (outputs of the processing of the tiles)
+-- Must be applied before update_processing_stats.sql
+CREATE TABLE public.tile_output (
+    tile_id integer NOT NULL,
+    layer text NOT NULL,
+    extension text NOT NULL,
+    action text NOT NULL,
+    uri text NOT NULL,
+    instance_id text NOT NULL DEFAULT '',
+    dformat jsonb,
+    PRIMARY KEY (tile_id, layer, extension),
+    FOREIGN KEY (tile_id) REFERENCES public.tile(id) ON DELETE CASCADE
+);
+
+--GRANT SELECT,INSERT,UPDATE,DELETE ON TABLE public.tile_output TO ingester;
@@ -0,0 +1,272 @@
+package geocube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	geocubeclient "github.com/airbusgeo/geocube-client-go/client"
+	geocubepb "github.com/airbusgeo/geocube-client-go/pb"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+	"github.com/airbusgeo/geocube-ingester/service/geometry"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/wkt"
+	"google.golang.org/grpc/codes"
+)
+
+// Client is the subset of the Geocube client used by the Indexer (implemented by geocubeclient.Client)
+type Client interface {
+	GetVariableFromInstanceID(ctx context.Context, id string) (*geocubeclient.VariableInstance, error)
+	GetVariableFromName(ctx context.Context, name string) (*geocubeclient.Variable, error)
+	CreateVariable(ctx context.Context, name, unit, description string, dformat *geocubeclient.DataFormat, bandsName []string, palette, resamplingAlg string) (string, error)
+	InstantiateVariable(ctx context.Context, variableID, name string, metadata map[string]string) (string, error)
+	CreateAOI(ctx context.Context, g geocubeclient.AOI) (string, error)
+	ListRecords(ctx context.Context, name string, tags map[string]string, aoi geocubeclient.AOI, fromTime, toTime time.Time, limit, page int, returnAOI bool) ([]*geocubeclient.Record, error)
+	CreateRecords(ctx context.Context, names, aoiIDs []string, dates []time.Time, tags []map[string]string) ([]string, error)
+	DeleteRecords(ctx context.Context, ids []string) (int64, error)
+	AddRecordsTags(ctx context.Context, ids []string, tags map[string]string) (int64, error)
+	IndexDataset(ctx context.Context, uri string, managed bool, containerSubdir, recordID, instanceID string, bands []int64, dformat *geocubeclient.DataFormat, realMin, realMax, exponent float64) error
+	ConsolidateDatasetsFromRecords(ctx context.Context, name string, instanceID, layoutName string, recordsID []string) (string, error)
+	GetJob(ctx context.Context, jobID string) (*geocubeclient.Job, error)
+}
+
+// Indexer implements indexer.Indexer using the Geocube
+type Indexer struct {
+	client Client
+}
+
+// New creates a Geocube indexer from a connected client
+func New(client Client) *Indexer {
+	return &Indexer{client: client}
+}
+
+// InstanceID implements indexer.Indexer
+func (idx *Indexer) InstanceID(ctx context.Context, variable, instance, instanceID string) (string, error) {
+	if instanceID != "" {
+		if _, err := idx.client.GetVariableFromInstanceID(ctx, instanceID); err != nil {
+			return "", fmt.Errorf("InstanceID: %w", err)
+		}
+		return instanceID, nil
+	}
+	v, err := idx.client.GetVariableFromName(ctx, variable)
+	if err != nil {
+		return "", fmt.Errorf("InstanceID: %w", err)
+	}
+	vi := v.Instance(instance)
+	if vi == nil {
+		return "", fmt.Errorf("InstanceID: unknown instance %s for variable %s", instance, variable)
+	}
+	return vi.InstanceID, nil
+}
+
+// ListRecords implements indexer.Indexer
+func (idx *Indexer) ListRecords(ctx context.Context, tags map[string]string, aoiWKT string, from, to time.Time) ([]indexer.Record, error) {
+	aoi, err := wktToGeocubeAOI(aoiWKT)
+	if err != nil {
+		return nil, fmt.Errorf("ListRecords.%w", err)
+	}
+	records, err := idx.client.ListRecords(ctx, "", tags, aoi, from, to, 0, 0, false)
+	if err != nil {
+		return nil, fmt.Errorf("ListRecords: %w", err)
+	}
+	res := make([]indexer.Record, len(records))
+	for i, r := range records {
+		res[i] = indexer.Record{
+			ID:   r.ID,
+			Name: r.Name,
+			Date: r.Time,
+			Tags: r.Tags,
+		}
+	}
+	return res, nil
+}
+
+// CreateRecords implements indexer.Indexer
+func (idx *Indexer) CreateRecords(ctx context.Context, records []indexer.Record) ([]string, error) {
+	names := make([]string, len(records))
+	aois := make([]string, len(records))
+	tags := make([]map[string]string, len(records))
+	dates := make([]time.Time, len(records))
+	for i, r := range records {
+		names[i] = r.Name
+		tags[i] = r.Tags
+		dates[i] = r.Date
+		// CreateAOI
+		aoi, err := wktToGeocubeAOI(r.GeometryWKT)
+		if err != nil {
+			return nil, fmt.Errorf("CreateRecords.%w", err)
+		}
+		if aois[i], err = idx.client.CreateAOI(ctx, aoi); err != nil && geocubeclient.Code(err) != codes.AlreadyExists {
+			return nil, fmt.Errorf("CreateRecords.%w", err)
+		}
+	}
+
+	ids, err := idx.client.CreateRecords(ctx, names, aois, dates, tags)
+	if err != nil {
+		return nil, fmt.Errorf("CreateRecords.%w", err)
+	}
+	return ids, nil
+}
+
+// DeleteRecords implements indexer.Indexer
+func (idx *Indexer) DeleteRecords(ctx context.Context, ids []string) error {
+	if _, err := idx.client.DeleteRecords(ctx, ids); err != nil {
+		return fmt.Errorf("DeleteRecords: %w", err)
+	}
+	return nil
+}
+
+// AddRecordsTags implements indexer.Indexer
+func (idx *Indexer) AddRecordsTags(ctx context.Context, ids []string, tags map[string]string) error {
+	if _, err := idx.client.AddRecordsTags(ctx, ids, tags); err != nil {
+		return fmt.Errorf("AddRecordsTags: %w", err)
+	}
+	return nil
+}
+
+// IndexDataset implements indexer.Indexer
+func (idx *Indexer) IndexDataset(ctx context.Context, dataset indexer.Dataset) error {
+	dtype, ok := geocubepb.DataFormat_Dtype_value[dtypes[dataset.DFormat.DType]]
+	if !ok {
+		return fmt.Errorf("IndexDataset: dtype '%s' not supported", dataset.DFormat.DType)
+	}
+	dformat := geocubeclient.DataFormat{
+		Dtype:    geocubepb.DataFormat_Dtype(dtype),
+		NoData:   dataset.DFormat.NoData,
+		MinValue: dataset.DFormat.Min,
+		MaxValue: dataset.DFormat.Max,
+	}
+
+	if err := idx.client.IndexDataset(ctx, dataset.URI, true, "", dataset.RecordID, dataset.InstanceID, dataset.Bands,
+		&dformat, dataset.DFormat.ExtMin, dataset.DFormat.ExtMax, dataset.DFormat.Exponent); err != nil {
+		if geocubeclient.Code(err) == codes.AlreadyExists {
+			return indexer.ErrAlreadyExists{URI: dataset.URI}
+		}
+		return fmt.Errorf("IndexDataset: %w", err)
+	}
+	return nil
+}
+
+// dtypes maps the datatypes of the graph to the datatypes of the Geocube
+var dtypes = map[string]string{
+	"uint8":     "UInt8",
+	"uint16":    "UInt16",
+	"uint32":    "UInt32",
+	"int16":     "Int16",
+	"int32":     "Int32",
+	"float32":   "Float32",
+	"float64":   "Float64",
+	"complex64": "Complex64",
+}
+
+// wktToGeocubeAOI converts the wkt to a Geocube AOI, normalized along the antimeridian and the poles (see geometry.NormalizeLonLat)
+func wktToGeocubeAOI(wktAOI string) (geocubeclient.AOI, error) {
+	geo, err := wkt.DecodeString(wktAOI)
+	if err != nil {
+		return nil, fmt.Errorf("wktToGeocubeAOI: %w", err)
+	}
+	geo, _ = geometry.NormalizeLonLat(geo)
+	var mp [][][][2]float64
+	switch g := geo.(type) {
+	case geom.Polygoner:
+		mp = [][][][2]float64{g.LinearRings()}
+	case geom.MultiPolygoner:
+		mp = g.Polygons()
+	default:
+		return geocubeclient.AOI{}, fmt.Errorf("unsupported geometry: %v", g)
+	}
+
+	for i, polygon := range mp {
+		for j, linearring := range polygon {
+			if linearring[0][0] != linearring[len(linearring)-1][0] || linearring[0][1] != linearring[len(linearring)-1][1] {
+				mp[i][j] = append(mp[i][j], mp[i][j][0])
+			}
+		}
+	}
+	return geocubeclient.AOIFromMultiPolygonArray(mp), nil
+}
+
+// ProvisionInstance implements indexer.Provisioner
+func (idx *Indexer) ProvisionInstance(ctx context.Context, variable, instance string, definition indexer.Variable, dryRun bool) (string, []string, error) {
+	var actions []string
+	if variable == "" || instance == "" {
+		return "", nil, fmt.Errorf("ProvisionInstance: variable and instance must be defined")
+	}
+	dformat, err := geocubeclient.ToDFormat(definition.DFormat)
+	if err != nil {
+		return "", nil, fmt.Errorf("ProvisionInstance[%s]: invalid dformat '%s': %w", variable, definition.DFormat, err)
+	}
+
+	// Get or create the variable
+	v, err := idx.client.GetVariableFromName(ctx, variable)
+	switch {
+	case err == nil:
+		if v.Dformat.GetDtype() != dformat.Dtype || (len(definition.Bands) > 0 && len(v.Bands) != len(definition.Bands)) {
+			return "", nil, fmt.Errorf("ProvisionInstance: variable %s already exists with a different definition (dtype: %v, bands: %v)", variable, v.Dformat.GetDtype(), v.Bands)
+		}
+	case geocubeclient.Code(err) == codes.NotFound:
+		actions = append(actions, fmt.Sprintf("create variable %s (dformat: %s, bands: %v, unit: %s, palette: %s, resampling: %s)",
+			variable, definition.DFormat, definition.Bands, definition.Unit, definition.Palette, definition.Resampling))
+		if dryRun {
+			actions = append(actions, fmt.Sprintf("create instance %s of variable %s (metadata: %v)", instance, variable, definition.InstanceMetadata))
+			return "", actions, nil
+		}
+		_, err = idx.client.CreateVariable(ctx, variable, definition.Unit, definition.Description, dformat, definition.Bands,
+			definition.Palette, strings.ToUpper(definition.Resampling))
+		if err != nil && geocubeclient.Code(err) != codes.AlreadyExists {
+			return "", nil, fmt.Errorf("ProvisionInstance.CreateVariable[%s]: %w", variable, err)
+		}
+		// Get the variable (in case of concurrent creation, it may have been created by another client)
+		if v, err = idx.client.GetVariableFromName(ctx, variable); err != nil {
+			return "", nil, fmt.Errorf("ProvisionInstance.GetVariable[%s]: %w", variable, err)
+		}
+	default:
+		return "", nil, fmt.Errorf("ProvisionInstance.GetVariable[%s]: %w", variable, err)
+	}
+
+	// Get or create the instance
+	if vi := v.Instance(instance); vi != nil {
+		return vi.InstanceID, actions, nil
+	}
+	actions = append(actions, fmt.Sprintf("create instance %s of variable %s (metadata: %v)", instance, variable, definition.InstanceMetadata))
+	if dryRun {
+		return "", actions, nil
+	}
+	instanceID, err := idx.client.InstantiateVariable(ctx, v.Id, instance, definition.InstanceMetadata)
+	if err != nil {
+		if geocubeclient.Code(err) != codes.AlreadyExists {
+			return "", nil, fmt.Errorf("ProvisionInstance.InstantiateVariable[%s:%s]: %w", variable, instance, err)
+		}
+		if instanceID, err = idx.InstanceID(ctx, variable, instance, ""); err != nil {
+			return "", nil, fmt.Errorf("ProvisionInstance.%w", err)
+		}
+	}
+	return instanceID, actions, nil
+}
+
+// Consolidate implements indexer.Consolidator
+func (idx *Indexer) Consolidate(ctx context.Context, jobName, instanceID, layout string, recordIDs []string) (string, error) {
+	jobID, err := idx.client.ConsolidateDatasetsFromRecords(ctx, jobName, instanceID, layout, recordIDs)
+	if err != nil {
+		return "", fmt.Errorf("Consolidate[%s]: %w", jobName, err)
+	}
+	return jobID, nil
+}
+
+// jobTerminatedStates are the states of a Geocube job that will not change anymore
+var jobTerminatedStates = map[string]bool{
+	"DONE":           true,
+	"DONEBUTUNTIDY":  true,
+	"ABORTED":        true,
+	"ROLLBACKFAILED": true,
+}
+
+// JobStatus implements indexer.Consolidator
+func (idx *Indexer) JobStatus(ctx context.Context, jobID string) (string, bool, error) {
+	job, err := idx.client.GetJob(ctx, jobID)
+	if err != nil {
+		return "", false, fmt.Errorf("JobStatus[%s]: %w", jobID, err)
+	}
+	return job.State, jobTerminatedStates[job.State], nil
+}
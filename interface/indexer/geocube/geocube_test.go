@@ -0,0 +1,129 @@
+package geocube
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	geocubeclient "github.com/airbusgeo/geocube-client-go/client"
+	geocubepb "github.com/airbusgeo/geocube-client-go/pb"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeVariable struct {
+	id        string
+	dtype     geocubepb.DataFormat_Dtype
+	bands     []string
+	instances map[string]string // name: id
+}
+
+// fakeClient implements the variables of Client in memory (the other methods are not implemented)
+type fakeClient struct {
+	Client
+	variables map[string]*fakeVariable
+	creations int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{variables: map[string]*fakeVariable{}}
+}
+
+func (c *fakeClient) GetVariableFromName(ctx context.Context, name string) (*geocubeclient.Variable, error) {
+	v, ok := c.variables[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "variable %s not found", name)
+	}
+	var instances []*geocubepb.Instance
+	for name, id := range v.instances {
+		instances = append(instances, &geocubepb.Instance{Id: id, Name: name})
+	}
+	return &geocubeclient.Variable{Variable: geocubepb.Variable{
+		Id:        v.id,
+		Name:      name,
+		Dformat:   &geocubepb.DataFormat{Dtype: v.dtype},
+		Bands:     v.bands,
+		Instances: instances,
+	}}, nil
+}
+
+func (c *fakeClient) CreateVariable(ctx context.Context, name, unit, description string, dformat *geocubeclient.DataFormat, bandsName []string, palette, resamplingAlg string) (string, error) {
+	if _, ok := c.variables[name]; ok {
+		return "", status.Errorf(codes.AlreadyExists, "variable %s already exists", name)
+	}
+	c.creations++
+	id := fmt.Sprintf("var-%d", c.creations)
+	c.variables[name] = &fakeVariable{id: id, dtype: dformat.Dtype, bands: bandsName, instances: map[string]string{}}
+	return id, nil
+}
+
+func (c *fakeClient) InstantiateVariable(ctx context.Context, variableID, name string, metadata map[string]string) (string, error) {
+	for _, v := range c.variables {
+		if v.id == variableID {
+			if _, ok := v.instances[name]; ok {
+				return "", status.Errorf(codes.AlreadyExists, "instance %s already exists", name)
+			}
+			c.creations++
+			v.instances[name] = fmt.Sprintf("instance-%d", c.creations)
+			return v.instances[name], nil
+		}
+	}
+	return "", status.Errorf(codes.NotFound, "variable %s not found", variableID)
+}
+
+func TestProvisionInstance(t *testing.T) {
+	ctx := context.Background()
+	definition := indexer.Variable{DFormat: "float32,0,0,1", Bands: []string{"VV"}}
+
+	client := newFakeClient()
+	idx := New(client)
+
+	// Dry run: nothing is created
+	id, actions, err := idx.ProvisionInstance(ctx, "sigma0", "snap", definition, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "" || len(actions) != 2 || client.creations != 0 {
+		t.Errorf("dry run: expecting no id, 2 actions and no creation, got %s, %v, %d", id, actions, client.creations)
+	}
+
+	// Create the variable and the instance
+	if id, actions, err = idx.ProvisionInstance(ctx, "sigma0", "snap", definition, false); err != nil {
+		t.Fatal(err)
+	}
+	if id == "" || len(actions) != 2 || client.creations != 2 {
+		t.Errorf("expecting an id, 2 actions and 2 creations, got %s, %v, %d", id, actions, client.creations)
+	}
+
+	// Already provisioned
+	id2, actions, err := idx.ProvisionInstance(ctx, "sigma0", "snap", definition, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id2 != id || len(actions) != 0 || client.creations != 2 {
+		t.Errorf("expecting %s, no action and no creation, got %s, %v, %d", id, id2, actions, client.creations)
+	}
+
+	// New instance of an existing variable
+	if _, actions, err = idx.ProvisionInstance(ctx, "sigma0", "other", definition, true); err != nil || len(actions) != 1 {
+		t.Errorf("dry run: expecting 1 action, got %v (err: %v)", actions, err)
+	}
+	if id2, _, err = idx.ProvisionInstance(ctx, "sigma0", "other", definition, false); err != nil || id2 == "" || id2 == id {
+		t.Errorf("expecting a new instance, got %s (err: %v)", id2, err)
+	}
+
+	// Inconsistent definitions
+	for _, def := range []indexer.Variable{
+		{DFormat: "uint16,0,0,10000", Bands: []string{"VV"}},    // Other datatype
+		{DFormat: "float32,0,0,1", Bands: []string{"VV", "VH"}}, // Other bands
+		{DFormat: "float32"}, // Invalid dformat
+	} {
+		if _, _, err := idx.ProvisionInstance(ctx, "sigma0", "snap", def, false); err == nil {
+			t.Errorf("%v: expecting an error", def)
+		}
+	}
+	if _, _, err := idx.ProvisionInstance(ctx, "", "snap", definition, false); err == nil {
+		t.Errorf("expecting an error if the variable is not defined")
+	}
+}
@@ -0,0 +1,85 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/common"
+)
+
+// Record is a set of datasets acquired over the same area at the same time (usually a scene)
+type Record struct {
+	ID          string
+	Name        string
+	AOI         string // ID of the AOI of the ingester
+	Date        time.Time
+	Tags        map[string]string
+	GeometryWKT string
+}
+
+// Dataset is an output layer of a tile to be indexed
+type Dataset struct {
+	URI        string
+	RecordID   string
+	InstanceID string
+	Layer      string
+	Bands      []int64
+	DFormat    common.OutputDFormat
+}
+
+// ErrAlreadyExists is returned by IndexDataset when the dataset is already indexed
+type ErrAlreadyExists struct {
+	URI string
+}
+
+func (e ErrAlreadyExists) Error() string {
+	return fmt.Sprintf("dataset %s already exists", e.URI)
+}
+
+// Indexer creates the records of the scenes and indexes the datasets produced by the processor
+type Indexer interface {
+	// InstanceID returns the ID of the instance of the variable that a layer is indexed into.
+	// If instanceID is not empty, it checks that it exists.
+	InstanceID(ctx context.Context, variable, instance, instanceID string) (string, error)
+	// ListRecords returns the records with the given tags, intersecting the aoi (WKT) and between from and to.
+	// An indexer that is not able to search records may return an empty list.
+	ListRecords(ctx context.Context, tags map[string]string, aoiWKT string, from, to time.Time) ([]Record, error)
+	// CreateRecords creates the records and returns their IDs (in the same order)
+	CreateRecords(ctx context.Context, records []Record) ([]string, error)
+	// DeleteRecords deletes the records (the records must not have any dataset indexed)
+	DeleteRecords(ctx context.Context, ids []string) error
+	// AddRecordsTags adds or updates the tags of the records
+	AddRecordsTags(ctx context.Context, ids []string, tags map[string]string) error
+	// IndexDataset indexes the dataset.
+	// Returns ErrAlreadyExists if the dataset is already indexed
+	IndexDataset(ctx context.Context, dataset Dataset) error
+}
+
+// Variable is the definition of a variable, used to create it if it does not exist
+type Variable struct {
+	Unit             string            `json:"unit"`
+	Description      string            `json:"description"`
+	DFormat          string            `json:"dformat"` // dtype,nodata,min,max (e.g. "float32,0,0,1")
+	Bands            []string          `json:"bands"`
+	Palette          string            `json:"palette"`
+	Resampling       string            `json:"resampling"` // e.g. near, bilinear, cubic...
+	InstanceMetadata map[string]string `json:"instance_metadata"`
+}
+
+// Provisioner is implemented by the indexers that are able to create the variables and their instances
+type Provisioner interface {
+	// ProvisionInstance returns the ID of the instance of the variable, creating the variable and/or the instance if they do not exist.
+	// It also returns the list of the actions done (or that would have been done, if dryRun).
+	// If dryRun, nothing is created and the ID is empty if the instance does not exist.
+	ProvisionInstance(ctx context.Context, variable, instance string, definition Variable, dryRun bool) (string, []string, error)
+}
+
+// Consolidator is implemented by the indexers that are able to consolidate the datasets
+type Consolidator interface {
+	// Consolidate starts a job to consolidate the datasets of the records indexed in the instance, in the layout.
+	// Returns the ID of the job
+	Consolidate(ctx context.Context, jobName, instanceID, layout string, recordIDs []string) (string, error)
+	// JobStatus returns the status of the job and whether it is terminated (successfully or not)
+	JobStatus(ctx context.Context, jobID string) (string, bool, error)
+}
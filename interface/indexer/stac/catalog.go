@@ -0,0 +1,115 @@
+package stac
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Version of the STAC specification
+const Version = "1.0.0"
+
+// Extensions used by the items and the collections
+var extensions = []string{
+	"https://stac-extensions.github.io/raster/v1.1.0/schema.json",
+}
+
+// Link of a STAC object
+type Link struct {
+	Rel   string `json:"rel"`
+	Href  string `json:"href"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// Catalog is the root of the static catalog
+type Catalog struct {
+	Type        string `json:"type"`
+	StacVersion string `json:"stac_version"`
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Links       []Link `json:"links"`
+}
+
+// Collection groups the items of an AOI
+type Collection struct {
+	Type           string              `json:"type"`
+	StacVersion    string              `json:"stac_version"`
+	StacExtensions []string            `json:"stac_extensions,omitempty"`
+	ID             string              `json:"id"`
+	Description    string              `json:"description"`
+	License        string              `json:"license"`
+	Extent         Extent              `json:"extent"`
+	Summaries      map[string][]string `json:"summaries,omitempty"`
+	Links          []Link              `json:"links"`
+}
+
+// Extent of a collection
+type Extent struct {
+	Spatial struct {
+		BBox [][4]float64 `json:"bbox"`
+	} `json:"spatial"`
+	Temporal struct {
+		Interval [][2]*time.Time `json:"interval"`
+	} `json:"temporal"`
+}
+
+// Item is a record
+type Item struct {
+	Type           string           `json:"type"`
+	StacVersion    string           `json:"stac_version"`
+	StacExtensions []string         `json:"stac_extensions,omitempty"`
+	ID             string           `json:"id"`
+	Collection     string           `json:"collection"`
+	Geometry       json.RawMessage  `json:"geometry"`
+	BBox           [4]float64       `json:"bbox"`
+	Properties     ItemProperties   `json:"properties"`
+	Links          []Link           `json:"links"`
+	Assets         map[string]Asset `json:"assets"`
+}
+
+// ItemProperties are the properties of an item
+type ItemProperties struct {
+	Datetime time.Time         `json:"datetime"`
+	Title    string            `json:"title"`
+	Tags     map[string]string `json:"ingester:tags,omitempty"`
+}
+
+// Asset is a dataset of an item
+type Asset struct {
+	Href        string       `json:"href"`
+	Title       string       `json:"title,omitempty"`
+	Type        string       `json:"type,omitempty"`
+	Roles       []string     `json:"roles,omitempty"`
+	Layer       string       `json:"ingester:layer"`
+	InstanceID  string       `json:"ingester:instance_id,omitempty"`
+	RasterBands []RasterBand `json:"raster:bands,omitempty"`
+}
+
+// RasterBand describes a band of an asset (raster extension)
+type RasterBand struct {
+	DataType string   `json:"data_type,omitempty"`
+	NoData   *float64 `json:"nodata,omitempty"`
+	Scale    *float64 `json:"scale,omitempty"`
+	Offset   *float64 `json:"offset,omitempty"`
+}
+
+// addLink adds the link if there is no link with the same rel and href
+func addLink(links []Link, link Link) []Link {
+	for _, l := range links {
+		if l.Rel == link.Rel && l.Href == link.Href {
+			return links
+		}
+	}
+	return append(links, link)
+}
+
+// removeLink removes the links with the given rel and href
+func removeLink(links []Link, rel, href string) []Link {
+	res := links[:0]
+	for _, l := range links {
+		if l.Rel != rel || l.Href != href {
+			res = append(res, l)
+		}
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+package stac
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"cloud.google.com/go/storage"
+	"github.com/airbusgeo/geocube/interface/storage/gcs"
+	"google.golang.org/api/googleapi"
+)
+
+// errConflict is returned by objects.writeIf and objects.deleteIf if the file has been modified since it was read
+var errConflict = errors.New("file concurrently modified")
+
+// objects reads and writes the files of the catalog with optimistic concurrency control:
+// a file is only written (or deleted) if it has not been modified since it was read.
+type objects interface {
+	// read returns the content of the file and its generation, or an empty generation if the file does not exist
+	read(ctx context.Context, file string) ([]byte, string, error)
+	// writeIf writes the file if its generation is the given one (empty: the file must not exist), otherwise it returns errConflict
+	writeIf(ctx context.Context, file string, data []byte, generation string) error
+	// deleteIf deletes the file if its generation is the given one, otherwise it returns errConflict
+	deleteIf(ctx context.Context, file string, generation string) error
+}
+
+// newObjects returns the implementation of objects for the catalogURI (local or gs)
+func newObjects(ctx context.Context, catalogURI string) (objects, error) {
+	switch {
+	case strings.HasPrefix(catalogURI, "gs://"):
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("newObjects.NewClient: %w", err)
+		}
+		return &gsObjects{client: client}, nil
+	case strings.HasPrefix(catalogURI, "file://"), strings.HasPrefix(catalogURI, "/"):
+		return localObjects{}, nil
+	}
+	return nil, fmt.Errorf("newObjects: storage not supported: %s", catalogURI)
+}
+
+// gsObjects implements objects in Google Storage using the generation of the objects as precondition
+type gsObjects struct {
+	client *storage.Client
+}
+
+func (o *gsObjects) object(file string) (*storage.ObjectHandle, error) {
+	bucket, object, err := gcs.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("Parse[%s]: %w", file, err)
+	}
+	return o.client.Bucket(bucket).Object(object), nil
+}
+
+func (o *gsObjects) read(ctx context.Context, file string) ([]byte, string, error) {
+	obj, err := o.object(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("read.%w", err)
+	}
+	r, err := obj.NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("read.NewReader[%s]: %w", file, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("read.ReadAll[%s]: %w", file, err)
+	}
+	return b, strconv.FormatInt(r.Attrs.Generation, 10), nil
+}
+
+func (o *gsObjects) conditions(generation string) (storage.Conditions, error) {
+	if generation == "" {
+		return storage.Conditions{DoesNotExist: true}, nil
+	}
+	g, err := strconv.ParseInt(generation, 10, 64)
+	if err != nil {
+		return storage.Conditions{}, fmt.Errorf("invalid generation %s: %w", generation, err)
+	}
+	return storage.Conditions{GenerationMatch: g}, nil
+}
+
+func (o *gsObjects) writeIf(ctx context.Context, file string, data []byte, generation string) error {
+	obj, err := o.object(file)
+	if err != nil {
+		return fmt.Errorf("writeIf.%w", err)
+	}
+	conds, err := o.conditions(generation)
+	if err != nil {
+		return fmt.Errorf("writeIf[%s]: %w", file, err)
+	}
+	w := obj.If(conds).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("writeIf.Write[%s]: %w", file, gsError(err))
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("writeIf.Close[%s]: %w", file, gsError(err))
+	}
+	return nil
+}
+
+func (o *gsObjects) deleteIf(ctx context.Context, file string, generation string) error {
+	obj, err := o.object(file)
+	if err != nil {
+		return fmt.Errorf("deleteIf.%w", err)
+	}
+	conds, err := o.conditions(generation)
+	if err != nil {
+		return fmt.Errorf("deleteIf[%s]: %w", file, err)
+	}
+	if err := obj.If(conds).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("deleteIf.Delete[%s]: %w", file, gsError(err))
+	}
+	return nil
+}
+
+// gsError converts a failed precondition into errConflict
+func gsError(err error) error {
+	var e *googleapi.Error
+	if errors.As(err, &e) && e.Code == http.StatusPreconditionFailed {
+		return errConflict
+	}
+	return err
+}
+
+// localObjects implements objects in a local filesystem, using the hash of the content as generation.
+// The files are compared and written while holding an exclusive lock (flock) on <file>.lock.
+type localObjects struct{}
+
+func localPath(file string) string {
+	return strings.TrimPrefix(file, "file://")
+}
+
+func generation(data []byte) string {
+	h := sha1.Sum(data)
+	return hex.EncodeToString(h[:])
+}
+
+func (localObjects) read(ctx context.Context, file string) ([]byte, string, error) {
+	b, err := os.ReadFile(localPath(file))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("read: %w", err)
+	}
+	return b, generation(b), nil
+}
+
+// locked checks the generation of the file and calls f while holding the lock of the file
+func (l localObjects) locked(ctx context.Context, file string, gen string, f func(path string) error) error {
+	path := localPath(file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("MkdirAll: %w", err)
+	}
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("OpenLock: %w", err)
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("Flock[%s]: %w", path, err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	_, current, err := l.read(ctx, file)
+	if err != nil {
+		return err
+	}
+	if current != gen {
+		return errConflict
+	}
+	return f(path)
+}
+
+func (l localObjects) writeIf(ctx context.Context, file string, data []byte, gen string) error {
+	if err := l.locked(ctx, file, gen, func(path string) error {
+		// Write in a temporary file and rename it, so that the readers never see a partial file
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return fmt.Errorf("WriteFile: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("Rename: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("writeIf[%s]: %w", file, err)
+	}
+	return nil
+}
+
+func (l localObjects) deleteIf(ctx context.Context, file string, gen string) error {
+	if err := l.locked(ctx, file, gen, func(path string) error {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Remove: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("deleteIf[%s]: %w", file, err)
+	}
+	return nil
+}
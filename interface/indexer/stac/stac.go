@@ -0,0 +1,489 @@
+package stac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+	"github.com/airbusgeo/geocube-ingester/service"
+	"github.com/go-spatial/geom"
+	"github.com/go-spatial/geom/encoding/geojson"
+	"github.com/go-spatial/geom/encoding/wkt"
+	"github.com/google/uuid"
+)
+
+const (
+	catalogFile       = "catalog.json"
+	collectionsFolder = "collections"
+	itemsFolder       = "items"
+	jsonType          = "application/json"
+	geojsonType       = "application/geo+json"
+	layerSummary      = "ingester:layer"
+)
+
+// Indexer implements indexer.Indexer by writing a static STAC catalog in a storage:
+//   - <root>/catalog.json
+//   - <root>/collections/<aoi>.json: one collection per AOI, with the items of the AOI, summarizing the layers (ingester:layer)
+//   - <root>/collections/<aoi>_<layer>.json: one collection per AOI and layer, child of the collection of the AOI,
+//     linking to the items of the AOI that have an asset of the layer
+//   - <root>/items/<record>.json: one item per record, with one asset per dataset (named after the file of the dataset)
+//
+// As an item belongs to only one collection, the collection of an item is the collection of its AOI.
+//
+// The catalog cannot be searched, so ListRecords always returns an empty list.
+// Instead, the ID of a record is derived from its AOI, name, date and tags, so that
+// CreateRecords returns the ID of the existing item if the same record is created twice.
+//
+// Items and collections are updated with read-modify-write operations using conditional writes
+// (generation precondition in gs, lock file in a local filesystem): if the file has been modified
+// by another processor (e.g. bursts of the same scene) since it was read, the update is retried.
+type Indexer struct {
+	objects objects
+	root    string
+}
+
+// maxUpdateTries is the maximum number of tries of a read-modify-write operation
+const maxUpdateTries = 50
+
+// New creates a STAC indexer writing a static catalog in catalogURI (currently supported: local, gs)
+func New(ctx context.Context, catalogURI string) (*Indexer, error) {
+	objects, err := newObjects(ctx, catalogURI)
+	if err != nil {
+		return nil, fmt.Errorf("NewStacIndexer.%w", err)
+	}
+	return &Indexer{objects: objects, root: strings.TrimSuffix(catalogURI, "/") + "/"}, nil
+}
+
+// InstanceID implements indexer.Indexer
+func (idx *Indexer) InstanceID(ctx context.Context, variable, instance, instanceID string) (string, error) {
+	if instanceID != "" {
+		return instanceID, nil
+	}
+	if variable == "" || instance == "" {
+		return "", fmt.Errorf("InstanceID: variable and instance must be defined")
+	}
+	return variable + ":" + instance, nil
+}
+
+// ListRecords implements indexer.Indexer
+func (idx *Indexer) ListRecords(ctx context.Context, tags map[string]string, aoiWKT string, from, to time.Time) ([]indexer.Record, error) {
+	return nil, nil
+}
+
+// CreateRecords implements indexer.Indexer
+func (idx *Indexer) CreateRecords(ctx context.Context, records []indexer.Record) ([]string, error) {
+	ids := make([]string, len(records))
+	newItems := map[string][]*Item{}
+	for i, r := range records {
+		ids[i] = recordID(r)
+		var created *Item
+		if err := update(ctx, idx, itemPath(ids[i]), func(item *Item, exists bool) (bool, error) {
+			if exists {
+				created = nil
+				return false, nil
+			}
+			newItem, err := newItem(ids[i], r)
+			if err != nil {
+				return false, err
+			}
+			*item, created = *newItem, newItem
+			return true, nil
+		}); err != nil {
+			return nil, fmt.Errorf("CreateRecords.%w", err)
+		}
+		if created != nil {
+			newItems[r.AOI] = append(newItems[r.AOI], created)
+		}
+	}
+
+	// Update the collections
+	for aoi, items := range newItems {
+		if err := idx.updateCollection(ctx, aoi, "", func(c *Collection) {
+			for _, item := range items {
+				c.Links = addLink(c.Links, Link{Rel: "item", Href: "../" + itemPath(item.ID), Type: geojsonType})
+				extendCollection(c, item)
+			}
+		}); err != nil {
+			return nil, fmt.Errorf("CreateRecords.%w", err)
+		}
+	}
+	return ids, nil
+}
+
+// DeleteRecords implements indexer.Indexer
+// As in the Geocube, the records that have datasets are not deleted.
+func (idx *Indexer) DeleteRecords(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		deleted, collection, err := idx.deleteItem(ctx, id)
+		if err != nil {
+			return fmt.Errorf("DeleteRecords.%w", err)
+		}
+		if !deleted {
+			continue
+		}
+		if err := idx.updateCollection(ctx, collection, "", func(c *Collection) {
+			c.Links = removeLink(c.Links, "item", "../"+itemPath(id))
+		}); err != nil {
+			return fmt.Errorf("DeleteRecords.%w", err)
+		}
+	}
+	return nil
+}
+
+// deleteItem deletes the item if it has no asset. It returns whether the item has been deleted and its collection
+func (idx *Indexer) deleteItem(ctx context.Context, id string) (bool, string, error) {
+	for try := 0; try < maxUpdateTries; try++ {
+		item := Item{}
+		generation, err := idx.readGeneration(ctx, itemPath(id), &item)
+		if err != nil || generation == "" || len(item.Assets) > 0 {
+			return false, "", err
+		}
+		err = idx.objects.deleteIf(ctx, idx.root+itemPath(id), generation)
+		if err == nil {
+			return true, item.Collection, nil
+		}
+		if !errors.Is(err, errConflict) {
+			return false, "", fmt.Errorf("deleteItem.%w", err)
+		}
+	}
+	return false, "", service.MakeTemporary(fmt.Errorf("deleteItem[%s]: %w (after %d tries)", id, errConflict, maxUpdateTries))
+}
+
+// AddRecordsTags implements indexer.Indexer
+func (idx *Indexer) AddRecordsTags(ctx context.Context, ids []string, tags map[string]string) error {
+	for _, id := range ids {
+		if err := idx.updateItem(ctx, id, func(item *Item) error {
+			if item.Properties.Tags == nil {
+				item.Properties.Tags = map[string]string{}
+			}
+			for k, v := range tags {
+				item.Properties.Tags[k] = v
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("AddRecordsTags.%w", err)
+		}
+	}
+	return nil
+}
+
+// IndexDataset implements indexer.Indexer
+func (idx *Indexer) IndexDataset(ctx context.Context, dataset indexer.Dataset) error {
+	key := strings.TrimSuffix(path.Base(dataset.URI), path.Ext(dataset.URI))
+	mediaType := assetMediaType(dataset.URI)
+	var indexed Item
+	if err := idx.updateItem(ctx, dataset.RecordID, func(item *Item) error {
+		if a, ok := item.Assets[key]; ok && a.Href == dataset.URI {
+			return indexer.ErrAlreadyExists{URI: dataset.URI}
+		}
+		if item.Assets == nil {
+			item.Assets = map[string]Asset{}
+		}
+		item.Assets[key] = newAsset(dataset, mediaType)
+		indexed = *item
+		return nil
+	}); err != nil {
+		if errors.As(err, &indexer.ErrAlreadyExists{}) {
+			return err
+		}
+		return fmt.Errorf("IndexDataset.%w", err)
+	}
+
+	// Add the layer to the summaries of the collection
+	if err := idx.updateCollection(ctx, indexed.Collection, "", func(c *Collection) {
+		if c.Summaries == nil {
+			c.Summaries = map[string][]string{}
+		}
+		if !slices.Contains(c.Summaries[layerSummary], dataset.Layer) {
+			c.Summaries[layerSummary] = append(c.Summaries[layerSummary], dataset.Layer)
+		}
+	}); err != nil {
+		return fmt.Errorf("IndexDataset.%w", err)
+	}
+
+	// Add the item to the collection of the layer
+	if err := idx.updateCollection(ctx, layerCollectionID(indexed.Collection, dataset.Layer), indexed.Collection, func(c *Collection) {
+		c.Summaries = map[string][]string{layerSummary: {dataset.Layer}}
+		c.Links = addLink(c.Links, Link{Rel: "item", Href: "../" + itemPath(indexed.ID), Type: geojsonType})
+		extendCollection(c, &indexed)
+	}); err != nil {
+		return fmt.Errorf("IndexDataset.%w", err)
+	}
+	return nil
+}
+
+// update reads the file into v, calls modify and writes v if modify returns true.
+// If the file has been concurrently modified, the operation is retried (up to maxUpdateTries).
+func update[T any](ctx context.Context, idx *Indexer, file string, modify func(v *T, exists bool) (bool, error)) error {
+	for try := 0; try < maxUpdateTries; try++ {
+		var v T
+		generation, err := idx.readGeneration(ctx, file, &v)
+		if err != nil {
+			return err
+		}
+		write, err := modify(&v, generation != "")
+		if err != nil || !write {
+			return err
+		}
+		err = idx.write(ctx, file, &v, generation)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, errConflict) {
+			return err
+		}
+		// Random backoff to let the concurrent writer finish
+		time.Sleep(time.Duration(rand.Intn(10*(try+1))) * time.Millisecond)
+	}
+	return service.MakeTemporary(fmt.Errorf("update[%s]: %w (after %d tries)", file, errConflict, maxUpdateTries))
+}
+
+// updateItem reads, updates and writes an existing item
+func (idx *Indexer) updateItem(ctx context.Context, id string, modify func(item *Item) error) error {
+	return update(ctx, idx, itemPath(id), func(item *Item, exists bool) (bool, error) {
+		if !exists {
+			return false, fmt.Errorf("updateItem: record %s not found", id)
+		}
+		return true, modify(item)
+	})
+}
+
+// updateCollection reads, updates and writes a collection, creating it if it does not exist.
+// A new collection is referenced in its parent collection or, if parent is empty, in the root catalog.
+func (idx *Indexer) updateCollection(ctx context.Context, id, parent string, modify func(c *Collection)) error {
+	return update(ctx, idx, collectionPath(id), func(collection *Collection, exists bool) (bool, error) {
+		if !exists {
+			parentHref, description := "../"+catalogFile, fmt.Sprintf("Datasets ingested over the AOI %s", id)
+			if parent != "" {
+				parentHref, description = "../"+collectionPath(parent), fmt.Sprintf("Datasets of the layer %s ingested over the AOI %s", strings.TrimPrefix(id, parent+"_"), parent)
+			}
+			*collection = Collection{
+				Type:           "Collection",
+				StacVersion:    Version,
+				StacExtensions: extensions,
+				ID:             id,
+				Description:    description,
+				License:        "proprietary",
+				Links: []Link{
+					{Rel: "root", Href: "../" + catalogFile, Type: jsonType},
+					{Rel: "parent", Href: parentHref, Type: jsonType},
+					{Rel: "self", Href: idx.root + collectionPath(id), Type: jsonType},
+				},
+			}
+			var err error
+			if parent == "" {
+				err = idx.addToCatalog(ctx, id)
+			} else {
+				err = idx.updateCollection(ctx, parent, "", func(c *Collection) {
+					c.Links = addLink(c.Links, Link{Rel: "child", Href: "../" + collectionPath(id), Type: jsonType, Title: id})
+				})
+			}
+			if err != nil {
+				return false, err
+			}
+		}
+		modify(collection)
+		return true, nil
+	})
+}
+
+// addToCatalog adds the collection to the root catalog, creating it if it does not exist
+func (idx *Indexer) addToCatalog(ctx context.Context, collectionID string) error {
+	return update(ctx, idx, catalogFile, func(catalog *Catalog, exists bool) (bool, error) {
+		if !exists {
+			*catalog = Catalog{
+				Type:        "Catalog",
+				StacVersion: Version,
+				ID:          "geocube-ingester",
+				Description: "Datasets ingested by the Geocube Ingester",
+				Links: []Link{
+					{Rel: "root", Href: "./" + catalogFile, Type: jsonType},
+					{Rel: "self", Href: idx.root + catalogFile, Type: jsonType},
+				},
+			}
+		}
+		catalog.Links = addLink(catalog.Links, Link{Rel: "child", Href: "./" + collectionPath(collectionID), Type: jsonType, Title: collectionID})
+		return true, nil
+	})
+}
+
+// read unmarshals the file into v. Returns false if the file does not exist
+func (idx *Indexer) read(ctx context.Context, file string, v interface{}) (bool, error) {
+	generation, err := idx.readGeneration(ctx, file, v)
+	return generation != "", err
+}
+
+// readGeneration unmarshals the file into v. Returns the generation of the file or an empty string if the file does not exist
+func (idx *Indexer) readGeneration(ctx context.Context, file string, v interface{}) (string, error) {
+	b, generation, err := idx.objects.read(ctx, idx.root+file)
+	if err != nil {
+		return "", fmt.Errorf("readGeneration.%w", err)
+	}
+	if generation == "" {
+		return "", nil
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return "", fmt.Errorf("readGeneration.Unmarshal[%s]: %w", file, err)
+	}
+	return generation, nil
+}
+
+// write marshals v into the file, if the generation of the file is still the given one (otherwise returns errConflict)
+func (idx *Indexer) write(ctx context.Context, file string, v interface{}, generation string) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("write.Marshal[%s]: %w", file, err)
+	}
+	if err := idx.objects.writeIf(ctx, idx.root+file, b, generation); err != nil {
+		return fmt.Errorf("write.%w", err)
+	}
+	return nil
+}
+
+func itemPath(id string) string {
+	return path.Join(itemsFolder, id+".json")
+}
+
+func collectionPath(id string) string {
+	return path.Join(collectionsFolder, id+".json")
+}
+
+// layerCollectionID returns the ID of the collection of the layer in the AOI
+func layerCollectionID(aoi, layer string) string {
+	return aoi + "_" + layer
+}
+
+// recordID returns a deterministic ID given the AOI, the name, the date and the tags of the record
+func recordID(r indexer.Record) string {
+	keys := make([]string, 0, len(r.Tags))
+	for k := range r.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := []string{r.AOI, r.Name, r.Date.UTC().Format(time.RFC3339Nano)}
+	for _, k := range keys {
+		s = append(s, k+"="+r.Tags[k])
+	}
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(strings.Join(s, "\n"))).String()
+}
+
+func newItem(id string, r indexer.Record) (*Item, error) {
+	g, err := wkt.DecodeString(r.GeometryWKT)
+	if err != nil {
+		return nil, fmt.Errorf("newItem.DecodeWKT: %w", err)
+	}
+	geometry, err := json.Marshal(geojson.Geometry{Geometry: g})
+	if err != nil {
+		return nil, fmt.Errorf("newItem.MarshalGeometry: %w", err)
+	}
+	extent, err := geom.NewExtentFromGeometry(g)
+	if err != nil {
+		return nil, fmt.Errorf("newItem.Extent: %w", err)
+	}
+	return &Item{
+		Type:           "Feature",
+		StacVersion:    Version,
+		StacExtensions: extensions,
+		ID:             id,
+		Collection:     r.AOI,
+		Geometry:       geometry,
+		BBox:           extent.Extent(),
+		Properties: ItemProperties{
+			Datetime: r.Date,
+			Title:    r.Name,
+			Tags:     r.Tags,
+		},
+		Links: []Link{
+			{Rel: "root", Href: "../" + catalogFile, Type: jsonType},
+			{Rel: "parent", Href: "../" + collectionPath(r.AOI), Type: jsonType},
+			{Rel: "collection", Href: "../" + collectionPath(r.AOI), Type: jsonType},
+		},
+		Assets: map[string]Asset{},
+	}, nil
+}
+
+// extendCollection extends the spatial and temporal extents of the collection to include the item
+func extendCollection(c *Collection, item *Item) {
+	if len(c.Extent.Spatial.BBox) == 0 {
+		c.Extent.Spatial.BBox = [][4]float64{item.BBox}
+	} else {
+		bbox := &c.Extent.Spatial.BBox[0]
+		bbox[0] = min(bbox[0], item.BBox[0])
+		bbox[1] = min(bbox[1], item.BBox[1])
+		bbox[2] = max(bbox[2], item.BBox[2])
+		bbox[3] = max(bbox[3], item.BBox[3])
+	}
+
+	date := item.Properties.Datetime
+	if len(c.Extent.Temporal.Interval) == 0 {
+		c.Extent.Temporal.Interval = [][2]*time.Time{{&date, &date}}
+		return
+	}
+	interval := &c.Extent.Temporal.Interval[0]
+	if interval[0] == nil || date.Before(*interval[0]) {
+		interval[0] = &date
+	}
+	if interval[1] == nil || date.After(*interval[1]) {
+		interval[1] = &date
+	}
+}
+
+func newAsset(dataset indexer.Dataset, mediaType string) Asset {
+	dformat := dataset.DFormat
+	band := RasterBand{
+		DataType: rasterDataTypes[dformat.DType],
+		NoData:   &dformat.NoData,
+	}
+	// Linear mapping from [Min, Max] to [ExtMin, ExtMax]
+	if (dformat.Exponent == 0 || dformat.Exponent == 1) && dformat.Max != dformat.Min {
+		scale := (dformat.ExtMax - dformat.ExtMin) / (dformat.Max - dformat.Min)
+		offset := dformat.ExtMin - dformat.Min*scale
+		band.Scale, band.Offset = &scale, &offset
+	}
+	bands := make([]RasterBand, len(dataset.Bands))
+	for i := range bands {
+		bands[i] = band
+	}
+
+	return Asset{
+		Href:        dataset.URI,
+		Title:       dataset.Layer,
+		Type:        mediaType,
+		Roles:       []string{"data"},
+		Layer:       dataset.Layer,
+		InstanceID:  dataset.InstanceID,
+		RasterBands: bands,
+	}
+}
+
+func assetMediaType(uri string) string {
+	switch strings.ToLower(path.Ext(uri)) {
+	case ".tif", ".tiff":
+		return "image/tiff; application=geotiff"
+	case ".jp2":
+		return "image/jp2"
+	case ".zip":
+		return "application/zip"
+	}
+	return ""
+}
+
+// rasterDataTypes maps the datatypes of the graph to the datatypes of the raster extension
+var rasterDataTypes = map[string]string{
+	"uint8":     "uint8",
+	"uint16":    "uint16",
+	"uint32":    "uint32",
+	"int16":     "int16",
+	"int32":     "int32",
+	"float32":   "float32",
+	"float64":   "float64",
+	"complex64": "cfloat32",
+}
@@ -0,0 +1,200 @@
+package stac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+)
+
+func TestStacIndexer(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := os.MkdirTemp("", "stac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	idx, err := New(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []indexer.Record{
+		{Name: "S2A_1", AOI: "aoi", Date: time.Date(2023, 1, 3, 10, 0, 0, 0, time.UTC), Tags: map[string]string{"constellation": "SENTINEL2"}, GeometryWKT: "POLYGON((0 0,1 0,1 1,0 1,0 0))"},
+		{Name: "S2A_2", AOI: "aoi", Date: time.Date(2023, 1, 8, 10, 0, 0, 0, time.UTC), Tags: map[string]string{"constellation": "SENTINEL2"}, GeometryWKT: "POLYGON((1 1,2 1,2 2,1 2,1 1))"},
+	}
+	ids, err := idx.CreateRecords(ctx, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("expected two different ids, got %v", ids)
+	}
+
+	// Same record => same ID
+	ids2, err := idx.CreateRecords(ctx, records[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids2[0] != ids[0] {
+		t.Errorf("expected id %s, got %s", ids[0], ids2[0])
+	}
+
+	// Collection
+	collection := Collection{}
+	if exists, err := idx.read(ctx, collectionPath("aoi"), &collection); err != nil || !exists {
+		t.Fatalf("collection not found: %v", err)
+	}
+	if bbox := collection.Extent.Spatial.BBox[0]; bbox != [4]float64{0, 0, 2, 2} {
+		t.Errorf("wrong collection bbox: %v", bbox)
+	}
+	if interval := collection.Extent.Temporal.Interval[0]; !interval[0].Equal(records[0].Date) || !interval[1].Equal(records[1].Date) {
+		t.Errorf("wrong collection interval: %v %v", interval[0], interval[1])
+	}
+	if _, err := os.Stat(path.Join(dir, catalogFile)); err != nil {
+		t.Error(err)
+	}
+
+	// Index dataset
+	dataset := indexer.Dataset{
+		URI:        "/storage/aoi/S2A_1/tiles/S2A_1/20230103_S2A_1_B04.tif",
+		RecordID:   ids[0],
+		InstanceID: "reflectance:B04",
+		Layer:      "B04",
+		Bands:      []int64{1},
+		DFormat:    common.OutputDFormat{DType: "uint16", NoData: 0, Min: 1, Max: 10001, ExtMin: 0, ExtMax: 1, Exponent: 1, Nbands: 1},
+	}
+	if err := idx.IndexDataset(ctx, dataset); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.IndexDataset(ctx, dataset); !errors.As(err, &indexer.ErrAlreadyExists{}) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+	if err := idx.AddRecordsTags(ctx, ids[:1], map[string]string{common.TagProcessingDate: "now"}); err != nil {
+		t.Fatal(err)
+	}
+
+	item := Item{}
+	if exists, err := idx.read(ctx, itemPath(ids[0]), &item); err != nil || !exists {
+		t.Fatalf("item not found: %v", err)
+	}
+	asset, ok := item.Assets["20230103_S2A_1_B04"]
+	if !ok {
+		t.Fatalf("asset not found: %v", item.Assets)
+	}
+	if asset.Layer != "B04" || len(asset.RasterBands) != 1 || asset.RasterBands[0].DataType != "uint16" || *asset.RasterBands[0].Scale != 0.0001 {
+		t.Errorf("wrong asset: %+v", asset)
+	}
+	if item.Properties.Tags[common.TagProcessingDate] != "now" || item.Properties.Tags["constellation"] != "SENTINEL2" {
+		t.Errorf("wrong tags: %v", item.Properties.Tags)
+	}
+
+	// Collection of the layer, child of the collection of the AOI
+	layerCollection := Collection{}
+	if exists, err := idx.read(ctx, collectionPath("aoi_B04"), &layerCollection); err != nil || !exists {
+		t.Fatalf("layer collection not found: %v", err)
+	}
+	if len(findLinks(layerCollection.Links, "item")) != 1 || layerCollection.Extent.Spatial.BBox[0] != [4]float64{0, 0, 1, 1} {
+		t.Errorf("wrong layer collection: %+v", layerCollection)
+	}
+	if parent := findLinks(layerCollection.Links, "parent"); len(parent) != 1 || parent[0].Href != "../"+collectionPath("aoi") {
+		t.Errorf("wrong parent: %v", parent)
+	}
+	if _, err := idx.read(ctx, collectionPath("aoi"), &collection); err != nil {
+		t.Fatal(err)
+	}
+	if children := findLinks(collection.Links, "child"); len(children) != 1 || children[0].Href != "../"+collectionPath("aoi_B04") {
+		t.Errorf("wrong children: %v", children)
+	}
+
+	// Records with datasets are not deleted
+	if err := idx.DeleteRecords(ctx, ids); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := idx.read(ctx, itemPath(ids[0]), &Item{}); !exists {
+		t.Errorf("record %s must not be deleted", ids[0])
+	}
+	if exists, _ := idx.read(ctx, itemPath(ids[1]), &Item{}); exists {
+		t.Errorf("record %s must be deleted", ids[1])
+	}
+}
+
+func TestStacIndexerConcurrent(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Several indexers (e.g. several processors) index the datasets of the bursts of the same scene
+	indexers := make([]*Indexer, 4)
+	for i := range indexers {
+		var err error
+		if indexers[i], err = New(ctx, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record := indexer.Record{Name: "S1A_1", AOI: "aoi", Date: time.Date(2023, 1, 3, 6, 0, 0, 0, time.UTC), GeometryWKT: "POLYGON((0 0,1 0,1 1,0 1,0 0))"}
+	ids, err := indexers[0].CreateRecords(ctx, []indexer.Record{record})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const datasetsPerIndexer = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, len(indexers)*datasetsPerIndexer)
+	for i, idx := range indexers {
+		wg.Add(1)
+		go func(i int, idx *Indexer) {
+			defer wg.Done()
+			for j := 0; j < datasetsPerIndexer; j++ {
+				errs <- idx.IndexDataset(ctx, indexer.Dataset{
+					URI:        fmt.Sprintf("/storage/aoi/S1A_1/burst_%d_%d.tif", i, j),
+					RecordID:   ids[0],
+					InstanceID: "sigma0:VV",
+					Layer:      fmt.Sprintf("layer%d", i),
+					Bands:      []int64{1},
+					DFormat:    common.OutputDFormat{DType: "float32", Nbands: 1},
+				})
+			}
+		}(i, idx)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item := Item{}
+	if exists, err := indexers[0].read(ctx, itemPath(ids[0]), &item); err != nil || !exists {
+		t.Fatalf("item not found: %v", err)
+	}
+	if len(item.Assets) != len(indexers)*datasetsPerIndexer {
+		t.Errorf("expected %d assets, got %d", len(indexers)*datasetsPerIndexer, len(item.Assets))
+	}
+	collection := Collection{}
+	if exists, err := indexers[1].read(ctx, collectionPath("aoi"), &collection); err != nil || !exists {
+		t.Fatalf("collection not found: %v", err)
+	}
+	if layers := collection.Summaries[layerSummary]; len(layers) != len(indexers) {
+		t.Errorf("expected %d layers, got %v", len(indexers), layers)
+	}
+}
+
+func findLinks(links []Link, rel string) []Link {
+	var found []Link
+	for _, link := range links {
+		if link.Rel == rel {
+			found = append(found, link)
+		}
+	}
+	return found
+}
@@ -0,0 +1,100 @@
+package orbit
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/interface/provider"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+const (
+	ASFOrbitURL = "https://s1qc.asf.alaska.edu/aux_{type}/"
+	// asfListingTTL is the duration the listings of the orbit files are kept in memory
+	asfListingTTL = time.Hour
+)
+
+var asfFileRegexp = regexp.MustCompile(`href="(?:[^"]*/)?(S1[A-Z]_OPER_AUX_(?:POEORB|RESORB)_OPOD_\d{8}T\d{6}_V\d{8}T\d{6}_\d{8}T\d{6}\.EOF)"`)
+
+type asfListing struct {
+	names   []string
+	expires time.Time
+}
+
+// ASFProvider implements Provider using the orbit files of the Alaska Satellite Facility.
+// The listing of the files is kept in memory for an hour.
+type ASFProvider struct {
+	Token    string // Earthdata bearer token (optional)
+	URL      string // Default: ASFOrbitURL ({type} is replaced by the type of orbit in lower case)
+	listings map[Type]asfListing
+	mutex    sync.Mutex
+}
+
+// Name implements Provider
+func (p *ASFProvider) Name() string {
+	return "ASF"
+}
+
+func (p *ASFProvider) url(orbitType Type) string {
+	url := p.URL
+	if url == "" {
+		url = ASFOrbitURL
+	}
+	return strings.ReplaceAll(url, "{type}", strings.ToLower(string(orbitType)))
+}
+
+func (p *ASFProvider) authorization() string {
+	if p.Token == "" {
+		return ""
+	}
+	return "Bearer " + p.Token
+}
+
+// list returns the names of the orbit files of the given type
+func (p *ASFProvider) list(ctx context.Context, orbitType Type) ([]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if listing, ok := p.listings[orbitType]; ok && time.Now().Before(listing.expires) {
+		return listing.names, nil
+	}
+
+	log.Logger(ctx).Sugar().Debugf("[ASF] List orbit files %s", p.url(orbitType))
+	body, err := get(ctx, p.url(orbitType), p.authorization(), provider.ASFAuthDomains)
+	if err != nil {
+		return nil, fmt.Errorf("list.%w", err)
+	}
+	var names []string
+	for _, m := range asfFileRegexp.FindAllSubmatch(body, -1) {
+		names = append(names, string(m[1]))
+	}
+	if p.listings == nil {
+		p.listings = map[Type]asfListing{}
+	}
+	p.listings[orbitType] = asfListing{names: names, expires: time.Now().Add(asfListingTTL)}
+	return names, nil
+}
+
+// Fetch implements Provider
+func (p *ASFProvider) Fetch(ctx context.Context, sceneID string, orbitType Type) (File, error) {
+	mission, start, stop, err := sceneInfo(sceneID)
+	if err != nil {
+		return File{}, fmt.Errorf("ASFProvider.Fetch.%w", err)
+	}
+	names, err := p.list(ctx, orbitType)
+	if err != nil {
+		return File{}, fmt.Errorf("ASFProvider.Fetch.%w", err)
+	}
+	name, ok := SelectFile(names, mission, orbitType, start, stop)
+	if !ok {
+		return File{}, ErrNotFound
+	}
+	data, err := get(ctx, p.url(orbitType)+name, p.authorization(), provider.ASFAuthDomains)
+	if err != nil {
+		return File{}, fmt.Errorf("ASFProvider.Fetch.%w", err)
+	}
+	return File{Name: name, Data: data}, nil
+}
@@ -0,0 +1,90 @@
+package orbit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/airbusgeo/geocube-ingester/interface/provider"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+const (
+	CopernicusSearchURL  = "https://catalogue.dataspace.copernicus.eu/odata/v1/Products"
+	copernicusTimeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// TokenSource provides a bearer token (e.g. provider.CopernicusImageProvider)
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// CopernicusProvider implements Provider using the OData API of the Copernicus Data Space Ecosystem
+type CopernicusProvider struct {
+	Tokens    TokenSource
+	SearchURL string // Default: CopernicusSearchURL (the files are downloaded from <SearchURL>(<Id>)/$value)
+}
+
+// Name implements Provider
+func (p *CopernicusProvider) Name() string {
+	return "Copernicus"
+}
+
+// Fetch implements Provider
+func (p *CopernicusProvider) Fetch(ctx context.Context, sceneID string, orbitType Type) (File, error) {
+	mission, start, stop, err := sceneInfo(sceneID)
+	if err != nil {
+		return File{}, fmt.Errorf("CopernicusProvider.Fetch.%w", err)
+	}
+	searchURL := p.SearchURL
+	if searchURL == "" {
+		searchURL = CopernicusSearchURL
+	}
+
+	// Search the orbit files covering the scene
+	query := neturl.Values{}
+	query.Set("$filter", fmt.Sprintf("startswith(Name,'%s_OPER_AUX_%s_OPOD_') and ContentDate/Start lt %s and ContentDate/End gt %s",
+		mission, orbitType, start.Add(-ValidityMargin).Format(copernicusTimeFormat), stop.Add(ValidityMargin).Format(copernicusTimeFormat)))
+	query.Set("$orderby", "ContentDate/Start desc")
+	query.Set("$top", "20")
+	log.Logger(ctx).Sugar().Debugf("[Copernicus] Search orbit %s", query.Encode())
+	body, err := get(ctx, searchURL+"?"+query.Encode(), "", nil)
+	if err != nil {
+		return File{}, fmt.Errorf("CopernicusProvider.Fetch.%w", err)
+	}
+	results := struct {
+		Value []struct {
+			ID   string `json:"Id"`
+			Name string `json:"Name"`
+		} `json:"value"`
+	}{}
+	if err := json.Unmarshal(body, &results); err != nil {
+		return File{}, fmt.Errorf("CopernicusProvider.Fetch.Unmarshal: %w", err)
+	}
+	names := make([]string, len(results.Value))
+	ids := map[string]string{}
+	for i, r := range results.Value {
+		names[i] = r.Name
+		ids[r.Name] = r.ID
+	}
+	name, ok := SelectFile(names, mission, orbitType, start, stop)
+	if !ok {
+		return File{}, ErrNotFound
+	}
+
+	// Download the orbit file (as a zip)
+	token, err := p.Tokens.Token()
+	if err != nil {
+		return File{}, fmt.Errorf("CopernicusProvider.Fetch.%w", err)
+	}
+	data, err := get(ctx, searchURL+"("+ids[name]+")/$value", "Bearer "+token, provider.CopernicusAuthDomains)
+	if err != nil {
+		return File{}, fmt.Errorf("CopernicusProvider.Fetch.%w", err)
+	}
+	if data, err = unzipEOF(data); err != nil {
+		return File{}, fmt.Errorf("CopernicusProvider.Fetch[%s].%w", name, err)
+	}
+	return File{Name: strings.TrimSuffix(name, ".EOF") + ".EOF", Data: data}, nil
+}
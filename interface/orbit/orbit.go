@@ -0,0 +1,322 @@
+package orbit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/common"
+	"github.com/airbusgeo/geocube-ingester/service"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+// Type of orbit file
+type Type string
+
+const (
+	POEORB Type = "POEORB" // Precise orbit ephemerides (available ~20 days after the acquisition)
+	RESORB Type = "RESORB" // Restituted orbit (available a few hours after the acquisition)
+)
+
+// ValidityMargin is the margin required before the start and after the end of the acquisition of the scene
+const ValidityMargin = time.Minute
+
+var (
+	// ErrNotFound is returned by a Provider if no orbit file of the requested type covers the scene
+	ErrNotFound = errors.New("orbit file not found")
+	// ErrNotAvailable is returned by Manager.Fetch if no orbit file of the required type is available (yet)
+	ErrNotAvailable = errors.New("orbit file not available")
+)
+
+// ParseType parses the orbit type required by an area ("", POEORB or RESORB)
+func ParseType(t string) (Type, error) {
+	switch Type(strings.ToUpper(t)) {
+	case "":
+		return "", nil
+	case POEORB:
+		return POEORB, nil
+	case RESORB:
+		return RESORB, nil
+	}
+	return "", fmt.Errorf("ParseType: unknown orbit type '%s' (must be POEORB or RESORB)", t)
+}
+
+// File is an orbit file
+type File struct {
+	Name string // Name of the file (e.g. S1A_OPER_AUX_POEORB_OPOD_20210317T121815_V20210224T225942_20210226T005942.EOF)
+	Data []byte
+}
+
+// FileInfo is parsed from the name of an orbit file
+type FileInfo struct {
+	Mission       string // S1A, S1B...
+	Type          Type
+	Production    time.Time
+	ValidityStart time.Time
+	ValidityStop  time.Time
+}
+
+var fileNameRegexp = regexp.MustCompile(`^(S1[A-Z])_OPER_AUX_(POEORB|RESORB)_OPOD_(\d{8}T\d{6})_V(\d{8}T\d{6})_(\d{8}T\d{6})(\.EOF)?$`)
+
+const fileNameTimeFormat = "20060102T150405"
+
+// ParseFileName parses the name of an orbit file: MMM_OPER_AUX_TTTTTT_OPOD_<production>_V<validity start>_<validity stop>.EOF
+func ParseFileName(name string) (FileInfo, error) {
+	m := fileNameRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return FileInfo{}, fmt.Errorf("ParseFileName: invalid orbit file name: %s", name)
+	}
+	info := FileInfo{Mission: m[1], Type: Type(m[2])}
+	var err error
+	for i, t := range []*time.Time{&info.Production, &info.ValidityStart, &info.ValidityStop} {
+		if *t, err = time.Parse(fileNameTimeFormat, m[3+i]); err != nil {
+			return FileInfo{}, fmt.Errorf("ParseFileName[%s]: %w", name, err)
+		}
+	}
+	return info, nil
+}
+
+// SelectFile returns the name of the most recent file of the given type and mission whose validity covers [start-ValidityMargin, stop+ValidityMargin]
+// The names that cannot be parsed are ignored.
+func SelectFile(names []string, mission string, orbitType Type, start, stop time.Time) (string, bool) {
+	var selected string
+	var production time.Time
+	for _, name := range names {
+		info, err := ParseFileName(name)
+		if err != nil || info.Mission != mission || info.Type != orbitType {
+			continue
+		}
+		if info.ValidityStart.After(start.Add(-ValidityMargin)) || info.ValidityStop.Before(stop.Add(ValidityMargin)) {
+			continue
+		}
+		if selected == "" || info.Production.After(production) {
+			selected, production = name, info.Production
+		}
+	}
+	return selected, selected != ""
+}
+
+// sceneInfo returns the mission and the acquisition period of a Sentinel-1 scene
+func sceneInfo(sceneID string) (string, time.Time, time.Time, error) {
+	if common.GetConstellationFromProductId(sceneID) != common.Sentinel1 {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("sceneInfo: not a Sentinel-1 scene: %s", sceneID)
+	}
+	if len(sceneID) < len("MMM_BB_TTTR_LFPP_YYYYMMDDTHHMMSS_YYYYMMDDTHHMMSS") {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("sceneInfo: invalid Sentinel1 file name: %s", sceneID)
+	}
+	start, err := time.Parse(fileNameTimeFormat, sceneID[17:32])
+	if err != nil {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("sceneInfo[%s]: %w", sceneID, err)
+	}
+	stop, err := time.Parse(fileNameTimeFormat, sceneID[33:48])
+	if err != nil {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("sceneInfo[%s]: %w", sceneID, err)
+	}
+	return sceneID[0:3], start, stop, nil
+}
+
+// Provider provides the orbit files of the Sentinel-1 scenes
+type Provider interface {
+	Name() string
+	// Fetch returns the most recent orbit file of the given type covering the acquisition of the scene, or ErrNotFound
+	Fetch(ctx context.Context, sceneID string, orbitType Type) (File, error)
+}
+
+// Manager fetches the orbit files of the scenes from a list of providers.
+// The files fetched from the providers are stored in the mirror (optional) and fetched from it first.
+type Manager struct {
+	Providers []Provider
+	Mirror    *StorageMirror
+}
+
+// AuxdataPath returns the path of the orbit file in the layout of the auxdata directory of SNAP (Orbits/Sentinel-1/<TYPE>/<S1x>/<YYYY>/<MM>/<file>),
+// where the Apply-Orbit-File operator looks for it (orbitType "Sentinel Precise" or "Sentinel Restituted") instead of downloading it.
+// The month is the month of the acquisition of the scene.
+func AuxdataPath(auxdataDir, sceneID string, orbitType Type, fileName string) (string, error) {
+	mission, start, _, err := sceneInfo(sceneID)
+	if err != nil {
+		return "", fmt.Errorf("AuxdataPath.%w", err)
+	}
+	return filepath.Join(auxdataDir, "Orbits", "Sentinel-1", string(orbitType), mission, start.Format("2006"), start.Format("01"), fileName), nil
+}
+
+// Fetch fetches the best orbit file available for the Sentinel-1 scene (POEORB, then RESORB if the required type is not POEORB)
+// and writes it in auxdataDir, with the layout of the auxdata of SNAP (see AuxdataPath).
+// It returns the path of the file and its type, or an empty path if no file is available and required is empty.
+// If no file of the required type (POEORB, or at least RESORB) is available, it returns ErrNotAvailable.
+func (m *Manager) Fetch(ctx context.Context, sceneID string, required Type, auxdataDir string) (string, Type, error) {
+	types := []Type{POEORB}
+	if required != POEORB {
+		types = append(types, RESORB)
+	}
+
+	for _, orbitType := range types {
+		file, err := m.fetch(ctx, sceneID, orbitType)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("Fetch[%s].%w", sceneID, err)
+		}
+		path, err := AuxdataPath(auxdataDir, sceneID, orbitType, file.Name)
+		if err != nil {
+			return "", "", fmt.Errorf("Fetch[%s].%w", sceneID, err)
+		}
+		if err := writeFile(path, file.Data); err != nil {
+			return "", "", service.MakeTemporary(fmt.Errorf("Fetch[%s].%w", sceneID, err))
+		}
+		return path, orbitType, nil
+	}
+
+	if required != "" {
+		return "", "", fmt.Errorf("Fetch[%s]: %w (%s required)", sceneID, ErrNotAvailable, required)
+	}
+	return "", "", nil
+}
+
+// writeFile writes the file atomically, as the auxdata directory may be shared by several downloaders
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("writeFile.MkdirAll: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writeFile.CreateTemp: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("writeFile.Write: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("writeFile.Close: %w", err)
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("writeFile.Chmod: %w", err)
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("writeFile.Rename: %w", err)
+	}
+	return nil
+}
+
+// fetch the orbit file from the mirror or from the first provider that has it (and stores it in the mirror)
+func (m *Manager) fetch(ctx context.Context, sceneID string, orbitType Type) (File, error) {
+	if m.Mirror != nil {
+		file, err := m.Mirror.Fetch(ctx, sceneID, orbitType)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			log.Logger(ctx).Sugar().Warnf("orbit.%v", err)
+		}
+	}
+
+	var err error
+	for _, provider := range m.Providers {
+		file, e := provider.Fetch(ctx, sceneID, orbitType)
+		if e == nil {
+			log.Logger(ctx).Sugar().Infof("%s orbit file of %s fetched from %s: %s", orbitType, sceneID, provider.Name(), file.Name)
+			if m.Mirror != nil {
+				if err := m.Mirror.Store(ctx, sceneID, orbitType, file); err != nil {
+					log.Logger(ctx).Sugar().Warnf("orbit.%v", err)
+				}
+			}
+			return file, nil
+		}
+		if !errors.Is(e, ErrNotFound) {
+			err = service.MergeErrors(false, err, fmt.Errorf("%s.%w", provider.Name(), e))
+		}
+	}
+	if err != nil {
+		return File{}, err
+	}
+	return File{}, ErrNotFound
+}
+
+// get the body of the url. Returns ErrNotFound if the status is 404
+// The Authorization header is only copied to the redirected requests to a host of authDomains (see service.CheckRedirectAndCopyAuth)
+func get(ctx context.Context, url, authorization string, authDomains []string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get.NewRequest: %w", err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	client := &http.Client{CheckRedirect: service.CheckRedirectAndCopyAuth(authDomains...)}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, service.MakeTemporary(fmt.Errorf("get[%s]: %w", url, err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("get[%s]: %w", url, ErrNotFound)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("get[%s]: %s: %s", url, resp.Status, body)
+		switch resp.StatusCode {
+		case 408, 429, 500, 502, 503, 504:
+			return nil, service.MakeTemporary(err)
+		}
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, service.MakeTemporary(fmt.Errorf("get[%s].ReadAll: %w", url, err))
+	}
+	return body, nil
+}
+
+// unzipEOF returns the first .EOF file of the archive, or the data if it is not an archive
+func unzipEOF(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return data, nil
+	}
+	zipf, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("unzipEOF.NewReader: %w", err)
+	}
+	for _, f := range zipf.File {
+		if strings.HasSuffix(strings.ToUpper(f.Name), ".EOF") {
+			fr, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("unzipEOF.Open[%s]: %w", f.Name, err)
+			}
+			defer fr.Close()
+			if data, err = io.ReadAll(fr); err != nil {
+				return nil, fmt.Errorf("unzipEOF.ReadAll[%s]: %w", f.Name, err)
+			}
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("unzipEOF: no EOF file found in the archive")
+}
+
+// fileName returns the name of the orbit file from its header (Earth_Explorer_Header/Fixed_Header/File_Name)
+func fileName(data []byte) (string, error) {
+	header := struct {
+		FileName string `xml:"Earth_Explorer_Header>Fixed_Header>File_Name"`
+	}{}
+	if err := xml.Unmarshal(data, &header); err != nil {
+		return "", fmt.Errorf("fileName.Unmarshal: %w", err)
+	}
+	name := strings.TrimSpace(header.FileName)
+	if _, err := ParseFileName(name); err != nil {
+		return "", fmt.Errorf("fileName.%w", err)
+	}
+	return strings.TrimSuffix(name, ".EOF") + ".EOF", nil
+}
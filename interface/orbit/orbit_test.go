@@ -0,0 +1,281 @@
+package orbit
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testScene  = "S1A_IW_SLC__1SDV_20210225T060000_20210225T060027_036589_044D5A_1A2B"
+	testPOEORB = "S1A_OPER_AUX_POEORB_OPOD_20210317T121815_V20210224T225942_20210226T005942.EOF"
+	testRESORB = "S1A_OPER_AUX_RESORB_OPOD_20210225T083622_V20210225T043410_20210225T075140.EOF"
+)
+
+func testFileContent(name string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0"?>
+<Earth_Explorer_File>
+  <Earth_Explorer_Header>
+    <Fixed_Header>
+      <File_Name>%s</File_Name>
+    </Fixed_Header>
+  </Earth_Explorer_Header>
+</Earth_Explorer_File>`, strings.TrimSuffix(name, ".EOF")))
+}
+
+func TestParseFileName(t *testing.T) {
+	info, err := ParseFileName(testPOEORB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := FileInfo{
+		Mission:       "S1A",
+		Type:          POEORB,
+		Production:    time.Date(2021, 3, 17, 12, 18, 15, 0, time.UTC),
+		ValidityStart: time.Date(2021, 2, 24, 22, 59, 42, 0, time.UTC),
+		ValidityStop:  time.Date(2021, 2, 26, 0, 59, 42, 0, time.UTC),
+	}
+	if info != expected {
+		t.Errorf("expecting %v, got %v", expected, info)
+	}
+	for _, name := range []string{"S1A_OPER_AUX_POEORB_OPOD_20210317T121815.EOF", "S1A_OPER_AUX_PREORB_OPOD_20210317T121815_V20210224T225942_20210226T005942.EOF", ""} {
+		if _, err := ParseFileName(name); err == nil {
+			t.Errorf("%s: expecting an error", name)
+		}
+	}
+}
+
+func TestSelectFile(t *testing.T) {
+	start, stop := time.Date(2021, 2, 25, 6, 0, 0, 0, time.UTC), time.Date(2021, 2, 25, 6, 0, 27, 0, time.UTC)
+	newerPOEORB := "S1A_OPER_AUX_POEORB_OPOD_20210318T121815_V20210224T225942_20210226T005942.EOF"
+	names := []string{
+		"S1B_OPER_AUX_POEORB_OPOD_20210317T121815_V20210224T225942_20210226T005942.EOF", // Other mission
+		"S1A_OPER_AUX_POEORB_OPOD_20210316T121815_V20210223T225942_20210225T005942.EOF", // Other day
+		testPOEORB,
+		newerPOEORB,
+		testRESORB,
+		"index.html",
+	}
+	for _, c := range []struct {
+		orbitType   Type
+		start, stop time.Time
+		expected    string
+	}{
+		{POEORB, start, stop, newerPOEORB},
+		{RESORB, start, stop, testRESORB},
+		{RESORB, start.Add(2 * time.Hour), stop.Add(2 * time.Hour), ""},                                             // Not covered
+		{POEORB, time.Date(2021, 2, 26, 0, 59, 0, 0, time.UTC), time.Date(2021, 2, 26, 0, 59, 20, 0, time.UTC), ""}, // Margin not covered
+	} {
+		selected, ok := SelectFile(names, "S1A", c.orbitType, c.start, c.stop)
+		if selected != c.expected || ok != (c.expected != "") {
+			t.Errorf("%s %v: expecting %s, got %s", c.orbitType, c.start, c.expected, selected)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	for _, c := range []struct {
+		orbitType string
+		expected  Type
+		err       bool
+	}{
+		{"", "", false},
+		{"poeorb", POEORB, false},
+		{"RESORB", RESORB, false},
+		{"PREORB", "", true},
+	} {
+		if orbitType, err := ParseType(c.orbitType); orbitType != c.expected || (err != nil) != c.err {
+			t.Errorf("%s: expecting %s (err: %v), got %s (err: %v)", c.orbitType, c.expected, c.err, orbitType, err)
+		}
+	}
+}
+
+// testProvider provides the files of its list
+type testProvider struct {
+	files   []string
+	fetches int
+}
+
+func (p *testProvider) Name() string {
+	return "test"
+}
+
+func (p *testProvider) Fetch(ctx context.Context, sceneID string, orbitType Type) (File, error) {
+	p.fetches++
+	mission, start, stop, err := sceneInfo(sceneID)
+	if err != nil {
+		return File{}, err
+	}
+	name, ok := SelectFile(p.files, mission, orbitType, start, stop)
+	if !ok {
+		return File{}, ErrNotFound
+	}
+	return File{Name: name, Data: testFileContent(name)}, nil
+}
+
+func TestManagerFetch(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		files        []string
+		required     Type
+		expectedFile string
+		expectedType Type
+		notAvailable bool
+	}{
+		{[]string{testPOEORB, testRESORB}, "", testPOEORB, POEORB, false},
+		{[]string{testRESORB}, "", testRESORB, RESORB, false},
+		{[]string{testRESORB}, RESORB, testRESORB, RESORB, false},
+		{[]string{testRESORB}, POEORB, "", "", true}, // Waiting for the POEORB
+		{nil, RESORB, "", "", true},
+		{nil, "", "", "", false}, // Optional
+	} {
+		dir := t.TempDir()
+		m := Manager{Providers: []Provider{&testProvider{files: c.files}}}
+		path, orbitType, err := m.Fetch(ctx, testScene, c.required, dir)
+		if c.notAvailable {
+			if !errors.Is(err, ErrNotAvailable) {
+				t.Errorf("%v %s: expecting ErrNotAvailable, got %v", c.files, c.required, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %s: %v", c.files, c.required, err)
+			continue
+		}
+		if orbitType != c.expectedType {
+			t.Errorf("%v %s: expecting %s, got %s", c.files, c.required, c.expectedType, orbitType)
+		}
+		if c.expectedFile == "" {
+			if path != "" {
+				t.Errorf("%v %s: expecting no file, got %s", c.files, c.required, path)
+			}
+			continue
+		}
+		if expected := filepath.Join(dir, "Orbits", "Sentinel-1", string(c.expectedType), testScene[0:3], testScene[17:21], testScene[21:23], c.expectedFile); path != expected {
+			t.Errorf("%v %s: expecting %s, got %s", c.files, c.required, expected, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestManagerFetchMirror(t *testing.T) {
+	ctx := context.Background()
+	mirror, err := NewStorageMirror(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &testProvider{files: []string{testPOEORB}}
+	m := Manager{Providers: []Provider{provider}, Mirror: mirror}
+
+	// The first fetch stores the file in the mirror, the second one reads it from the mirror
+	for i := 0; i < 2; i++ {
+		path, _, err := m.Fetch(ctx, testScene, POEORB, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(path) != testPOEORB {
+			t.Errorf("expecting %s, got %s", testPOEORB, filepath.Base(path))
+		}
+	}
+	if provider.fetches != 1 {
+		t.Errorf("expecting 1 fetch from the provider, got %d", provider.fetches)
+	}
+}
+
+func TestASFProvider(t *testing.T) {
+	var listings int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/aux_poeorb/":
+			listings++
+			fmt.Fprintf(w, `<html><body><a href="%s">%s</a><a href="%s">%s</a></body></html>`, testPOEORB, testPOEORB, testRESORB, testRESORB)
+		case "/aux_resorb/":
+			listings++
+			fmt.Fprint(w, `<html><body></body></html>`)
+		case "/aux_poeorb/" + testPOEORB:
+			w.Write(testFileContent(testPOEORB))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &ASFProvider{URL: server.URL + "/aux_{type}/"}
+	for i := 0; i < 2; i++ {
+		file, err := p.Fetch(context.Background(), testScene, POEORB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.Name != testPOEORB || !bytes.Equal(file.Data, testFileContent(testPOEORB)) {
+			t.Errorf("wrong file: %s", file.Name)
+		}
+	}
+	if _, err := p.Fetch(context.Background(), testScene, RESORB); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expecting ErrNotFound, got %v", err)
+	}
+	if listings != 2 {
+		t.Errorf("expecting 2 listings, got %d", listings)
+	}
+}
+
+type testTokens struct{}
+
+func (testTokens) Token() (string, error) {
+	return "token", nil
+}
+
+func TestCopernicusProvider(t *testing.T) {
+	// Orbit files are delivered as zip
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	f, err := w.Create(testPOEORB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(testFileContent(testPOEORB))
+	w.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/Products":
+			filter := r.URL.Query().Get("$filter")
+			if strings.Contains(filter, "S1A_OPER_AUX_POEORB_OPOD_") {
+				fmt.Fprintf(w, `{"value":[{"Id":"1234","Name":"%s"}]}`, testPOEORB)
+			} else {
+				fmt.Fprint(w, `{"value":[]}`)
+			}
+		case "/Products(1234)/$value":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write(buf.Bytes())
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &CopernicusProvider{Tokens: testTokens{}, SearchURL: server.URL + "/Products"}
+	file, err := p.Fetch(context.Background(), testScene, POEORB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != testPOEORB || !bytes.Equal(file.Data, testFileContent(testPOEORB)) {
+		t.Errorf("wrong file: %s", file.Name)
+	}
+	if _, err := p.Fetch(context.Background(), testScene, RESORB); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expecting ErrNotFound, got %v", err)
+	}
+}
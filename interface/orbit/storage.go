@@ -0,0 +1,66 @@
+package orbit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/airbusgeo/geocube/interface/storage"
+	"github.com/airbusgeo/geocube/interface/storage/uri"
+)
+
+// StorageMirror stores the orbit files of the scenes in a storage (local, gs or s3), shared between the downloaders:
+//   - <root>/<type>/<scene>.EOF
+//
+// The name of the orbit file is read from its header.
+type StorageMirror struct {
+	storage storage.Strategy
+	root    string
+}
+
+// NewStorageMirror creates a mirror of orbit files in mirrorURI
+func NewStorageMirror(ctx context.Context, mirrorURI string) (*StorageMirror, error) {
+	u, err := uri.ParseUri(mirrorURI)
+	if err != nil {
+		return nil, fmt.Errorf("NewStorageMirror.ParseUri: %w", err)
+	}
+	strategy, err := u.NewStorageStrategy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("NewStorageMirror: %w", err)
+	}
+	return &StorageMirror{storage: strategy, root: strings.TrimSuffix(mirrorURI, "/") + "/"}, nil
+}
+
+// Name implements Provider
+func (m *StorageMirror) Name() string {
+	return "mirror"
+}
+
+func (m *StorageMirror) file(sceneID string, orbitType Type) string {
+	return m.root + string(orbitType) + "/" + sceneID + ".EOF"
+}
+
+// Fetch implements Provider
+func (m *StorageMirror) Fetch(ctx context.Context, sceneID string, orbitType Type) (File, error) {
+	data, err := m.storage.Download(ctx, m.file(sceneID, orbitType))
+	if err != nil {
+		if errors.Is(err, storage.ErrFileNotFound) {
+			return File{}, ErrNotFound
+		}
+		return File{}, fmt.Errorf("StorageMirror.Fetch[%s].Download: %w", sceneID, err)
+	}
+	name, err := fileName(data)
+	if err != nil {
+		return File{}, fmt.Errorf("StorageMirror.Fetch[%s].%w", sceneID, err)
+	}
+	return File{Name: name, Data: data}, nil
+}
+
+// Store the orbit file of the scene
+func (m *StorageMirror) Store(ctx context.Context, sceneID string, orbitType Type, file File) error {
+	if err := m.storage.Upload(ctx, m.file(sceneID, orbitType), file.Data); err != nil {
+		return fmt.Errorf("StorageMirror.Store[%s].Upload: %w", sceneID, err)
+	}
+	return nil
+}
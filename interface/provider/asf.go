@@ -22,23 +22,31 @@ func (ip *ASFImageProvider) Name() string {
 	return "ASF"
 }
 
+// ASFAuthDomains are the domains trusted to receive the token when a download is redirected (e.g. to Earthdata login)
+var ASFAuthDomains = []string{"asf.alaska.edu", "earthdata.nasa.gov"}
+
+// AuthDomains returns the domains trusted to receive the Authorization header when the download of an archive is redirected
+func (ip *ASFImageProvider) AuthDomains() []string {
+	return ASFAuthDomains
+}
+
 // NewASFImageProvider creates a new ImageProvider from ASF
 func NewASFImageProvider(token string) *ASFImageProvider {
 	return &ASFImageProvider{token: token}
 }
 
-// Download implements ImageProvider
-func (ip *ASFImageProvider) Download(ctx context.Context, scene common.Scene, localDir string) error {
+// ArchiveURL returns the url of the archive of the scene and the value of the Authorization header to download it
+func (ip *ASFImageProvider) ArchiveURL(scene common.Scene) (string, string, error) {
 	sceneName := scene.SourceID
 	switch common.GetConstellationFromProductId(sceneName) {
 	case common.Sentinel1:
 	default:
-		return fmt.Errorf("ASFImageProvider: constellation not supported")
+		return "", "", fmt.Errorf("ASFImageProvider: constellation not supported")
 	}
 
 	info, err := common.Info(sceneName)
 	if err != nil {
-		return fmt.Errorf("ASFImageProvider.%w", err)
+		return "", "", fmt.Errorf("ASFImageProvider.%w", err)
 	}
 	var url string
 	switch info["PRODUCT_TYPE"] {
@@ -47,12 +55,19 @@ func (ip *ASFImageProvider) Download(ctx context.Context, scene common.Scene, lo
 	case "GRD":
 		url = ASFDownloadProductGRD
 	default:
-		return fmt.Errorf("ASFImageProvider: not supported product type: %s", info["PRODUCT_TYPE"])
+		return "", "", fmt.Errorf("ASFImageProvider: not supported product type: %s", info["PRODUCT_TYPE"])
+	}
+	return common.FormatBrackets(url, info), "Bearer " + ip.token, nil
+}
+
+// Download implements ImageProvider
+func (ip *ASFImageProvider) Download(ctx context.Context, scene common.Scene, localDir string) error {
+	url, token, err := ip.ArchiveURL(scene)
+	if err != nil {
+		return fmt.Errorf("Download.%w", err)
 	}
-	url = common.FormatBrackets(url, info)
 
-	token := "Bearer " + ip.token
-	if err = downloadZipWithAuth(ctx, url, localDir, sceneName, ip.Name(), nil, nil, "Authorization", &token, true); err != nil {
+	if err = downloadZipWithAuth(ctx, url, localDir, scene.SourceID, ip.Name(), nil, nil, "Authorization", &token, ASFAuthDomains); err != nil {
 		return fmt.Errorf("ASFImageProvider.%w", err)
 	}
 	return nil
@@ -97,7 +97,8 @@ func displayProgress(ctx context.Context, prefix string, resp *grab.Response, ev
 	}
 }
 
-func downloadZipWithAuth(ctx context.Context, url, localDir, sceneName, provider string, user, pword *string, header_key string, header_value *string, copyAuthOnRedirect bool) error {
+// If authDomains is not nil, the Authorization header is copied when redirected to a host of these domains (see service.CheckRedirectAndCopyAuth)
+func downloadZipWithAuth(ctx context.Context, url, localDir, sceneName, provider string, user, pword *string, header_key string, header_value *string, authDomains []string) error {
 	localZip := sceneFilePath(localDir, sceneName, service.ExtensionZIP)
 	req, err := grab.NewRequest(localZip, url)
 	if err != nil {
@@ -115,7 +116,7 @@ func downloadZipWithAuth(ctx context.Context, url, localDir, sceneName, provider
 		req.HTTPRequest.Header.Add(header_key, *header_value)
 	}
 
-	if err := download(ctx, req, provider+":"+sceneName, copyAuthOnRedirect); err != nil {
+	if err := download(ctx, req, provider+":"+sceneName, authDomains); err != nil {
 		return fmt.Errorf("downloadZipWithAuth.%w", err)
 	}
 
@@ -126,21 +127,11 @@ func downloadZipWithAuth(ctx context.Context, url, localDir, sceneName, provider
 	return nil
 }
 
-func checkRedirectAndCopyAuth(req *http.Request, via []*http.Request) error {
-	if len(via) >= 10 {
-		return fmt.Errorf("stopped after 10 redirects")
-	}
-	if auth, ok := via[0].Header["Authorization"]; ok {
-		req.Header.Add("Authorization", auth[0])
-	}
-	return nil
-}
-
 // download a file with display every 5%
-func download(ctx context.Context, req *grab.Request, displayPrefix string, copyAuthOnRedirect bool) error {
+func download(ctx context.Context, req *grab.Request, displayPrefix string, authDomains []string) error {
 	client := grab.NewClient()
-	if copyAuthOnRedirect {
-		client.HTTPClient.CheckRedirect = checkRedirectAndCopyAuth
+	if authDomains != nil {
+		client.HTTPClient.CheckRedirect = service.CheckRedirectAndCopyAuth(authDomains...)
 	}
 	resp := client.Do(req)
 
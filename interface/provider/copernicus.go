@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/airbusgeo/geocube-ingester/common"
@@ -15,12 +16,16 @@ import (
 const copernicusDownloadProduct = "https://catalogue.dataspace.copernicus.eu/odata/v1/Products(%s)/$value"
 const copernicusAuth = "https://identity.dataspace.copernicus.eu/auth/realms/CDSE/protocol/openid-connect/token"
 
+// CopernicusAuthDomains are the domains trusted to receive the token when a download is redirected
+var CopernicusAuthDomains = []string{"dataspace.copernicus.eu"}
+
 // CopernicusImageProvider implements ImageProvider for Copernicus
 type CopernicusImageProvider struct {
 	user   string
 	pword  string
 	token  string
 	expire time.Time
+	mutex  sync.Mutex
 }
 
 // Name implements ImageProvider
@@ -63,36 +68,56 @@ func (ip *CopernicusImageProvider) LoadCopernicusToken() error {
 	return nil
 }
 
-// NewCopernicusImageProvider creates a new ImageProvider from Copernicus
-func NewCopernicusImageProvider(user, pword string) *CopernicusImageProvider {
-	return &CopernicusImageProvider{user: user, pword: pword}
-
+// Token returns a valid download token, loading a new one if it is expired
+func (ip *CopernicusImageProvider) Token() (string, error) {
+	ip.mutex.Lock()
+	defer ip.mutex.Unlock()
+	if time.Now().After(ip.expire) || ip.token == "" {
+		if err := ip.LoadCopernicusToken(); err != nil {
+			return "", fmt.Errorf("Token.%w", err)
+		}
+	}
+	return ip.token, nil
 }
 
-// Download implements ImageProvider
-func (ip *CopernicusImageProvider) Download(ctx context.Context, scene common.Scene, localDir string) error {
-	sceneName := scene.SourceID
-	switch common.GetConstellationFromProductId(sceneName) {
-	case common.Sentinel1, common.Sentinel2:
+// ArchiveURL returns the url of the archive of the scene and the value of the Authorization header to download it
+func (ip *CopernicusImageProvider) ArchiveURL(scene common.Scene) (string, string, error) {
+	switch common.GetConstellationFromProductId(scene.SourceID) {
+	case common.Sentinel1, common.Sentinel2, common.Sentinel3:
 	default:
-		return fmt.Errorf("CopernicusImageProvider: constellation not supported")
+		return "", "", fmt.Errorf("CopernicusImageProvider: constellation not supported")
 	}
 	sceneUUID, ok := scene.Data.Metadata[common.UUIDMetadata]
 	if !ok {
-		return fmt.Errorf("CopernicusImageProvider: uuid not found in metadata")
+		return "", "", fmt.Errorf("CopernicusImageProvider: uuid not found in metadata")
+	}
+
+	token, err := ip.Token()
+	if err != nil {
+		return "", "", fmt.Errorf("CopernicusImageProvider.%w", err)
 	}
+	return fmt.Sprintf(copernicusDownloadProduct, sceneUUID), "Bearer " + token, nil
+}
 
-	url := fmt.Sprintf(copernicusDownloadProduct, sceneUUID)
+// AuthDomains returns the domains trusted to receive the Authorization header when the download of an archive is redirected
+func (ip *CopernicusImageProvider) AuthDomains() []string {
+	return CopernicusAuthDomains
+}
 
-	// Load token
-	if time.Now().After(ip.expire) || ip.token == "" {
-		if err := ip.LoadCopernicusToken(); err != nil {
-			return fmt.Errorf("CopernicusImageProvider.Download.%w", err)
-		}
+// NewCopernicusImageProvider creates a new ImageProvider from Copernicus
+func NewCopernicusImageProvider(user, pword string) *CopernicusImageProvider {
+	return &CopernicusImageProvider{user: user, pword: pword}
+
+}
+
+// Download implements ImageProvider
+func (ip *CopernicusImageProvider) Download(ctx context.Context, scene common.Scene, localDir string) error {
+	url, token, err := ip.ArchiveURL(scene)
+	if err != nil {
+		return fmt.Errorf("Download.%w", err)
 	}
 
-	token := "Bearer " + ip.token
-	if err := downloadZipWithAuth(ctx, url, localDir, sceneName, ip.Name(), nil, nil, "Authorization", &token, true); err != nil {
+	if err := downloadZipWithAuth(ctx, url, localDir, scene.SourceID, ip.Name(), nil, nil, "Authorization", &token, CopernicusAuthDomains); err != nil {
 		return fmt.Errorf("CopernicusImageProvider.%w", err)
 	}
 	return nil
@@ -18,7 +18,7 @@ import (
 
 const (
 	landsatAwsBucket         = "usgs-landsat"
-	landsatAwsPrefixTemplate = "collection02/level-1/standard/%s/%s/%s/%s/%s/"
+	landsatAwsPrefixTemplate = "collection02/%s/standard/%s/%s/%s/%s/%s/" // level, sensor, year, path, row, scene
 	landsatAwsRegion         = "us-west-2"
 )
 
@@ -44,7 +44,7 @@ func (ip *LandsatAwsImageProvider) Download(ctx context.Context, scene common.Sc
 
 	sceneName := scene.SourceID
 	switch common.GetConstellationFromProductId(sceneName) {
-	case common.Landsat89:
+	case common.Landsat89, common.Landsat457:
 	default:
 		return fmt.Errorf("LandsatAwsImageProvider: constellation not supported")
 	}
@@ -54,12 +54,16 @@ func (ip *LandsatAwsImageProvider) Download(ctx context.Context, scene common.Sc
 		return fmt.Errorf("LandsatAwsImageProvider.common.Info: %w", err)
 	}
 
+	level := "level-1"
+	if strings.HasPrefix(info["PROCESSING_LEVEL"], "L2") {
+		level = "level-2"
+	}
 	sensorCollection := info["COLLECTION"]
 	landsatPath := info["PATH"]
 	landsatRow := info["ROW"]
 	year := info["YEAR"]
 
-	landsatAwsPrefix := fmt.Sprintf(landsatAwsPrefixTemplate, sensorCollection, year, landsatPath, landsatRow, sceneName)
+	landsatAwsPrefix := fmt.Sprintf(landsatAwsPrefixTemplate, level, sensorCollection, year, landsatPath, landsatRow, sceneName)
 
 	cfg, err := config.LoadDefaultConfig(context.Background(),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(ip.accessKeyId, ip.secretAccessKey, "")),
@@ -0,0 +1,190 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/airbusgeo/geocube-ingester/graph"
+	"github.com/airbusgeo/geocube-ingester/service"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+// COGValidation is the policy applied to the GeoTIFF outputs to be indexed, that are not Cloud Optimized GeoTIFF
+type COGValidation int
+
+// COGValidation
+const (
+	NoCOGValidation COGValidation = iota // Outputs are not validated
+	COGConvert                           // Outputs are converted in-place to COG
+	COGFail                              // Processing of the tile fails
+)
+
+const cogBlockSize = 512
+
+// COGValidationFromString returns the COGValidation from its name ("", "convert" or "fail")
+func COGValidationFromString(s string) (COGValidation, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return NoCOGValidation, nil
+	case "convert":
+		return COGConvert, nil
+	case "fail":
+		return COGFail, nil
+	}
+	return NoCOGValidation, fmt.Errorf("unknown cog validation policy: %s (expecting none, convert or fail)", s)
+}
+
+// validateCOG checks that the file has a CRS, a nodata consistent with the graph and a COG layout.
+// According to the policy, a file with a wrong layout or without nodata is converted in-place to COG or a fatal error is returned.
+func validateCOG(ctx context.Context, filename string, file graph.OutFile, policy COGValidation) error {
+	if policy == NoCOGValidation {
+		return nil
+	}
+	info, err := readCOGInfo(ctx, filename)
+	if err != nil {
+		return fmt.Errorf("validateCOG[%s].%w", file.Layer, err)
+	}
+	issues, err := cogIssues(info, file)
+	if err != nil || len(issues) == 0 {
+		return err
+	}
+
+	if policy == COGFail {
+		return service.MakeFatal(fmt.Errorf("validateCOG[%s]: the file is not a valid Cloud Optimized GeoTIFF: %s", file.Layer, strings.Join(issues, ", ")))
+	}
+	log.Logger(ctx).Sugar().Infof("convert layer '%s' to COG (%s)", file.Layer, strings.Join(issues, ", "))
+	if err := convertToCOG(ctx, filename, file.NoData); err != nil {
+		return fmt.Errorf("validateCOG[%s].%w", file.Layer, err)
+	}
+	return nil
+}
+
+// cogIssues returns the issues of the file that can be fixed by a conversion to COG (no nodata, wrong layout...)
+// or a fatal error if the file cannot be fixed (no CRS, nodata inconsistent with the graph).
+func cogIssues(info cogInfo, file graph.OutFile) ([]string, error) {
+	// Errors that cannot be fixed by a conversion
+	if !info.hasCRS {
+		return nil, service.MakeFatal(fmt.Errorf("validateCOG[%s]: the file has no CRS", file.Layer))
+	}
+	if !info.sameNoData {
+		return nil, service.MakeFatal(fmt.Errorf("validateCOG[%s]: the bands of the file have different nodata values", file.Layer))
+	}
+	if info.noData != nil && !graph.SameValue(*info.noData, file.NoData) {
+		return nil, service.MakeFatal(fmt.Errorf("validateCOG[%s]: the nodata of the file (%v) is different from the nodata of the graph (%v)", file.Layer, *info.noData, file.NoData))
+	}
+
+	// Issues that can be fixed by a conversion
+	var issues []string
+	if info.noData == nil {
+		issues = append(issues, "no nodata")
+	}
+	if !info.tiled {
+		issues = append(issues, "not tiled")
+	}
+	if info.missingOverviews {
+		issues = append(issues, "no overviews")
+	}
+	if info.layout != "COG" {
+		issues = append(issues, "not a COG layout")
+	}
+	return issues, nil
+}
+
+// convertToCOG converts the file in-place to a Cloud Optimized GeoTIFF, setting the nodata
+func convertToCOG(ctx context.Context, filename string, nodata float64) error {
+	tmpFile := filename + ".cog.tif"
+	defer os.Remove(tmpFile)
+	cmd := exec.CommandContext(ctx, "gdal_translate", "-q", "-of", "COG",
+		"-co", "BLOCKSIZE="+strconv.Itoa(cogBlockSize), "-co", "COMPRESS=DEFLATE", "-co", "BIGTIFF=IF_SAFER",
+		"-a_nodata", strconv.FormatFloat(nodata, 'g', -1, 64), filename, tmpFile)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("convertToCOG: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := os.Rename(tmpFile, filename); err != nil {
+		return fmt.Errorf("convertToCOG.Rename: %w", err)
+	}
+	return nil
+}
+
+type cogInfo struct {
+	hasCRS           bool
+	noData           *float64 // nodata of the first band
+	sameNoData       bool     // all the bands have the same nodata
+	tiled            bool
+	missingOverviews bool
+	layout           string
+}
+
+// readCOGInfo runs gdalinfo to get the structure of the file
+func readCOGInfo(ctx context.Context, filename string) (cogInfo, error) {
+	cmd := exec.CommandContext(ctx, "gdalinfo", "-json", filename)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return cogInfo{}, fmt.Errorf("readCOGInfo[%s]: %w (%s)", filename, err, strings.TrimSpace(stderr.String()))
+	}
+	info, err := parseCOGInfo(output)
+	if err != nil {
+		return cogInfo{}, fmt.Errorf("readCOGInfo[%s].%w", filename, err)
+	}
+	return info, nil
+}
+
+// parseCOGInfo parses the output of "gdalinfo -json"
+func parseCOGInfo(data []byte) (cogInfo, error) {
+	var gdalinfo struct {
+		Size             [2]int `json:"size"`
+		CoordinateSystem struct {
+			Wkt string `json:"wkt"`
+		} `json:"coordinateSystem"`
+		Metadata struct {
+			ImageStructure struct {
+				Layout string `json:"LAYOUT"`
+			} `json:"IMAGE_STRUCTURE"`
+		} `json:"metadata"`
+		Bands []struct {
+			Block       [2]int          `json:"block"`
+			NoDataValue json.RawMessage `json:"noDataValue"`
+			Overviews   []struct{}      `json:"overviews"`
+		} `json:"bands"`
+	}
+	if err := json.Unmarshal(data, &gdalinfo); err != nil {
+		return cogInfo{}, fmt.Errorf("parseCOGInfo: %w", err)
+	}
+	if len(gdalinfo.Bands) == 0 {
+		return cogInfo{}, fmt.Errorf("parseCOGInfo: no band found")
+	}
+
+	band := gdalinfo.Bands[0]
+	info := cogInfo{
+		hasCRS:     gdalinfo.CoordinateSystem.Wkt != "",
+		sameNoData: true,
+		tiled:      band.Block[0] < gdalinfo.Size[0] || band.Block[0] == band.Block[1],
+		layout:     gdalinfo.Metadata.ImageStructure.Layout,
+	}
+	info.missingOverviews = (gdalinfo.Size[0] > band.Block[0] || gdalinfo.Size[1] > band.Block[1]) && len(band.Overviews) == 0
+
+	var err error
+	if info.noData, err = graph.ParseGdalNoData(band.NoDataValue); err != nil {
+		return cogInfo{}, fmt.Errorf("parseCOGInfo.%w", err)
+	}
+	for _, b := range gdalinfo.Bands[1:] {
+		nodata, err := graph.ParseGdalNoData(b.NoDataValue)
+		if err != nil {
+			return cogInfo{}, fmt.Errorf("parseCOGInfo.%w", err)
+		}
+		if (nodata == nil) != (info.noData == nil) || (nodata != nil && !graph.SameValue(*nodata, *info.noData)) {
+			info.sameNoData = false
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/airbusgeo/geocube-ingester/graph"
+	"github.com/airbusgeo/geocube-ingester/service"
+)
+
+const testWKT = `PROJCRS[\"WGS 84 / UTM zone 31N\",BASEGEOGCRS[\"WGS 84\",DATUM[\"World Geodetic System 1984\",ELLIPSOID[\"WGS 84\",6378137,298.257223563]]],CONVERSION[\"UTM zone 31N\",METHOD[\"Transverse Mercator\"]],ID[\"EPSG\",32631]]`
+
+// gdalinfoJSON returns the output of "gdalinfo -json" (GDAL 3.6) for a 1024x1024 file
+func gdalinfoJSON(wkt, layout string, bands string) []byte {
+	metadata := `"metadata":{"":{"AREA_OR_POINT":"Area"},"IMAGE_STRUCTURE":{"COMPRESSION":"DEFLATE","INTERLEAVE":"BAND"}},`
+	if layout != "" {
+		metadata = fmt.Sprintf(`"metadata":{"":{"AREA_OR_POINT":"Area"},"IMAGE_STRUCTURE":{"COMPRESSION":"DEFLATE","INTERLEAVE":"BAND","LAYOUT":"%s"}},`, layout)
+	}
+	return []byte(fmt.Sprintf(`{
+  "description":"/workdir/20230103_S2A_1_B04.tif",
+  "driverShortName":"GTiff",
+  "driverLongName":"GeoTIFF",
+  "files":["/workdir/20230103_S2A_1_B04.tif"],
+  "size":[1024,1024],
+  "coordinateSystem":{"wkt":"%s","dataAxisToSRSAxisMapping":[1,2]},
+  "geoTransform":[300000.0,10.0,0.0,4900020.0,0.0,-10.0],
+  %s
+  "cornerCoordinates":{"upperLeft":[300000.0,4900020.0],"lowerRight":[310240.0,4889780.0]},
+  "bands":[%s]
+}`, wkt, metadata, bands))
+}
+
+const (
+	tiledBand       = `{"band":1,"block":[512,512],"type":"UInt16","colorInterpretation":"Gray","noDataValue":0,"overviews":[{"size":[512,512]}],"metadata":{}}`
+	tiledBandNoOvr  = `{"band":1,"block":[512,512],"type":"UInt16","colorInterpretation":"Gray","noDataValue":0,"metadata":{}}`
+	stripedBand     = `{"band":1,"block":[1024,2],"type":"UInt16","colorInterpretation":"Gray","noDataValue":0,"metadata":{}}`
+	tiledBandNoND   = `{"band":1,"block":[512,512],"type":"UInt16","colorInterpretation":"Gray","overviews":[{"size":[512,512]}],"metadata":{}}`
+	tiledBandNaN    = `{"band":1,"block":[512,512],"type":"Float32","colorInterpretation":"Gray","noDataValue":"nan","overviews":[{"size":[512,512]}],"metadata":{}}`
+	tiledBand2NaN   = `{"band":2,"block":[512,512],"type":"Float32","colorInterpretation":"Undefined","noDataValue":"nan","overviews":[{"size":[512,512]}],"metadata":{}}`
+	tiledBand2Other = `{"band":2,"block":[512,512],"type":"UInt16","colorInterpretation":"Undefined","noDataValue":65535,"overviews":[{"size":[512,512]}],"metadata":{}}`
+)
+
+func TestParseCOGInfo(t *testing.T) {
+	zero, nan := 0., math.NaN()
+	for _, c := range []struct {
+		name     string
+		gdalinfo []byte
+		expected cogInfo
+	}{
+		{"cog", gdalinfoJSON(testWKT, "COG", tiledBand), cogInfo{hasCRS: true, noData: &zero, sameNoData: true, tiled: true, layout: "COG"}},
+		{"tiled geotiff", gdalinfoJSON(testWKT, "", tiledBand), cogInfo{hasCRS: true, noData: &zero, sameNoData: true, tiled: true}},
+		{"striped", gdalinfoJSON(testWKT, "", stripedBand), cogInfo{hasCRS: true, noData: &zero, sameNoData: true, missingOverviews: true}},
+		{"missing overviews", gdalinfoJSON(testWKT, "", tiledBandNoOvr), cogInfo{hasCRS: true, noData: &zero, sameNoData: true, tiled: true, missingOverviews: true}},
+		{"no crs", gdalinfoJSON("", "COG", tiledBand), cogInfo{noData: &zero, sameNoData: true, tiled: true, layout: "COG"}},
+		{"no nodata", gdalinfoJSON(testWKT, "COG", tiledBandNoND), cogInfo{hasCRS: true, sameNoData: true, tiled: true, layout: "COG"}},
+		{"nan nodata", gdalinfoJSON(testWKT, "COG", tiledBandNaN+","+tiledBand2NaN), cogInfo{hasCRS: true, noData: &nan, sameNoData: true, tiled: true, layout: "COG"}},
+		{"different nodata", gdalinfoJSON(testWKT, "COG", tiledBand+","+tiledBand2Other), cogInfo{hasCRS: true, noData: &zero, tiled: true, layout: "COG"}},
+	} {
+		info, err := parseCOGInfo(c.gdalinfo)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if (info.noData == nil) != (c.expected.noData == nil) || (info.noData != nil && !graph.SameValue(*info.noData, *c.expected.noData)) {
+			t.Errorf("%s: expecting nodata %v, got %v", c.name, c.expected.noData, info.noData)
+		}
+		info.noData, c.expected.noData = nil, nil
+		if info != c.expected {
+			t.Errorf("%s: expecting %+v, got %+v", c.name, c.expected, info)
+		}
+	}
+
+	for _, gdalinfo := range []string{`{"size":[10,10],"bands":[]}`, `{"bands":[{"noDataValue":"none"}]}`, `not json`} {
+		if _, err := parseCOGInfo([]byte(gdalinfo)); err == nil {
+			t.Errorf("%s: expecting an error", gdalinfo)
+		}
+	}
+}
+
+func TestCOGIssues(t *testing.T) {
+	file := graph.OutFile{File: graph.File{Layer: "B04"}, NoData: 0}
+	nanFile := graph.OutFile{File: graph.File{Layer: "sigma0"}, NoData: math.NaN()}
+	for _, c := range []struct {
+		name     string
+		gdalinfo []byte
+		file     graph.OutFile
+		expected []string
+		fatal    bool
+	}{
+		{"cog", gdalinfoJSON(testWKT, "COG", tiledBand), file, nil, false},
+		{"nan nodata", gdalinfoJSON(testWKT, "COG", tiledBandNaN+","+tiledBand2NaN), nanFile, nil, false},
+		{"tiled geotiff", gdalinfoJSON(testWKT, "", tiledBand), file, []string{"not a COG layout"}, false},
+		{"striped", gdalinfoJSON(testWKT, "", stripedBand), file, []string{"not tiled", "no overviews", "not a COG layout"}, false},
+		{"missing overviews", gdalinfoJSON(testWKT, "", tiledBandNoOvr), file, []string{"no overviews", "not a COG layout"}, false},
+		{"no nodata", gdalinfoJSON(testWKT, "COG", tiledBandNoND), file, []string{"no nodata"}, false},
+		{"no crs", gdalinfoJSON("", "COG", tiledBand), file, nil, true},
+		{"nodata mismatch", gdalinfoJSON(testWKT, "COG", tiledBand), nanFile, nil, true},
+		{"different nodata", gdalinfoJSON(testWKT, "COG", tiledBand+","+tiledBand2Other), file, nil, true},
+	} {
+		info, err := parseCOGInfo(c.gdalinfo)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		issues, err := cogIssues(info, c.file)
+		if c.fatal {
+			if !service.Fatal(err) {
+				t.Errorf("%s: expecting a fatal error, got %v", c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(issues, c.expected) {
+			t.Errorf("%s: expecting %v, got %v", c.name, c.expected, issues)
+		}
+	}
+}
+
+func TestValidateCOGNoValidation(t *testing.T) {
+	// The file is not read if the outputs are not validated
+	if err := validateCOG(context.Background(), "/not/found.tif", graph.OutFile{}, NoCOGValidation); err != nil {
+		t.Error(err)
+	}
+}
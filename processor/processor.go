@@ -8,34 +8,35 @@ import (
 	"path/filepath"
 	"time"
 
-	geocube "github.com/airbusgeo/geocube-client-go/client"
-	geocubepb "github.com/airbusgeo/geocube-client-go/pb"
 	"github.com/airbusgeo/geocube-ingester/common"
 	"github.com/airbusgeo/geocube-ingester/graph"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
 	"github.com/airbusgeo/geocube-ingester/service"
 	"github.com/airbusgeo/geocube-ingester/service/log"
 	"github.com/google/uuid"
-	"google.golang.org/grpc/codes"
 )
 
 type outFileTile struct {
 	file graph.OutFile
 	tile common.Tile
+	size int64
 }
 
 // ProcessTile processes a tile.
-func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *geocube.Client, tile common.TileToProcess, workdir string, opts []graph.Option) error {
+// The GeoTIFF outputs to be indexed are validated according to cogValidation before being saved.
+// Returns the manifest of the layers created, indexed or deleted (even if an error occured)
+func ProcessTile(ctx context.Context, storageService service.Storage, idx indexer.Indexer, tile common.TileToProcess, workdir string, opts []graph.Option, cogValidation COGValidation) ([]common.TileOutput, error) {
 	tag := fmt.Sprintf("%s_%s", tile.Scene.Data.Date.Format("20060102"), tile.SourceID)
 
 	// Working dir
 	workdir = filepath.Join(workdir, uuid.New().String())
 
 	if err := os.MkdirAll(workdir, 0766); err != nil {
-		return service.MakeTemporary(fmt.Errorf("make directory %s: %w", workdir, err))
+		return nil, service.MakeTemporary(fmt.Errorf("make directory %s: %w", workdir, err))
 	}
 	defer os.RemoveAll(workdir)
 	if err := os.Chdir(workdir); err != nil {
-		return service.MakeTemporary(fmt.Errorf("chdir: %w", err))
+		return nil, service.MakeTemporary(fmt.Errorf("chdir: %w", err))
 	}
 
 	// Custom storage
@@ -43,7 +44,7 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 		var err error
 		storageService, err = service.NewStorageStrategy(ctx, tile.Scene.Data.StorageURI)
 		if err != nil {
-			return fmt.Errorf("ProcessTile[%s].%w", tag, err)
+			return nil, fmt.Errorf("ProcessTile[%s].%w", tag, err)
 		}
 	}
 
@@ -54,7 +55,7 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 	// Graph
 	g, config, envs, err := graph.LoadGraph(ctx, tile.Data.GraphName, opts...)
 	if err != nil {
-		return fmt.Errorf("ProcessTile[%s].%w", tag, err)
+		return nil, fmt.Errorf("ProcessTile[%s].%w", tag, err)
 	}
 	// Append the user config
 	for key, val := range tile.Scene.Data.GraphConfig {
@@ -65,16 +66,9 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 	config["workdir"] = workdir
 
 	// Input tiles
-	tiles := []common.Tile{tile.Tile}
-	if tile.Previous.SourceID == "" {
-		tiles = append(tiles, tiles[0])
-	} else {
-		tiles = append(tiles, tile.Previous)
-	}
-	if tile.Reference.SourceID == "" {
-		tiles = append(tiles, tiles[0])
-	} else {
-		tiles = append(tiles, tile.Reference)
+	tiles := tile.Tiles()
+	if len(g.InFiles) > len(tiles) {
+		return nil, service.MakeFatal(fmt.Errorf("ProcessTile[%s]: the graph expects %d input tiles, but only %d are provided", tag, len(g.InFiles), len(tiles)))
 	}
 
 	// Import input layers from storage
@@ -88,7 +82,7 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 				log.Logger(ctx).Sugar().Debugf("import layer '%s'", infile.Layer)
 				imported.Push(filename)
 				if err := storageService.ImportLayer(ctx, tiles[i], infile.Layer, infile.Extension, workdir); err != nil {
-					return fmt.Errorf("ProcessTile[%s].%w", tag, err)
+					return nil, fmt.Errorf("ProcessTile[%s].%w", tag, err)
 				}
 			}
 		}
@@ -99,7 +93,19 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 	outfiles, processErr := g.Process(ctx, config, envs, tiles)
 
 	// Handle outFiles
+	var outputs []common.TileOutput
 	outFileErr := func() error {
+		// Validate the COG layout of all the outputs to be indexed before saving any of them
+		for i, outtilefile := range outfiles {
+			for _, f := range outtilefile {
+				if f.Action == graph.ToIndex && f.Extension == service.ExtensionGTiff {
+					if err := validateCOG(ctx, filepath.Join(workdir, service.LayerFileName(tiles[i], f.Layer, f.Extension)), f, cogValidation); err != nil {
+						return fmt.Errorf("ProcessTile[%s].%w", tag, err)
+					}
+				}
+			}
+		}
+
 		toIndex := map[string]outFileTile{}
 		var toDelete []outFileTile
 		// Create file, delay indexation and deletion
@@ -113,9 +119,14 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 					if err != nil {
 						return fmt.Errorf("ProcessTile[%s].%w", tag, err)
 					}
+					size := fileSize(filepath.Join(workdir, service.LayerFileName(tiles[i], f.Layer, f.Extension)))
 					// Index tile => differ
 					if f.Action == graph.ToIndex {
-						toIndex[uri] = outFileTile{file: f, tile: tiles[i]}
+						toIndex[uri] = outFileTile{file: f, tile: tiles[i], size: size}
+					} else {
+						output := newTileOutput(tiles[i], f, uri)
+						output.Size = size
+						outputs = append(outputs, output)
 					}
 				case graph.ToDelete:
 					toDelete = append(toDelete, outFileTile{file: f, tile: tiles[i]})
@@ -127,8 +138,8 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 		if err := service.Retriable(ctx, func() error {
 			for uri, f := range toIndex {
 				log.Logger(ctx).Sugar().Infof("index layer %s", f.file.Layer)
-				if err := indexTile(ctx, gcclient, f.tile.Scene.Data.InstancesID, f.tile.Scene.Data.RecordID, f.file, uri); err != nil {
-					if geocube.Code(err) == codes.AlreadyExists {
+				if err := indexTile(ctx, idx, f.tile.Scene.Data.InstancesID, f.tile.Scene.Data.RecordID, f.file, uri); err != nil {
+					if errors.As(err, &indexer.ErrAlreadyExists{}) {
 						log.Logger(ctx).Sugar().Warnf("layer %s already exists: %v", f.file.Layer, err)
 					} else {
 						return err
@@ -139,6 +150,14 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 		}, 15*time.Second, 3); err != nil {
 			return fmt.Errorf("ProcessTile[%s].%w (after 3 retries)", tag, err)
 		}
+		for uri, f := range toIndex {
+			output := newTileOutput(f.tile, f.file, uri)
+			output.Size = f.size
+			output.InstanceID = f.tile.Scene.Data.InstancesID[string(f.file.Layer)]
+			dformat := outputDFormat(f.file)
+			output.DFormat = &dformat
+			outputs = append(outputs, output)
+		}
 
 		// Delete tiles at the end to ease a retry
 		for _, f := range toDelete {
@@ -146,11 +165,12 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 			if err := storageService.DeleteLayer(ctx, f.tile, f.file.Layer, f.file.Extension); err != nil && !errors.As(err, &service.ErrFileNotFound{}) {
 				return fmt.Errorf("ProcessTile[%s].%w", tag, err)
 			}
+			outputs = append(outputs, newTileOutput(f.tile, f.file, ""))
 		}
 
 		if len(toIndex) > 0 {
 			// Update record processing date (errors are not fatal)
-			if _, err := gcclient.AddRecordsTags(ctx, []string{tile.Scene.Data.RecordID}, map[string]string{common.TagProcessingDate: time.Now().Format("2006-01-02 15:04:05")}); err != nil {
+			if err := idx.AddRecordsTags(ctx, []string{tile.Scene.Data.RecordID}, map[string]string{common.TagProcessingDate: time.Now().Format("2006-01-02 15:04:05")}); err != nil {
 				log.Logger(ctx).Sugar().Warnf("UpdateRecordTag[%s] fails: %v", tile.Scene.Data.RecordID, err)
 			}
 		}
@@ -159,44 +179,53 @@ func ProcessTile(ctx context.Context, storageService service.Storage, gcclient *
 
 	if processErr != nil {
 		if outFileErr != nil {
-			return fmt.Errorf("%w (during cleaning, an other error occured: %v)", processErr, outFileErr)
+			return outputs, fmt.Errorf("%w (during cleaning, an other error occured: %v)", processErr, outFileErr)
 		}
-		return processErr
+		return outputs, processErr
 	}
 
-	return outFileErr
+	return outputs, outFileErr
 }
 
-// indexTile indexes the tile in the Geocube
-func indexTile(ctx context.Context, gcclient *geocube.Client, instancesID map[string]string, recordID string, file graph.OutFile, uri string) error {
-	// Get dataset information
-	dformat := geocube.DataFormat{
-		NoData:   file.NoData,
-		MinValue: file.Min,
-		MaxValue: file.Max,
+// newTileOutput creates the manifest entry of an output file of the tile
+func newTileOutput(tile common.Tile, file graph.OutFile, uri string) common.TileOutput {
+	return common.TileOutput{
+		TileID:    tile.ID,
+		Layer:     string(file.Layer),
+		Extension: string(file.Extension),
+		Action:    file.Action.String(),
+		URI:       uri,
 	}
+}
+
+// fileSize returns the size of a file or of all the files of a directory (0 if it cannot be read)
+func fileSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
 
-	switch file.DType {
-	default:
-		return fmt.Errorf("indexTile: file.DType '%v' not found", file.DType)
-	case graph.UInt8:
-		dformat.Dtype = geocubepb.DataFormat_UInt8
-	case graph.UInt16:
-		dformat.Dtype = geocubepb.DataFormat_UInt16
-	case graph.UInt32:
-		dformat.Dtype = geocubepb.DataFormat_UInt32
-	case graph.Int16:
-		dformat.Dtype = geocubepb.DataFormat_Int16
-	case graph.Int32:
-		dformat.Dtype = geocubepb.DataFormat_Int32
-	case graph.Float32:
-		dformat.Dtype = geocubepb.DataFormat_Float32
-	case graph.Float64:
-		dformat.Dtype = geocubepb.DataFormat_Float64
-	case graph.Complex64:
-		dformat.Dtype = geocubepb.DataFormat_Complex64
+// outputDFormat returns the data format of an output file of the tile
+func outputDFormat(file graph.OutFile) common.OutputDFormat {
+	return common.OutputDFormat{
+		DType:    file.DType.String(),
+		NoData:   file.NoData,
+		Min:      file.Min,
+		Max:      file.Max,
+		ExtMin:   file.ExtMin,
+		ExtMax:   file.ExtMax,
+		Exponent: file.Exponent,
+		Nbands:   file.Nbands,
 	}
+}
 
+// indexTile indexes the tile using the indexer
+func indexTile(ctx context.Context, idx indexer.Indexer, instancesID map[string]string, recordID string, file graph.OutFile, uri string) error {
 	// Get instance ID
 	if instancesID == nil {
 		return fmt.Errorf("indexTile: layer %s not found in InstancesID", file.Layer)
@@ -212,8 +241,12 @@ func indexTile(ctx context.Context, gcclient *geocube.Client, instancesID map[st
 	}
 
 	// Index
-	if err := gcclient.IndexDataset(ctx, uri, true, "", recordID, instanceID, bands, &dformat, file.ExtMin, file.ExtMax, file.Exponent); err != nil {
-		return err
-	}
-	return nil
+	return idx.IndexDataset(ctx, indexer.Dataset{
+		URI:        uri,
+		RecordID:   recordID,
+		InstanceID: instanceID,
+		Layer:      string(file.Layer),
+		Bands:      bands,
+		DFormat:    outputDFormat(file),
+	})
 }
@@ -0,0 +1,179 @@
+package geometry
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-spatial/geom"
+	geomwkt "github.com/go-spatial/geom/encoding/wkt"
+)
+
+// NormalizeLonLat normalizes the polygons of a geometry in EPSG:4326 whose longitudes are outside [-180, 180]
+// or whose edges cross the antimeridian (consecutive longitudes more than 180° apart, e.g. 179 -> -179):
+// - the rings are unwrapped, so that each edge is the shortest one in longitude,
+// - a ring going round a pole (polar cap) is closed through the pole,
+// - the polygons are split along the antimeridian and shifted to [-180, 180].
+// Returns the normalized geometry (MultiPolygon) and true, or the geometry and false if it does not need to be normalized (or is not polygonal).
+// The polygons are clipped with Sutherland-Hodgman: a concave polygon crossing the antimeridian several times may have degenerated edges along it.
+func NormalizeLonLat(g geom.Geometry) (geom.Geometry, bool) {
+	var mp geom.MultiPolygon
+	if err := mergeMultiPolygons(g, &mp); err != nil || len(mp) == 0 || !needsNormalization(mp) {
+		return g, false
+	}
+	var normalized geom.MultiPolygon
+	for _, polygon := range mp {
+		if len(polygon) > 0 && len(polygon[0]) > 0 {
+			normalized = append(normalized, normalizePolygon(polygon)...)
+		}
+	}
+	return normalized, true
+}
+
+// NormalizeWKT normalizes the geometry (see NormalizeLonLat)
+// Returns the wkt unchanged if it does not need to be normalized
+func NormalizeWKT(wkt string) (string, error) {
+	g, err := geomwkt.DecodeString(wkt)
+	if err != nil {
+		return "", fmt.Errorf("NormalizeWKT.DecodeString: %w", err)
+	}
+	g, ok := NormalizeLonLat(g)
+	if !ok {
+		return wkt, nil
+	}
+	if wkt, err = geomwkt.EncodeString(g); err != nil {
+		return "", fmt.Errorf("NormalizeWKT.EncodeString: %w", err)
+	}
+	return wkt, nil
+}
+
+// AntimeridianParts returns the eastern and the western parts of the geometry normalized (see NormalizeLonLat),
+// if it crosses the antimeridian, so that each part can be searched using its convex hull or its extent.
+// Otherwise, returns the normalized geometry.
+func AntimeridianParts(g geom.Geometry) []geom.Geometry {
+	g, _ = NormalizeLonLat(g)
+	var mp geom.MultiPolygon
+	if err := mergeMultiPolygons(g, &mp); err != nil || len(mp) < 2 {
+		return []geom.Geometry{g}
+	}
+	if extent, err := geom.NewExtentFromGeometry(mp); err != nil || extent.MaxX()-extent.MinX() <= 180 {
+		return []geom.Geometry{g}
+	}
+	var east, west geom.MultiPolygon
+	for _, polygon := range mp {
+		extent, err := geom.NewExtentFromGeometry(geom.Polygon(polygon))
+		if err != nil {
+			return []geom.Geometry{g}
+		}
+		if extent.MinX()+extent.MaxX() >= 0 {
+			east = append(east, polygon)
+		} else {
+			west = append(west, polygon)
+		}
+	}
+	if len(east) == 0 || len(west) == 0 {
+		return []geom.Geometry{g}
+	}
+	return []geom.Geometry{east, west}
+}
+
+// needsNormalization returns true if a longitude is outside [-180, 180] or an edge crosses the antimeridian
+// An edge between -180 and 180 is not considered as crossing the antimeridian (e.g. the edges of a polar cap already normalized, as in RFC 7946)
+func needsNormalization(mp geom.MultiPolygon) bool {
+	for _, polygon := range mp {
+		for _, ring := range polygon {
+			for i, p := range ring {
+				if p[0] < -180 || p[0] > 180 {
+					return true
+				}
+				if i > 0 && math.Abs(p[0]-ring[i-1][0]) > 180 && (math.Abs(p[0]) != 180 || math.Abs(ring[i-1][0]) != 180) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// normalizePolygon unwraps the rings of the polygon and splits it along the antimeridian
+func normalizePolygon(polygon [][][2]float64) [][][][2]float64 {
+	exterior := unwrapRing(polygon[0])
+	minLon, maxLon := lonRange(exterior)
+	var holes [][][2]float64
+	for _, hole := range polygon[1:] {
+		if hole = unwrapRing(hole); len(hole) > 0 {
+			// Shift the hole next to the exterior ring
+			holes = append(holes, shiftRing(hole, 360*math.Round(((minLon+maxLon)/2-hole[0][0])/360)))
+		}
+	}
+
+	// Clip the polygon in each band of 360° and shift it to [-180, 180]
+	var polygons [][][][2]float64
+	for k := math.Floor((minLon + 180) / 360); k <= math.Ceil((maxLon+180)/360)-1; k++ {
+		band := [4]float64{-180 + 360*k, -90, 180 + 360*k, 90}
+		ext := clipRing(exterior, band)
+		if ringArea(ext) == 0 {
+			continue
+		}
+		p := [][][2]float64{shiftRing(ext, -360*k)}
+		for _, hole := range holes {
+			if h := clipRing(hole, band); ringArea(h) != 0 {
+				p = append(p, shiftRing(h, -360*k))
+			}
+		}
+		polygons = append(polygons, p)
+	}
+	return polygons
+}
+
+// unwrapRing returns the ring with continuous longitudes (each edge is the shortest one in longitude)
+// If the ring goes round a pole, it is closed through the pole (the north pole if the ring is mostly in the northern hemisphere)
+func unwrapRing(ring [][2]float64) [][2]float64 {
+	if len(ring) == 0 {
+		return nil
+	}
+	unwrapped := make([][2]float64, 1, len(ring)+3)
+	unwrapped[0] = ring[0]
+	meanLat := ring[0][1]
+	for i := 1; i < len(ring); i++ {
+		d := ring[i][0] - ring[i-1][0]
+		d -= 360 * math.Round(d/360)
+		unwrapped = append(unwrapped, [2]float64{unwrapped[i-1][0] + d, ring[i][1]})
+		meanLat += ring[i][1]
+	}
+	if first, last := unwrapped[0], unwrapped[len(unwrapped)-1]; math.Abs(last[0]-first[0]) >= 180 {
+		// The longitudes made a complete turn: close the ring through the pole
+		pole := 90.
+		if meanLat < 0 {
+			pole = -90
+		}
+		unwrapped = append(unwrapped, [2]float64{last[0], pole}, [2]float64{first[0], pole}, first)
+	}
+	return unwrapped
+}
+
+// lonRange returns the minimum and maximum longitudes of the ring
+func lonRange(ring [][2]float64) (float64, float64) {
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, p := range ring {
+		minLon, maxLon = math.Min(minLon, p[0]), math.Max(maxLon, p[0])
+	}
+	return minLon, maxLon
+}
+
+// shiftRing returns the ring shifted by dLon degrees of longitude
+func shiftRing(ring [][2]float64, dLon float64) [][2]float64 {
+	shifted := make([][2]float64, len(ring))
+	for i, p := range ring {
+		shifted[i] = [2]float64{p[0] + dLon, p[1]}
+	}
+	return shifted
+}
+
+// ringArea returns the absolute area of the ring (shoelace formula, in square degrees)
+func ringArea(ring [][2]float64) float64 {
+	area := 0.
+	for i := 1; i < len(ring); i++ {
+		area += ring[i-1][0]*ring[i][1] - ring[i][0]*ring[i-1][1]
+	}
+	return math.Abs(area) / 2
+}
@@ -0,0 +1,78 @@
+package geometry
+
+import (
+	"testing"
+
+	"github.com/go-spatial/geom"
+)
+
+// checkExtents checks the extents (minLon, minLat, maxLon, maxLat) of the polygons of the geometry
+func checkExtents(t *testing.T, name string, g geom.Geometry, expected [][4]float64) {
+	mp, ok := g.(geom.MultiPolygon)
+	if !ok || len(mp) != len(expected) {
+		t.Errorf("%s: expecting %d polygons, got %v", name, len(expected), g)
+		return
+	}
+	for i, polygon := range mp {
+		extent, err := geom.NewExtentFromGeometry(geom.Polygon(polygon))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if [4]float64{extent.MinX(), extent.MinY(), extent.MaxX(), extent.MaxY()} != expected[i] {
+			t.Errorf("%s: expecting polygon %d in %v, got %v", name, i, expected[i], extent)
+		}
+	}
+}
+
+func TestNormalizeLonLat(t *testing.T) {
+	// Pacific AOI crossing the antimeridian
+	pacific := geom.Polygon{{{170, -10}, {-170, -10}, {-170, 10}, {170, 10}, {170, -10}}}
+	g, ok := NormalizeLonLat(pacific)
+	if !ok {
+		t.Fatalf("expecting the pacific AOI to be normalized")
+	}
+	checkExtents(t, "pacific", g, [][4]float64{{170, -10, 180, 10}, {-180, -10, -170, 10}})
+	if parts := AntimeridianParts(pacific); len(parts) != 2 {
+		t.Errorf("expecting two parts, got %v", parts)
+	}
+
+	// Same AOI with longitudes in [0, 360]
+	g, _ = NormalizeLonLat(geom.Polygon{{{170, -10}, {190, -10}, {190, 10}, {170, 10}, {170, -10}}})
+	checkExtents(t, "pacific360", g, [][4]float64{{170, -10, 180, 10}, {-180, -10, -170, 10}})
+
+	// Arctic cap around the north pole
+	g, ok = NormalizeLonLat(geom.Polygon{{{0, 80}, {90, 80}, {180, 80}, {-90, 80}, {0, 80}}})
+	if !ok {
+		t.Fatalf("expecting the arctic AOI to be normalized")
+	}
+	checkExtents(t, "arctic", g, [][4]float64{{0, 80, 180, 90}, {-180, 80, 0, 90}})
+
+	// Antarctic cap around the south pole
+	g, _ = NormalizeLonLat(geom.Polygon{{{0, -70}, {-120, -70}, {120, -70}, {0, -70}}})
+	checkExtents(t, "antarctic", g, [][4]float64{{0, -90, 180, -70}, {-180, -90, 0, -70}})
+
+	// Geometries that do not need to be normalized
+	for name, g := range map[string]geom.Geometry{
+		"toulouse":      geom.Polygon{{{1, 43}, {2, 43}, {2, 44}, {1, 44}, {1, 43}}},
+		"rfc7946 cap":   geom.Polygon{{{-180, 70}, {180, 70}, {180, 90}, {-180, 90}, {-180, 70}}},
+		"point":         geom.Point{179, 0},
+		"split pacific": geom.MultiPolygon{{{{170, 0}, {180, 0}, {180, 10}, {170, 0}}}, {{{-180, 0}, {-170, 0}, {-180, 10}, {-180, 0}}}},
+	} {
+		if _, ok := NormalizeLonLat(g); ok {
+			t.Errorf("%s: expecting no normalization", name)
+		}
+	}
+	if parts := AntimeridianParts(geom.Polygon{{{1, 43}, {2, 43}, {2, 44}, {1, 44}, {1, 43}}}); len(parts) != 1 {
+		t.Errorf("expecting one part, got %v", parts)
+	}
+}
+
+func TestNormalizeWKT(t *testing.T) {
+	wkt, err := NormalizeWKT("POLYGON ((179 60,-179 60,-179 61,179 61,179 60))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wkt != "MULTIPOLYGON (((179 60,180 60,180 61,179 61,179 60)),((-180 60,-179 60,-179 61,-180 61,-180 60)))" {
+		t.Errorf("wrong normalized wkt: %s", wkt)
+	}
+}
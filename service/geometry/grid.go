@@ -0,0 +1,259 @@
+package geometry
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-spatial/geom"
+)
+
+// GridCell is a cell of a grid, in EPSG:4326
+type GridCell struct {
+	Name    string // Name of the cell, made of [a-zA-Z0-9]
+	Polygon geom.Polygon
+}
+
+// DegreeGrid returns the cells of a regular grid of size degrees (aligned on -180, -90) intersecting the extent
+// The cells are named after their lower-left corner (e.g. N43E001, S12p5W045p5 for a grid of 0.5°)
+func DegreeGrid(extent *geom.Extent, size float64) []GridCell {
+	// Coordinates are rounded to the decimals of size to prevent floating-point noise in the names (e.g. 43.300000000000004)
+	_, fraction, _ := strings.Cut(strconv.FormatFloat(size, 'f', -1, 64), ".")
+	scale := math.Pow10(len(fraction))
+	round := func(v float64) float64 { return math.Round(v*scale) / scale }
+
+	var cells []GridCell
+	for i := math.Floor((extent.MinY() + 90) / size); round(i*size-90) < extent.MaxY(); i++ {
+		lat, nextLat := round(i*size-90), round((i+1)*size-90)
+		for j := math.Floor((extent.MinX() + 180) / size); round(j*size-180) < extent.MaxX(); j++ {
+			lon, nextLon := round(j*size-180), round((j+1)*size-180)
+			cells = append(cells, GridCell{
+				Name:    hemisphere(lat, "N", "S") + formatCoord(lat, 2) + hemisphere(lon, "E", "W") + formatCoord(lon, 3),
+				Polygon: rectangle(lon, lat, math.Min(nextLon, 180), math.Min(nextLat, 90)),
+			})
+		}
+	}
+	return cells
+}
+
+func hemisphere(v float64, positive, negative string) string {
+	if v < 0 {
+		return negative
+	}
+	return positive
+}
+
+// formatCoord formats the absolute value of the coordinate, with the integer part padded to width digits and the decimal point replaced by "p"
+func formatCoord(v float64, width int) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', -1, 64)
+	integer, fraction, _ := strings.Cut(s, ".")
+	if len(integer) < width {
+		integer = strings.Repeat("0", width-len(integer)) + integer
+	}
+	if fraction != "" {
+		return integer + "p" + fraction
+	}
+	return integer
+}
+
+func rectangle(minX, minY, maxX, maxY float64) geom.Polygon {
+	return geom.Polygon{{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}, {minX, minY}}}
+}
+
+const (
+	mgrsSquareSize   = 100000.
+	mgrsEdgeSamples  = 8 // Number of points per edge of a square to project its boundary
+	mgrsBands        = "CDEFGHJKLMNPQRSTUVWX"
+	mgrsColumnLetter = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	mgrsRowLetters   = "ABCDEFGHJKLMNPQRSTUV"
+)
+
+// MGRSGrid returns the 100km squares of the Military Grid Reference System intersecting the extent (latitudes between -80° and 84°)
+// The squares are named after their grid zone designator and their 100km square identifier (e.g. 31TCJ, as the Sentinel-2 tiles)
+// and clipped to their grid zone (UTM zone and latitude band). The exceptions of the grid zones around Norway and Svalbard are not handled.
+func MGRSGrid(extent *geom.Extent) []GridCell {
+	var cells []GridCell
+	minLat, maxLat := math.Max(extent.MinY(), -80), math.Min(extent.MaxY(), 84)
+	for zone := utmZone(extent.MinX()); zone <= utmZone(extent.MaxX()); zone++ {
+		zoneMinLon := float64(zone-1)*6 - 180
+		for b := 0; b < len(mgrsBands); b++ {
+			bandMinLat, bandMaxLat := float64(b)*8-80, float64(b+1)*8-80
+			if mgrsBands[b] == 'X' {
+				bandMaxLat = 84
+			}
+			// Part of the grid zone inside the extent
+			clip := [4]float64{math.Max(zoneMinLon, extent.MinX()), math.Max(bandMinLat, minLat), math.Min(zoneMinLon+6, extent.MaxX()), math.Min(bandMaxLat, maxLat)}
+			if clip[0] >= clip[2] || clip[1] >= clip[3] {
+				continue
+			}
+			south := bandMinLat < 0
+			minE, minN, maxE, maxN := utmExtent(clip, zone, south)
+			for e := math.Floor(minE/mgrsSquareSize) * mgrsSquareSize; e < maxE; e += mgrsSquareSize {
+				for n := math.Floor(minN/mgrsSquareSize) * mgrsSquareSize; n < maxN; n += mgrsSquareSize {
+					ring := clipRing(utmSquare(e, n, zone, south), clip)
+					if len(ring) < 4 {
+						continue
+					}
+					cells = append(cells, GridCell{
+						Name:    fmt.Sprintf("%02d%c%s", zone, mgrsBands[b], mgrsSquareID(zone, e, n)),
+						Polygon: geom.Polygon{ring},
+					})
+				}
+			}
+		}
+	}
+	return cells
+}
+
+// utmZone returns the UTM zone of the longitude
+func utmZone(lon float64) int {
+	zone := int(math.Floor((lon+180)/6)) + 1
+	return max(1, min(zone, 60))
+}
+
+// utmExtent returns the extent in UTM coordinates of the rectangle (minLon, minLat, maxLon, maxLat)
+func utmExtent(rect [4]float64, zone int, south bool) (minE, minN, maxE, maxN float64) {
+	minE, minN, maxE, maxN = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i := 0; i <= mgrsEdgeSamples; i++ {
+		t := float64(i) / mgrsEdgeSamples
+		lon, lat := rect[0]+t*(rect[2]-rect[0]), rect[1]+t*(rect[3]-rect[1])
+		for _, p := range [][2]float64{{lon, rect[1]}, {lon, rect[3]}, {rect[0], lat}, {rect[2], lat}} {
+			e, n := toUTM(p[0], p[1], zone, south)
+			minE, minN, maxE, maxN = math.Min(minE, e), math.Min(minN, n), math.Max(maxE, e), math.Max(maxN, n)
+		}
+	}
+	return
+}
+
+// utmSquare returns the boundary in EPSG:4326 of the square of the UTM zone whose lower-left corner is (e, n)
+func utmSquare(e, n float64, zone int, south bool) [][2]float64 {
+	corners := [][2]float64{{e, n}, {e + mgrsSquareSize, n}, {e + mgrsSquareSize, n + mgrsSquareSize}, {e, n + mgrsSquareSize}}
+	var ring [][2]float64
+	for i, c := range corners {
+		next := corners[(i+1)%len(corners)]
+		for s := 0; s < mgrsEdgeSamples; s++ {
+			t := float64(s) / mgrsEdgeSamples
+			lon, lat := fromUTM(c[0]+t*(next[0]-c[0]), c[1]+t*(next[1]-c[1]), zone, south)
+			ring = append(ring, [2]float64{lon, lat})
+		}
+	}
+	return ring
+}
+
+// mgrsSquareID returns the identifier of the 100km square (column and row letters) whose lower-left corner is (e, n)
+func mgrsSquareID(zone int, e, n float64) string {
+	column := (zone-1)%3*8 + int(e/mgrsSquareSize) - 1
+	row := int(n / mgrsSquareSize)
+	if zone%2 == 0 {
+		row += 5
+	}
+	return string(mgrsColumnLetter[column%len(mgrsColumnLetter)]) + string(mgrsRowLetters[row%len(mgrsRowLetters)])
+}
+
+// clipRing clips the ring to the rectangle (minX, minY, maxX, maxY) (Sutherland-Hodgman) and closes it
+func clipRing(ring [][2]float64, rect [4]float64) [][2]float64 {
+	edges := []struct {
+		inside    func(p [2]float64) bool
+		intersect func(a, b [2]float64) [2]float64
+	}{
+		{func(p [2]float64) bool { return p[0] >= rect[0] }, func(a, b [2]float64) [2]float64 { return atX(a, b, rect[0]) }},
+		{func(p [2]float64) bool { return p[0] <= rect[2] }, func(a, b [2]float64) [2]float64 { return atX(a, b, rect[2]) }},
+		{func(p [2]float64) bool { return p[1] >= rect[1] }, func(a, b [2]float64) [2]float64 { return atY(a, b, rect[1]) }},
+		{func(p [2]float64) bool { return p[1] <= rect[3] }, func(a, b [2]float64) [2]float64 { return atY(a, b, rect[3]) }},
+	}
+	for _, edge := range edges {
+		var clipped [][2]float64
+		for i, p := range ring {
+			prev := ring[(i+len(ring)-1)%len(ring)]
+			switch {
+			case edge.inside(p) && !edge.inside(prev):
+				clipped = append(clipped, edge.intersect(prev, p), p)
+			case edge.inside(p):
+				clipped = append(clipped, p)
+			case edge.inside(prev):
+				clipped = append(clipped, edge.intersect(prev, p))
+			}
+		}
+		if ring = clipped; len(ring) == 0 {
+			return nil
+		}
+	}
+	return append(ring, ring[0])
+}
+
+func atX(a, b [2]float64, x float64) [2]float64 {
+	return [2]float64{x, a[1] + (b[1]-a[1])*(x-a[0])/(b[0]-a[0])}
+}
+
+func atY(a, b [2]float64, y float64) [2]float64 {
+	return [2]float64{a[0] + (b[0]-a[0])*(y-a[1])/(b[1]-a[1]), y}
+}
+
+// WGS84 ellipsoid and UTM projection parameters
+const (
+	wgs84A    = 6378137.
+	wgs84F    = 1 / 298.257223563
+	utmK0     = 0.9996
+	utmFalseE = 500000.
+	utmFalseN = 10000000. // Southern hemisphere
+)
+
+var (
+	wgs84E2  = wgs84F * (2 - wgs84F)
+	wgs84Ep2 = wgs84E2 / (1 - wgs84E2)
+)
+
+// meridianArc returns the distance along the meridian from the equator to the latitude (radians)
+func meridianArc(phi float64) float64 {
+	e2, e4, e6 := wgs84E2, wgs84E2*wgs84E2, wgs84E2*wgs84E2*wgs84E2
+	return wgs84A * ((1-e2/4-3*e4/64-5*e6/256)*phi -
+		(3*e2/8+3*e4/32+45*e6/1024)*math.Sin(2*phi) +
+		(15*e4/256+45*e6/1024)*math.Sin(4*phi) -
+		(35*e6/3072)*math.Sin(6*phi))
+}
+
+// toUTM projects the point (degrees) in the UTM zone (Snyder, Map Projections: A Working Manual, p61)
+func toUTM(lon, lat float64, zone int, south bool) (float64, float64) {
+	phi := lat * math.Pi / 180
+	lambda0 := float64(zone-1)*6 - 180 + 3
+	sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+	n := wgs84A / math.Sqrt(1-wgs84E2*sin*sin)
+	t := tan * tan
+	c := wgs84Ep2 * cos * cos
+	a := cos * (lon - lambda0) * math.Pi / 180
+
+	e := utmK0*n*(a+(1-t+c)*math.Pow(a, 3)/6+(5-18*t+t*t+72*c-58*wgs84Ep2)*math.Pow(a, 5)/120) + utmFalseE
+	y := utmK0 * (meridianArc(phi) + n*tan*(a*a/2+(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+(61-58*t+t*t+600*c-330*wgs84Ep2)*math.Pow(a, 6)/720))
+	if south {
+		y += utmFalseN
+	}
+	return e, y
+}
+
+// fromUTM returns the point (degrees) of the UTM coordinates (Snyder, Map Projections: A Working Manual, p63)
+func fromUTM(e, y float64, zone int, south bool) (float64, float64) {
+	x := e - utmFalseE
+	if south {
+		y -= utmFalseN
+	}
+	e2 := wgs84E2
+	mu := y / utmK0 / (wgs84A * (1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256))
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+		(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+		(151*math.Pow(e1, 3)/96)*math.Sin(6*mu) +
+		(1097*math.Pow(e1, 4)/512)*math.Sin(8*mu)
+
+	sin, cos, tan := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+	c1 := wgs84Ep2 * cos * cos
+	t1 := tan * tan
+	n1 := wgs84A / math.Sqrt(1-e2*sin*sin)
+	r1 := wgs84A * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+	d := x / (n1 * utmK0)
+
+	phi := phi1 - (n1*tan/r1)*(d*d/2-(5+3*t1+10*c1-4*c1*c1-9*wgs84Ep2)*math.Pow(d, 4)/24+
+		(61+90*t1+298*c1+45*t1*t1-252*wgs84Ep2-3*c1*c1)*math.Pow(d, 6)/720)
+	lambda := (d - (1+2*t1+c1)*math.Pow(d, 3)/6 + (5-2*c1+28*t1-3*c1*c1+8*wgs84Ep2+24*t1*t1)*math.Pow(d, 5)/120) / cos
+	return float64(zone-1)*6 - 180 + 3 + lambda*180/math.Pi, phi * 180 / math.Pi
+}
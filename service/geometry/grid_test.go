@@ -0,0 +1,79 @@
+package geometry
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-spatial/geom"
+)
+
+func TestDegreeGrid(t *testing.T) {
+	cells := DegreeGrid(geom.NewExtent([2]float64{-0.5, 43.2}, [2]float64{1.5, 43.8}), 1)
+	if len(cells) != 3 || cells[0].Name != "N43W001" || cells[1].Name != "N43E000" || cells[2].Name != "N43E001" {
+		t.Errorf("expecting 3 cells, got %v", cells)
+	}
+	if cells[0].Polygon[0][2] != [2]float64{0, 44} {
+		t.Errorf("wrong polygon %v", cells[0].Polygon)
+	}
+	cells = DegreeGrid(geom.NewExtent([2]float64{-45.2, -12.2}, [2]float64{-45.1, -12.1}), 0.5)
+	if len(cells) != 1 || cells[0].Name != "S12p5W045p5" {
+		t.Errorf("expecting cell S12p5W045p5, got %v", cells)
+	}
+	cells = DegreeGrid(geom.NewExtent([2]float64{1.22, 43.32}, [2]float64{1.25, 43.35}), 0.1)
+	if len(cells) != 1 || cells[0].Name != "N43p3E001p2" {
+		t.Errorf("expecting cell N43p3E001p2, got %v", cells)
+	}
+	if cells[0].Polygon[0][2] != [2]float64{1.3, 43.4} {
+		t.Errorf("wrong polygon %v", cells[0].Polygon)
+	}
+	cells = DegreeGrid(geom.NewExtent([2]float64{-0.35, -0.55}, [2]float64{-0.25, -0.35}), 0.2)
+	if len(cells) != 2 || cells[0].Name != "S00p6W000p4" || cells[1].Name != "S00p4W000p4" {
+		t.Errorf("expecting cells S00p6W000p4, S00p4W000p4, got %v", cells)
+	}
+}
+
+func TestUTM(t *testing.T) {
+	for _, p := range [][2]float64{{1.4442, 43.6045}, {151.2093, -33.8688}, {-70.5, 10}} {
+		zone, south := utmZone(p[0]), p[1] < 0
+		e, n := toUTM(p[0], p[1], zone, south)
+		lon, lat := fromUTM(e, n, zone, south)
+		if math.Abs(lon-p[0]) > 1e-7 || math.Abs(lat-p[1]) > 1e-7 {
+			t.Errorf("expecting %v, got %f %f", p, lon, lat)
+		}
+	}
+	// Toulouse
+	if e, n := toUTM(1.4442, 43.6045, 31, false); math.Abs(e-374300) > 500 || math.Abs(n-4829300) > 500 {
+		t.Errorf("wrong UTM coordinates: %f %f", e, n)
+	}
+}
+
+func TestMGRSGrid(t *testing.T) {
+	for point, expected := range map[[2]float64]string{
+		{1.4442, 43.6045}:    "31TCJ", // Toulouse
+		{151.2093, -33.8688}: "56HLH", // Sydney
+		{-74.006, 40.7128}:   "18TWL", // New York
+	} {
+		cells := MGRSGrid(geom.NewExtent(point, [2]float64{point[0] + 1e-6, point[1] + 1e-6}))
+		if len(cells) != 1 || cells[0].Name != expected {
+			t.Errorf("expecting %s, got %v", expected, cells)
+		}
+	}
+
+	// A whole grid zone
+	cells := MGRSGrid(geom.NewExtent([2]float64{0, 40}, [2]float64{6, 48}))
+	names := map[string]bool{}
+	for _, cell := range cells {
+		if names[cell.Name] {
+			t.Errorf("duplicated cell %s", cell.Name)
+		}
+		names[cell.Name] = true
+		for _, p := range cell.Polygon[0] {
+			if p[0] < -1e-9 || p[0] > 6+1e-9 || p[1] < 40-1e-9 || p[1] > 48+1e-9 {
+				t.Errorf("cell %s is not clipped to the grid zone: %v", cell.Name, p)
+			}
+		}
+	}
+	if !names["31TCJ"] || !names["31TFN"] || len(cells) < 60 {
+		t.Errorf("expecting the squares of the grid zone 31T, got %d squares", len(cells))
+	}
+}
@@ -0,0 +1,91 @@
+package geometry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-spatial/geom"
+	geomwkt "github.com/go-spatial/geom/encoding/wkt"
+)
+
+var ogr2ogrCmd = "ogr2ogr"
+
+// ReadVectorFile reads the polygons of a layer of a vector file (Shapefile, GeoPackage, KML, FlatGeobuf, GeoJSON... or a zip containing it)
+// optionally filtered by an attribute filter (OGR SQL WHERE clause), reprojects them to EPSG:4326 and dissolves them in one geometry.
+// If layer is empty, the first layer is read. Requires ogr2ogr (GDAL).
+func ReadVectorFile(ctx context.Context, filename, layer, where string) (geom.Geometry, error) {
+	if strings.EqualFold(filepath.Ext(filename), ".zip") && !strings.HasPrefix(filename, "/vsizip/") {
+		filename = "/vsizip/" + filename
+	}
+	args := []string{"-f", "GeoJSON", "-t_srs", "EPSG:4326", "-lco", "RFC7946=YES"}
+	if where != "" {
+		args = append(args, "-where", where)
+	}
+	args = append(args, "/vsistdout/", filename)
+	if layer != "" {
+		args = append(args, layer)
+	}
+
+	cmd := exec.CommandContext(ctx, ogr2ogrCmd, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ReadVectorFile[%s]: %w (%s)", filename, err, strings.TrimSpace(stderr.String()))
+	}
+
+	polygons, err := parseVectorFeatures(output)
+	if err != nil {
+		return nil, fmt.Errorf("ReadVectorFile[%s].%w", filename, err)
+	}
+	aoi, err := dissolve(polygons)
+	if err != nil {
+		return nil, fmt.Errorf("ReadVectorFile[%s].%w", filename, err)
+	}
+	return aoi, nil
+}
+
+// parseVectorFeatures returns the polygons of the features of the GeoJSON FeatureCollection output by ogr2ogr
+// The other geometries (points, lines) are ignored.
+func parseVectorFeatures(data []byte) (geom.MultiPolygon, error) {
+	g, err := UnmarshalGeometry(data)
+	if err != nil {
+		return nil, fmt.Errorf("parseVectorFeatures: %w", err)
+	}
+	var mp geom.MultiPolygon
+	if err := mergeMultiPolygons(g, &mp); err != nil {
+		return nil, fmt.Errorf("parseVectorFeatures: %w", err)
+	}
+	if len(mp) == 0 {
+		return nil, fmt.Errorf("parseVectorFeatures: no polygon found (check the layer and the filter)")
+	}
+	return mp, nil
+}
+
+// dissolve merges the polygons in one geometry
+func dissolve(mp geom.MultiPolygon) (geom.Geometry, error) {
+	if len(mp) == 1 {
+		return geom.Polygon(mp[0]), nil
+	}
+	var wkts []string
+	for _, p := range mp {
+		wkt, err := geomwkt.EncodeString(geom.Polygon(p))
+		if err != nil {
+			return nil, fmt.Errorf("dissolve.EncodeString: %w", err)
+		}
+		wkts = append(wkts, wkt)
+	}
+	wkt, err := WKTUnion(wkts, TOLERANCE_GEOG)
+	if err != nil {
+		return nil, fmt.Errorf("dissolve.%w", err)
+	}
+	aoi, err := geomwkt.DecodeString(wkt)
+	if err != nil {
+		return nil, fmt.Errorf("dissolve.DecodeString: %w", err)
+	}
+	return aoi, nil
+}
@@ -0,0 +1,35 @@
+package geometry
+
+import (
+	"testing"
+
+	"github.com/go-spatial/geom"
+)
+
+func TestParseVectorFeatures(t *testing.T) {
+	output := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"name":"a"},"geometry":{"type":"Polygon","coordinates":[[[1,43],[2,43],[2,44],[1,44],[1,43]]]}},
+		{"type":"Feature","properties":{"name":"b"},"geometry":{"type":"MultiPolygon","coordinates":[[[[3,43],[4,43],[4,44],[3,44],[3,43]]],[[[5,43],[6,43],[6,44],[5,44],[5,43]]]]}},
+		{"type":"Feature","properties":{"name":"c"},"geometry":{"type":"Point","coordinates":[1,43]}}
+	]}`
+	mp, err := parseVectorFeatures([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp) != 3 {
+		t.Errorf("expecting 3 polygons, got %d", len(mp))
+	}
+
+	// One polygon is not dissolved
+	aoi, err := dissolve(mp[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := aoi.(geom.Polygon); !ok || p[0][1] != [2]float64{2, 43} {
+		t.Errorf("expecting the first polygon, got %v", aoi)
+	}
+
+	if _, err := parseVectorFeatures([]byte(`{"type":"FeatureCollection","features":[]}`)); err == nil {
+		t.Errorf("expecting an error without polygon")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
 func HTTPGetWithAuth(ctx context.Context, url, authName, authPswd, authToken string) ([]byte, error) {
@@ -40,6 +41,40 @@ func doWithAuth(req *http.Request, authName, authPswd, authToken string) (*http.
 	return client.Do(req)
 }
 
+// CheckRedirectAndCopyAuth returns a CheckRedirect function of http.Client that copies the Authorization header of the first request
+// when redirected to the same host or to a host of one of the trusted domains (e.g. "dataspace.copernicus.eu" trusts "download.dataspace.copernicus.eu").
+// The credentials are not sent to the other hosts.
+func CheckRedirectAndCopyAuth(trustedDomains ...string) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		auth := via[0].Header.Get("Authorization")
+		if auth == "" || req.Header.Get("Authorization") != "" {
+			return nil
+		}
+		if trustedHost(req.URL.Hostname(), via[0].URL.Hostname(), trustedDomains) {
+			req.Header.Add("Authorization", auth)
+		}
+		return nil
+	}
+}
+
+// trustedHost returns true if host is the origin or belongs to one of the trusted domains
+func trustedHost(host, origin string, trustedDomains []string) bool {
+	if strings.EqualFold(host, origin) {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, domain := range trustedDomains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
 // PageQueryParam provides the information required to request a single page in a Catalog.
 type PageQueryParam struct {
 	// number of rows to request in a single page
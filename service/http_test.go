@@ -2,6 +2,7 @@ package service
 
 import (
 	"fmt"
+	"net/http"
 	"reflect"
 	"testing"
 )
@@ -61,3 +62,38 @@ func TestComputePagesToQuery(t *testing.T) {
 
 	//t.Errorf("not implemented")
 }
+
+func TestCheckRedirectAndCopyAuth(t *testing.T) {
+	checkRedirect := CheckRedirectAndCopyAuth("dataspace.copernicus.eu")
+	first, err := http.NewRequest(http.MethodGet, "https://catalogue.dataspace.copernicus.eu/odata/v1/Products(id)/$value", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Header.Set("Authorization", "Bearer token")
+	for url, expected := range map[string]string{
+		"https://catalogue.dataspace.copernicus.eu/other": "Bearer token",
+		"https://zipper.dataspace.copernicus.eu/download": "Bearer token",
+		"https://dataspace.copernicus.eu/download":        "Bearer token",
+		"https://s3.amazonaws.com/bucket/file":            "",
+		"https://evildataspace.copernicus.eu.com/file":    "",
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := checkRedirect(req, []*http.Request{first}); err != nil {
+			t.Fatal(err)
+		}
+		if auth := req.Header.Values("Authorization"); (expected == "" && len(auth) != 0) || (expected != "" && (len(auth) != 1 || auth[0] != expected)) {
+			t.Errorf("%s: expecting Authorization '%s', got %v", url, expected, auth)
+		}
+	}
+
+	via := make([]*http.Request, 10)
+	for i := range via {
+		via[i] = first
+	}
+	if err := checkRedirect(first, via); err == nil {
+		t.Error("expecting an error after 10 redirects")
+	}
+}
@@ -49,6 +49,7 @@ const (
 	// The following extensions are directories, thus, they are stored as a zip file (see service.storeAsZip() function)
 	// Using those extensions ensures that the stored file will be unzipped in a directory named <layer>.<Extension>
 	ExtensionSAFE      Extension = "SAFE" // Sentinel product
+	ExtensionSEN3      Extension = "SEN3" // Sentinel-3 product
 	ExtensionDIMAP     Extension = "dim"
 	ExtensionDIMAPData Extension = "data"
 	ExtensionAll       Extension = "*" // The content of the whole working directory (e.g. useful to export all the downloaded files as one zip file). Replaced by NoExtension in the directory name
@@ -244,7 +245,7 @@ func (ss *StorageStrategy) getPath(tile common.Tile, filename string) string {
 
 func storedAsZip(ext Extension) bool {
 	switch ext {
-	case ExtensionDIMAP, ExtensionDIMAPData, ExtensionAll, ExtensionSAFE:
+	case ExtensionDIMAP, ExtensionDIMAPData, ExtensionAll, ExtensionSAFE, ExtensionSEN3:
 		return true
 	}
 	return false
@@ -0,0 +1,215 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/common"
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+// Status of the consolidation jobs before they are updated from the indexer
+const (
+	ConsolidationStarted = "STARTED"
+	ConsolidationFailed  = "FAILED" // The job cannot be started
+)
+
+// SetAOIConsolidationLayouts implements catalog.WorkflowManager
+// The jobs of the previous consolidation are kept
+func (wf *Workflow) SetAOIConsolidationLayouts(ctx context.Context, aoi string, layouts []string) error {
+	consolidation, err := wf.AOIConsolidation(ctx, aoi)
+	if err != nil {
+		return fmt.Errorf("SetAOIConsolidationLayouts.%w", err)
+	}
+	consolidation.Layouts = layouts
+	if err = wf.UpdateAOIConsolidation(ctx, aoi, consolidation); err != nil {
+		return fmt.Errorf("SetAOIConsolidationLayouts.%w", err)
+	}
+	return nil
+}
+
+// consolidator returns the indexer if it is able to consolidate the datasets
+func (wf *Workflow) consolidator() (indexer.Consolidator, bool) {
+	if wf.catalog == nil || wf.catalog.Indexer == nil {
+		return nil, false
+	}
+	consolidator, ok := wf.catalog.Indexer.(indexer.Consolidator)
+	return consolidator, ok
+}
+
+// markConsolidation marks the consolidation of the AOI as pending (if layouts are defined), in the transaction of the update of the status of the AOI.
+// The jobs are started by RunConsolidations, after the commit of the transaction.
+func (wf *Workflow) markConsolidation(ctx context.Context, wfb db.WorkflowBackend, aoi string) error {
+	consolidation, err := wfb.AOIConsolidation(ctx, aoi)
+	if err != nil {
+		return fmt.Errorf("markConsolidation.%w", err)
+	}
+	if len(consolidation.Layouts) == 0 {
+		return nil
+	}
+	consolidation.Pending = true
+	if err := wfb.UpdateAOIConsolidation(ctx, aoi, consolidation); err != nil {
+		return fmt.Errorf("markConsolidation.%w", err)
+	}
+	// Wake up RunConsolidations
+	select {
+	case wf.consolidationc <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RunConsolidations starts the pending consolidations every period or when an AOI is DONE, until the context is done
+func (wf *Workflow) RunConsolidations(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wf.consolidationc:
+			// Wait for the end of the transaction that marked the consolidation
+			wf.dbmu.Lock()
+			wf.dbmu.Unlock()
+		}
+		if err := wf.StartPendingConsolidations(ctx); err != nil {
+			log.Logger(ctx).Sugar().Errorf("RunConsolidations: %v", err)
+		}
+	}
+}
+
+// StartPendingConsolidations starts the jobs of the pending consolidations
+// The consolidations are claimed in a transaction, so that they are started only once by several workflow servers.
+// The jobs are started outside the transaction.
+func (wf *Workflow) StartPendingConsolidations(ctx context.Context) error {
+	var aois []string
+	if err := db.UnitOfWork(ctx, wf, func(tx db.WorkflowTxBackend) error {
+		var err error
+		if aois, err = tx.PendingConsolidations(ctx); err != nil {
+			return err
+		}
+		for _, aoi := range aois {
+			consolidation, err := tx.AOIConsolidation(ctx, aoi)
+			if err != nil {
+				return err
+			}
+			consolidation.Pending = false
+			if err := tx.UpdateAOIConsolidation(ctx, aoi, consolidation); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("StartPendingConsolidations.%w", err)
+	}
+
+	for _, aoi := range aois {
+		// The consolidation is optional: its failure must not fail the workflow
+		if err := wf.startConsolidation(ctx, aoi); err != nil {
+			log.Logger(ctx).Sugar().Errorf("AOI %s: %v", aoi, err)
+		}
+	}
+	return nil
+}
+
+// startConsolidation starts a consolidation job for each layout and each instance of the AOI (if layouts are defined)
+// The instances are the ones of the DONE scenes, and the records are consolidated all together.
+func (wf *Workflow) startConsolidation(ctx context.Context, aoi string) error {
+	consolidation, err := wf.AOIConsolidation(ctx, aoi)
+	if err != nil {
+		return fmt.Errorf("startConsolidation.%w", err)
+	}
+	if len(consolidation.Layouts) == 0 {
+		return nil
+	}
+	consolidator, ok := wf.consolidator()
+	if !ok {
+		log.Logger(ctx).Sugar().Warnf("AOI %s: consolidation is not supported by the indexer", aoi)
+		return nil
+	}
+
+	// List records and instances
+	scenes, err := wf.Scenes(ctx, aoi, common.StatusDONE.String(), 0, -1)
+	if err != nil {
+		return fmt.Errorf("startConsolidation.%w", err)
+	}
+	records, instances := map[string]struct{}{}, map[string]struct{}{}
+	for _, scene := range scenes {
+		if scene.Data.RecordID != "" {
+			records[scene.Data.RecordID] = struct{}{}
+		}
+		for _, instanceID := range scene.Data.InstancesID {
+			instances[instanceID] = struct{}{}
+		}
+	}
+	recordIDs := make([]string, 0, len(records))
+	for recordID := range records {
+		recordIDs = append(recordIDs, recordID)
+	}
+	instanceIDs := make([]string, 0, len(instances))
+	for instanceID := range instances {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	sort.Strings(recordIDs)
+	sort.Strings(instanceIDs)
+
+	// Start jobs
+	consolidation.Jobs = nil
+	now := time.Now().Unix()
+	for _, layout := range consolidation.Layouts {
+		for _, instanceID := range instanceIDs {
+			job := db.ConsolidationJob{Layout: layout, InstanceID: instanceID}
+			jobName := fmt.Sprintf("ingester_%s_%s_%s_%d", aoi, layout, instanceID, now)
+			if job.JobID, err = consolidator.Consolidate(ctx, jobName, instanceID, layout, recordIDs); err != nil {
+				log.Logger(ctx).Sugar().Errorf("AOI %s: %v", aoi, err)
+				job.Status, job.Terminated, job.Message = ConsolidationFailed, true, err.Error()
+			} else {
+				log.Logger(ctx).Sugar().Infof("AOI %s: consolidation job %s started (layout: %s, instance: %s)", aoi, job.JobID, layout, instanceID)
+				job.Status = ConsolidationStarted
+			}
+			consolidation.Jobs = append(consolidation.Jobs, job)
+		}
+	}
+	if err := wf.UpdateAOIConsolidation(ctx, aoi, consolidation); err != nil {
+		return fmt.Errorf("startConsolidation.%w", err)
+	}
+	return nil
+}
+
+// RefreshAOIConsolidation updates the status of the consolidation jobs of the AOI that are not terminated
+func (wf *Workflow) RefreshAOIConsolidation(ctx context.Context, aoi string) (db.Consolidation, error) {
+	consolidation, err := wf.AOIConsolidation(ctx, aoi)
+	if err != nil {
+		return consolidation, fmt.Errorf("RefreshAOIConsolidation.%w", err)
+	}
+	consolidator, ok := wf.consolidator()
+	if !ok {
+		return consolidation, nil
+	}
+	updated := false
+	for i, job := range consolidation.Jobs {
+		if job.Terminated || job.JobID == "" {
+			continue
+		}
+		status, terminated, err := consolidator.JobStatus(ctx, job.JobID)
+		if err != nil {
+			log.Logger(ctx).Sugar().Warnf("AOI %s: %v", aoi, err)
+			continue
+		}
+		if status != job.Status || terminated != job.Terminated {
+			consolidation.Jobs[i].Status, consolidation.Jobs[i].Terminated = status, terminated
+			updated = true
+		}
+	}
+	if updated {
+		if err := wf.UpdateAOIConsolidation(ctx, aoi, consolidation); err != nil {
+			return consolidation, fmt.Errorf("RefreshAOIConsolidation.%w", err)
+		}
+	}
+	return consolidation, nil
+}
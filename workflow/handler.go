@@ -29,6 +29,7 @@ func (wf *Workflow) NewRouter() *mux.Router {
 	r.HandleFunc("/scene/{scene}/force/{status}", wf.ForceSceneStatusHandler).Methods("PUT")
 	r.HandleFunc("/tile/{tile}", wf.GetTileHandler).Methods("GET")
 	r.HandleFunc("/tile/{tile}/data", wf.UpdateTileDataHandler).Methods("PUT")
+	r.HandleFunc("/tile/{tile}/outputs", wf.ListTileOutputsHandler).Methods("GET")
 	r.HandleFunc("/tile/{tile}/retry", wf.RetryTileHandler).Methods("PUT")
 	r.HandleFunc("/tile/{tile}/fail", wf.FailTileHandler).Methods("PUT")
 	r.HandleFunc("/tile/{tile}/force/{status}", wf.ForceTileStatusHandler).Methods("PUT")
@@ -37,21 +38,47 @@ func (wf *Workflow) NewRouter() *mux.Router {
 	r.HandleFunc("/aoi/{aoi}", wf.CreateAOIHandler).Methods("POST")
 	r.HandleFunc("/aoi/{aoi}", wf.DeleteAOIHandler).Methods("DELETE")
 	r.HandleFunc("/aoi/{aoi}/dot", wf.PrintDotHandler).Methods("GET")
+	r.HandleFunc("/aoi/{aoi}/consolidation", wf.GetAOIConsolidationHandler).Methods("GET")
+	r.HandleFunc("/aoi/{aoi}/consolidation", wf.SetAOIConsolidationHandler).Methods("POST")
+	r.HandleFunc("/aoi/{aoi}/parent/{parent}", wf.SetAOIParentHandler).Methods("POST")
+	r.HandleFunc("/aoi/{aoi}/children", wf.ListChildAOIsHandler).Methods("GET")
 	r.HandleFunc("/aoi/{aoi}/scene", wf.CreateSceneHandler).Methods("POST")
 	r.HandleFunc("/aoi/{aoi}/scenes", wf.ListScenesHandler).Methods("GET")
 	r.HandleFunc("/aoi/{aoi}/scenes/{status}", wf.ListScenesHandler).Methods("GET")
 	r.HandleFunc("/aoi/{aoi}/tiles/{status}", wf.ListAOITilesHandler).Methods("GET")
 	r.HandleFunc("/aoi/{aoi}/roottiles", wf.ListRootTilesHandler).Methods("GET")
 	r.HandleFunc("/aoi/{aoi}/leaftiles", wf.ListLeafTilesHandler).Methods("GET")
+	r.HandleFunc("/aoi/{aoi}/outputs", wf.ListAOIOutputsHandler).Methods("GET")
 	r.HandleFunc("/aoi/{aoi}/retry", wf.RetryAOIHandler).Methods("PUT")
 	r.HandleFunc("/aoi/{aoi}/retry/{force}", wf.RetryAOIHandler).Methods("PUT")
+	r.HandleFunc("/graphs/stats", wf.GraphsStatsHandler).Methods("GET")
+	r.HandleFunc("/subscription/", wf.ListSubscriptionsHandler).Methods("GET")
+	r.HandleFunc("/subscription/{subscription}", wf.GetSubscriptionHandler).Methods("GET")
+	r.HandleFunc("/subscription/{subscription}", wf.CreateSubscriptionHandler).Methods("POST")
+	r.HandleFunc("/subscription/{subscription}", wf.UpdateSubscriptionHandler).Methods("PUT")
+	r.HandleFunc("/subscription/{subscription}", wf.DeleteSubscriptionHandler).Methods("DELETE")
+	r.HandleFunc("/subscription/{subscription}/run", wf.RunSubscriptionHandler).Methods("PUT")
 	return r
 }
 
 func (wf *Workflow) ResultHandler(ctx context.Context, result common.Result) error {
 	var err error
+	if result.Status == common.StatusDONE && result.Duration > 0 {
+		// Statistics are not critical
+		if err := wf.AddProcessingDuration(ctx, result.Type, result.ID, result.Duration); err != nil {
+			log.Logger(ctx).Sugar().Warnf("ResultHandler: %v", err)
+		}
+	}
 	switch result.Type {
 	case common.ResultTypeTile:
+		if len(result.Outputs) > 0 {
+			if err = wf.UpdateTileOutputs(ctx, result.Outputs); err != nil {
+				if !errors.As(err, &db.ErrNotFound{}) {
+					return err
+				}
+				log.Logger(ctx).Sugar().Errorf("ResultHandler: %v", err)
+			}
+		}
 		_, err = wf.UpdateTileStatus(ctx, result.ID, result.Status, &result.Message, false)
 	case common.ResultTypeScene:
 		_, err = wf.UpdateSceneStatus(ctx, result.ID, result.Status, &result.Message, false)
@@ -288,6 +315,25 @@ func (wf *Workflow) UpdateTileDataHandler(w http.ResponseWriter, req *http.Reque
 	w.WriteHeader(204)
 }
 
+// ListTileOutputsHandler lists the layers produced by the processing of the tile
+func (wf *Workflow) ListTileOutputsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	tilestr := mux.Vars(req)["tile"]
+	tile, err := strconv.Atoi(tilestr)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	outputs, err := wf.TileOutputs(ctx, tile)
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.ListTileOutputsHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(outputs)
+}
+
 // RetryTileHandler retries the tile if its status is RETRY
 func (wf *Workflow) RetryTileHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
@@ -409,6 +455,18 @@ func (wf *Workflow) GetAOIStatusHandler(w http.ResponseWriter, req *http.Request
 		fmt.Fprintf(w, "%v", err)
 		return
 	}
+	consolidation, err := wf.AOIConsolidation(ctx, aoi)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	children, err := wf.ChildAOIs(ctx, aoi)
+	if err != nil {
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
 	from := time.Now()
 	to := time.Time{}
 	for _, tile := range rootTiles {
@@ -430,6 +488,92 @@ func (wf *Workflow) GetAOIStatusHandler(w http.ResponseWriter, req *http.Request
 		tilesStatus.New, tilesStatus.Pending, tilesStatus.Done, tilesStatus.Retry, tilesStatus.Failed,
 		tilesStatus.New+tilesStatus.Pending+tilesStatus.Done+tilesStatus.Retry+tilesStatus.Failed)
 	fmt.Fprintf(w, "\nRoot tiles : %d\n  From: %s\n  To:   %s\n", len(rootTiles), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if len(children) > 0 {
+		fmt.Fprintf(w, "\nChild AOIs (scenes and tiles are aggregated): %d\n", len(children))
+		for _, child := range children {
+			fmt.Fprintf(w, "  %s: %s\n", child.ID, child.Status)
+		}
+	}
+	if len(consolidation.Layouts) > 0 {
+		fmt.Fprintf(w, "\nConsolidation (layouts: %s):\n", strings.Join(consolidation.Layouts, ", "))
+		for _, job := range consolidation.Jobs {
+			fmt.Fprintf(w, "  %s/%s: job %s %s %s\n", job.Layout, job.InstanceID, job.JobID, job.Status, job.Message)
+		}
+	}
+}
+
+// GetAOIConsolidationHandler returns the consolidation of the aoi and the status of its jobs
+func (wf *Workflow) GetAOIConsolidationHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	consolidation, err := wf.RefreshAOIConsolidation(ctx, mux.Vars(req)["aoi"])
+	if err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.GetAOIConsolidationHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(consolidation)
+}
+
+// SetAOIConsolidationHandler sets the layouts to consolidate the aoi in, when it is DONE
+func (wf *Workflow) SetAOIConsolidationHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	consolidation := db.Consolidation{}
+	if err := json.NewDecoder(req.Body).Decode(&consolidation); err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	if err := wf.SetAOIConsolidationLayouts(ctx, mux.Vars(req)["aoi"], consolidation.Layouts); err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.SetAOIConsolidationHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// SetAOIParentHandler sets the parent of the aoi (child of a split aoi)
+func (wf *Workflow) SetAOIParentHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	aoi, parent := mux.Vars(req)["aoi"], mux.Vars(req)["parent"]
+	if aoi == parent {
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "an aoi cannot be its own parent")
+		return
+	}
+	if err := wf.SetAOIParent(ctx, aoi, parent); err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.SetAOIParentHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// ListChildAOIsHandler lists the children of the aoi and their status
+func (wf *Workflow) ListChildAOIsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	aois, err := wf.ChildAOIs(ctx, mux.Vars(req)["aoi"])
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.ListChildAOIsHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(aois)
 }
 
 // CreateAOIHandler creates a new aoi
@@ -553,6 +697,31 @@ func (wf *Workflow) ListLeafTilesHandler(w http.ResponseWriter, req *http.Reques
 	json.NewEncoder(w).Encode(ims)
 }
 
+// ListAOIOutputsHandler exports the layers produced by all the tiles of the AOI
+// The layer can be filtered using the "layer" parameter
+func (wf *Workflow) ListAOIOutputsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	aoi := mux.Vars(req)["aoi"]
+	page := 0
+	paramPage := req.FormValue("page")
+	if p, err := strconv.Atoi(paramPage); err == nil {
+		page = p
+	}
+	limit := -1
+	paramLimit := req.FormValue("limit")
+	if l, err := strconv.Atoi(paramLimit); err == nil {
+		limit = l
+	}
+	outputs, err := wf.AOIOutputs(ctx, aoi, req.FormValue("layer"), page, limit)
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.ListAOIOutputsHandler: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(outputs)
+}
+
 // RetryAOIHandler retries all the scenes and tiles with the status 'RETRY' (and also 'PENDING' if force=true)
 func (wf *Workflow) RetryAOIHandler(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
@@ -636,3 +805,137 @@ func (wf *Workflow) RetryAOIHandler(w http.ResponseWriter, req *http.Request) {
 		json.NewEncoder(w).Encode(struct{ Scenes, Tiles int }{nbScenes, nbTiles})
 	}
 }
+
+// GraphsStatsHandler returns the statistics of the processings per graph (mean duration and mean size of the outputs)
+func (wf *Workflow) GraphsStatsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	stats, err := wf.GraphsStats(ctx)
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.GraphsStats: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(stats)
+}
+
+// ListSubscriptionsHandler lists the subscriptions and the status of their last run
+func (wf *Workflow) ListSubscriptionsHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	subscriptions, err := wf.Subscriptions(ctx)
+	if err != nil {
+		log.Logger(ctx).Sugar().Warnf("wf.Subscriptions: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+// GetSubscriptionHandler returns a subscription and the status of its last run
+func (wf *Workflow) GetSubscriptionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	subscription, err := wf.Subscription(ctx, mux.Vars(req)["subscription"])
+	if err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.Subscription: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	json.NewEncoder(w).Encode(subscription)
+}
+
+// decodeSubscription decodes the definition of a subscription (area, polling_interval, lookback, enabled, next_run)
+func decodeSubscription(req *http.Request) (db.Subscription, error) {
+	subscription := db.Subscription{Enabled: true}
+	if err := json.NewDecoder(req.Body).Decode(&subscription); err != nil {
+		return subscription, err
+	}
+	subscription.ID = mux.Vars(req)["subscription"]
+	return subscription, nil
+}
+
+// CreateSubscriptionHandler creates a new subscription
+func (wf *Workflow) CreateSubscriptionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	subscription, err := decodeSubscription(req)
+	if err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	if err := wf.AddSubscription(ctx, subscription); err != nil {
+		if errors.As(err, &db.ErrAlreadyExists{}) {
+			w.WriteHeader(409)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.AddSubscription: %v", err)
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// UpdateSubscriptionHandler updates the definition of a subscription
+func (wf *Workflow) UpdateSubscriptionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	subscription, err := decodeSubscription(req)
+	if err != nil {
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	if err := wf.ModifySubscription(ctx, subscription); err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.ModifySubscription: %v", err)
+		w.WriteHeader(400)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// DeleteSubscriptionHandler deletes a subscription (the AOI and its scenes are kept)
+func (wf *Workflow) DeleteSubscriptionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if err := wf.DeleteSubscription(ctx, mux.Vars(req)["subscription"]); err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.DeleteSubscription: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// RunSubscriptionHandler schedules the next run of a subscription now (it will be run by the scheduler)
+func (wf *Workflow) RunSubscriptionHandler(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	subscription, err := wf.Subscription(ctx, mux.Vars(req)["subscription"])
+	if err == nil {
+		subscription.NextRun = time.Now()
+		err = wf.UpdateSubscription(ctx, subscription)
+	}
+	if err != nil {
+		if errors.As(err, &db.ErrNotFound{}) {
+			w.WriteHeader(404)
+			return
+		}
+		log.Logger(ctx).Sugar().Warnf("wf.RunSubscription: %v", err)
+		w.WriteHeader(500)
+		fmt.Fprintf(w, "%v", err)
+		return
+	}
+	w.WriteHeader(204)
+}
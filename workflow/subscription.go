@@ -0,0 +1,226 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+	"github.com/airbusgeo/geocube-ingester/service/log"
+)
+
+// subscriptionSettings returns the area, the polling interval and the lookback of the subscription
+func subscriptionSettings(s db.Subscription) (entities.AreaToIngest, time.Duration, time.Duration, error) {
+	var area entities.AreaToIngest
+	if err := json.Unmarshal(s.Area, &area); err != nil {
+		return area, 0, 0, fmt.Errorf("subscription %s: wrong area: %w", s.ID, err)
+	}
+	if area.AOIID == "" {
+		return area, 0, 0, fmt.Errorf("subscription %s: the name of the area is missing", s.ID)
+	}
+	interval, err := time.ParseDuration(s.PollingInterval)
+	if err != nil || interval <= 0 {
+		return area, 0, 0, fmt.Errorf("subscription %s: polling_interval must be a positive duration (found '%s')", s.ID, s.PollingInterval)
+	}
+	var lookback time.Duration
+	if s.Lookback != "" {
+		if lookback, err = time.ParseDuration(s.Lookback); err != nil || lookback < 0 {
+			return area, 0, 0, fmt.Errorf("subscription %s: lookback must be a positive duration (found '%s')", s.ID, s.Lookback)
+		}
+	}
+	return area, interval, lookback, nil
+}
+
+// subscriptionWindow returns the window of the next run of the subscription:
+// from the end of the window of the last successful run (minus the lookback) or from the start time of the area, to now.
+func subscriptionWindow(s db.Subscription, area entities.AreaToIngest, lookback time.Duration, now time.Time) (time.Time, time.Time) {
+	start := area.StartTime
+	if !s.WindowEnd.IsZero() && s.WindowEnd.Add(-lookback).After(start) {
+		start = s.WindowEnd.Add(-lookback)
+	}
+	return start, now
+}
+
+// AddSubscription checks and creates a subscription. Its first run is scheduled now, unless NextRun is defined.
+// May return ErrAlreadyExists
+func (wf *Workflow) AddSubscription(ctx context.Context, s db.Subscription) error {
+	if _, _, _, err := subscriptionSettings(s); err != nil {
+		return fmt.Errorf("AddSubscription: %w", err)
+	}
+	if s.NextRun.IsZero() {
+		s.NextRun = time.Now()
+	}
+	s.WindowEnd, s.LastRun = time.Time{}, db.SubscriptionRun{}
+	if err := wf.CreateSubscription(ctx, s); err != nil {
+		return fmt.Errorf("AddSubscription.%w", err)
+	}
+	return nil
+}
+
+// ModifySubscription checks and updates the definition of a subscription (area, polling interval, lookback, enabled and next run if defined)
+// The state of the subscription (window and last run) is kept. May return ErrNotFound
+func (wf *Workflow) ModifySubscription(ctx context.Context, s db.Subscription) error {
+	if _, _, _, err := subscriptionSettings(s); err != nil {
+		return fmt.Errorf("ModifySubscription: %w", err)
+	}
+	current, err := wf.Subscription(ctx, s.ID)
+	if err != nil {
+		return fmt.Errorf("ModifySubscription.%w", err)
+	}
+	if s.NextRun.IsZero() {
+		s.NextRun = current.NextRun
+	}
+	s.WindowEnd, s.LastRun = current.WindowEnd, current.LastRun
+	if err := wf.UpdateSubscription(ctx, s); err != nil {
+		return fmt.Errorf("ModifySubscription.%w", err)
+	}
+	return nil
+}
+
+// RunSubscriptions polls the due subscriptions every period, until the context is done
+func (wf *Workflow) RunSubscriptions(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := wf.runDueSubscriptions(ctx, time.Now()); err != nil {
+			log.Logger(ctx).Sugar().Errorf("RunSubscriptions: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueSubscriptions runs the subscriptions that are due
+// The subscriptions are claimed in a transaction (their next run is scheduled), so that they are run only once by several workflow servers
+func (wf *Workflow) runDueSubscriptions(ctx context.Context, now time.Time) error {
+	var subscriptions []db.Subscription
+	if err := db.UnitOfWork(ctx, wf, func(tx db.WorkflowTxBackend) error {
+		var err error
+		if subscriptions, err = tx.DueSubscriptions(ctx, now); err != nil {
+			return err
+		}
+		for i, s := range subscriptions {
+			interval, err := time.ParseDuration(s.PollingInterval)
+			if err != nil || interval <= 0 {
+				interval = time.Hour // Wrong subscription: it will fail and be retried later
+			}
+			subscriptions[i].NextRun = now.Add(interval)
+			if err := tx.UpdateSubscription(ctx, subscriptions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("runDueSubscriptions.%w", err)
+	}
+
+	for _, s := range subscriptions {
+		run := wf.RunSubscription(ctx, s, now)
+		log.Logger(ctx).Sugar().Infof("subscription %s: %s (%d scenes, %d tiles) %s", s.ID, run.Status, run.ScenesNb, run.TilesNb, run.Message)
+	}
+	return nil
+}
+
+// RunSubscription ingests the new scenes of the area of the subscription acquired during the window of the run (see subscriptionWindow)
+// and saves the status of the run. The scenes already ingested in the AOI (or in its children if the area is split) are ignored.
+// If the run fails, the next run will retry the whole window.
+func (wf *Workflow) RunSubscription(ctx context.Context, s db.Subscription, now time.Time) db.SubscriptionRun {
+	run := db.SubscriptionRun{RunAt: now, Status: db.SubscriptionRunDone}
+	if err := wf.runSubscription(ctx, s, now, &run); err != nil {
+		run.Status, run.Message = db.SubscriptionRunFailed, err.Error()
+	}
+
+	// Save the run (the subscription may have been modified in the meantime)
+	current, err := wf.Subscription(ctx, s.ID)
+	if err != nil {
+		log.Logger(ctx).Sugar().Errorf("RunSubscription[%s].%v", s.ID, err)
+		return run
+	}
+	current.LastRun = run
+	if run.Status == db.SubscriptionRunDone {
+		current.WindowEnd = run.End
+	}
+	if err := wf.UpdateSubscription(ctx, current); err != nil {
+		log.Logger(ctx).Sugar().Errorf("RunSubscription[%s].%v", s.ID, err)
+	}
+	return run
+}
+
+func (wf *Workflow) runSubscription(ctx context.Context, s db.Subscription, now time.Time, run *db.SubscriptionRun) error {
+	if wf.catalog == nil {
+		return fmt.Errorf("runSubscription: catalog is not defined")
+	}
+	area, _, lookback, err := subscriptionSettings(s)
+	if err != nil {
+		return fmt.Errorf("runSubscription: %w", err)
+	}
+	run.Start, run.End = subscriptionWindow(s, area, lookback, now)
+	area.StartTime, area.EndTime = run.Start, run.End
+	area.Page, area.Limit = 0, 0
+	if err := wf.catalog.LoadAOI(ctx, &area); err != nil {
+		return fmt.Errorf("runSubscription.%w", err)
+	}
+
+	// Scenes inventory
+	scenes, err := wf.catalog.DoScenesInventory(ctx, area)
+	if err != nil {
+		return fmt.Errorf("runSubscription.%w", err)
+	}
+
+	// Remove the scenes already ingested
+	if scenes.Scenes, err = newScenes(ctx, wf, area.AOIID, scenes.Scenes); err != nil {
+		return fmt.Errorf("runSubscription.%w", err)
+	}
+	if len(scenes.Scenes) == 0 {
+		return nil
+	}
+
+	result, err := wf.catalog.IngestArea(ctx, area, scenes, entities.Scenes{}, "")
+	if err != nil {
+		return fmt.Errorf("runSubscription.%w", err)
+	}
+	run.ScenesNb, run.TilesNb = len(result.ScenesID), result.TilesNb
+	return nil
+}
+
+// newScenes returns the scenes that are not already ingested in the AOI or in one of its children (if the AOI is split, the scenes are ingested in the children)
+func newScenes(ctx context.Context, wfb db.WorkflowBackend, aoi string, scenes []*entities.Scene) ([]*entities.Scene, error) {
+	children, err := wfb.ChildAOIs(ctx, aoi)
+	if err != nil {
+		return nil, fmt.Errorf("newScenes.%w", err)
+	}
+	aois := []string{aoi}
+	for _, child := range children {
+		aois = append(aois, child.ID)
+	}
+
+	var notIngested []*entities.Scene
+	for _, scene := range scenes {
+		ingested, err := sceneIngested(ctx, wfb, aois, scene.SourceID)
+		if err != nil {
+			return nil, fmt.Errorf("newScenes.%w", err)
+		}
+		if !ingested {
+			notIngested = append(notIngested, scene)
+		}
+	}
+	return notIngested, nil
+}
+
+// sceneIngested returns true if the scene is ingested in one of the AOIs
+func sceneIngested(ctx context.Context, wfb db.WorkflowBackend, aois []string, sourceID string) (bool, error) {
+	for _, aoi := range aois {
+		if _, err := wfb.SceneId(ctx, aoi, sourceID); err == nil {
+			return true, nil
+		} else if !errors.As(err, &db.ErrNotFound{}) {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/airbusgeo/geocube-ingester/catalog/entities"
+	"github.com/airbusgeo/geocube-ingester/common"
+	db "github.com/airbusgeo/geocube-ingester/interface/database"
+)
+
+func TestSubscriptionWindow(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	windowEnd := time.Date(2023, 2, 20, 12, 0, 0, 0, time.UTC)
+	area := entities.AreaToIngest{StartTime: start}
+	for _, test := range []struct {
+		name      string
+		windowEnd time.Time
+		lookback  time.Duration
+		start     time.Time
+	}{
+		{"first run", time.Time{}, 72 * time.Hour, start},
+		{"without lookback", windowEnd, 0, windowEnd},
+		{"with lookback", windowEnd, 72 * time.Hour, windowEnd.Add(-72 * time.Hour)},
+		{"lookback before the start time", start.Add(24 * time.Hour), 72 * time.Hour, start},
+	} {
+		s, e := subscriptionWindow(db.Subscription{WindowEnd: test.windowEnd}, area, test.lookback, now)
+		if !s.Equal(test.start) || !e.Equal(now) {
+			t.Errorf("%s: expecting [%v, %v], got [%v, %v]", test.name, test.start, now, s, e)
+		}
+	}
+}
+
+func TestSubscriptionSettings(t *testing.T) {
+	area := `{"name":"aoi","type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}`
+	s := db.Subscription{ID: "sub", Area: []byte(area), PollingInterval: "24h", Lookback: "72h"}
+	if _, interval, lookback, err := subscriptionSettings(s); err != nil || interval != 24*time.Hour || lookback != 72*time.Hour {
+		t.Errorf("expecting 24h and 72h, got %v, %v (%v)", interval, lookback, err)
+	}
+	for _, wrong := range []db.Subscription{
+		{ID: "sub", Area: []byte(`{"type":"Feature","geometry":{"type":"Point","coordinates":[0,0]}}`), PollingInterval: "24h"},
+		{ID: "sub", Area: []byte(area), PollingInterval: "0s"},
+		{ID: "sub", Area: []byte(area), PollingInterval: "24h", Lookback: "-1h"},
+	} {
+		if _, _, _, err := subscriptionSettings(wrong); err == nil {
+			t.Errorf("%v: expecting an error", wrong)
+		}
+	}
+}
+
+// ingestedBackend implements db.WorkflowBackend (only SceneId and ChildAOIs)
+type ingestedBackend struct {
+	db.WorkflowBackend
+	scenes   map[string]int      // aoi/sourceID: id
+	children map[string][]string // aoi: children
+	err      error
+}
+
+func (b ingestedBackend) ChildAOIs(ctx context.Context, parent string) ([]db.AOI, error) {
+	var aois []db.AOI
+	for _, child := range b.children[parent] {
+		aois = append(aois, db.AOI{ID: child, Parent: parent})
+	}
+	return aois, nil
+}
+
+func (b ingestedBackend) SceneId(ctx context.Context, aoi, sourceID string) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if id, ok := b.scenes[aoi+"/"+sourceID]; ok {
+		return id, nil
+	}
+	return 0, db.ErrNotFound{Type: "scene", ID: sourceID}
+}
+
+func TestNewScenes(t *testing.T) {
+	ctx := context.Background()
+	var scenes []*entities.Scene
+	for _, id := range []string{"S1", "S2", "S3"} {
+		scenes = append(scenes, &entities.Scene{Scene: common.Scene{SourceID: id}})
+	}
+	// S2 is already ingested in the aoi, S3 in another aoi
+	backend := ingestedBackend{scenes: map[string]int{"aoi/S2": 1, "other/S3": 2}}
+	notIngested, err := newScenes(ctx, backend, "aoi", scenes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(notIngested, []*entities.Scene{scenes[0], scenes[2]}) {
+		t.Errorf("expecting S1 and S3, got %v", notIngested)
+	}
+	if len(scenes) != 3 || scenes[1].SourceID != "S2" {
+		t.Errorf("the input scenes must not be modified")
+	}
+
+	// Every scene is already ingested
+	backend.scenes["aoi/S1"], backend.scenes["aoi/S3"] = 3, 4
+	if notIngested, err = newScenes(ctx, backend, "aoi", scenes); err != nil || len(notIngested) != 0 {
+		t.Errorf("expecting no scene, got %v (%v)", notIngested, err)
+	}
+
+	// Split AOI: S2 is already ingested in a child of the aoi
+	backend = ingestedBackend{scenes: map[string]int{"aoi_1/S2": 1, "other/S3": 2}, children: map[string][]string{"aoi": {"aoi_0", "aoi_1"}}}
+	if notIngested, err = newScenes(ctx, backend, "aoi", scenes); err != nil || !reflect.DeepEqual(notIngested, []*entities.Scene{scenes[0], scenes[2]}) {
+		t.Errorf("expecting S1 and S3, got %v (%v)", notIngested, err)
+	}
+
+	// Database error
+	backend.err = errors.New("connection lost")
+	if _, err := newScenes(ctx, backend, "aoi", scenes); err == nil {
+		t.Error("expecting an error")
+	}
+}
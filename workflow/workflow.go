@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"sync"
 
@@ -24,6 +25,8 @@ type Workflow struct {
 	tileQueue  messaging.Publisher
 
 	catalog *catalog.Catalog
+	// To wake up RunConsolidations when a consolidation is pending
+	consolidationc chan struct{}
 }
 
 func NewWorkflow(db db.WorkflowDBBackend, sceneQueue, tileQueue messaging.Publisher, catalog *catalog.Catalog) *Workflow {
@@ -32,16 +35,14 @@ func NewWorkflow(db db.WorkflowDBBackend, sceneQueue, tileQueue messaging.Publis
 		sceneQueue:        sceneQueue,
 		tileQueue:         tileQueue,
 		catalog:           catalog,
+		consolidationc:    make(chan struct{}, 1),
 	}
 }
 
 func (wf *Workflow) FailTile(ctx context.Context, tile db.Tile, tx db.WorkflowTxBackend) error {
 	lg := log.Logger(ctx).Sugar()
-	var ptile db.Tile
-	var err error
 	if tile.PreviousID != nil {
-		var sceneStatus common.Status
-		ptile, sceneStatus, err = wf.Tile(ctx, *tile.PreviousID, true)
+		ptile, sceneStatus, err := wf.Tile(ctx, *tile.PreviousID, true)
 		if err != nil {
 			return fmt.Errorf("get previous tile: %w", err)
 		}
@@ -63,12 +64,30 @@ func (wf *Workflow) FailTile(ctx context.Context, tile db.Tile, tx db.WorkflowTx
 	if err != nil {
 		return fmt.Errorf("FailTile.%w", err)
 	}
-	// If root tile, update reference
-	if tile.ReferenceID == nil && len(bids) != 0 {
-		if err = tx.UpdateRefTiles(ctx, tile.ID, bids[0]); err != nil {
+	// If reference tile, update reference (the first next tile becomes the reference)
+	// If there is no next tile, the reference is removed and the tiles that depended on it may be ready
+	var rbids []int
+	if tile.ReferenceID == nil {
+		if len(bids) != 0 {
+			_, err = tx.UpdateRefTiles(ctx, tile.ID, &bids[0])
+		} else {
+			rbids, err = tx.UpdateRefTiles(ctx, tile.ID, nil)
+		}
+		if err != nil {
 			return fmt.Errorf("FailTile.%w", err)
 		}
 	}
+	// Remove the tile from the additional inputs of its dependants
+	ibids, err := tx.RemoveTilesInput(ctx, tile.ID)
+	if err != nil {
+		return fmt.Errorf("FailTile.%w", err)
+	}
+	for _, bid := range slices.Concat(rbids, ibids) {
+		if !slices.Contains(bids, bid) {
+			bids = append(bids, bid)
+		}
+	}
+
 	var publishes [][]byte
 	idsToMarkPending := []int{}
 	for _, bid := range bids {
@@ -79,10 +98,15 @@ func (wf *Workflow) FailTile(ctx context.Context, tile db.Tile, tx db.WorkflowTx
 		if ctile.Status != common.StatusNEW {
 			return fmt.Errorf("child tile %d status %s", bid, ctile.Status)
 		}
-		if sceneStatus == common.StatusDONE {
+		if sceneStatus != common.StatusDONE {
+			continue
+		}
+		if ready, err := inputsDone(ctx, tx, ctile); err != nil {
+			return fmt.Errorf("FailTile.%w", err)
+		} else if ready {
 			idsToMarkPending = append(idsToMarkPending, ctile.ID)
 			lg.Infof("queueing tile %s/%s", ctile.Scene.SourceID, ctile.SourceID)
-			prepublish, err := wf.prepublishTile(ctx, ctile.Tile, &ptile.Tile, ctile.ReferenceID)
+			prepublish, err := wf.prepublishTile(ctx, tx, ctile)
 			if err != nil {
 				return fmt.Errorf("FailTile.%w", err)
 			}
@@ -100,14 +124,13 @@ func (wf *Workflow) FailTile(ctx context.Context, tile db.Tile, tx db.WorkflowTx
 }
 
 func (wf *Workflow) RetryTile(ctx context.Context, tile db.Tile) error {
-	var ptile db.Tile
-	if tile.PreviousID != nil {
-		var err error
-		if ptile, _, err = wf.Tile(ctx, *tile.PreviousID, true); err != nil {
-			return fmt.Errorf("get previous tile: %w", err)
+	for _, id := range tile.InputIDs() {
+		itile, _, err := wf.Tile(ctx, id, false)
+		if err != nil {
+			return fmt.Errorf("get input tile: %w", err)
 		}
-		if ptile.Status != common.StatusDONE {
-			return fmt.Errorf("cannot retry tile when previous is %s", ptile.Status)
+		if itile.Status != common.StatusDONE {
+			return fmt.Errorf("cannot retry tile when input %d is %s", id, itile.Status)
 		}
 	}
 
@@ -117,7 +140,7 @@ func (wf *Workflow) RetryTile(ctx context.Context, tile db.Tile) error {
 			return err
 		}
 		lg.Infof("retrying tile %s/%s", tile.Scene.SourceID, tile.SourceID)
-		publish, err := wf.prepublishTile(ctx, tile.Tile, &ptile.Tile, tile.ReferenceID)
+		publish, err := wf.prepublishTile(ctx, tx, tile)
 		if err != nil {
 			return err
 		}
@@ -132,29 +155,25 @@ func (wf *Workflow) RetryTile(ctx context.Context, tile db.Tile) error {
 
 func (wf *Workflow) FinishTile(ctx context.Context, tile db.Tile) error {
 	lg := log.Logger(ctx).Sugar()
-	scenes := map[int]db.Scene{
-		tile.Scene.ID: {Scene: tile.Scene},
-	}
 
 	err := db.UnitOfWork(ctx, wf, func(tx db.WorkflowTxBackend) error {
 		var publishes [][]byte
 		if err := tx.UpdateTile(ctx, tile.ID, common.StatusDONE, nil, false); err != nil {
 			return err
 		}
-		// Update next tiles
-		nextTiles, scenesID, err := tx.UpdateNextTilesStatus(ctx, tile.ID, common.StatusNEW, common.StatusDONE, common.StatusPENDING)
+		// Update next tiles (all their inputs must be done)
+		nextTilesID, err := tx.UpdateNextTilesStatus(ctx, tile.ID, common.StatusNEW, common.StatusDONE, common.StatusDONE, common.StatusPENDING)
 		if err != nil {
 			return err
 		}
 		// Start next tiles
-		for i, nextTile := range nextTiles {
-			sc, err := tx.Scene(ctx, scenesID[i], &scenes)
+		for _, nextTileID := range nextTilesID {
+			nextTile, _, err := tx.Tile(ctx, nextTileID, true)
 			if err != nil {
-				return fmt.Errorf("Tile[%d]Scene[%d].%w", nextTile.ID, scenesID[i], err)
+				return fmt.Errorf("Tile[%d].%w", nextTileID, err)
 			}
-			nextTile.Scene = sc.Scene
 			lg.Infof("queueing tile %s/%s", nextTile.Scene.SourceID, nextTile.SourceID)
-			prepublish, err := wf.prepublishTile(ctx, nextTile.Tile, &tile.Tile, nextTile.ReferenceID)
+			prepublish, err := wf.prepublishTile(ctx, tx, nextTile)
 			if err != nil {
 				return err
 			}
@@ -320,6 +339,11 @@ func (wf *Workflow) Dot(ctx context.Context, aoi string, out io.Writer) error {
 			if tile.PreviousID != nil {
 				fmt.Fprintf(out, "t%d -> t%d%s;\n", *tile.PreviousID, tile.ID, tstyle)
 			}
+			for _, input := range tile.Inputs {
+				if input.ID != nil {
+					fmt.Fprintf(out, "t%d -> t%d [label=\"%s\" color=blue%s];\n", *input.ID, tile.ID, input.Role, tstyle)
+				}
+			}
 		}
 	}
 	return nil
@@ -327,9 +351,7 @@ func (wf *Workflow) Dot(ctx context.Context, aoi string, out io.Writer) error {
 
 func (wf *Workflow) FinishScene(ctx context.Context, scene db.Scene) error {
 	lg := log.Logger(ctx).Sugar()
-	scenes := map[int]db.Scene{scene.ID: scene}
 	var publishes [][]byte
-	scene.Status = common.StatusDONE
 
 	err := db.UnitOfWork(ctx, wf, func(tx db.WorkflowTxBackend) error {
 		// Update scene status
@@ -337,36 +359,18 @@ func (wf *Workflow) FinishScene(ctx context.Context, scene db.Scene) error {
 			return err
 		}
 
-		// Publish root tiles of the scene
-		tiles, err := tx.UpdateSceneRootTilesStatus(ctx, scene.ID, common.StatusNEW, common.StatusPENDING)
-		if err != nil {
-			return err
-		}
-		for _, tile := range tiles {
-			tile.Scene = scene.Scene
-			lg.Infof("queueing root tile %s/%s", tile.Scene.SourceID, tile.SourceID)
-			prepublish, err := wf.prepublishTile(ctx, tile.Tile, nil, nil)
-			if err != nil {
-				return err
-			}
-			publishes = append(publishes, prepublish)
-		}
-
-		// Publish other tiles if prev tile is done
-		ctiles, ptiles, pscenesID, err := tx.UpdateSceneTilesStatus(ctx, scene.ID, common.StatusNEW, common.StatusDONE, common.StatusPENDING)
+		// Publish the tiles of the scene whose inputs are done (root tiles included)
+		tilesID, err := tx.UpdateSceneTilesStatus(ctx, scene.ID, common.StatusNEW, common.StatusDONE, common.StatusPENDING)
 		if err != nil {
 			return err
 		}
-		for i, ctile := range ctiles {
-			ctile.Scene = scene.Scene
-			sc, err := tx.Scene(ctx, pscenesID[i], &scenes)
+		for _, tileID := range tilesID {
+			tile, _, err := tx.Tile(ctx, tileID, true)
 			if err != nil {
-				return fmt.Errorf("get tile %d scene %d: %w", ctile.ID, pscenesID[i], err)
+				return fmt.Errorf("Tile[%d].%w", tileID, err)
 			}
-			ptiles[i].Scene = sc.Scene
-
-			lg.Infof("queueing tile %s/%s (parent %s/%s)", ctile.Scene.SourceID, ctile.SourceID, ptiles[i].Scene.SourceID, ptiles[i].SourceID)
-			prepublish, err := wf.prepublishTile(ctx, ctile.Tile, &ptiles[i].Tile, ctile.ReferenceID)
+			lg.Infof("queueing tile %s/%s", tile.Scene.SourceID, tile.SourceID)
+			prepublish, err := wf.prepublishTile(ctx, tx, tile)
 			if err != nil {
 				return err
 			}
@@ -527,6 +531,11 @@ func (wf *Workflow) IngestScenes(ctx context.Context, aoi string, scenes ...comm
 		if scene.AOI != aoi {
 			return nil, fmt.Errorf("IngestScenes: scene[%s].AOI and aoi are different", scene.SourceID)
 		}
+		for tileID, tile := range scene.Tiles {
+			if err := validateTileInputs(tile); err != nil {
+				return nil, fmt.Errorf("IngestScenes: scene[%s].tile[%s]: %w", scene.SourceID, tileID, err)
+			}
+		}
 
 		// Check that the scene does not already exist
 		if _, err := wf.SceneId(ctx, aoi, scene.SourceID); err != nil {
@@ -558,7 +567,7 @@ func (wf *Workflow) IngestScenes(ctx context.Context, aoi string, scenes ...comm
 			}
 
 			for sourceID, tile := range scene.Tiles {
-				if _, err := tx.CreateTile(ctx, sourceID, scene.ID, tile.Data, aoi, tile.PreviousTileID, tile.PreviousSceneID, tile.ReferenceTileID, tile.ReferenceSceneID, scene.RetryCount); err != nil {
+				if _, err := tx.CreateTile(ctx, sourceID, scene.ID, aoi, tile, scene.RetryCount); err != nil {
 					return err
 				}
 			}
@@ -581,6 +590,21 @@ func (wf *Workflow) IngestScenes(ctx context.Context, aoi string, scenes ...comm
 	return ids, nil
 }
 
+// validateTileInputs checks that the roles of the additional inputs are defined and unique
+func validateTileInputs(tile common.TileToIngest) error {
+	roles := service.StringSet{common.RolePrevious: {}, common.RoleReference: {}}
+	for _, input := range tile.Inputs {
+		if input.Role == "" || input.TileID == "" || input.SceneID == "" {
+			return errors.New("input must have a role, a tile_id and a scene_id")
+		}
+		if roles.Exists(input.Role) {
+			return fmt.Errorf("input role %s is reserved or already used", input.Role)
+		}
+		roles.Push(input.Role)
+	}
+	return nil
+}
+
 // UpdateSceneData update the data of a scene
 func (wf *Workflow) UpdateSceneData(ctx context.Context, sceneID int, data common.SceneAttrs) error {
 	wf.dbmu.Lock()
@@ -607,6 +631,19 @@ func (wf *Workflow) UpdateTileData(ctx context.Context, tileID int, data common.
 	return nil
 }
 
+// UpdateTileOutputs saves the outputs of a tile
+func (wf *Workflow) UpdateTileOutputs(ctx context.Context, outputs []common.TileOutput) error {
+	wf.dbmu.Lock()
+	defer wf.dbmu.Unlock()
+	if err := db.UnitOfWork(ctx, wf, func(tx db.WorkflowTxBackend) error {
+		return tx.SaveTileOutputs(ctx, outputs)
+	}); err != nil {
+		return fmt.Errorf("UpdateTileOutputs.%w", err)
+	}
+
+	return nil
+}
+
 func (wf *Workflow) publishScenes(ctx context.Context, scenes ...common.Scene) error {
 	var scenesb [][]byte
 	for _, scene := range scenes {
@@ -623,23 +660,42 @@ func (wf *Workflow) publishScenes(ctx context.Context, scenes ...common.Scene) e
 	return nil
 }
 
-func (wf *Workflow) prepublishTile(ctx context.Context, tile common.Tile, prevTile *common.Tile, refID *int) ([]byte, error) {
+// prepublishTile loads the inputs of the tile and returns the message to publish
+// The scene of the tile must be loaded
+func (wf *Workflow) prepublishTile(ctx context.Context, wfb db.WorkflowBackend, tile db.Tile) ([]byte, error) {
 	tileToProcess := common.TileToProcess{
-		Tile: tile,
+		Tile: tile.Tile,
 	}
 
-	// Define previous tile
-	if prevTile != nil {
-		tileToProcess.Previous = *prevTile
-	}
-
-	// Load reference tile
-	if refID != nil {
-		tile, _, err := wf.Tile(ctx, *refID, true)
+	// Load previous and reference tiles
+	for _, input := range []struct {
+		id   *int
+		tile *common.Tile
+	}{{tile.PreviousID, &tileToProcess.Previous}, {tile.ReferenceID, &tileToProcess.Reference}} {
+		if input.id == nil {
+			continue
+		}
+		itile, _, err := wfb.Tile(ctx, *input.id, true)
 		if err != nil {
 			return nil, fmt.Errorf("prepublishTile.%w", err)
 		}
-		tileToProcess.Reference = tile.Tile
+		*input.tile = itile.Tile
+	}
+
+	// Load additional inputs (a missing input is left empty to keep the index of the next ones)
+	for _, input := range tile.Inputs {
+		i := input.TileIndex - common.TileIndexInputs
+		for len(tileToProcess.Inputs) <= i {
+			tileToProcess.Inputs = append(tileToProcess.Inputs, common.TileInput{})
+		}
+		tileToProcess.Inputs[i].Role = input.Role
+		if input.ID != nil {
+			itile, _, err := wfb.Tile(ctx, *input.ID, true)
+			if err != nil {
+				return nil, fmt.Errorf("prepublishTile.%w", err)
+			}
+			tileToProcess.Inputs[i].Tile = itile.Tile
+		}
 	}
 
 	// Marshal
@@ -650,7 +706,56 @@ func (wf *Workflow) prepublishTile(ctx context.Context, tile common.Tile, prevTi
 	return plb, nil
 }
 
+// inputsDone returns true if all the inputs of the tile are DONE
+func inputsDone(ctx context.Context, wfb db.WorkflowBackend, tile db.Tile) (bool, error) {
+	for _, id := range tile.InputIDs() {
+		itile, _, err := wfb.Tile(ctx, id, false)
+		if err != nil {
+			return false, fmt.Errorf("inputsDone.%w", err)
+		}
+		if itile.Status != common.StatusDONE {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func (wf *Workflow) updateAOIStatus(ctx context.Context, wfb db.WorkflowBackend, aoi string, isRetry bool) error {
-	_, _, err := wfb.UpdateAOIStatus(ctx, aoi, isRetry)
-	return err
+	status, changed, err := wfb.UpdateAOIStatus(ctx, aoi, isRetry)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	if status == common.StatusDONE {
+		// The consolidation is optional: its failure must not fail the workflow
+		if err := wf.markConsolidation(ctx, wfb, aoi); err != nil {
+			log.Logger(ctx).Sugar().Errorf("AOI %s: %v", aoi, err)
+		}
+	}
+	// The status of the parent is the aggregation of the status of its children
+	parent, err := wfb.ParentAOI(ctx, aoi)
+	if err != nil {
+		return err
+	}
+	if parent != "" {
+		return wf.updateAOIStatus(ctx, wfb, parent, false)
+	}
+	return nil
+}
+
+// SetAOIParent sets the parent of the AOI (child of a split AOI) and updates the status of the parent. May return ErrNotFound
+func (wf *Workflow) SetAOIParent(ctx context.Context, aoi, parent string) error {
+	wf.dbmu.Lock()
+	defer wf.dbmu.Unlock()
+	if err := db.UnitOfWork(ctx, wf, func(tx db.WorkflowTxBackend) error {
+		if err := tx.SetAOIParent(ctx, aoi, parent); err != nil {
+			return err
+		}
+		return wf.updateAOIStatus(ctx, tx, parent, false)
+	}); err != nil {
+		return fmt.Errorf("SetAOIParent.%w", err)
+	}
+	return nil
 }
@@ -1,16 +1,37 @@
 package workflow_test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/airbusgeo/geocube-ingester/catalog"
 	"github.com/airbusgeo/geocube-ingester/common"
 	db "github.com/airbusgeo/geocube-ingester/interface/database"
+	"github.com/airbusgeo/geocube-ingester/interface/indexer"
+	"github.com/airbusgeo/geocube-ingester/workflow"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+// fakeConsolidator implements indexer.Indexer and indexer.Consolidator (only Consolidate and JobStatus)
+type fakeConsolidator struct {
+	indexer.Indexer
+	jobs []string
+}
+
+func (c *fakeConsolidator) Consolidate(ctx context.Context, jobName, instanceID, layout string, recordIDs []string) (string, error) {
+	c.jobs = append(c.jobs, instanceID+"/"+layout+"/"+strings.Join(recordIDs, ","))
+	return fmt.Sprintf("job-%d", len(c.jobs)), nil
+}
+
+func (c *fakeConsolidator) JobStatus(ctx context.Context, jobID string) (string, bool, error) {
+	return "DONE", true, nil
+}
+
 var _ = Describe("Workflow", func() {
 	var err error
 	aoi := "test"
@@ -634,6 +655,94 @@ var _ = Describe("Workflow", func() {
 		})
 	})
 
+	Describe("Finishing the inputs of a tile with several inputs", func() {
+		inputSceneToIngest := func(sourceID string, day int) common.SceneToIngest {
+			return common.SceneToIngest{
+				Scene: common.Scene{
+					SourceID: sourceID,
+					AOI:      aoi,
+					Data: common.SceneAttrs{
+						Date:         time.Date(2019, 8, day, 17, 1, 13, 0, time.Local),
+						TileMappings: map[string]common.TileMapping{"A44_IW1_8951": {SwathID: "IW1", TileNr: 4}},
+						GraphName:    "S1Preprocessing",
+					},
+				},
+				Tiles: map[string]common.TileToIngest{
+					"A44_IW1_8951": {Data: common.TileAttrs{SwathID: "IW1", TileNr: 4, GraphName: "S1Backscatter"}},
+				},
+			}
+		}
+		scenes := []common.SceneToIngest{inputSceneToIngest("input_previous", 1), inputSceneToIngest("input_reference", 2), inputSceneToIngest("input_secondary", 3)}
+		finalSceneToIngest := inputSceneToIngest("final", 4)
+		finalSceneToIngest.Tiles = map[string]common.TileToIngest{
+			"A44_IW1_8951": {
+				PreviousTileID:   "A44_IW1_8951",
+				PreviousSceneID:  "input_previous",
+				ReferenceTileID:  "A44_IW1_8951",
+				ReferenceSceneID: "input_reference",
+				Inputs:           []common.TileInputToIngest{{Role: "secondary", TileID: "A44_IW1_8951", SceneID: "input_secondary"}},
+				Data:             common.TileAttrs{SwathID: "IW1", TileNr: 4, GraphName: "S1Coherence"},
+			},
+		}
+
+		var inputIDs [3]int
+		var finalID int
+		BeforeEach(func() {
+			_, err = pgdb.ExecContext(ctx, "DELETE from public.tile")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = pgdb.ExecContext(ctx, "DELETE from public.scene")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = pgdb.ExecContext(ctx, "DELETE from public.aoi")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wf.CreateAOI(ctx, aoi)).NotTo(HaveOccurred())
+			ids, err := wf.IngestScenes(ctx, aoi, append(scenes, finalSceneToIngest)...)
+			Expect(err).NotTo(HaveOccurred())
+			for _, id := range ids {
+				wf.ResultHandler(ctx, common.Result{Type: common.ResultTypeScene, ID: id, Status: common.StatusDONE})
+			}
+			for i, scene := range scenes {
+				tiles, err := wf.Tiles(ctx, "", ids[scene.SourceID], "", false, 0, -1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(len(tiles)).To(Equal(1))
+				Expect(tiles[0].Status).To(Equal(common.StatusPENDING))
+				inputIDs[i] = tiles[0].ID
+			}
+			tiles, err := wf.Tiles(ctx, "", ids[finalSceneToIngest.SourceID], "", false, 0, -1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(tiles)).To(Equal(1))
+			finalID = tiles[0].ID
+			tileQueue.messages = nil
+		})
+
+		for _, order := range [][3]int{{0, 1, 2}, {2, 1, 0}, {1, 2, 0}, {0, 2, 1}} {
+			order := order
+			Context(fmt.Sprintf("In the order %v", order), func() {
+				It("should queue the tile only when all its inputs are done", func() {
+					for i, input := range order {
+						wf.ResultHandler(ctx, common.Result{Type: common.ResultTypeTile, ID: inputIDs[input], Status: common.StatusDONE})
+						tile, _, err := wf.Tile(ctx, finalID, false)
+						Expect(err).NotTo(HaveOccurred())
+						if i < len(order)-1 {
+							Expect(tile.Status).To(Equal(common.StatusNEW))
+							Expect(tileQueue.messages).To(BeEmpty())
+						} else {
+							Expect(tile.Status).To(Equal(common.StatusPENDING))
+						}
+					}
+					Expect(len(tileQueue.messages)).To(Equal(1))
+					tile := common.TileToProcess{}
+					Expect(json.Unmarshal(tileQueue.messages[0], &tile)).NotTo(HaveOccurred())
+					Expect(tile.ID).To(Equal(finalID))
+					Expect(tile.Previous.ID).To(Equal(inputIDs[0]))
+					Expect(tile.Reference.ID).To(Equal(inputIDs[1]))
+					Expect(len(tile.Inputs)).To(Equal(1))
+					Expect(tile.Inputs[0].Role).To(Equal("secondary"))
+					Expect(tile.Inputs[0].Tile.ID).To(Equal(inputIDs[2]))
+				})
+			})
+		}
+	})
+
 	Describe("Getting Root tiles", func() {
 		var roots []common.Tile
 		BeforeEach(func() {
@@ -696,4 +805,204 @@ var _ = Describe("Workflow", func() {
 			expectTileToBeIngested(leaves[1], leaf2SceneToIngest)
 		})
 	})
+
+	Describe("Finishing a tile with outputs", func() {
+		var idb0, idb1 int
+		BeforeEach(func() {
+			_, _, _, idb0, idb1, _ = initDbScenesTiles(true)
+			wf.ResultHandler(ctx, common.Result{
+				Type:   common.ResultTypeTile,
+				ID:     idb1,
+				Status: common.StatusDONE,
+				Outputs: []common.TileOutput{
+					{TileID: idb1, Layer: "coh_VH", Extension: "tif", Action: "to_index", URI: "gs://bucket/coh_VH.tif", InstanceID: "4c8acc94-7b23-497b-8d31-8845a9ea76d2",
+						DFormat: &common.OutputDFormat{DType: "uint8", NoData: 0, Min: 1, Max: 255, ExtMin: 0, ExtMax: 1, Exponent: 1, Nbands: 1}},
+					{TileID: idb1, Layer: "coregextract", Extension: "dim", Action: "to_create", URI: "gs://bucket/coregextract.zip"},
+					{TileID: idb0, Layer: "coregextract", Extension: "dim", Action: "to_delete"},
+				},
+			})
+		})
+		It("should save the outputs of the tile", func() {
+			outputs, err := wf.TileOutputs(ctx, idb1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(outputs)).To(Equal(2))
+			Expect(outputs[0].Layer).To(Equal("coh_VH"))
+			Expect(outputs[0].InstanceID).To(Equal("4c8acc94-7b23-497b-8d31-8845a9ea76d2"))
+			Expect(outputs[0].DFormat).NotTo(BeNil())
+			Expect(outputs[0].DFormat.Max).To(Equal(255.))
+			Expect(outputs[1].URI).To(Equal("gs://bucket/coregextract.zip"))
+			Expect(outputs[1].DFormat).To(BeNil())
+		})
+		It("should not keep the deleted outputs", func() {
+			outputs, err := wf.TileOutputs(ctx, idb0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(outputs)).To(Equal(0))
+		})
+		It("should export the outputs of the aoi", func() {
+			outputs, err := wf.AOIOutputs(ctx, aoi, "coh_VH", 0, -1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(outputs)).To(Equal(1))
+			Expect(outputs[0].SceneSourceID).To(Equal(leaf1SceneToIngest.SourceID))
+		})
+	})
+
+	Describe("Setting the consolidation of an aoi", func() {
+		BeforeEach(func() {
+			initDbScenesTiles(true)
+			err = wf.SetAOIConsolidationLayouts(ctx, aoi, []string{"UTM-32N-256"})
+		})
+		It("should save the layouts", func() {
+			Expect(err).NotTo(HaveOccurred())
+			consolidation, err := wf.RefreshAOIConsolidation(ctx, aoi)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consolidation.Layouts).To(Equal([]string{"UTM-32N-256"}))
+			Expect(consolidation.Jobs).To(BeEmpty())
+		})
+		It("should raise error if the aoi does not exist", func() {
+			err = wf.SetAOIConsolidationLayouts(ctx, "unknown_aoi", []string{"UTM-32N-256"})
+			Expect(errors.As(err, &db.ErrNotFound{})).To(BeTrue())
+		})
+	})
+
+	Describe("Finishing an aoi with consolidation layouts", func() {
+		var consolidator *fakeConsolidator
+		var cwf *workflow.Workflow
+		BeforeEach(func() {
+			initDbScenesTiles(true)
+			Expect(wf.SetAOIConsolidationLayouts(ctx, aoi, []string{"UTM-32N-256"})).NotTo(HaveOccurred())
+			consolidator = &fakeConsolidator{}
+			cwf = workflow.NewWorkflow(wf.WorkflowDBBackend, &sceneQueue, &tileQueue, &catalog.Catalog{Indexer: consolidator})
+			// Finish the tiles as soon as they are pending
+			for i := 0; i < 3; i++ {
+				tiles, err := wf.Tiles(ctx, aoi, 0, common.StatusPENDING.String(), false, 0, -1)
+				Expect(err).NotTo(HaveOccurred())
+				for _, tile := range tiles {
+					cwf.ResultHandler(ctx, common.Result{Type: common.ResultTypeTile, ID: tile.ID, Status: common.StatusDONE})
+				}
+			}
+		})
+		It("should mark the consolidation as pending without starting the jobs", func() {
+			tiles, err := wf.Tiles(ctx, aoi, 0, common.StatusDONE.String(), false, 0, -1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(tiles)).To(Equal(6))
+			consolidation, err := wf.AOIConsolidation(ctx, aoi)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consolidation.Pending).To(BeTrue())
+			Expect(consolidation.Jobs).To(BeEmpty())
+			Expect(consolidator.jobs).To(BeEmpty())
+		})
+		It("should start the pending consolidation jobs once", func() {
+			Expect(cwf.StartPendingConsolidations(ctx)).NotTo(HaveOccurred())
+			Expect(cwf.StartPendingConsolidations(ctx)).NotTo(HaveOccurred())
+			Expect(consolidator.jobs).To(Equal([]string{"4c8acc94-7b23-497b-8d31-8845a9ea76d2/UTM-32N-256/05a3b01d-4b30-4573-94d6-8d83f1dbb2ff"}))
+			consolidation, err := wf.AOIConsolidation(ctx, aoi)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consolidation.Pending).To(BeFalse())
+			Expect(len(consolidation.Jobs)).To(Equal(1))
+			Expect(consolidation.Jobs[0].JobID).To(Equal("job-1"))
+			Expect(consolidation.Jobs[0].Status).To(Equal(workflow.ConsolidationStarted))
+		})
+	})
+
+	Describe("Managing subscriptions", func() {
+		subscription := db.Subscription{
+			ID:              "sub",
+			Area:            json.RawMessage(`{"name":"sub_aoi","type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]},"start_time":"2023-01-01T00:00:00Z","scene_type":{"constellation":"sentinel2"}}`),
+			PollingInterval: "24h",
+			Lookback:        "72h",
+			Enabled:         true,
+		}
+		BeforeEach(func() {
+			_, err := pgdb.ExecContext(ctx, "DELETE from public.subscription")
+			Expect(err).NotTo(HaveOccurred())
+			err = wf.AddSubscription(ctx, subscription)
+		})
+		It("should create a subscription due now", func() {
+			Expect(err).NotTo(HaveOccurred())
+			subscriptions, err := wf.DueSubscriptions(ctx, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(subscriptions)).To(Equal(1))
+			Expect(subscriptions[0].ID).To(Equal(subscription.ID))
+			Expect(subscriptions[0].WindowEnd.IsZero()).To(BeTrue())
+		})
+		It("should return an AlreadyExists error", func() {
+			err = wf.AddSubscription(ctx, subscription)
+			Expect(err).To(HaveOccurred())
+			Expect(errors.As(err, &db.ErrAlreadyExists{})).To(BeTrue())
+		})
+		It("should refuse a wrong polling interval", func() {
+			s := subscription
+			s.ID, s.PollingInterval = "wrong", "daily"
+			Expect(wf.AddSubscription(ctx, s)).To(HaveOccurred())
+		})
+		It("should not return disabled subscriptions as due", func() {
+			s := subscription
+			s.Enabled = false
+			Expect(wf.ModifySubscription(ctx, s)).NotTo(HaveOccurred())
+			subscriptions, err := wf.DueSubscriptions(ctx, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subscriptions).To(BeEmpty())
+		})
+		It("should delete the subscription", func() {
+			Expect(wf.DeleteSubscription(ctx, subscription.ID)).NotTo(HaveOccurred())
+			_, err = wf.Subscription(ctx, subscription.ID)
+			Expect(errors.As(err, &db.ErrNotFound{})).To(BeTrue())
+		})
+	})
+
+	Describe("Managing child AOIs", func() {
+		BeforeEach(func() {
+			_, err := pgdb.ExecContext(ctx, "DELETE from public.aoi")
+			Expect(err).NotTo(HaveOccurred())
+			for _, id := range []string{"parent", "parent_N43E001", "parent_N43E002"} {
+				Expect(wf.CreateAOI(ctx, id)).NotTo(HaveOccurred())
+			}
+			err = wf.SetAOIParent(ctx, "parent_N43E001", "parent")
+		})
+		It("should list the child AOIs", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wf.SetAOIParent(ctx, "parent_N43E002", "parent")).NotTo(HaveOccurred())
+			children, err := wf.ChildAOIs(ctx, "parent")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(children)).To(Equal(2))
+			Expect(children[0].Parent).To(Equal("parent"))
+		})
+		It("should return a NotFound error", func() {
+			err = wf.SetAOIParent(ctx, "parent_N43E002", "unknown")
+			Expect(errors.As(err, &db.ErrNotFound{})).To(BeTrue())
+			err = wf.SetAOIParent(ctx, "unknown", "parent")
+			Expect(errors.As(err, &db.ErrNotFound{})).To(BeTrue())
+		})
+	})
+
+	Describe("Finishing the child of a split AOI", func() {
+		BeforeEach(func() {
+			initDbScenesTiles(true)
+			Expect(wf.CreateAOI(ctx, "parent")).NotTo(HaveOccurred())
+			Expect(wf.SetAOIConsolidationLayouts(ctx, "parent", []string{"UTM-32N-256"})).NotTo(HaveOccurred())
+			Expect(wf.SetAOIParent(ctx, aoi, "parent")).NotTo(HaveOccurred())
+		})
+		It("should aggregate the status of the children", func() {
+			aois, err := wf.AOIs(ctx, "parent")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(aois)).To(Equal(1))
+			Expect(aois[0].Status).To(Equal(common.StatusPENDING))
+		})
+		It("should finish the parent and mark its consolidation as pending", func() {
+			for i := 0; i < 3; i++ {
+				tiles, err := wf.Tiles(ctx, aoi, 0, common.StatusPENDING.String(), false, 0, -1)
+				Expect(err).NotTo(HaveOccurred())
+				for _, tile := range tiles {
+					wf.ResultHandler(ctx, common.Result{Type: common.ResultTypeTile, ID: tile.ID, Status: common.StatusDONE})
+				}
+			}
+			aois, err := wf.AOIs(ctx, "parent")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(aois)).To(Equal(1))
+			Expect(aois[0].Status).To(Equal(common.StatusDONE))
+			consolidation, err := wf.AOIConsolidation(ctx, "parent")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consolidation.Pending).To(BeTrue())
+		})
+	})
 })